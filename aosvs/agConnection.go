@@ -0,0 +1,240 @@
+// +build virtual !physical
+
+// agConnection.go - the pseudo-Agent's connection manager, customers and servers
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// A process that has issued ?SERVE may have customer processes connected to it.  The
+// server is sent an obituary on its ?SPTM port whenever a customer terminates or
+// disconnects, customers who asked for it at ?CON time are told when their server goes.
+
+import (
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+const explicitDisconnect = 1 << (15 - cxbed)
+
+type connKeyT struct {
+	customer, server dg.WordT
+}
+
+type agConnectionT struct {
+	ring      int  // the customer's
+	wantsObit bool // customer is told if the server terminates
+}
+
+var agConnections = map[connKeyT]*agConnectionT{}
+
+// notify delivers a connection obituary if the recipient still exists
+func notify(PID dg.WordT, obit ipcMsgT) {
+	if ppd, found := PerProcessData[int(PID)]; found {
+		ppd.deliverIPC(obit, false)
+	}
+}
+
+// findServer looks up a process by PID, or by name if PID is zero, and checks that it is a server
+func findServer(PID dg.WordT, name string) (serverPID dg.WordT, errCode dg.WordT) {
+	if PID == 0 {
+		if PID = findProcessByName(name); PID == 0 {
+			return 0, erpnm
+		}
+	}
+	ppd, found := PerProcessData[int(PID)]
+	if !found {
+		return 0, erprh
+	}
+	if !ppd.isServer {
+		return 0, ernas
+	}
+	return PID, 0
+}
+
+type agServeReqT struct {
+	PID    dg.WordT
+	resign bool
+}
+type agServeRespT struct {
+	errCode dg.WordT
+}
+
+// agServe handles ?SERVE and ?RESIGN, resigning breaks every connection to our customers
+func agServe(req agServeReqT) (resp agServeRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	if req.resign {
+		if !ppd.isServer {
+			resp.errCode = ernas
+			return resp
+		}
+		breakConnections(req.PID, ppd, explicitDisconnect, []dg.WordT{req.PID})
+		ppd.isServer = false
+		logging.DebugPrint(logging.ScLog, "\tPID %d. has resigned as a server\n", req.PID)
+		return resp
+	}
+	if ppd.isServer {
+		resp.errCode = erasv
+		return resp
+	}
+	ppd.isServer = true
+	logging.DebugPrint(logging.ScLog, "\tPID %d. is now a server\n", req.PID)
+	return resp
+}
+
+type agConnectReqT struct {
+	PID        dg.WordT // the customer
+	ring       int
+	serverPID  dg.WordT // zero => use serverName
+	serverName string
+	wantsObit  bool
+	disconnect bool
+}
+type agConnectRespT struct {
+	serverPID dg.WordT
+	errCode   dg.WordT
+}
+
+// agConnect handles ?CON and ?DCON for a customer
+func agConnect(req agConnectReqT) (resp agConnectRespT) {
+	if resp.serverPID, resp.errCode = findServer(req.serverPID, req.serverName); resp.errCode != 0 {
+		return resp
+	}
+	key := connKeyT{customer: req.PID, server: resp.serverPID}
+	conn, found := agConnections[key]
+	switch {
+	case req.disconnect && !found:
+		resp.errCode = ercnx
+	case req.disconnect:
+		delete(agConnections, key)
+		notify(key.server, obituary(req.PID, conn.ring, explicitDisconnect, []dg.WordT{req.PID}))
+		logging.DebugPrint(logging.ScLog, "\tPID %d. disconnected from server PID %d.\n", req.PID, key.server)
+	case found:
+		resp.errCode = eracn
+	case resp.serverPID == req.PID:
+		resp.errCode = ernas
+	default:
+		agConnections[key] = &agConnectionT{ring: req.ring, wantsObit: req.wantsObit}
+		logging.DebugPrint(logging.ScLog, "\tPID %d. connected to server PID %d.\n", req.PID, key.server)
+	}
+	return resp
+}
+
+type agDrconReqT struct {
+	PID         dg.WordT // the server
+	customerPID dg.WordT
+}
+type agDrconRespT struct {
+	errCode dg.WordT
+}
+
+// agDrcon handles ?DRCON, a server breaking the connection with one of its customers
+func agDrcon(req agDrconReqT) (resp agDrconRespT) {
+	key := connKeyT{customer: req.customerPID, server: req.PID}
+	conn, found := agConnections[key]
+	if !found {
+		resp.errCode = ercnx
+		return resp
+	}
+	delete(agConnections, key)
+	if conn.wantsObit {
+		ring := PerProcessData[int(req.PID)].ring
+		notify(req.customerPID, obituary(req.PID, ring, explicitDisconnect, []dg.WordT{req.PID}))
+	}
+	return resp
+}
+
+type agCheckConnReqT struct {
+	PID, otherPID dg.WordT
+}
+type agCheckConnRespT struct {
+	isServer bool // caller is the server of the connection
+	ring     int  // of the customer
+	errCode  dg.WordT
+}
+
+// agCheckConn handles ?CTOD, checking for a connection between two processes in either direction
+func agCheckConn(req agCheckConnReqT) (resp agCheckConnRespT) {
+	if conn, found := agConnections[connKeyT{customer: req.otherPID, server: req.PID}]; found {
+		resp.isServer, resp.ring = true, conn.ring
+		return resp
+	}
+	if conn, found := agConnections[connKeyT{customer: req.PID, server: req.otherPID}]; found {
+		resp.ring = conn.ring
+		return resp
+	}
+	resp.errCode = ercnx
+	return resp
+}
+
+type agPassConnReqT struct {
+	PID, customerPID, newServerPID dg.WordT
+}
+type agPassConnRespT struct {
+	errCode dg.WordT
+}
+
+// agPassConn handles ?PCNX, a server passing one of its customers on to another server
+func agPassConn(req agPassConnReqT) (resp agPassConnRespT) {
+	key := connKeyT{customer: req.customerPID, server: req.PID}
+	conn, found := agConnections[key]
+	if !found {
+		resp.errCode = ercnx
+		return resp
+	}
+	if _, resp.errCode = findServer(req.newServerPID, ""); resp.errCode != 0 {
+		return resp
+	}
+	newKey := connKeyT{customer: req.customerPID, server: req.newServerPID}
+	if _, found := agConnections[newKey]; found || req.newServerPID == req.customerPID {
+		resp.errCode = eracn
+		return resp
+	}
+	delete(agConnections, key)
+	agConnections[newKey] = conn
+	logging.DebugPrint(logging.ScLog, "\tCustomer PID %d. passed from server PID %d. to PID %d.\n",
+		req.customerPID, req.PID, req.newServerPID)
+	return resp
+}
+
+// breakConnections removes every connection of a server, telling those customers that want to know
+func breakConnections(PID dg.WordT, ppd *PerProcessDataT, connBits dg.WordT, words []dg.WordT) {
+	for key, conn := range agConnections {
+		if key.server != PID {
+			continue
+		}
+		delete(agConnections, key)
+		if conn.wantsObit {
+			notify(key.customer, obituary(PID, ppd.ring, connBits, words))
+		}
+	}
+}
+
+// releaseConnections breaks all connections of a terminated process, its servers get its obituary
+func releaseConnections(PID dg.WordT, ppd *PerProcessDataT) {
+	obit := ppd.termInfo.words()
+	for key, conn := range agConnections {
+		if key.customer == PID {
+			delete(agConnections, key)
+			notify(key.server, obituary(PID, conn.ring, 0, obit))
+		}
+	}
+	breakConnections(PID, ppd, 0, obit)
+}
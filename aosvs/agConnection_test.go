@@ -0,0 +1,112 @@
+// +build virtual !physical
+
+// agConnection_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// spooledObit returns the only obituary spooled for a process
+func spooledObit(t *testing.T, PID dg.WordT) ipcMsgT {
+	t.Helper()
+	spool := PerProcessData[int(PID)].ipcSpool
+	if len(spool) != 1 || spool[0].destLocal != sptm {
+		t.Fatalf("Expected one obituary for PID %d, got %v", PID, spool)
+	}
+	PerProcessData[int(PID)].ipcSpool = nil
+	return spool[0]
+}
+
+func TestCustomerTerminationObituary(t *testing.T) {
+	server := ipcTestProcess(t, 95)
+	server.isServer = true
+	customer := ipcTestProcess(t, 96)
+	defer func() { agConnections = map[connKeyT]*agConnectionT{} }()
+
+	if resp := agConnect(agConnectReqT{PID: 96, ring: 7, serverPID: 95, wantsObit: true}); resp.errCode != 0 {
+		t.Fatalf("Expected ?CON to succeed, got %#o", resp.errCode)
+	}
+	if resp := agCheckConn(agCheckConnReqT{PID: 95, otherPID: 96}); resp.errCode != 0 || !resp.isServer || resp.ring != 7 {
+		t.Errorf("Expected ?CTOD to find the connection, got %v", resp)
+	}
+
+	customer.termInfo = termInfoT{PID: 96, errorCode: erfde, flags: Rfab, message: "OOPS"}
+	releaseConnections(96, customer)
+	obit := spooledObit(t, 95)
+	if obit.origin != globalPort(96, 7, 0) || obit.connBits != 0 {
+		t.Errorf("Expected an obituary from the customer's ring, got origin %#x bits %#o", obit.origin, obit.connBits)
+	}
+	want := customer.termInfo.words()
+	if len(obit.words) != len(want) || obit.words[0] != 96 || obit.words[3] != erfde {
+		t.Errorf("Expected the customer's termination message, got %v", obit.words)
+	}
+	if len(agConnections) != 0 {
+		t.Error("Expected the connection to be gone")
+	}
+	if resp := agCheckConn(agCheckConnReqT{PID: 95, otherPID: 96}); resp.errCode != ercnx {
+		t.Errorf("Expected ?CTOD to fail with ERCNX, got %#o", resp.errCode)
+	}
+}
+
+func TestServerTerminationObituary(t *testing.T) {
+	server := ipcTestProcess(t, 95)
+	server.isServer = true
+	ipcTestProcess(t, 96)
+	ipcTestProcess(t, 97)
+	defer func() { agConnections = map[connKeyT]*agConnectionT{} }()
+
+	agConnect(agConnectReqT{PID: 96, ring: 7, serverPID: 95, wantsObit: true})
+	agConnect(agConnectReqT{PID: 97, ring: 7, serverPID: 95})
+	server.termInfo = termInfoT{PID: 95}
+	releaseConnections(95, server)
+	if obit := spooledObit(t, 96); obit.origin != globalPort(95, 7, 0) || obit.words[0] != 95 {
+		t.Errorf("Expected the server's obituary, got origin %#x words %v", obit.origin, obit.words)
+	}
+	if len(PerProcessData[97].ipcSpool) != 0 {
+		t.Error("Expected no obituary for a customer which did not ask for one")
+	}
+	if len(agConnections) != 0 {
+		t.Error("Expected every connection to be gone")
+	}
+}
+
+func TestDisconnectObituary(t *testing.T) {
+	server := ipcTestProcess(t, 95)
+	server.isServer = true
+	ipcTestProcess(t, 96)
+	defer func() { agConnections = map[connKeyT]*agConnectionT{} }()
+
+	agConnect(agConnectReqT{PID: 96, ring: 7, serverPID: 95})
+	if resp := agConnect(agConnectReqT{PID: 96, serverPID: 95, disconnect: true}); resp.errCode != 0 {
+		t.Fatalf("Expected ?DCON to succeed, got %#o", resp.errCode)
+	}
+	if obit := spooledObit(t, 95); obit.connBits != explicitDisconnect || obit.words[0] != 96 {
+		t.Errorf("Expected an explicit disconnection obituary, got bits %#o words %v", obit.connBits, obit.words)
+	}
+	if resp := agConnect(agConnectReqT{PID: 96, serverPID: 95, disconnect: true}); resp.errCode != ercnx {
+		t.Errorf("Expected a second ?DCON to fail with ERCNX, got %#o", resp.errCode)
+	}
+}
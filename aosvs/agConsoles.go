@@ -0,0 +1,389 @@
+// +build virtual !physical
+
+// agConsoles.go - the pseudo-Agent's console registry
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+// Every console connection is registered under a device name, @CON10, @CON11 and so on.
+// A process created on a console keeps its name, its sons inherit it, and the generic
+// files @CONSOLE, @INPUT and @OUTPUT refer to it.
+//
+// A goroutine reads each console as characters are typed, so that the console interrupts
+// CTRL-C CTRL-A and CTRL-C CTRL-B, and CTRL-S/CTRL-Q flow control, act at once rather than
+// when a program next reads.  Console ?READs and ?WRITEs are done by the calling task, not
+// the pseudo-Agent, so that one console waiting for its user does not hold up the others.
+
+const (
+	consolePrefix  = "CON"
+	firstConsoleNo = 10
+	inputBuffer    = 4096 // characters typed ahead
+
+	ctrlA = 001 // after CTRL-C, console interrupt
+	ctrlB = 002 // after CTRL-C, terminate the console's process
+	ctrlC = 003
+	ctrlQ = 021 // resumes output
+	ctrlS = 023 // stops output
+)
+
+// console interrupt kinds
+const (
+	consoleIntA = iota
+	consoleIntB
+	consoleHangup
+)
+
+// consoleT is a registered console
+type consoleT struct {
+	name     string
+	conn     net.Conn
+	chars    *devCharsT
+	input    chan byte  // typed characters other than interrupts and flow control, closed on disconnection
+	outMu    sync.Mutex // keeps each ?WRITE together
+	heldMu   sync.Mutex
+	held     bool // output stopped by CTRL-S or a full page
+	gone     bool // connection closed
+	heldCond *sync.Cond
+}
+
+var agConsoles = map[string]*consoleT{} // key is device name without the '@'
+
+type agAttachConsoleReqT struct {
+	conn net.Conn
+}
+type agAttachConsoleRespT struct {
+	name string
+	con  *consoleT
+}
+
+type agDetachConsoleReqT struct {
+	name string
+}
+type agDetachConsoleRespT struct{}
+
+// AttachConsole registers a newly connected console and returns its device name, from now
+// on the emulator reads the console
+func AttachConsole(conn net.Conn) (name string) {
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentAttachConsole, agAttachConsoleReqT{conn}, nil}
+	areq := <-gw
+	return areq.result.(agAttachConsoleRespT).name
+}
+
+// DetachConsole forgets a console once its connection has gone
+func DetachConsole(name string) {
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentDetachConsole, agDetachConsoleReqT{name}, nil}
+	<-gw
+}
+
+// agAttachConsole gives a connection the lowest free console name, or the one it already has
+func agAttachConsole(req agAttachConsoleReqT) (resp agAttachConsoleRespT) {
+	resp.con = attachConsole(req.conn)
+	resp.name = resp.con.name
+	return resp
+}
+
+func agDetachConsole(req agDetachConsoleReqT) (resp agDetachConsoleRespT) {
+	delete(agConsoles, req.name)
+	return resp
+}
+
+// attachConsole returns the console of a connection, registering it if need be
+func attachConsole(conn net.Conn) *consoleT {
+	for _, con := range agConsoles {
+		if con.conn == conn {
+			return con
+		}
+	}
+	for n := firstConsoleNo; ; n++ {
+		name := fmt.Sprintf("%s%d", consolePrefix, n)
+		if _, inUse := agConsoles[name]; !inUse {
+			con := &consoleT{name: name, conn: conn, chars: newConsoleChars(conn), input: make(chan byte, inputBuffer)}
+			con.heldCond = sync.NewCond(&con.heldMu)
+			agConsoles[name] = con
+			go con.readInput()
+			return con
+		}
+	}
+}
+
+// findConsole returns a console device, where @CONSOLE, @INPUT and @OUTPUT are the process's own
+func findConsole(PID dg.WordT, devName string) (con *consoleT, errCode dg.WordT) {
+	name := strings.TrimPrefix(strings.ToUpper(devName), "@")
+	switch name {
+	case "CONSOLE", "INPUT", "OUTPUT":
+		ppd := getPerProcessData(PID)
+		if ppd == nil || ppd.consoleName == "" {
+			return nil, erdnm
+		}
+		name = ppd.consoleName
+	}
+	con, found := agConsoles[name]
+	if !found {
+		return nil, erdnm
+	}
+	return con, 0
+}
+
+// readInput passes typed characters on to readers, acting on interrupt and flow-control sequences
+func (con *consoleT) readInput() {
+	buf := make([]byte, 256)
+	afterCtrlC := false
+	for {
+		n, err := con.conn.Read(buf)
+		if err != nil {
+			break
+		}
+		for _, c := range buf[:n] {
+			if afterCtrlC {
+				afterCtrlC = false
+				switch c {
+				case ctrlA:
+					con.interrupt(consoleIntA)
+					continue
+				case ctrlB:
+					con.interrupt(consoleIntB)
+					continue
+				}
+				con.queue(ctrlC)
+			}
+			switch c {
+			case ctrlC:
+				afterCtrlC = true
+			case ctrlS:
+				con.setHeld(true)
+			case ctrlQ:
+				con.setHeld(false)
+			default:
+				con.queue(c)
+			}
+		}
+	}
+	logging.DebugPrint(logging.ScLog, "Console @%s disconnected\n", con.name)
+	close(con.input)
+	con.heldMu.Lock()
+	con.gone = true
+	con.heldMu.Unlock()
+	con.heldCond.Broadcast()
+	con.interrupt(consoleHangup)
+}
+
+// queue passes a typed character on to readers, one typed while the buffer is full is
+// dropped with a bell so that we keep watching for interrupts
+func (con *consoleT) queue(c byte) {
+	select {
+	case con.input <- c:
+	default:
+		con.conn.Write([]byte{dg.ASCIIBEL})
+	}
+}
+
+// interrupt echoes a console interrupt and has the pseudo-Agent act on it
+func (con *consoleT) interrupt(kind int) {
+	switch kind {
+	case consoleIntA:
+		con.conn.Write([]byte("^C^A\n"))
+	case consoleIntB:
+		con.conn.Write([]byte("^C^B\n"))
+	}
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentConsoleInterrupt, agConsoleInterruptReqT{con.name, kind}, nil}
+	<-gw
+}
+
+// setHeld stops or restarts output
+func (con *consoleT) setHeld(held bool) {
+	con.heldMu.Lock()
+	con.held = held
+	con.heldMu.Unlock()
+	con.heldCond.Broadcast()
+}
+
+// waitOutput blocks while output is stopped
+func (con *consoleT) waitOutput() {
+	con.heldMu.Lock()
+	for con.held && !con.gone {
+		con.heldCond.Wait()
+	}
+	con.heldMu.Unlock()
+}
+
+// readByte returns the next character typed, giving up if the console goes or the reader's process terminates
+func (con *consoleT) readByte(abort <-chan struct{}) (c byte, errCode dg.WordT) {
+	select {
+	case c, ok := <-con.input:
+		if !ok {
+			return 0, eritc
+		}
+		return c, 0
+	case <-abort:
+		return 0, eritc
+	}
+}
+
+// ?SEND target types, in AC2 bits 22-23
+const (
+	sendToPID     = 0 // AC0 holds a PID
+	sendToProc    = 1 // AC0 is a byte pointer to a process name
+	sendToConsole = 2 // AC0 is a byte pointer to a console name
+)
+
+type agSendReqT struct {
+	PID        dg.WordT // the sender
+	targetType dg.DwordT
+	targetPID  dg.WordT
+	targetName string // process or console name
+}
+type agSendRespT struct {
+	con     *consoleT
+	errCode dg.WordT
+}
+
+// agSend finds the console a ?SEND message goes to, a target process without a console
+// of its own has it shown on the sender's
+func agSend(req agSendReqT) (resp agSendRespT) {
+	var conName string
+	switch req.targetType {
+	case sendToPID, sendToProc:
+		target := req.targetPID
+		if req.targetType == sendToProc {
+			if target = findProcessByName(req.targetName); target == 0 {
+				resp.errCode = erpnm
+				return resp
+			}
+		}
+		ppd, found := PerProcessData[int(target)]
+		if !found {
+			resp.errCode = erprh
+			return resp
+		}
+		conName = ppd.consoleName
+	case sendToConsole:
+		conName = strings.TrimPrefix(req.targetName, ":PER:")
+	default:
+		resp.errCode = erpre
+		return resp
+	}
+	if conName == "" {
+		conName = "@CONSOLE"
+	}
+	resp.con, resp.errCode = findConsole(req.PID, conName)
+	return resp
+}
+
+type agConsoleInterruptReqT struct {
+	name string
+	kind int
+}
+type agConsoleInterruptRespT struct{}
+
+// agConsoleInterrupt delivers a console interrupt to the newest process on the console, or
+// on disconnection terminates every process there
+func agConsoleInterrupt(req agConsoleInterruptReqT) (resp agConsoleInterruptRespT) {
+	var target dg.WordT
+	for PID, ppd := range PerProcessData {
+		if ppd.consoleName != req.name || ppd.terminating {
+			continue
+		}
+		switch {
+		case req.kind == consoleHangup:
+			agTerminate(agTerminateReqT{PID: dg.WordT(PID), info: termInfoT{byTerm: true}})
+		case target == 0 || ppd.created.After(PerProcessData[int(target)].created):
+			target = dg.WordT(PID)
+		}
+	}
+	if target == 0 {
+		return resp
+	}
+	logging.DebugPrint(logging.ScLog, "AGENT console interrupt %d on @%s for PID %d\n", req.kind, req.name, target)
+	switch req.kind {
+	case consoleIntA:
+		PerProcessData[int(target)].consoleInterrupt()
+	case consoleIntB:
+		agAbort(target, ctrlCBMessage)
+	}
+	return resp
+}
+
+// ctrlCBMessage is the termination message of a process stopped by CTRL-C CTRL-B
+const ctrlCBMessage = "CTRL-C CTRL-B"
+
+// consoleInterrupt wakes the tasks waiting in ?INTWT, or keeps the interrupt for the next one,
+// while ?KIOFF is in force it is kept until ?KION
+func (ppd *PerProcessDataT) consoleInterrupt() {
+	ppd.intPending = true
+	if ppd.kiOff || len(ppd.intWaiters) == 0 {
+		return
+	}
+	for _, w := range ppd.intWaiters {
+		close(w)
+	}
+	ppd.intWaiters, ppd.intPending = nil, false
+}
+
+type agKiReqT struct {
+	PID dg.WordT
+	on  bool
+}
+type agKiRespT struct{}
+
+// agKi handles ?KIOFF and ?KION
+func agKi(req agKiReqT) (resp agKiRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	ppd.kiOff = !req.on
+	if req.on && ppd.intPending {
+		ppd.consoleInterrupt()
+	}
+	return resp
+}
+
+type agIntwtReqT struct {
+	PID dg.WordT
+}
+type agIntwtRespT struct {
+	waiter chan struct{} // nil if an interrupt was already pending
+}
+
+// agIntwt handles ?INTWT, returning a channel which is closed by the next console interrupt
+func agIntwt(req agIntwtReqT) (resp agIntwtRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	if ppd.intPending && !ppd.kiOff {
+		ppd.intPending = false
+		return resp
+	}
+	resp.waiter = make(chan struct{})
+	ppd.intWaiters = append(ppd.intWaiters, resp.waiter)
+	return resp
+}
@@ -0,0 +1,123 @@
+// +build virtual !physical
+
+// agConsoles_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+func TestCtrlCCtrlB(t *testing.T) {
+	older := &PerProcessDataT{consoleName: "CON9", created: time.Now(), sched: newScheduler()}
+	newer := &PerProcessDataT{consoleName: "CON9", created: older.created.Add(time.Second), sched: newScheduler()}
+	PerProcessData[95], PerProcessData[96] = older, newer
+	defer delete(PerProcessData, 95)
+	defer delete(PerProcessData, 96)
+
+	agConsoleInterrupt(agConsoleInterruptReqT{name: "CON9", kind: consoleIntB})
+	if older.terminating {
+		t.Error("Expected only the newest process on the console to be aborted")
+	}
+	info := newer.termInfo
+	if !newer.terminating || info.errorCode != 0 || info.flags&Rfab != Rfab || info.message != ctrlCBMessage || !info.byTerm {
+		t.Errorf("Expected an abort with message %s, got %+v", ctrlCBMessage, info)
+	}
+}
+
+func TestSendTarget(t *testing.T) {
+	sender := &PerProcessDataT{name: "SENDER", consoleName: "CON8"}
+	target := &PerProcessDataT{name: "TARGET", consoleName: "CON9"}
+	detached := &PerProcessDataT{name: "DETACHED"}
+	PerProcessData[95], PerProcessData[96], PerProcessData[97] = sender, target, detached
+	agConsoles["CON8"], agConsoles["CON9"] = &consoleT{name: "CON8"}, &consoleT{name: "CON9"}
+	defer func() {
+		delete(PerProcessData, 95)
+		delete(PerProcessData, 96)
+		delete(PerProcessData, 97)
+		delete(agConsoles, "CON8")
+		delete(agConsoles, "CON9")
+	}()
+	tests := []struct {
+		req     agSendReqT
+		conName string
+		errCode dg.WordT
+	}{
+		{agSendReqT{PID: 95, targetType: sendToPID, targetPID: 96}, "CON9", 0},
+		{agSendReqT{PID: 95, targetType: sendToPID, targetPID: 95}, "CON8", 0},
+		{agSendReqT{PID: 95, targetType: sendToPID, targetPID: 97}, "CON8", 0},
+		{agSendReqT{PID: 95, targetType: sendToPID, targetPID: 98}, "", erprh},
+		{agSendReqT{PID: 95, targetType: sendToProc, targetName: "TARGET"}, "CON9", 0},
+		{agSendReqT{PID: 95, targetType: sendToProc, targetName: "NOBODY"}, "", erpnm},
+		{agSendReqT{PID: 95, targetType: sendToConsole, targetName: "@CON9"}, "CON9", 0},
+		{agSendReqT{PID: 95, targetType: sendToConsole, targetName: ":PER:CON9"}, "CON9", 0},
+		{agSendReqT{PID: 95, targetType: sendToConsole, targetName: "CON7"}, "", erdnm},
+		{agSendReqT{PID: 95, targetType: 3}, "", erpre},
+	}
+	for _, tt := range tests {
+		resp := agSend(tt.req)
+		if resp.errCode != tt.errCode || resp.errCode == 0 && resp.con.name != tt.conName {
+			t.Errorf("%+v: expected %s %#o, got %+v", tt.req, tt.conName, tt.errCode, resp)
+		}
+	}
+}
+
+func TestTypeAheadOverflow(t *testing.T) {
+	user, server := net.Pipe()
+	con := &consoleT{name: "CON9", conn: server, input: make(chan byte, 2)}
+	con.heldCond = sync.NewCond(&con.heldMu)
+	go con.readInput()
+	echoed := make(chan []byte)
+	go func() {
+		buf := make([]byte, 16)
+		n, _ := user.Read(buf)
+		echoed <- buf[:n]
+	}()
+
+	user.Write([]byte("ABC"))
+	if bell := <-echoed; len(bell) != 1 || bell[0] != dg.ASCIIBEL {
+		t.Errorf("Expected a bell for the character which did not fit, got %v", bell)
+	}
+	user.Write([]byte{ctrlS})
+	for i := 0; ; i++ {
+		con.heldMu.Lock()
+		held := con.held
+		con.heldMu.Unlock()
+		if held {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Expected CTRL-S to be acted on with the buffer full")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if a, b := <-con.input, <-con.input; a != 'A' || b != 'B' {
+		t.Errorf("Expected AB to be buffered, got %c%c", a, b)
+	}
+	con.setHeld(false)
+	server.Close()
+}
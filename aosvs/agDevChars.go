@@ -0,0 +1,310 @@
+// +build virtual !physical
+
+// agDevChars.go - character device characteristics and the console I/O they govern
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// Every console has one characteristics block, shared by all the channels open on it.
+// ?SCHR and ?SECHR change it and console ?READs and ?WRITEs honour it.
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+const (
+	echoMask      = ceos | ceoc
+	dasherFnKey   = 036 // DASHER function keys send this followed by a code
+	dasherRubEcho = "\x19 \x19"
+	ansiRubEcho   = "\b \b"
+)
+
+// devCharsT is the characteristics block of a character device
+type devCharsT struct {
+	mu                sync.Mutex // the Agent sets characteristics while tasks do console I/O
+	current, defaults [clmax]dg.WordT
+	lines             int // output lines since the last read, for page mode
+	col               int // output column, for line wrapping
+}
+
+// consoleDefaultChars are those of a DASHER 605x on a TELNET console, the terminal echoes locally
+var consoleDefaultChars = [clmax]dg.WordT{
+	ch1:    0,
+	ch2:    0x8000>>culc | crt3 | 0x8000>>cwrp,
+	ch3:    24<<8 | 80,
+	cctype: ctnc,
+}
+
+// newConsoleChars returns the characteristics block of a new console, matched to what a TELNET client negotiated
+func newConsoleChars(conn net.Conn) *devCharsT {
+	dc := &devCharsT{current: consoleDefaultChars, defaults: consoleDefaultChars}
+	if tc, ok := conn.(*TelnetConsoleT); ok {
+		tc.adaptChars(&dc.defaults)
+		dc.current = dc.defaults
+	}
+	return dc
+}
+
+func (dc *devCharsT) bit(word, bitNum int) bool {
+	return dc.current[word]&(0x8000>>bitNum) != 0
+}
+
+func (dc *devCharsT) linesPerPage() int {
+	return int(dc.current[cpgsz] >> 8)
+}
+
+func (dc *devCharsT) charsPerLine() int {
+	return int(dc.current[cpgsz] & 0xff)
+}
+
+// isANSI reports whether NLs must be sent as CR LF and CRs read as NLs
+func (dc *devCharsT) isANSI() bool {
+	return dc.bit(ch5, cxlt)
+}
+
+// echo sends back a character typed at the console according to the echo mode
+func (dc *devCharsT) echo(conn io.Writer, c byte) {
+	var e []byte
+	switch mode := dc.current[ch1] & echoMask; {
+	case mode == 0:
+		return
+	case c == dg.ASCIINL || c == dg.ASCIICR:
+		e = []byte{dg.ASCIINL}
+		if dc.isANSI() {
+			e = []byte{dg.ASCIICR, dg.ASCIINL}
+		}
+	case mode == ceoc && c == dg.ASCIIESC:
+		e = []byte{'$'}
+	case mode == ceoc && c < dg.ASCIISPC && c != dg.ASCIITAB:
+		e = []byte{'^', c + 0100}
+	default:
+		e = []byte{c}
+	}
+	conn.Write(e)
+}
+
+// rubout removes the last character typed from the screen
+func (dc *devCharsT) rubout(conn io.Writer) {
+	if dc.current[ch1]&echoMask == 0 {
+		return
+	}
+	if dc.isANSI() {
+		conn.Write([]byte(ansiRubEcho))
+	} else {
+		conn.Write([]byte(dasherRubEcho))
+	}
+}
+
+// readConsole reads a line, or for binary I/O exactly length bytes, from a console
+func (con *consoleT) readConsole(length int, binary bool, abort <-chan struct{}) (buff []byte, errCode dg.WordT) {
+	dc := con.chars
+	dc.mu.Lock()
+	dc.lines, dc.col = 0, 0
+	dc.mu.Unlock()
+	for length <= 0 || len(buff) < length {
+		var c byte
+		if c, errCode = con.readByte(abort); errCode != 0 {
+			return buff, errCode
+		}
+		if debugLogging {
+			logging.DebugPrint(logging.ScLog, "\tRead <%c> from CONSOLE\n", c)
+		}
+		if binary {
+			buff = append(buff, c)
+			continue
+		}
+		dc.mu.Lock()
+		if c == dg.DasherDELETE {
+			if len(buff) > 0 {
+				buff = buff[:len(buff)-1]
+				dc.rubout(con.conn)
+			}
+			dc.mu.Unlock()
+			continue
+		}
+		if c == dg.ASCIICR && dc.isANSI() {
+			c = dg.ASCIINL
+		}
+		fnKey := c == dasherFnKey && dc.bit(ch2, cfkt)
+		if !fnKey {
+			dc.echo(con.conn, c)
+		}
+		dc.mu.Unlock()
+		buff = append(buff, c)
+		if fnKey {
+			// the function key code completes the read, neither is echoed
+			if c, errCode = con.readByte(abort); errCode == 0 {
+				buff = append(buff, c)
+			}
+			break
+		}
+		if c == dg.ASCIINL || c == dg.ASCIICR {
+			break
+		}
+	}
+	return buff, errCode
+}
+
+// writeConsole sends output to a console applying case, line-length and page-mode
+// characteristics, it returns the number of the caller's bytes written
+func (con *consoleT) writeConsole(b []byte) (n int) {
+	con.outMu.Lock()
+	defer con.outMu.Unlock()
+	dc := con.chars
+	dc.mu.Lock()
+	if dc.bit(ch1, cuco) {
+		b = bytes.ToUpper(b)
+	}
+	lpp, cpl := dc.linesPerPage(), dc.charsPerLine()
+	autoNL := !dc.bit(ch1, ceol) && !dc.bit(ch2, cwrp) && cpl > 0
+	var out []byte
+	newLine := func() {
+		if dc.isANSI() {
+			out = append(out, dg.ASCIICR)
+		}
+		out = append(out, dg.ASCIINL)
+		dc.lines++
+		dc.col = 0
+	}
+	for _, c := range b {
+		if dc.bit(ch2, cpm) && lpp > 0 && dc.lines >= lpp-1 {
+			dc.mu.Unlock()
+			con.send(out)
+			out = out[:0]
+			con.pagePause()
+			dc.mu.Lock()
+			dc.lines = 0
+		}
+		switch {
+		case c == dg.ASCIINL:
+			newLine()
+		case c == dg.ASCIICR || c == dg.ASCIIFF:
+			out = append(out, c)
+			dc.col = 0
+			if c == dg.ASCIIFF {
+				dc.lines = 0
+			}
+		default:
+			if autoNL && dc.col >= cpl {
+				newLine()
+			}
+			out = append(out, c)
+			if c >= dg.ASCIISPC {
+				dc.col++
+			}
+		}
+	}
+	dc.mu.Unlock()
+	con.send(out)
+	return len(b)
+}
+
+// send writes to a console once any CTRL-S is lifted, output to a console which has gone is lost
+func (con *consoleT) send(out []byte) {
+	con.waitOutput()
+	con.conn.Write(out)
+}
+
+// pagePause holds page-mode output until the user types CTRL-Q
+func (con *consoleT) pagePause() {
+	con.setHeld(true)
+	con.waitOutput()
+}
+
+type agGchrReqT struct {
+	PID         dg.WordT
+	getDefaults bool // otherwise get current
+	useChan     bool // otherwise use name
+	devChan     dg.WordT
+	devName     string
+	length      int // words wanted
+}
+type agGchrRespT struct {
+	words   []dg.WordT
+	errCode dg.WordT
+}
+
+// findDevChars returns the characteristics block of a device given by channel or name
+func findDevChars(PID dg.WordT, useChan bool, devChan dg.WordT, devName string) (dc *devCharsT, errCode dg.WordT) {
+	if useChan {
+		agChan, isOpen := findChannel(PID, int(devChan))
+		if !isOpen {
+			return nil, eracu
+		}
+		if !agChan.isConsole {
+			return nil, erdnm
+		}
+		return agChan.console.chars, 0
+	}
+	con, errCode := findConsole(PID, devName)
+	if errCode != 0 {
+		return nil, errCode
+	}
+	return con.chars, 0
+}
+
+// agGetChars handles ?GCHR and ?GECHR
+func agGetChars(req agGchrReqT) (resp agGchrRespT) {
+	dc, errCode := findDevChars(req.PID, req.useChan, req.devChan, req.devName)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if req.getDefaults {
+		resp.words = append(resp.words, dc.defaults[:req.length]...)
+	} else {
+		resp.words = append(resp.words, dc.current[:req.length]...)
+	}
+	return resp
+}
+
+type agSchrReqT struct {
+	PID     dg.WordT
+	useChan bool // otherwise use name
+	devChan dg.WordT
+	devName string
+	words   []dg.WordT // new characteristics, words beyond these are unchanged
+}
+type agSchrRespT struct {
+	errCode dg.WordT
+}
+
+// agSetChars handles ?SCHR and ?SECHR
+func agSetChars(req agSchrReqT) (resp agSchrRespT) {
+	dc, errCode := findDevChars(req.PID, req.useChan, req.devChan, req.devName)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	dc.mu.Lock()
+	copy(dc.current[:], req.words)
+	logging.DebugPrint(logging.ScLog, "\tCharacteristics now %#o\n", dc.current)
+	dc.mu.Unlock()
+	return resp
+}
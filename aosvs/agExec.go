@@ -0,0 +1,211 @@
+// +build virtual !physical
+
+// agExec.go - a stand-in for the EXEC, answering ?EXEC requests and holding its queues
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// There is no real EXEC, so the pseudo-Agent answers ?EXEC itself.  Files submitted to a
+// queue are copied into the submitter's :QUEUE directory as <queue>.<sequence no.> - output
+// queues are treated as printed at once, batch jobs stay queued as there is no batch stream.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+// queue types returned by ?XFQST
+const (
+	queueBatch = iota
+	queuePrint
+	queuePlot
+	queuePunch
+	queueMount
+	queueUser
+)
+
+const spoolDir = ":QUEUE"
+
+type execQueueT struct {
+	qType   int
+	entries map[dg.WordT]*execEntryT
+}
+
+type execEntryT struct {
+	PID       dg.WordT // submitter
+	aosPath   string   // the file submitted
+	spoolFile string   // host copy in :QUEUE
+	held      bool
+}
+
+var (
+	execQueues = map[string]*execQueueT{
+		"BATCH_INPUT": {qType: queueBatch},
+		"LPT":         {qType: queuePrint},
+		"PLOT":        {qType: queuePlot},
+		"PTP":         {qType: queuePunch},
+		"MOUNT":       {qType: queueMount},
+	}
+	execSeqNo dg.WordT // last sequence number issued
+)
+
+// defaultQueues gives the queue used by each of the fixed-queue submission functions
+var defaultQueues = map[dg.WordT]string{
+	xfsub: "BATCH_INPUT",
+	xfoth: "BATCH_INPUT",
+	xfbat: "BATCH_INPUT",
+	xflpt: "LPT",
+	xfplt: "PLOT",
+	xfptp: "PTP",
+	xfmnt: "MOUNT",
+}
+
+type agExecReqT struct {
+	PID      dg.WordT
+	function dg.WordT
+	aosPath  string // file to submit
+	queue    string // empty for the function's default queue
+	seqNo    dg.WordT
+}
+type agExecRespT struct {
+	seqNo       dg.WordT
+	qType       int
+	execSonPID  dg.WordT
+	consoleName string
+	errCode     dg.WordT
+}
+
+// agExec handles the ?EXEC functions the stand-in EXEC supports
+func agExec(req agExecReqT) (resp agExecRespT) {
+	switch req.function {
+	case xfsts, xfxts:
+		resp.execSonPID, resp.consoleName = execSon(req.PID), PerProcessData[int(req.PID)].consoleName
+	case xfsub, xfoth, xfbat, xflpt, xfplt, xfptp, xfusr:
+		resp.seqNo, resp.errCode = execSubmit(req)
+	case xfhol, xfunh, xfcan:
+		resp.errCode = execAlter(req)
+	case xfqst:
+		queue, found := execQueues[strings.ToUpper(req.queue)]
+		if !found {
+			resp.errCode = erfde
+			return resp
+		}
+		resp.qType = queue.qType
+	case xfmun, xfmlt, xfdun, xfxun, xfxml, xfxdu, xfmnt:
+		resp.errCode = erxna // no tape drives to mount
+	default:
+		resp.errCode = erxuf
+	}
+	return resp
+}
+
+// execSon returns the PID of the process's top-level ancestor, the one the EXEC would have created
+func execSon(PID dg.WordT) dg.WordT {
+	for {
+		ppd, found := PerProcessData[int(PID)]
+		if !found || ppd.fatherPID == 0 {
+			return PID
+		}
+		PID = ppd.fatherPID
+	}
+}
+
+// execSubmit spools a copy of a file to a queue
+func execSubmit(req agExecReqT) (seqNo dg.WordT, errCode dg.WordT) {
+	qName := strings.ToUpper(req.queue)
+	if qName == "" {
+		qName = defaultQueues[req.function]
+	}
+	queue, found := execQueues[qName]
+	if !found {
+		return 0, erfde
+	}
+	path, full, errCode := agResolvePathname(req.PID, req.aosPath)
+	if errCode != 0 {
+		return 0, errCode
+	}
+	ppd := PerProcessData[int(req.PID)]
+	spoolPath, errCode := ppd.hostPath(spoolDir)
+	if errCode != 0 {
+		return 0, errCode
+	}
+	if err := os.MkdirAll(spoolPath, 0755); err != nil {
+		return 0, erdde
+	}
+	execSeqNo++
+	spooled := filepath.Join(spoolPath, qName+"."+strconv.Itoa(int(execSeqNo)))
+	if errCode = copyHostFile(path, spooled); errCode != 0 {
+		return 0, errCode
+	}
+	logging.DebugPrint(logging.ScLog, "\tEXEC queued %s on %s as sequence no. %d\n", full, qName, execSeqNo)
+	if queue.qType == queueBatch {
+		if queue.entries == nil {
+			queue.entries = make(map[dg.WordT]*execEntryT)
+		}
+		queue.entries[execSeqNo] = &execEntryT{PID: req.PID, aosPath: full, spoolFile: spooled}
+	}
+	return execSeqNo, 0
+}
+
+// execAlter holds, unholds or cancels a queued job
+func execAlter(req agExecReqT) dg.WordT {
+	for _, queue := range execQueues {
+		entry, found := queue.entries[req.seqNo]
+		if !found {
+			continue
+		}
+		switch req.function {
+		case xfhol:
+			entry.held = true
+		case xfunh:
+			entry.held = false
+		case xfcan:
+			os.Remove(entry.spoolFile)
+			delete(queue.entries, req.seqNo)
+		}
+		return 0
+	}
+	return erfde
+}
+
+// copyHostFile copies a submitted file into the spool directory
+func copyHostFile(from, to string) dg.WordT {
+	src, err := os.Open(from)
+	if err != nil {
+		return erfde
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return erwad
+	}
+	defer dst.Close()
+	if _, err = io.Copy(dst, src); err != nil {
+		return erwad
+	}
+	return 0
+}
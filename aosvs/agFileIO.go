@@ -24,17 +24,27 @@
 package aosvs
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
 )
 
+const (
+	defaultRecordLength = 136 // used when no length is given at ?OPEN time
+	recordFormatMask    = 7   // record format field of isti
+	varRecHdrLen        = 4   // length of a variable record header or IBM block/record descriptor
+)
+
 type agCloseReqT struct {
+	PID    dg.WordT
 	chanNo int
 }
 type agCloseRespT struct {
@@ -42,27 +52,68 @@ type agCloseRespT struct {
 }
 
 func agFileClose(req agCloseReqT) (resp agCloseRespT) {
-	if req.chanNo == 0 {
-		resp.errCode = 0
-	} else {
-		logging.DebugPrint(logging.ScLog, "\tChannel # %d\n", req.chanNo)
-		agChan, isOpen := agChannels[req.chanNo]
-		if isOpen {
-			if agChan.isConsole {
-				logging.DebugPrint(logging.ScLog, "\tIgnoring ?CLOSE on console channel\n")
-			} else {
-				agChan.file.Close()
-				delete(agChannels, req.chanNo)
-				logging.DebugPrint(logging.ScLog, "\tFile closed\n")
-			}
-		} else {
-			logging.DebugPrint(logging.ScLog, "\tFILE WAS NOT OPEN\n")
-			resp.errCode = eracu
+	logging.DebugPrint(logging.ScLog, "\tChannel # %d\n", req.chanNo)
+	agChan, isOpen := findChannel(req.PID, req.chanNo)
+	if isOpen {
+		// the connection of a console stays open for its other users
+		if agChan.file != nil {
+			agChan.file.Close()
 		}
+		delete(PerProcessData[int(req.PID)].channels, req.chanNo)
+		logging.DebugPrint(logging.ScLog, "\tChannel closed\n")
+	} else {
+		logging.DebugPrint(logging.ScLog, "\tFILE WAS NOT OPEN\n")
+		resp.errCode = eracu
 	}
 	return resp
 }
 
+// findChannel returns one of a process's open channels, every process has its own channel numbers
+func findChannel(PID dg.WordT, chanNo int) (agChan *agChannelT, isOpen bool) {
+	ppd := PerProcessData[int(PID)]
+	if ppd == nil {
+		return nil, false
+	}
+	agChan, isOpen = ppd.channels[chanNo]
+	return agChan, isOpen
+}
+
+// allocChannel stores an open channel under the process's lowest free channel number
+func (ppd *PerProcessDataT) allocChannel(agChan *agChannelT) (chanNo int) {
+	if ppd.channels == nil {
+		ppd.channels = map[int]*agChannelT{}
+	}
+	for {
+		if _, inUse := ppd.channels[chanNo]; !inUse {
+			ppd.channels[chanNo] = agChan
+			return chanNo
+		}
+		chanNo++
+	}
+}
+
+// closeChannels closes every file a process left open, consoles stay connected
+func (ppd *PerProcessDataT) closeChannels() {
+	for chanNo, agChan := range ppd.channels {
+		if agChan.file != nil {
+			agChan.file.Close()
+		}
+		delete(ppd.channels, chanNo)
+	}
+}
+
+// nullFile is the generic file which discards its output
+const nullFile = "@NULL"
+
+// isConsoleFile reports whether a generic file name refers to a console
+func isConsoleFile(name string) bool {
+	switch name {
+	case "@CONSOLE", "@INPUT", "@OUTPUT":
+		return true
+	}
+	return strings.HasPrefix(name, "@"+consolePrefix)
+}
+
 type agOpenReqT struct {
 	PID    dg.WordT
 	path   string
@@ -82,7 +133,7 @@ func agFileOpen(req agOpenReqT) (resp agOpenRespT) {
 		err    error
 		agChan agChannelT
 	)
-	agChan.path = req.path
+	agChan.aosPath = req.path
 	// parse creation options
 	switch {
 	case (req.mode&ofcr != 0) && (req.mode&ofce != 0):
@@ -105,148 +156,147 @@ func agFileOpen(req agOpenReqT) (resp agOpenRespT) {
 		agChan.write = true
 	case req.mode&ofio != 0:
 		flags |= os.O_RDWR
+		agChan.read = true
+		agChan.write = true
 	}
-	// append?
-	if req.mode&apnd != 0 {
-		flags |= os.O_APPEND
+	agChan.recordFormat = int(req.mode & recordFormatMask)
+	if agChan.recordFormat > rtvb {
+		resp.ac0 = errfm
+		return resp
+	}
+	if agChan.recordFormat == 0 {
+		agChan.recordFormat = rtds // the default for disk files
 	}
 	switch {
+	case req.path == nullFile: // everything written is discarded, reads get end-of-file
+		agChan.path = os.DevNull
+		fp, err = os.OpenFile(os.DevNull, os.O_RDWR, 0)
 	case req.path[0] == '@':
-		switch req.path {
-		case "@CONSOLE", "@INPUT", "@OUTPUT":
-			agChan.conn = console
-			agChan.isConsole = true
-		default:
-			log.Panicf("ERROR: Pseudo-Agent cannot handle unknown generic file %s\n", req.path)
+		if !isConsoleFile(req.path) {
+			resp.ac0 = erfde
+			return resp
+		}
+		con, errCode := findConsole(req.PID, req.path)
+		if errCode != 0 {
+			resp.ac0 = dg.DwordT(errCode)
+			return resp
 		}
-	case req.path[0] != ':' && PerProcessData[int(req.PID)].virtualRoot != "":
-		logging.DebugPrint(logging.ScLog, "\tAttempting to Open file: %s\n", PerProcessData[int(req.PID)].virtualRoot+"/"+req.path)
-		fp, err = os.OpenFile(PerProcessData[int(req.PID)].virtualRoot+"/"+req.path, flags, 0755)
+		agChan.console = con
+		agChan.aosPath = ":PER:" + con.name
+		agChan.isConsole = true
 	default:
-		fp, err = os.OpenFile(req.path, flags, 0755)
+		var errCode dg.WordT
+		if req.mode&(ofcr|ofce) == 0 {
+			agChan.path, agChan.aosPath, errCode = agSearchPath(req.PID, req.path)
+		} else {
+			agChan.path, agChan.aosPath, errCode = agResolvePathname(req.PID, req.path)
+		}
+		if errCode != 0 {
+			resp.ac0 = dg.DwordT(errCode)
+			return resp
+		}
+		logging.DebugPrint(logging.ScLog, "\tAttempting to Open file: %s\n", agChan.path)
+		fi, statErr := os.Stat(agChan.path)
+		if statErr == nil && !fi.IsDir() {
+			access := PerProcessData[int(req.PID)].access(getFileMeta(agChan.path, fi).ACL)
+			if agChan.read && access&facr == 0 || agChan.write && access&(facw|faca) == 0 {
+				resp.ac0 = erfad
+				return resp
+			}
+		}
+		fp, err = os.OpenFile(agChan.path, flags, 0755)
+		if err == nil && os.IsNotExist(statErr) {
+			putFileMeta(agChan.path, newFileMeta(req.PID, fudf, agChan.recordFormat))
+		}
+	}
+	if os.IsExist(err) {
+		resp.ac0 = ernae
+		return resp
 	}
 	if err != nil {
 		resp.ac0 = erfad
 		return resp
 	}
+	agChan.recordLength = defaultRecordLength
 	if req.recLen > 0 {
 		agChan.recordLength = req.recLen
 	}
+	if agChan.recordFormat == rtfx && req.recLen <= 0 {
+		fp.Close()
+		resp.ac0 = erirl
+		return resp
+	}
+	// appending is done by positioning as we use explicit offsets for all file I/O
+	if fp != nil && req.mode&apnd != 0 {
+		agChan.pos, _ = fp.Seek(0, io.SeekEnd)
+	}
 	agChan.file = fp
-	newChan := len(agChannels)
-	agChannels[newChan] = &agChan
+	newChan := PerProcessData[int(req.PID)].allocChannel(&agChan)
 	resp.channelNo = dg.WordT(newChan)
 	return resp
 }
 
 type agReadReqT struct {
+	PID      dg.WordT
 	chanNo   int
 	specs    dg.WordT
 	length   int
 	readLine bool
+	position int64 // record number for ?RTFX, otherwise a byte offset
 }
 type agReadRespT struct {
-	ac0  dg.WordT
-	data []byte
+	ac0     dg.WordT
+	data    []byte    // any partial record is returned along with an error in ac0
+	console *consoleT // non-nil if the caller must read from this console itself
 }
 
 func agFileRead(req agReadReqT) (resp agReadRespT) {
-	agChan, isOpen := agChannels[req.chanNo]
-	if isOpen {
-		recLen := req.length
-		if recLen == -1 {
-			if agChan.recordLength == -1 {
-				log.Panicf("ERROR: Default length file read on file with no default length set at ?OPEN")
-			} else {
-				recLen = agChan.recordLength
-			}
+	agChan, isOpen := findChannel(req.PID, req.chanNo)
+	if !isOpen {
+		resp.ac0 = eracu
+		return resp
+	}
+	if agChan.isConsole {
+		if debugLogging {
+			logging.DebugPrint(logging.ScLog, "?READ from CONSOLE device...\n")
 		}
-		if agChan.isConsole {
-			if debugLogging {
-				logging.DebugPrint(logging.ScLog, "?READ from CONSOLE device...\n")
-			}
-			buff := make([]byte, 0)
-			for {
-				oneByte := make([]byte, 1, 1)
-				l, err := agChan.conn.Read(oneByte)
-				if err != nil {
-					log.Panic("ERROR: Could not read from @CONSOLE")
-				}
-				if l == 0 {
-					log.Panic("ERROR: ?READ got 0 bytes from @CONSOLE")
-				}
-				// TODO DELete
-				buff = append(buff, oneByte[0])
-				if debugLogging {
-					logging.DebugPrint(logging.ScLog, "\tRead <%c> from CONSOLE\n", oneByte[0])
-				}
-				if oneByte[0] == dg.ASCIINL || oneByte[0] == '\r' {
-					break
-				}
-				//buff = append(buff, oneByte[0])
-			}
-			resp.data = buff
-		} else {
-			if req.specs&ipst != 0 {
-				log.Panic("Absolute positining NYI")
-			}
-
-			switch {
-			case req.specs&rtdy != 0:
-				if debugLogging {
-					logging.DebugPrint(logging.ScLog, "\tDynamic Length: %d.\n", recLen)
-				}
-				buf := make([]byte, recLen)
-				n, err := agChannels[req.chanNo].file.Read(buf)
-				if n == 0 && err == io.EOF {
-					resp.ac0 = ereof
-					return resp
-				}
-				resp.data = buf
-			case req.specs&rtds != 0:
-				if debugLogging {
-					logging.DebugPrint(logging.ScLog, "\tData Sensitive\n")
-				}
-				buf := make([]byte, recLen)
-				n, err := agChannels[req.chanNo].file.Read(buf)
-				if n == 0 && err == io.EOF {
-					resp.ac0 = ereof
-					return resp
-				}
-				tooLong := false
-				buf, tooLong = getDataSensitivePortion(buf, recLen)
-				if tooLong {
-					resp.ac0 = erltl
-					return resp
-				}
-				resp.data = buf
-			case req.specs&rtvr != 0:
-				lenBytes := make([]byte, 4)
-				n, _ := agChannels[req.chanNo].file.Read(lenBytes)
-				recLen, err := strconv.Atoi(string(lenBytes[0:4]))
-				if err != nil {
-					log.Panicf("ERROR: ?READ could not parse variable record length <%v>\n", lenBytes[0:4])
-				}
-				recLen -= 4
-				if debugLogging {
-					logging.DebugPrint(logging.ScLog, "\tVariable Length: %d.\n", recLen)
-				}
-				buf := make([]byte, recLen)
-				n, err = agChannels[req.chanNo].file.Read(buf)
-				if n == 0 && err == io.EOF {
-					resp.ac0 = ereof
-					return resp
-				}
-				resp.data = buf
-			default:
-				log.Panicf("ERROR: ?READ record type %#x not yet implemented\n", req.specs)
-			}
+		resp.console = agChan.console // the caller reads it
+		return resp
+	}
+	if !agChan.read {
+		resp.ac0 = erfad
+		return resp
+	}
+	recFmt, recLen, errCode := agChan.ioParms(req.specs, req.length)
+	if errCode != 0 {
+		resp.ac0 = errCode
+		return resp
+	}
+	if req.specs&ipst != 0 {
+		agChan.position(recFmt, recLen, req.position)
+	}
+	switch recFmt {
+	case rtdy, rtun:
+		if debugLogging {
+			logging.DebugPrint(logging.ScLog, "\tDynamic/Undefined Length: %d.\n", recLen)
 		}
-
-	} else {
-		log.Panic("ERROR: attempt to ?READ from unopened file")
+		resp.data, resp.ac0 = agChan.readFixed(recLen)
+	case rtfx:
+		if debugLogging {
+			logging.DebugPrint(logging.ScLog, "\tFixed Length: %d.\n", recLen)
+		}
+		resp.data, resp.ac0 = agChan.readFixed(recLen)
+	case rtds:
+		if debugLogging {
+			logging.DebugPrint(logging.ScLog, "\tData Sensitive, Max Length: %d.\n", recLen)
+		}
+		resp.data, resp.ac0 = agChan.readDataSensitive(recLen)
+	case rtvr:
+		resp.data, resp.ac0 = agChan.readVariable(recLen)
+	case rtvb:
+		resp.data, resp.ac0 = agChan.readVariableBlock(recLen)
 	}
-	logging.DebugPrint(logging.ScLog, "?READ - Agent returning <%v>\n", resp.data)
+	logging.DebugPrint(logging.ScLog, "?READ - Agent returning <%v>, error code: %#o\n", resp.data, resp.ac0)
 	return resp
 }
 
@@ -260,19 +310,31 @@ type agRecreateRespT struct {
 }
 
 func agFileRecreate(req agRecreateReqT) (resp agRecreateRespT) {
-	filename := strings.ReplaceAll(req.aosFilename, ":", "/") // convert any : to /
-	if filename[0] == '@' {
-		log.Panicf("ERROR: ?RECREATE in :PER not yet implemented (file: %s)", filename)
+	switch {
+	case req.aosFilename == nullFile:
+		resp.ok = true
+		return resp
+	case isConsoleFile(req.aosFilename):
+		resp.errCode = erift // a device cannot be recreated
+		return resp
+	case req.aosFilename[0] == '@':
+		resp.errCode = erfde
+		return resp
 	}
-	if filename[0] != '/' {
-		filename = PerProcessData[int(req.PID)].virtualRoot + "/" + filename
-		logging.DebugPrint(logging.ScLog, "\tResolved %s to %s\n", req.aosFilename, filename)
+	filename, errCode := agResolvePath(req.PID, req.aosFilename)
+	if errCode != 0 {
+		resp.errCode = dg.DwordT(errCode)
+		return resp
 	}
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) {
 		resp.errCode = erfde
 		resp.ok = false
 	} else {
+		meta := getFileMeta(filename, fi)
 		os.Truncate(filename, 0)
+		meta.Modified = time.Now()
+		putFileMeta(filename, meta)
 		resp.ok = true
 	}
 	return resp
@@ -282,6 +344,7 @@ type agSharedOpenReqT struct {
 	PID      dg.WordT
 	filename string
 	readonly bool
+	chanNo   int // -1 for any free channel
 }
 type agSharedOpenRespT struct {
 	ac0       dg.DwordT
@@ -295,123 +358,313 @@ func agSharedOpen(req agSharedOpenReqT) (resp agSharedOpenRespT) {
 		err    error
 		agChan agChannelT
 	)
-	agChan.path = req.filename
+	if req.chanNo != -1 {
+		if _, inUse := findChannel(req.PID, req.chanNo); inUse {
+			resp.ac0 = erciu
+			return resp
+		}
+	}
 	if req.readonly {
 		flags = os.O_RDONLY
 	} else {
 		flags = os.O_RDWR
 	}
-	if req.filename[0] != ':' && PerProcessData[int(req.PID)].virtualRoot != "" {
-		logging.DebugPrint(logging.ScLog, "\tAttempting to SOpen file: %s\n", PerProcessData[int(req.PID)].virtualRoot+"/"+req.filename)
-		fp, err = os.OpenFile(PerProcessData[int(req.PID)].virtualRoot+"/"+req.filename, flags, 0755)
-	} else {
-		fp, err = os.OpenFile(req.filename, flags, 0755)
+	var errCode dg.WordT
+	if agChan.path, agChan.aosPath, errCode = agSearchPath(req.PID, req.filename); errCode != 0 {
+		resp.ac0 = dg.DwordT(errCode)
+		return resp
 	}
+	logging.DebugPrint(logging.ScLog, "\tAttempting to SOpen file: %s\n", agChan.path)
+	fp, err = os.OpenFile(agChan.path, flags, 0755)
 	if err != nil {
 		resp.ac0 = erfad // TODO add more errors here
 		return resp
 	}
 	agChan.file = fp
-	newChan := len(agChannels)
-	agChannels[newChan] = &agChan
+	agChan.forShared = true
+	agChan.read, agChan.write = true, !req.readonly
+	ppd := PerProcessData[int(req.PID)]
+	newChan := req.chanNo
+	if newChan == -1 {
+		newChan = ppd.allocChannel(&agChan)
+	} else {
+		ppd.channels[newChan] = &agChan
+	}
 	resp.channelNo = dg.DwordT(newChan)
 	logging.DebugPrint(logging.ScLog, "\tReturning channel: %d.\n", newChan)
 	return resp
 }
 
-type agSharedReadReqT struct {
-	chanNo   int
-	length   int
-	startPos int64
+type agSharedFileReqT struct {
+	PID    dg.WordT
+	chanNo int
 }
-type agSharedReadRespT struct {
-	ac0  dg.DwordT
-	data []byte
+type agSharedFileRespT struct {
+	path     string
+	writable bool
+	errCode  dg.WordT
 }
 
-func agSharedRead(req agSharedReadReqT) (resp agSharedReadRespT) {
-	_, isOpen := agChannels[req.chanNo]
-	if isOpen {
-		buf := make([]byte, req.length)
-		if debugLogging {
-			logging.DebugPrint(logging.ScLog, "\tAttempting to Seek to byte: %d. on channel: %d.\n", req.startPos, req.chanNo)
-		}
-		_, err := agChannels[req.chanNo].file.Seek(req.startPos, 0)
-		if err != nil {
-			log.Panicf("ERROR: ?SPAGE positioning failed: %v", err)
-		}
-		n, err := agChannels[req.chanNo].file.Read(buf)
-		if n == 0 && err == io.EOF {
-			// It looks as if we should create pages here - can't find it in the docs though...
-			resp.data = make([]byte, req.length)
-			if debugLogging {
-				logging.DebugPrint(logging.ScLog, "\tRead no bytes from channnel #%d., returning %d. empty bytes\n", req.chanNo, req.length)
-			}
-		} else {
-			resp.data = buf
-			if debugLogging {
-				logging.DebugPrint(logging.ScLog, "\tRead %d. bytes from channnel #%d.\n", n, req.chanNo)
-			}
-		}
-	} else {
-		log.Panic("ERROR: attempt to ?SPAGE from unopened file")
+// agSharedFile returns the host file of a channel opened via ?SOPEN
+func agSharedFile(req agSharedFileReqT) (resp agSharedFileRespT) {
+	agChan, isOpen := findChannel(req.PID, req.chanNo)
+	if !isOpen || !agChan.forShared {
+		resp.errCode = erfno
+		return resp
 	}
+	resp.path, resp.writable = agChan.path, agChan.write
 	return resp
 }
 
 type agWriteReqT struct {
+	PID        dg.WordT
 	channel    int
 	isExtended bool
 	isAbsolute bool
-	isDataSens bool
+	specs      dg.WordT
 	recLen     int
 	bytes      []byte
-	position   int32
+	position   int64 // record number for ?RTFX, otherwise a byte offset
 }
 type agWriteRespT struct {
 	errCode    int
 	bytesTxfrd dg.WordT
+	console    *consoleT // non-nil if the caller must write data to this console itself
+	data       []byte
 }
 
 func agFileWrite(req agWriteReqT) (resp agWriteRespT) {
 	if debugLogging {
-		logging.DebugPrint(logging.ScLog, "\tChan: %d., Extended: %v, Posn: %#x, Data Sensitive: %v, Len: %d.\n", req.channel, req.isExtended, req.position, req.isDataSens, req.recLen)
+		logging.DebugPrint(logging.ScLog, "\tChan: %d., Extended: %v, Posn: %#x, Specs: %#x, Len: %d.\n", req.channel, req.isExtended, req.position, req.specs, req.recLen)
+	}
+	agChan, isOpen := findChannel(req.PID, req.channel)
+	if !isOpen {
+		resp.errCode = eracu
+		return resp
+	}
+	recFmt, recLen, errCode := agChan.ioParms(req.specs, req.recLen)
+	if errCode != 0 {
+		resp.errCode = int(errCode)
+		return resp
 	}
-	agChan, isOpen := agChannels[req.channel]
 	bytes := req.bytes
-	if isOpen {
-		if agChan.isConsole {
-			resp.bytesTxfrd = dg.WordT(agWriteToUserConsole(agChan, bytes))
+	if len(bytes) > recLen {
+		bytes = bytes[:recLen]
+	}
+	if agChan.isConsole {
+		if recFmt == rtds {
+			var tooLong bool
+			if bytes, tooLong = getDataSensitivePortion(bytes, recLen); tooLong {
+				resp.errCode = erltl
+				return resp
+			}
 		}
-	} else {
-		log.Panic("ERROR: attempt to ?WRITE to unopened file")
+		resp.console, resp.data = agChan.console, bytes
+		return resp
+	}
+	if !agChan.write {
+		resp.errCode = erfad
+		return resp
+	}
+	if req.isAbsolute {
+		agChan.position(recFmt, recLen, req.position)
+	}
+	var record []byte
+	switch recFmt {
+	case rtdy, rtun:
+		record = bytes
+	case rtfx:
+		record = make([]byte, recLen) // short records are padded with NULs
+		copy(record, bytes)
+	case rtds:
+		var tooLong bool
+		if record, tooLong = getDataSensitivePortion(bytes, recLen); tooLong {
+			resp.errCode = erltl
+			return resp
+		}
+	case rtvr:
+		record = append([]byte(fmt.Sprintf("%04d", len(bytes)+varRecHdrLen)), bytes...)
+	case rtvb:
+		// we write each record in its own block
+		record = make([]byte, 2*varRecHdrLen, 2*varRecHdrLen+len(bytes))
+		binary.BigEndian.PutUint16(record, uint16(len(bytes)+2*varRecHdrLen))
+		binary.BigEndian.PutUint16(record[varRecHdrLen:], uint16(len(bytes)+varRecHdrLen))
+		record = append(record, bytes...)
+	}
+	n, err := agChan.file.WriteAt(record, agChan.pos)
+	agChan.pos += int64(n)
+	if err != nil {
+		log.Printf("WARNING: ?WRITE to %s failed with %v\n", agChan.path, err)
+		resp.errCode = erfad
+		return resp
+	}
+	switch recFmt {
+	case rtvr, rtvb:
+		resp.bytesTxfrd = dg.WordT(len(bytes))
+	default:
+		resp.bytesTxfrd = dg.WordT(len(record))
 	}
 	return resp
 }
 
-func agWriteToUserConsole(agChan *agChannelT, b []byte) (n int) {
-	n, err := agChan.conn.Write(b)
-	if err != nil {
-		log.Panic("ERROR: Could not write to @CONSOLE")
-	}
-	// var err error
-	// for _, c := range b {
-	// 	time.Sleep(time.Millisecond * 2)
-	// 	bs := []byte{c}
-	// 	n, err = agChan.conn.Write(bs)
-	// 	if err != nil {
-	// 		log.Panic("ERROR: Could not write to @CONSOLE")
-	// 	}
-	// }
-	if debugLogging {
-		logging.DebugPrint(logging.ScLog, "\twrote %d., bytes <%v> to @CONSOLE\n", n, b)
-		logging.DebugPrint(logging.ScLog, "\t\tString: <%s>\n", string(b))
+type agChannelInfoReqT struct {
+	PID    dg.WordT
+	chanNo int
+}
+type agChannelInfoRespT struct {
+	recordFormat int
+	recordLength int
+	path         string
+	errCode      dg.WordT
+}
+
+// agChannelInfo tells a system call the defaults set when a channel was opened
+func agChannelInfo(req agChannelInfoReqT) (resp agChannelInfoRespT) {
+	agChan, isOpen := findChannel(req.PID, req.chanNo)
+	if !isOpen {
+		resp.errCode = eracu
+		return resp
+	}
+	resp.recordFormat, resp.recordLength = agChan.recordFormat, agChan.recordLength
+	resp.path = agChan.path
+	return resp
+}
+
+// ioParms returns the record format and length to use for a ?READ or ?WRITE,
+// which may override those set at ?OPEN time
+func (agChan *agChannelT) ioParms(specs dg.WordT, length int) (recFmt, recLen int, errCode dg.WordT) {
+	recFmt = agChan.recordFormat
+	if specs&icrf != 0 {
+		recFmt = int(specs & recordFormatMask)
+		if recFmt == 0 || recFmt > rtvb {
+			return 0, 0, errfm
+		}
+	}
+	recLen = length
+	if recLen == -1 || recFmt == rtfx && specs&icrf == 0 {
+		recLen = agChan.recordLength
+	}
+	if recLen <= 0 {
+		return 0, 0, erirl
+	}
+	return recFmt, recLen, 0
+}
+
+// position sets the file position for the next record, the position is a record
+// number for fixed-length records and a byte offset otherwise
+func (agChan *agChannelT) position(recFmt, recLen int, pos int64) {
+	if recFmt == rtfx {
+		pos *= int64(recLen)
+	}
+	agChan.pos = pos
+	agChan.vbBlock = nil
+	logging.DebugPrint(logging.ScLog, "\tPositioned to byte %d. on %s\n", pos, agChan.path)
+}
+
+// readUpTo returns up to n bytes from the current position
+func (agChan *agChannelT) readUpTo(n int) []byte {
+	buf := make([]byte, n)
+	got, _ := agChan.file.ReadAt(buf, agChan.pos)
+	agChan.pos += int64(got)
+	return buf[:got]
+}
+
+// readFixed handles fixed-length, dynamic and undefined records, a short record means we hit the end of file
+func (agChan *agChannelT) readFixed(recLen int) (data []byte, errCode dg.WordT) {
+	data = agChan.readUpTo(recLen)
+	if len(data) < recLen {
+		return data, ereof
+	}
+	return data, 0
+}
+
+// readDataSensitive returns a record up to and including its delimiter, a final record
+// without a delimiter is returned as-is
+func (agChan *agChannelT) readDataSensitive(maxLen int) (data []byte, errCode dg.WordT) {
+	start := agChan.pos
+	data = agChan.readUpTo(maxLen)
+	if len(data) == 0 {
+		return nil, ereof
 	}
-	return n
+	rec, tooLong := getDataSensitivePortion(data, maxLen)
+	if !tooLong {
+		agChan.pos = start + int64(len(rec))
+		return rec, 0
+	}
+	if len(data) < maxLen {
+		return data, 0 // partial last record
+	}
+	return data, erltl // the rest of the line will be returned by the next read
+}
+
+// readVariable returns a record which is preceded in the file by a 4-digit ASCII length (including the length)
+func (agChan *agChannelT) readVariable(maxLen int) (data []byte, errCode dg.WordT) {
+	hdr := agChan.readUpTo(varRecHdrLen)
+	if len(hdr) < varRecHdrLen {
+		return nil, ereof
+	}
+	recLen, err := strconv.Atoi(string(hdr))
+	if err != nil || recLen < varRecHdrLen {
+		log.Printf("WARNING: ?READ could not parse variable record length <%v> in %s\n", hdr, agChan.path)
+		return nil, errfm
+	}
+	return agChan.readRecordBody(recLen-varRecHdrLen, maxLen)
+}
+
+// readRecordBody returns a record of the given length, truncated to maxLen, positioned after it
+func (agChan *agChannelT) readRecordBody(recLen, maxLen int) (data []byte, errCode dg.WordT) {
+	if recLen > maxLen {
+		data = agChan.readUpTo(maxLen)
+		agChan.pos += int64(recLen - maxLen)
+		return data, erltl
+	}
+	data = agChan.readUpTo(recLen)
+	if len(data) < recLen {
+		return data, ereof
+	}
+	return data, 0
+}
+
+// readVariableBlock returns the next record of an IBM variable block file, blocks are preceded by a
+// 4-byte Block Descriptor Word and records by a 4-byte Record Descriptor Word, both binary
+func (agChan *agChannelT) readVariableBlock(maxLen int) (data []byte, errCode dg.WordT) {
+	if len(agChan.vbBlock) == 0 {
+		bdw := agChan.readUpTo(varRecHdrLen)
+		if len(bdw) < varRecHdrLen {
+			return nil, ereof
+		}
+		blkLen := int(binary.BigEndian.Uint16(bdw))
+		if blkLen < varRecHdrLen {
+			return nil, errfm
+		}
+		agChan.vbBlock = agChan.readUpTo(blkLen - varRecHdrLen)
+		if len(agChan.vbBlock) < blkLen-varRecHdrLen {
+			agChan.vbBlock = nil
+			return nil, ereof
+		}
+	}
+	if len(agChan.vbBlock) < varRecHdrLen {
+		agChan.vbBlock = nil
+		return nil, errfm
+	}
+	recLen := int(binary.BigEndian.Uint16(agChan.vbBlock))
+	if recLen < varRecHdrLen || recLen > len(agChan.vbBlock) {
+		agChan.vbBlock = nil
+		return nil, errfm
+	}
+	data = agChan.vbBlock[varRecHdrLen:recLen]
+	agChan.vbBlock = agChan.vbBlock[recLen:]
+	if len(data) > maxLen {
+		return data[:maxLen], erltl
+	}
+	return data, 0
 }
 
 func getDataSensitivePortion(ba []byte, maxLen int) (res []byte, tooLong bool) {
 	tooLong = false
+	if len(ba) > maxLen {
+		ba = ba[:maxLen]
+	}
 	for ix, b := range ba {
 		if b == 0 || b == dg.ASCIINL || b == dg.ASCIICR || b == dg.ASCIIFF { //|| b == dg.ASCIITAB {
 			if debugLogging {
@@ -0,0 +1,214 @@
+// +build virtual !physical
+
+// agFileIO_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// fileTestProcess registers a process whose working directory is the root of a temporary namespace
+func fileTestProcess(t *testing.T, PID dg.WordT, root string) *PerProcessDataT {
+	ppd := &PerProcessDataT{virtualRoot: root, workingDir: rootDir, channels: map[int]*agChannelT{}}
+	PerProcessData[int(PID)] = ppd
+	t.Cleanup(func() { delete(PerProcessData, int(PID)) })
+	return ppd
+}
+
+func TestChannelsArePerProcess(t *testing.T) {
+	root := t.TempDir()
+	owner := fileTestProcess(t, 93, root)
+	fileTestProcess(t, 94, root)
+
+	open := agFileOpen(agOpenReqT{PID: 93, path: "DATA", mode: ofcr | ofio | rtds})
+	if open.ac0 != 0 {
+		t.Fatalf("Expected ?OPEN to succeed, got %#o", open.ac0)
+	}
+	chanNo := int(open.channelNo)
+	if resp := agFileWrite(agWriteReqT{PID: 94, channel: chanNo, specs: icrf | rtds, recLen: 3, bytes: []byte("BAD")}); resp.errCode != eracu {
+		t.Errorf("Expected another process's ?WRITE to fail with ERACU, got %#o", resp.errCode)
+	}
+	if resp := agFileClose(agCloseReqT{PID: 94, chanNo: chanNo}); resp.errCode != eracu {
+		t.Errorf("Expected another process's ?CLOSE to fail with ERACU, got %#o", resp.errCode)
+	}
+	other := agFileOpen(agOpenReqT{PID: 94, path: "DATA", mode: ofio | rtds})
+	if other.ac0 != 0 || int(other.channelNo) != chanNo {
+		t.Errorf("Expected the other process to get its own channel %d, got %d (%#o)", chanNo, other.channelNo, other.ac0)
+	}
+
+	file := owner.channels[chanNo].file
+	owner.closeChannels()
+	if len(owner.channels) != 0 {
+		t.Error("Expected every channel to be released")
+	}
+	if err := file.Close(); err == nil {
+		t.Error("Expected the host file to have been closed")
+	}
+}
+
+func TestConsoleClose(t *testing.T) {
+	ppd := fileTestProcess(t, 93, t.TempDir())
+	ppd.consoleName = "CON9"
+	agConsoles["CON9"] = &consoleT{name: "CON9"}
+	defer delete(agConsoles, "CON9")
+
+	for i := 0; i < 3; i++ {
+		open := agFileOpen(agOpenReqT{PID: 93, path: "@CONSOLE", mode: ofio})
+		if open.ac0 != 0 || open.channelNo != 0 {
+			t.Fatalf("Expected @CONSOLE to open on channel 0, got %d (%#o)", open.channelNo, open.ac0)
+		}
+		if resp := agFileClose(agCloseReqT{PID: 93, chanNo: 0}); resp.errCode != 0 {
+			t.Fatalf("Expected ?CLOSE of @CONSOLE to succeed, got %#o", resp.errCode)
+		}
+	}
+	if len(ppd.channels) != 0 {
+		t.Errorf("Expected the console channel to be released, %d remain", len(ppd.channels))
+	}
+	if _, found := agConsoles["CON9"]; !found {
+		t.Error("Expected the console to stay connected")
+	}
+}
+
+type recordReadT struct {
+	length  int
+	pos     int64 // absolute position (record number for fixed-length records), -1 for none
+	data    string
+	errCode dg.WordT
+}
+
+func TestReadRecords(t *testing.T) {
+	vb := func(blocks ...[]byte) (file []byte) {
+		for _, b := range blocks {
+			file = append(file, b...)
+		}
+		return file
+	}
+	tests := []struct {
+		name    string
+		format  dg.WordT
+		recLen  int
+		content []byte
+		reads   []recordReadT
+	}{
+		{"fixed", rtfx, 4, []byte("ABCDEFGHIJ"), []recordReadT{
+			{0, -1, "ABCD", 0}, {0, -1, "EFGH", 0}, {0, -1, "IJ", ereof}, {0, -1, "", ereof},
+		}},
+		{"fixed by record number", rtfx, 4, []byte("ABCDEFGHIJKL"), []recordReadT{
+			{0, 2, "IJKL", 0}, {0, 0, "ABCD", 0}, {0, -1, "EFGH", 0}, {0, 3, "", ereof},
+		}},
+		{"dynamic", rtdy, 0, []byte("ABCDE"), []recordReadT{
+			{3, -1, "ABC", 0}, {3, -1, "DE", ereof}, {3, 1, "BCD", 0},
+		}},
+		{"undefined", rtun, 0, []byte("ABCDE"), []recordReadT{
+			{4, -1, "ABCD", 0}, {4, -1, "E", ereof}, {4, -1, "", ereof},
+		}},
+		{"data sensitive", rtds, 0, []byte("ONE\nTWO\rLONGLINE\nEND"), []recordReadT{
+			{6, -1, "ONE\n", 0}, {6, -1, "TWO\r", 0}, {6, -1, "LONGLI", erltl}, {6, -1, "NE\n", 0},
+			{6, -1, "END", 0}, {6, -1, "", ereof}, {6, 4, "TWO\r", 0},
+		}},
+		{"variable", rtvr, 0, []byte("0007ABC00040009HELLO0010AB"), []recordReadT{
+			{8, -1, "ABC", 0}, {8, -1, "", 0}, {3, -1, "HEL", erltl}, {8, -1, "AB", ereof}, {8, -1, "", ereof},
+		}},
+		{"variable bad length", rtvr, 0, []byte("00X7ABC"), []recordReadT{
+			{8, -1, "", errfm},
+		}},
+		{"IBM variable block", rtvb, 0, vb(
+			[]byte{0, 20, 0, 0}, []byte{0, 7, 0, 0}, []byte("ABC"), []byte{0, 9, 0, 0}, []byte("HELLO"),
+			[]byte{0, 10, 0, 0}, []byte{0, 6, 0, 0}, []byte("Z"),
+		), []recordReadT{
+			{8, -1, "ABC", 0}, {4, -1, "HELL", erltl}, {8, -1, "", ereof}, {8, 0, "ABC", 0},
+		}},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		fileTestProcess(t, 93, root)
+		if err := os.WriteFile(filepath.Join(root, "DATA"), tt.content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		open := agFileOpen(agOpenReqT{PID: 93, path: "DATA", mode: ofin | tt.format, recLen: tt.recLen})
+		if open.ac0 != 0 {
+			t.Fatalf("%s: expected ?OPEN to succeed, got %#o", tt.name, open.ac0)
+		}
+		for i, rd := range tt.reads {
+			req := agReadReqT{PID: 93, chanNo: int(open.channelNo), length: rd.length, position: rd.pos}
+			if rd.pos >= 0 {
+				req.specs = ipst
+			}
+			resp := agFileRead(req)
+			if string(resp.data) != rd.data || resp.ac0 != rd.errCode {
+				t.Errorf("%s read %d: expected %q %#o, got %q %#o", tt.name, i, rd.data, rd.errCode, resp.data, resp.ac0)
+			}
+		}
+		agFileClose(agCloseReqT{PID: 93, chanNo: int(open.channelNo)})
+	}
+}
+
+func TestWriteRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  dg.WordT
+		recLen  int
+		records []string
+		errCode dg.WordT // of the last write
+		content []byte
+		first   string // the first record read back
+	}{
+		{"fixed", rtfx, 4, []string{"AB", "CDEFGH"}, 0, []byte("AB\x00\x00CDEF"), "AB\x00\x00"},
+		{"dynamic", rtdy, 3, []string{"AB", "CDEF"}, 0, []byte("ABCDE"), "ABC"},
+		{"undefined", rtun, 3, []string{"ABCD"}, 0, []byte("ABC"), "ABC"},
+		{"data sensitive", rtds, 8, []string{"ONE\nXX", "TWO\r"}, 0, []byte("ONE\nTWO\r"), "ONE\n"},
+		{"data sensitive too long", rtds, 4, []string{"ONE\n", "LONGER\n"}, erltl, []byte("ONE\n"), "ONE\n"},
+		{"variable", rtvr, 8, []string{"ABC", ""}, 0, []byte("0007ABC0004"), "ABC"},
+		{"IBM variable block", rtvb, 8, []string{"ABC"}, 0, []byte{0, 11, 0, 0, 0, 7, 0, 0, 'A', 'B', 'C'}, "ABC"},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		fileTestProcess(t, 93, root)
+		open := agFileOpen(agOpenReqT{PID: 93, path: "DATA", mode: ofcr | ofot | tt.format, recLen: tt.recLen})
+		if open.ac0 != 0 {
+			t.Fatalf("%s: expected ?OPEN to succeed, got %#o", tt.name, open.ac0)
+		}
+		var resp agWriteRespT
+		for _, rec := range tt.records {
+			resp = agFileWrite(agWriteReqT{PID: 93, channel: int(open.channelNo), recLen: tt.recLen, bytes: []byte(rec)})
+		}
+		agFileClose(agCloseReqT{PID: 93, chanNo: int(open.channelNo)})
+		if dg.WordT(resp.errCode) != tt.errCode {
+			t.Errorf("%s: expected error %#o, got %#o", tt.name, tt.errCode, resp.errCode)
+		}
+		content, _ := os.ReadFile(filepath.Join(root, "DATA"))
+		if string(content) != string(tt.content) {
+			t.Errorf("%s: expected file %q, got %q", tt.name, tt.content, content)
+		}
+
+		open = agFileOpen(agOpenReqT{PID: 93, path: "DATA", mode: ofin | tt.format, recLen: tt.recLen})
+		if rd := agFileRead(agReadReqT{PID: 93, chanNo: int(open.channelNo), length: tt.recLen}); string(rd.data) != tt.first || rd.ac0 != 0 {
+			t.Errorf("%s: expected to read back %q, got %q %#o", tt.name, tt.first, rd.data, rd.ac0)
+		}
+		agFileClose(agCloseReqT{PID: 93, chanNo: int(open.channelNo)})
+	}
+}
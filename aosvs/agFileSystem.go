@@ -0,0 +1,584 @@
+// +build virtual !physical
+
+// agFileSystem.go - emulation of the AOS/VS file system on top of the host's
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// The host file system cannot hold the AOS/VS attributes of a file, so we keep them in a
+// hidden sidecar file in each host directory, keyed by filename.  Files which were put
+// in place on the host have no entry, sensible attributes are deduced for them.
+// Only the Agent touches the sidecars.
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+const (
+	metaFilename    = ".aosvs_meta.json" // lower-case, so it can never clash with an AOS/VS filename
+	defaultElemSize = 1                  // in disk blocks
+	oware           = faco | facw | faca | facr | face
+)
+
+// aclEntryT is one user template and the access it grants
+type aclEntryT struct {
+	Template string `json:"template"`
+	Access   byte   `json:"access"`
+}
+
+// fileMetaT holds the AOS/VS attributes of a file that the host cannot
+type fileMetaT struct {
+	FileType  int         `json:"type"`
+	RecFormat int         `json:"recFormat"`
+	ElemSize  int         `json:"elemSize"`
+	ACL       []aclEntryT `json:"acl"`
+	UDA       []byte      `json:"uda,omitempty"`
+	Created   time.Time   `json:"created"`
+	Accessed  time.Time   `json:"accessed"`
+	Modified  time.Time   `json:"modified"`
+}
+
+func loadDirMeta(dir string) map[string]*fileMetaT {
+	metas := map[string]*fileMetaT{}
+	js, err := ioutil.ReadFile(filepath.Join(dir, metaFilename))
+	if err != nil {
+		return metas
+	}
+	if err = json.Unmarshal(js, &metas); err != nil {
+		log.Printf("WARNING: Ignoring corrupt file system metadata in %s - %v\n", dir, err)
+	}
+	return metas
+}
+
+func saveDirMeta(dir string, metas map[string]*fileMetaT) {
+	metaPath := filepath.Join(dir, metaFilename)
+	if len(metas) == 0 {
+		os.Remove(metaPath)
+		return
+	}
+	js, _ := json.MarshalIndent(metas, "", " ")
+	if err := ioutil.WriteFile(metaPath, js, 0644); err != nil {
+		log.Printf("WARNING: Could not save file system metadata in %s - %v\n", dir, err)
+	}
+}
+
+// getFileMeta returns the attributes of a host file, or deduces them if we have none
+func getFileMeta(path string, fi os.FileInfo) *fileMetaT {
+	if meta, found := loadDirMeta(filepath.Dir(path))[filepath.Base(path)]; found {
+		return meta
+	}
+	meta := &fileMetaT{
+		FileType:  fudf,
+		RecFormat: rtds,
+		ElemSize:  defaultElemSize,
+		ACL:       []aclEntryT{{"+", oware}},
+		Created:   fi.ModTime(),
+		Accessed:  fi.ModTime(),
+		Modified:  fi.ModTime(),
+	}
+	switch {
+	case fi.IsDir():
+		meta.FileType = fdir
+	case strings.HasSuffix(fi.Name(), ".PR"):
+		meta.FileType = fprv
+	case strings.HasSuffix(fi.Name(), ".CLI"), strings.HasSuffix(fi.Name(), ".F77"), strings.HasSuffix(fi.Name(), ".SR"):
+		meta.FileType = ftxt
+	}
+	return meta
+}
+
+func putFileMeta(path string, meta *fileMetaT) {
+	dir := filepath.Dir(path)
+	metas := loadDirMeta(dir)
+	metas[filepath.Base(path)] = meta
+	saveDirMeta(dir, metas)
+}
+
+func deleteFileMeta(path string) {
+	dir := filepath.Dir(path)
+	metas := loadDirMeta(dir)
+	if _, found := metas[filepath.Base(path)]; found {
+		delete(metas, filepath.Base(path))
+		saveDirMeta(dir, metas)
+	}
+}
+
+// newFileMeta returns the attributes of a newly-created file, with the creator's default ACL
+func newFileMeta(PID dg.WordT, fileType, recFormat int) *fileMetaT {
+	now := time.Now()
+	return &fileMetaT{
+		FileType:  fileType,
+		RecFormat: recFormat,
+		ElemSize:  defaultElemSize,
+		ACL:       PerProcessData[int(PID)].getDefaultACL(),
+		Created:   now,
+		Accessed:  now,
+		Modified:  now,
+	}
+}
+
+// getDefaultACL returns the ACL given to new files, empty if the process has turned it off
+func (ppd *PerProcessDataT) getDefaultACL() []aclEntryT {
+	if ppd.defaultACLOff {
+		return nil
+	}
+	if ppd.defaultACL == nil {
+		return []aclEntryT{{ppd.username, oware}}
+	}
+	return append([]aclEntryT(nil), ppd.defaultACL...)
+}
+
+// encodeACL returns the AOS/VS form of an ACL: each template is followed by a NUL and
+// the access byte, the list is terminated by a further NUL
+func encodeACL(acl []aclEntryT) (ba []byte) {
+	for _, e := range acl {
+		ba = append(ba, []byte(e.Template)...)
+		ba = append(ba, 0, e.Access)
+	}
+	return append(ba, 0)
+}
+
+// decodeACL converts an AOS/VS ACL into our form, ok is false if it is malformed
+func decodeACL(ba []byte) (acl []aclEntryT, ok bool) {
+	for len(ba) > 0 && ba[0] != 0 {
+		nul := strings.IndexByte(string(ba), 0)
+		if nul == -1 || nul+1 >= len(ba) || nul > mxun {
+			return nil, false
+		}
+		acl = append(acl, aclEntryT{strings.ToUpper(string(ba[:nul])), ba[nul+1] & oware})
+		ba = ba[nul+2:]
+	}
+	if len(ba) == 0 {
+		return nil, false // no terminator
+	}
+	return acl, true
+}
+
+// aclAccess returns the access granted to a user, the first matching template wins
+func aclAccess(acl []aclEntryT, username string) byte {
+	for _, e := range acl {
+		if matchTemplate(e.Template, username) {
+			return e.Access
+		}
+	}
+	return 0
+}
+
+// matchTemplate matches a name against an AOS/VS template where + matches any string
+// (including the empty one), * matches any single character and - matches any string without a period
+func matchTemplate(template, name string) bool {
+	if template == "" {
+		return name == ""
+	}
+	switch template[0] {
+	case '+':
+		for i := 0; i <= len(name); i++ {
+			if matchTemplate(template[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	case '-':
+		for i := 0; i <= len(name); i++ {
+			if matchTemplate(template[1:], name[i:]) {
+				return true
+			}
+			if i < len(name) && name[i] == '.' {
+				return false
+			}
+		}
+		return false
+	case '*':
+		return name != "" && matchTemplate(template[1:], name[1:])
+	default:
+		return name != "" && template[0] == name[0] && matchTemplate(template[1:], name[1:])
+	}
+}
+
+// aosvsTime returns a date as days since 31st Dec 1967 and a time as seconds since midnight / 2
+func aosvsTime(t time.Time) (date, tm dg.WordT) {
+	t = t.Local()
+	epoch := time.Date(1967, 12, 31, 0, 0, 0, 0, time.Local)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	date = dg.WordT(midnight.Sub(epoch).Hours()/24 + 0.5)
+	tm = dg.WordT(t.Sub(midnight).Seconds() / 2)
+	return date, tm
+}
+
+// goTime is the inverse of aosvsTime
+func goTime(date, tm dg.WordT) time.Time {
+	return time.Date(1967, 12, 31+int(date), 0, 0, 2*int(tm), 0, time.Local)
+}
+
+type agCreateReqT struct {
+	PID                 dg.WordT
+	aosFilename         string
+	fileType, recFormat int
+	elemSize            int
+	times               []time.Time // created, accessed, modified - nil for now
+	acl                 []aclEntryT
+	defaultACL          bool
+}
+type agCreateRespT struct {
+	errCode dg.WordT
+}
+
+func agCreate(req agCreateReqT) (resp agCreateRespT) {
+	path, errCode := agResolvePath(req.PID, req.aosFilename)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	if _, err := os.Lstat(path); err == nil {
+		resp.errCode = ernae
+		return resp
+	}
+	if fi, err := os.Stat(filepath.Dir(path)); err != nil {
+		resp.errCode = erdde
+		return resp
+	} else if !fi.IsDir() {
+		resp.errCode = ernad
+		return resp
+	}
+	switch {
+	case req.fileType >= ldir && req.fileType <= hcpd:
+		if err := os.Mkdir(path, 0755); err != nil {
+			resp.errCode = erdad
+			return resp
+		}
+	default:
+		fp, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			resp.errCode = erdad
+			return resp
+		}
+		fp.Close()
+	}
+	meta := newFileMeta(req.PID, req.fileType, req.recFormat)
+	if req.elemSize > 0 {
+		meta.ElemSize = req.elemSize
+	}
+	if !req.defaultACL {
+		meta.ACL = req.acl
+	}
+	if req.times != nil {
+		meta.Created, meta.Accessed, meta.Modified = req.times[0], req.times[1], req.times[2]
+	}
+	putFileMeta(path, meta)
+	logging.DebugPrint(logging.ScLog, "\tCreated %s with type %d.\n", path, req.fileType)
+	return resp
+}
+
+type agDeleteReqT struct {
+	PID         dg.WordT
+	aosFilename string
+}
+type agDeleteRespT struct {
+	errCode dg.WordT
+}
+
+// agDelete handles ?DELETE, the caller needs Write access to the parent directory and
+// a directory must be empty
+func agDelete(req agDeleteReqT) (resp agDeleteRespT) {
+	path, errCode := agResolvePath(req.PID, req.aosFilename)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		resp.errCode = erfde
+		return resp
+	}
+	parent := filepath.Dir(path)
+	if pfi, err := os.Stat(parent); err == nil {
+		if PerProcessData[int(req.PID)].access(getFileMeta(parent, pfi).ACL)&facw == 0 {
+			resp.errCode = erwad
+			return resp
+		}
+	}
+	if fi.IsDir() {
+		if names, _ := listDir(path); len(names) > 0 {
+			resp.errCode = erdid
+			return resp
+		}
+		os.Remove(filepath.Join(path, metaFilename))
+	}
+	if err = os.Remove(path); err != nil {
+		if fi.IsDir() {
+			resp.errCode = erdid
+		} else {
+			resp.errCode = erwad
+		}
+		return resp
+	}
+	deleteFileMeta(path)
+	return resp
+}
+
+// listDir returns the sorted AOS/VS filenames in a host directory
+func listDir(dir string) (names []string, err error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		if fi.Name() != metaFilename {
+			names = append(names, strings.ToUpper(fi.Name())) // host files may be in any case
+		}
+	}
+	return names, nil
+}
+
+type agDirOpenReqT struct {
+	PID         dg.WordT
+	aosFilename string
+}
+type agDirOpenRespT struct {
+	chanNo  int
+	errCode dg.WordT
+}
+
+// agDirOpen handles ?GOPEN on a directory, the channel may only be used for ?GNFN and ?FSTAT
+func agDirOpen(req agDirOpenReqT) (resp agDirOpenRespT) {
+	path, errCode := agResolvePath(req.PID, req.aosFilename)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		resp.errCode = erdde
+		return resp
+	}
+	if !fi.IsDir() {
+		resp.errCode = erndr
+		return resp
+	}
+	if PerProcessData[int(req.PID)].access(getFileMeta(path, fi).ACL)&facr == 0 {
+		resp.errCode = erdad
+		return resp
+	}
+	resp.chanNo = PerProcessData[int(req.PID)].allocChannel(&agChannelT{path: path, isDirectory: true})
+	return resp
+}
+
+type agNextFilenameReqT struct {
+	PID      dg.WordT
+	chanNo   int
+	template string // empty for all files
+	key      int    // index of the next name to consider
+}
+type agNextFilenameRespT struct {
+	filename string
+	nextKey  int
+	errCode  dg.WordT
+}
+
+// agNextFilename handles ?GNFN, it returns the next name in the directory matching the template
+func agNextFilename(req agNextFilenameReqT) (resp agNextFilenameRespT) {
+	agChan, isOpen := findChannel(req.PID, req.chanNo)
+	if !isOpen {
+		resp.errCode = erfno
+		return resp
+	}
+	if !agChan.isDirectory {
+		resp.errCode = erndr
+		return resp
+	}
+	names, err := listDir(agChan.path)
+	if err != nil {
+		resp.errCode = erdde
+		return resp
+	}
+	for ix := req.key; ix < len(names); ix++ {
+		if req.template == "" || matchTemplate(req.template, names[ix]) {
+			resp.filename = names[ix]
+			resp.nextKey = ix + 1
+			return resp
+		}
+	}
+	resp.errCode = erfde // no more files
+	return resp
+}
+
+type agFileStatusReqT struct {
+	PID         dg.WordT
+	aosFilename string
+	chanNo      int // used if aosFilename is empty
+}
+type agFileStatusRespT struct {
+	meta    fileMetaT
+	length  int64
+	openCnt int
+	errCode dg.WordT
+}
+
+func agFileStatus(req agFileStatusReqT) (resp agFileStatusRespT) {
+	var path string
+	if req.aosFilename == "" {
+		agChan, isOpen := findChannel(req.PID, req.chanNo)
+		if !isOpen || agChan.isConsole {
+			resp.errCode = eracu
+			return resp
+		}
+		path = agChan.path
+	} else {
+		if path, resp.errCode = agResolvePath(req.PID, req.aosFilename); resp.errCode != 0 {
+			return resp
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		resp.errCode = erfde
+		return resp
+	}
+	resp.meta = *getFileMeta(path, fi)
+	if fi.ModTime().After(resp.meta.Modified) {
+		resp.meta.Modified = fi.ModTime() // written since we last looked
+	}
+	if resp.meta.Modified.After(resp.meta.Accessed) {
+		resp.meta.Accessed = resp.meta.Modified
+	}
+	resp.length = fi.Size()
+	for _, ppd := range PerProcessData {
+		for _, agChan := range ppd.channels {
+			if agChan.path == path {
+				resp.openCnt++
+			}
+		}
+	}
+	return resp
+}
+
+type agACLReqT struct {
+	PID         dg.WordT
+	aosFilename string
+	set         bool
+	acl         []aclEntryT
+}
+type agACLRespT struct {
+	acl     []aclEntryT
+	errCode dg.WordT
+}
+
+// agACL handles ?GACL and ?SACL, only a user with Owner access may change an ACL
+func agACL(req agACLReqT) (resp agACLRespT) {
+	path, errCode := agResolvePath(req.PID, req.aosFilename)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		resp.errCode = erfde
+		return resp
+	}
+	meta := getFileMeta(path, fi)
+	if req.set {
+		if PerProcessData[int(req.PID)].access(meta.ACL)&faco == 0 {
+			resp.errCode = erfad
+			return resp
+		}
+		meta.ACL = req.acl
+		putFileMeta(path, meta)
+	}
+	resp.acl = meta.ACL
+	return resp
+}
+
+type agDefaultACLReqT struct {
+	PID        dg.WordT
+	set, unset bool
+	acl        []aclEntryT
+}
+type agDefaultACLRespT struct {
+	acl []aclEntryT
+}
+
+// agDefaultACL handles ?DACL, it always returns the previous default ACL
+func agDefaultACL(req agDefaultACLReqT) (resp agDefaultACLRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	resp.acl = ppd.getDefaultACL()
+	switch {
+	case req.set:
+		ppd.defaultACL = req.acl
+		ppd.defaultACLOff = false
+	case req.unset:
+		ppd.defaultACLOff = true
+	}
+	return resp
+}
+
+type agRenameReqT struct {
+	PID              dg.WordT
+	oldName, newName string
+}
+type agRenameRespT struct {
+	errCode dg.WordT
+}
+
+// agRename handles ?RENAME, a simple new filename stays in the same directory
+func agRename(req agRenameReqT) (resp agRenameRespT) {
+	oldPath, errCode := agResolvePath(req.PID, req.oldName)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	fi, err := os.Lstat(oldPath)
+	if err != nil {
+		resp.errCode = erfde
+		return resp
+	}
+	var newPath string
+	if isSimpleFilename(req.newName) {
+		if errCode = legalFilename(req.newName); errCode == 0 {
+			newPath = filepath.Join(filepath.Dir(oldPath), req.newName)
+		}
+	} else {
+		newPath, errCode = agResolvePath(req.PID, req.newName)
+	}
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	if _, err = os.Lstat(newPath); err == nil {
+		resp.errCode = ernae
+		return resp
+	}
+	meta := getFileMeta(oldPath, fi)
+	if err = os.Rename(oldPath, newPath); err != nil {
+		resp.errCode = erwad
+		return resp
+	}
+	deleteFileMeta(oldPath)
+	putFileMeta(newPath, meta)
+	return resp
+}
@@ -0,0 +1,144 @@
+// +build virtual !physical
+
+// agFileSystem_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestACLChecks(t *testing.T) {
+	root := t.TempDir()
+	owner := fileTestProcess(t, 93, root)
+	owner.username = "OWNER"
+	other := fileTestProcess(t, 94, root)
+	other.username = "OTHER"
+
+	if resp := agCreate(agCreateReqT{PID: 93, aosFilename: "PRIVATE", fileType: fudf, recFormat: rtds, defaultACL: true}); resp.errCode != 0 {
+		t.Fatalf("Expected ?CREATE to succeed, got %#o", resp.errCode)
+	}
+	if resp := agACL(agACLReqT{PID: 93, aosFilename: "PRIVATE"}); len(resp.acl) != 1 || resp.acl[0] != (aclEntryT{"OWNER", oware}) {
+		t.Errorf("Expected the creator's default ACL, got %v", resp.acl)
+	}
+	if resp := agFileOpen(agOpenReqT{PID: 94, path: "PRIVATE", mode: ofin | rtds}); resp.ac0 != erfad {
+		t.Errorf("Expected ?OPEN without Read access to fail with ERFAD, got %#o", resp.ac0)
+	}
+	if resp := agACL(agACLReqT{PID: 94, aosFilename: "PRIVATE", set: true, acl: []aclEntryT{{"+", oware}}}); resp.errCode != erfad {
+		t.Errorf("Expected ?SACL without Owner access to fail with ERFAD, got %#o", resp.errCode)
+	}
+
+	// first matching template wins
+	acl := []aclEntryT{{"OTH-", facr}, {"+", oware}}
+	if resp := agACL(agACLReqT{PID: 93, aosFilename: "PRIVATE", set: true, acl: acl}); resp.errCode != 0 {
+		t.Fatalf("Expected the owner's ?SACL to succeed, got %#o", resp.errCode)
+	}
+	open := agFileOpen(agOpenReqT{PID: 94, path: "PRIVATE", mode: ofin | rtds})
+	if open.ac0 != 0 {
+		t.Errorf("Expected ?OPEN for reading to succeed, got %#o", open.ac0)
+	} else {
+		agFileClose(agCloseReqT{PID: 94, chanNo: int(open.channelNo)})
+	}
+	if resp := agFileOpen(agOpenReqT{PID: 94, path: "PRIVATE", mode: ofio | rtds}); resp.ac0 != erfad {
+		t.Errorf("Expected ?OPEN for writing with only Read access to fail with ERFAD, got %#o", resp.ac0)
+	}
+
+	// deletion needs Write access to the directory
+	if resp := agCreate(agCreateReqT{PID: 93, aosFilename: "DIR", fileType: fdir, acl: []aclEntryT{{"OWNER", oware}}}); resp.errCode != 0 {
+		t.Fatalf("Expected ?CREATE of a directory to succeed, got %#o", resp.errCode)
+	}
+	if resp := agCreate(agCreateReqT{PID: 93, aosFilename: "DIR:FILE", fileType: fudf, recFormat: rtds}); resp.errCode != 0 {
+		t.Fatalf("Expected ?CREATE in the directory to succeed, got %#o", resp.errCode)
+	}
+	if resp := agDelete(agDeleteReqT{PID: 94, aosFilename: "DIR:FILE"}); resp.errCode != erwad {
+		t.Errorf("Expected ?DELETE without Write access to the directory to fail with ERWAD, got %#o", resp.errCode)
+	}
+	if resp := agDirOpen(agDirOpenReqT{PID: 94, aosFilename: "DIR"}); resp.errCode != erdad {
+		t.Errorf("Expected ?GOPEN without Read access to the directory to fail with ERDAD, got %#o", resp.errCode)
+	}
+	other.privModes[sysprvSuser] = privModeOn
+	if resp := agDelete(agDeleteReqT{PID: 94, aosFilename: "DIR:FILE"}); resp.errCode != 0 {
+		t.Errorf("Expected a Superuser's ?DELETE to succeed, got %#o", resp.errCode)
+	}
+}
+
+func TestUDARoundTrip(t *testing.T) {
+	root := t.TempDir()
+	fileTestProcess(t, 93, root)
+	if resp := agCreate(agCreateReqT{PID: 93, aosFilename: "UDAFILE", fileType: fudf, recFormat: rtds, defaultACL: true}); resp.errCode != 0 {
+		t.Fatalf("Expected ?CREATE to succeed, got %#o", resp.errCode)
+	}
+	path := filepath.Join(root, "UDAFILE")
+	fi, _ := os.Stat(path)
+	uda := make([]byte, 512)
+	for i := range uda {
+		uda[i] = byte(i)
+	}
+	meta := getFileMeta(path, fi)
+	meta.UDA = uda
+	putFileMeta(path, meta)
+
+	if resp := agFileStatus(agFileStatusReqT{PID: 93, aosFilename: "UDAFILE"}); !bytes.Equal(resp.meta.UDA, uda) {
+		t.Errorf("Expected the UDA to be read back, got %d bytes", len(resp.meta.UDA))
+	}
+	if resp := agRename(agRenameReqT{PID: 93, oldName: "UDAFILE", newName: "NEWNAME"}); resp.errCode != 0 {
+		t.Fatalf("Expected ?RENAME to succeed, got %#o", resp.errCode)
+	}
+	resp := agFileStatus(agFileStatusReqT{PID: 93, aosFilename: "NEWNAME"})
+	if !bytes.Equal(resp.meta.UDA, uda) {
+		t.Errorf("Expected the UDA to follow a renamed file, got %d bytes", len(resp.meta.UDA))
+	}
+	if resp.meta.FileType != fudf || resp.meta.RecFormat != rtds {
+		t.Errorf("Expected the file type and record format to be kept, got %d %d", resp.meta.FileType, resp.meta.RecFormat)
+	}
+	if resp := agDelete(agDeleteReqT{PID: 93, aosFilename: "NEWNAME"}); resp.errCode != 0 {
+		t.Fatalf("Expected ?DELETE to succeed, got %#o", resp.errCode)
+	}
+	if _, err := os.Stat(filepath.Join(root, metaFilename)); !os.IsNotExist(err) {
+		t.Error("Expected the metadata of the last file to be removed with it")
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	tests := []struct {
+		template, name string
+		want           bool
+	}{
+		{"+", "", true},
+		{"+", "ANY.NAME", true},
+		{"OP", "OP", true},
+		{"OP", "OPER", false},
+		{"OP*", "OPS", true},
+		{"OP*", "OP", false},
+		{"-.PR", "CLI.PR", true},
+		{"-.PR", "A.B.PR", false},
+		{"+.PR", "A.B.PR", true},
+	}
+	for _, test := range tests {
+		if got := matchTemplate(test.template, test.name); got != test.want {
+			t.Errorf("matchTemplate(%q, %q) - expected %v, got %v", test.template, test.name, test.want, got)
+		}
+	}
+}
@@ -28,15 +28,98 @@ import (
 	"github.com/SMerrony/dgemug/logging"
 )
 
+// Each process has its own set of local ports, a port is known to other processes by a
+// global port number which also identifies the owner's PID and ring.  Messages sent to a
+// process that is not waiting for them are spooled by the Agent until received.
+
+// ipcMsgT is a message in transit
+type ipcMsgT struct {
+	sysFlags, usrFlags dg.WordT
+	origin             dg.DwordT // global port of the sender
+	destLocal          dg.WordT
+	connBits           dg.WordT // connection obituaries only, returned in ?IPTL
+	words              []dg.WordT
+}
+
+// ipcRecvT is what a waiting receiver is woken with
+type ipcRecvT struct {
+	msg     ipcMsgT
+	errCode dg.WordT
+}
+
+// ipcWaiterT is a task blocked in ?IREC
+type ipcWaiterT struct {
+	localPort     dg.WordT  // zero for any
+	origin        dg.DwordT // zero for any
+	bufLen        int
+	spoolOverflow bool // leave an over-long message spooled
+	ch            chan ipcRecvT
+}
+
+// globalPort forms the global port number of a process's local port
+func globalPort(PID dg.WordT, ring int, localPort dg.WordT) dg.DwordT {
+	return dg.DwordT(ring&7)<<28 | dg.DwordT(PID&0xffff)<<12 | dg.DwordT(localPort&mxlpn)
+}
+
+// splitGlobalPort is the inverse of globalPort
+func splitGlobalPort(gp dg.DwordT) (PID dg.WordT, ring int, localPort dg.WordT) {
+	return dg.WordT(gp>>12) & 0xffff, int(gp>>28) & 7, dg.WordT(gp & mxlpn)
+}
+
+// obituary builds a termination message about PID for the ?SPTM port of its father or
+// of the other party to a connection
+func obituary(PID dg.WordT, ring int, connBits dg.WordT, words []dg.WordT) ipcMsgT {
+	return ipcMsgT{
+		sysFlags:  1 << (15 - ibdth),
+		origin:    globalPort(PID, ring, 0),
+		destLocal: sptm,
+		connBits:  connBits,
+		words:     words,
+	}
+}
+
+func (w *ipcWaiterT) matches(msg ipcMsgT) bool {
+	return (w.localPort == 0 || w.localPort == msg.destLocal) && (w.origin == 0 || w.origin == msg.origin)
+}
+
+// deliverIPC gives a message to a waiting task of the destination process, or spools it
+func (ppd *PerProcessDataT) deliverIPC(msg ipcMsgT, noSpool bool) (errCode dg.WordT) {
+	for i, w := range ppd.ipcWaiters {
+		if !w.matches(msg) {
+			continue
+		}
+		ppd.ipcWaiters = append(ppd.ipcWaiters[:i], ppd.ipcWaiters[i+1:]...)
+		if len(msg.words) > w.bufLen && w.spoolOverflow {
+			ppd.ipcSpool = append(ppd.ipcSpool, msg)
+			w.ch <- ipcRecvT{msg: msg, errCode: ernef}
+			return 0
+		}
+		w.ch <- ipcRecvT{msg: msg}
+		return 0
+	}
+	if noSpool {
+		return ernrr
+	}
+	ppd.ipcSpool = append(ppd.ipcSpool, msg)
+	return 0
+}
+
 type agCreateIPCReqT struct {
 	PID         dg.WordT
+	ring        int
 	filename    string
 	localPortNo int
 	ACL         string
 }
 
 func agCreateIPC(req agCreateIPCReqT) (errCode dg.WordT) {
-	path := PerProcessData[int(req.PID)].virtualRoot + "/" + req.filename
+	if req.localPortNo < 1 || req.localPortNo > imprt {
+		return erivp
+	}
+	path, errCode := PerProcessData[int(req.PID)].completePathname(req.filename)
+	if errCode != 0 {
+		return errCode
+	}
 	if _, found := agIPCs[path]; found {
 		logging.DebugPrint(logging.ScLog, "\t?CREATE called for extant IPC file %s\n", path)
 		errCode = ernae
@@ -45,7 +128,7 @@ func agCreateIPC(req agCreateIPCReqT) (errCode dg.WordT) {
 		agIPC.ownerPID = req.PID
 		agIPC.localPortNo = req.localPortNo
 		agIPC.name = req.filename
-		agIPC.globalPortNo = int(req.PID)<<16 | req.localPortNo // TODO should really include ring #
+		agIPC.globalPortNo = int(globalPort(req.PID, req.ring, dg.WordT(req.localPortNo)))
 		agIPCs[path] = &agIPC
 		logging.DebugPrint(logging.ScLog, "\t?CREATEd virtual IPC file %s\n", path)
 	}
@@ -63,7 +146,11 @@ type agIlkupRespT struct {
 }
 
 func agIlkup(req agIlkupReqT) (resp agIlkupRespT) {
-	path := PerProcessData[int(req.PID)].virtualRoot + "/" + req.filename
+	path, errCode := PerProcessData[int(req.PID)].completePathname(req.filename)
+	if errCode != 0 {
+		resp.errCode = int(errCode)
+		return resp
+	}
 	agIPC, found := agIPCs[path]
 	logging.DebugPrint(logging.ScLog, "\tChecking for virtual IPC %s\n", path)
 	if !found {
@@ -76,3 +163,107 @@ func agIlkup(req agIlkupReqT) (resp agIlkupRespT) {
 	}
 	return resp
 }
+
+type agIsendReqT struct {
+	PID     dg.WordT
+	ring    int
+	dest    dg.DwordT // global port
+	origin  dg.WordT  // local port
+	noSpool bool
+	msg     ipcMsgT
+}
+type agIsendRespT struct {
+	errCode dg.WordT
+}
+
+// agIsend handles ?ISEND
+func agIsend(req agIsendReqT) (resp agIsendRespT) {
+	if req.origin > mxlpn {
+		resp.errCode = eriop
+		return resp
+	}
+	destPID, _, destLocal := splitGlobalPort(req.dest)
+	ppd, found := PerProcessData[int(destPID)]
+	if !found || destLocal == 0 || ppd.terminating {
+		resp.errCode = eridp
+		return resp
+	}
+	req.msg.origin = globalPort(req.PID, req.ring, req.origin)
+	req.msg.destLocal = destLocal
+	req.msg.sysFlags = req.msg.sysFlags&^7 | dg.WordT(req.ring&7) // ?IFRING
+	resp.errCode = ppd.deliverIPC(req.msg, req.noSpool)
+	logging.DebugPrint(logging.ScLog, "\tIPC from %#o to %#o (%d. words) - error code %#o\n",
+		req.msg.origin, req.dest, len(req.msg.words), resp.errCode)
+	return resp
+}
+
+type agIrecReqT struct {
+	PID    dg.WordT
+	waiter ipcWaiterT
+	noWait bool
+}
+type agIrecRespT struct {
+	recv   ipcRecvT
+	waiter *ipcWaiterT // non-nil if we must wait for a message
+}
+
+// agIrec handles ?IREC, returning a spooled message if there is one
+func agIrec(req agIrecReqT) (resp agIrecRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	for i, msg := range ppd.ipcSpool {
+		if !req.waiter.matches(msg) {
+			continue
+		}
+		if len(msg.words) > req.waiter.bufLen {
+			resp.recv.errCode = ernef
+			if req.waiter.spoolOverflow {
+				resp.recv.msg = msg // only the header is returned
+				return resp
+			}
+		}
+		ppd.ipcSpool = append(ppd.ipcSpool[:i], ppd.ipcSpool[i+1:]...)
+		resp.recv.msg = msg
+		return resp
+	}
+	if req.noWait {
+		resp.recv.errCode = ernmw
+		return resp
+	}
+	resp.waiter = &req.waiter
+	resp.waiter.ch = make(chan ipcRecvT, 1)
+	ppd.ipcWaiters = append(ppd.ipcWaiters, resp.waiter)
+	return resp
+}
+
+type agIrecCancelReqT struct {
+	PID    dg.WordT
+	waiter *ipcWaiterT
+}
+type agIrecCancelRespT struct {
+	ok bool
+}
+
+// agIrecCancel withdraws a receive that is no longer waiting
+func agIrecCancel(req agIrecCancelReqT) (resp agIrecCancelRespT) {
+	ppd, found := PerProcessData[int(req.PID)]
+	if !found {
+		return resp
+	}
+	for i, w := range ppd.ipcWaiters {
+		if w == req.waiter {
+			ppd.ipcWaiters = append(ppd.ipcWaiters[:i], ppd.ipcWaiters[i+1:]...)
+			resp.ok = true
+			break
+		}
+	}
+	return resp
+}
+
+// releaseIPCs forgets the ports of a process that has gone
+func releaseIPCs(PID dg.WordT) {
+	for path, agIPC := range agIPCs {
+		if agIPC.ownerPID == PID {
+			delete(agIPCs, path)
+		}
+	}
+}
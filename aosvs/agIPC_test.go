@@ -0,0 +1,117 @@
+// +build virtual !physical
+
+// agIPC_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// ipcTestProcess registers a process which can send and receive IPC messages
+func ipcTestProcess(t *testing.T, PID dg.WordT) *PerProcessDataT {
+	ppd := &PerProcessDataT{ring: 7}
+	PerProcessData[int(PID)] = ppd
+	t.Cleanup(func() { delete(PerProcessData, int(PID)) })
+	return ppd
+}
+
+func isendTo(PID dg.WordT, destPID, destLocal, word dg.WordT) dg.WordT {
+	return agIsend(agIsendReqT{PID: PID, ring: 7, dest: globalPort(destPID, 7, destLocal), origin: 1,
+		msg: ipcMsgT{words: []dg.WordT{word}}}).errCode
+}
+
+func TestIsendIrecByPort(t *testing.T) {
+	ipcTestProcess(t, 95)
+	receiver := ipcTestProcess(t, 96)
+
+	// a task waiting on port 6 is not woken by a message for port 5
+	waiting := agIrec(agIrecReqT{PID: 96, waiter: ipcWaiterT{localPort: 6, bufLen: 1}})
+	if waiting.waiter == nil {
+		t.Fatal("Expected ?IREC to wait with nothing spooled")
+	}
+	if errCode := isendTo(95, 96, 5, 55); errCode != 0 {
+		t.Fatalf("Expected ?ISEND to succeed, got %#o", errCode)
+	}
+	select {
+	case recv := <-waiting.waiter.ch:
+		t.Fatalf("Expected the port 6 receiver to keep waiting, got %v", recv.msg.words)
+	default:
+	}
+	if len(receiver.ipcSpool) != 1 {
+		t.Fatalf("Expected the port 5 message to be spooled, %d are", len(receiver.ipcSpool))
+	}
+	isendTo(95, 96, 6, 66)
+	recv := <-waiting.waiter.ch
+	if recv.errCode != 0 || recv.msg.words[0] != 66 || recv.msg.destLocal != 6 {
+		t.Errorf("Expected the port 6 message, got %v for port %d (%#o)", recv.msg.words, recv.msg.destLocal, recv.errCode)
+	}
+	if recv.msg.origin != globalPort(95, 7, 1) {
+		t.Errorf("Expected the sender's global port, got %#x", recv.msg.origin)
+	}
+
+	// spooled messages are collected by port, and by origin
+	if resp := agIrec(agIrecReqT{PID: 96, waiter: ipcWaiterT{localPort: 7, bufLen: 1}, noWait: true}); resp.recv.errCode != ernmw {
+		t.Errorf("Expected ERNMW for an empty port, got %#o", resp.recv.errCode)
+	}
+	isendTo(95, 96, 7, 77)
+	if resp := agIrec(agIrecReqT{PID: 96, waiter: ipcWaiterT{origin: globalPort(94, 7, 1), bufLen: 1}, noWait: true}); resp.recv.errCode != ernmw {
+		t.Errorf("Expected ERNMW for a different origin, got %#o", resp.recv.errCode)
+	}
+	resp := agIrec(agIrecReqT{PID: 96, waiter: ipcWaiterT{localPort: 7, bufLen: 1}, noWait: true})
+	if resp.recv.errCode != 0 || resp.recv.msg.words[0] != 77 {
+		t.Errorf("Expected the port 7 message, got %v (%#o)", resp.recv.msg.words, resp.recv.errCode)
+	}
+	resp = agIrec(agIrecReqT{PID: 96, waiter: ipcWaiterT{origin: globalPort(95, 7, 1), bufLen: 1}, noWait: true})
+	if resp.recv.errCode != 0 || resp.recv.msg.words[0] != 55 {
+		t.Errorf("Expected the spooled port 5 message, got %v (%#o)", resp.recv.msg.words, resp.recv.errCode)
+	}
+	if len(receiver.ipcSpool) != 0 {
+		t.Errorf("Expected the spool to be empty, %d messages remain", len(receiver.ipcSpool))
+	}
+}
+
+func TestIsendErrors(t *testing.T) {
+	ipcTestProcess(t, 95)
+	ipcTestProcess(t, 96)
+	if errCode := isendTo(95, 97, 5, 1); errCode != eridp {
+		t.Errorf("Expected ERIDP for a non-existent process, got %#o", errCode)
+	}
+	if errCode := isendTo(95, 96, 0, 1); errCode != eridp {
+		t.Errorf("Expected ERIDP for local port zero, got %#o", errCode)
+	}
+	noSpool := agIsend(agIsendReqT{PID: 95, ring: 7, dest: globalPort(96, 7, 5), origin: 1, noSpool: true,
+		msg: ipcMsgT{words: []dg.WordT{1}}})
+	if noSpool.errCode != ernrr {
+		t.Errorf("Expected ERNRR for an unspooled message with no receiver, got %#o", noSpool.errCode)
+	}
+
+	// an over-long message may be left spooled for a larger buffer
+	isendTo(95, 96, 5, 1)
+	PerProcessData[96].ipcSpool[0].words = make([]dg.WordT, 4)
+	resp := agIrec(agIrecReqT{PID: 96, waiter: ipcWaiterT{localPort: 5, bufLen: 2, spoolOverflow: true}, noWait: true})
+	if resp.recv.errCode != ernef || len(PerProcessData[96].ipcSpool) != 1 {
+		t.Errorf("Expected ERNEF with the message left spooled, got %#o", resp.recv.errCode)
+	}
+}
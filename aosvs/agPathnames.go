@@ -0,0 +1,366 @@
+// +build virtual !physical
+
+// agPathnames.go - the AOS/VS namespace, working directories and searchlists
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// Every process sees a single AOS/VS namespace whose root directory ":" is a host
+// directory (the process's virtualRoot).  Pathnames are resolved entirely within
+// it - each filename is checked so that nothing can refer to a host file outside it.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+const rootDir = ":"
+
+// defaultSearchList is given to processes created by the emulator itself
+var defaultSearchList = []string{":UTIL", rootDir}
+
+// splitPathname returns the filenames in a complete pathname
+func splitPathname(full string) []string {
+	if len(full) <= len(rootDir) {
+		return nil
+	}
+	return strings.Split(full[1:], ":")
+}
+
+// joinPathname is the inverse of splitPathname
+func joinPathname(names []string) string {
+	return rootDir + strings.Join(names, ":")
+}
+
+// legalFilename checks that a name is a valid AOS/VS filename
+func legalFilename(name string) dg.WordT {
+	if name == "" || name == "." || name == ".." {
+		return erifc
+	}
+	if len(name) >= mxfn {
+		return erftl
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '$', c == '.', c == '?', c == '_':
+		default:
+			return erifc
+		}
+	}
+	return 0
+}
+
+// completePathname converts any pathname into a complete one, starting with ':', relative
+// names start from the process's working directory
+func (ppd *PerProcessDataT) completePathname(aosPath string) (full string, errCode dg.WordT) {
+	if aosPath == "" {
+		return "", erifc
+	}
+	if len(aosPath) >= mxpl {
+		return "", erftl
+	}
+	var names []string
+	switch aosPath[0] {
+	case ':':
+		aosPath = aosPath[1:]
+	case '@':
+		names = []string{"PER"}
+		aosPath = aosPath[1:]
+	case '=':
+		names = splitPathname(ppd.workingDir)
+		aosPath = aosPath[1:]
+	case '^':
+		names = splitPathname(ppd.workingDir)
+		for ; aosPath != "" && aosPath[0] == '^'; aosPath = aosPath[1:] {
+			if len(names) == 0 {
+				return "", erdde // cannot go above the root
+			}
+			names = names[:len(names)-1]
+		}
+	default:
+		names = splitPathname(ppd.workingDir)
+	}
+	aosPath = strings.TrimPrefix(aosPath, ":") // e.g. "=:FOO" or "^:FOO"
+	if aosPath != "" {
+		for _, name := range strings.Split(aosPath, ":") {
+			if errCode = legalFilename(name); errCode != 0 {
+				return "", errCode
+			}
+			names = append(names, strings.ToUpper(name))
+		}
+	}
+	return joinPathname(names), 0
+}
+
+// hostPath returns the host file for a complete pathname, refusing any symbolic link
+// that leads outside the namespace
+func (ppd *PerProcessDataT) hostPath(full string) (path string, errCode dg.WordT) {
+	root, err := filepath.Abs(ppd.virtualRoot)
+	if err != nil {
+		return "", erdde
+	}
+	path = root
+	for _, name := range splitPathname(full) {
+		path = filepath.Join(path, hostName(path, name))
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", erdde
+	}
+	// check the deepest part of the path that exists
+	for existing := path; ; existing = filepath.Dir(existing) {
+		real, err := filepath.EvalSymlinks(existing)
+		if err != nil {
+			if existing == root {
+				return "", erdde
+			}
+			continue
+		}
+		if real != realRoot && !strings.HasPrefix(real, realRoot+string(filepath.Separator)) {
+			logging.DebugPrint(logging.ScLog, "\tRefusing %s as it leads outside the namespace to %s\n", full, real)
+			return "", erfad
+		}
+		break
+	}
+	return path, 0
+}
+
+// hostName returns the entry of a host directory which matches an AOS/VS filename
+// regardless of case, or the filename itself if there is none
+func hostName(dir, name string) string {
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		return name
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return name
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return entry.Name()
+		}
+	}
+	return name
+}
+
+// agResolvePath converts an AOS/VS pathname into a host one
+func agResolvePath(PID dg.WordT, aosPath string) (path string, errCode dg.WordT) {
+	path, _, errCode = agResolvePathname(PID, aosPath)
+	return path, errCode
+}
+
+// agResolvePathname returns both the host and the complete AOS/VS pathnames for a file
+func agResolvePathname(PID dg.WordT, aosPath string) (path, full string, errCode dg.WordT) {
+	ppd := PerProcessData[int(PID)]
+	if full, errCode = ppd.completePathname(aosPath); errCode != 0 {
+		return "", "", errCode
+	}
+	path, errCode = ppd.hostPath(full)
+	logging.DebugPrint(logging.ScLog, "\tResolved %s to %s\n", aosPath, path)
+	return path, full, errCode
+}
+
+// isSimpleFilename reports whether a pathname may be looked for via the searchlist
+func isSimpleFilename(aosPath string) bool {
+	return aosPath != "" && !strings.ContainsAny(aosPath, ":^=@")
+}
+
+// agSearchPath finds an existing file, simple filenames not in the working directory
+// are looked for in each directory of the searchlist
+func agSearchPath(PID dg.WordT, aosPath string) (path, full string, errCode dg.WordT) {
+	return PerProcessData[int(PID)].searchPath(aosPath)
+}
+
+// searchPath finds an existing file from the process's working directory and searchlist
+func (ppd *PerProcessDataT) searchPath(aosPath string) (path, full string, errCode dg.WordT) {
+	if full, errCode = ppd.completePathname(aosPath); errCode != 0 {
+		return "", "", errCode
+	}
+	candidates := []string{full}
+	if isSimpleFilename(aosPath) {
+		for _, dir := range ppd.searchList {
+			candidates = append(candidates, strings.TrimSuffix(dir, rootDir)+":"+strings.ToUpper(aosPath))
+		}
+	}
+	for _, full = range candidates {
+		if path, errCode = ppd.hostPath(full); errCode == 0 {
+			if _, err := os.Lstat(path); err == nil {
+				return path, full, 0
+			}
+		}
+	}
+	return "", "", erfde
+}
+
+type agResolveProgramReqT struct {
+	PID      dg.WordT
+	progName string
+}
+type agResolveProgramRespT struct {
+	path    string
+	errCode dg.WordT
+}
+
+// agResolveProgram finds the host file for an AOS/VS program name, adding .PR if required
+func agResolveProgram(req agResolveProgramReqT) (resp agResolveProgramRespT) {
+	resp.path, resp.errCode = PerProcessData[int(req.PID)].findProgram(req.progName)
+	return resp
+}
+
+// findProgram returns the host file of a program found via the process's searchlist
+func (ppd *PerProcessDataT) findProgram(progName string) (path string, errCode dg.WordT) {
+	names := []string{progName}
+	if !strings.HasSuffix(strings.ToUpper(progName), ".PR") {
+		names = append(names, progName+".PR")
+	}
+	for _, name := range names {
+		if path, _, errCode := ppd.searchPath(name); errCode == 0 {
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+				return path, 0
+			}
+		}
+	}
+	return "", erfde
+}
+
+// resolveProgram asks the pseudo-Agent for the host file of a program
+func resolveProgram(agentChan chan AgentReqT, PID dg.WordT, progName string) (path string, errCode dg.WordT) {
+	areq := AgentReqT{agentResolveProgram, agResolveProgramReqT{PID, progName}, nil}
+	agentChan <- areq
+	areq = <-agentChan
+	resp := areq.result.(agResolveProgramRespT)
+	return resp.path, resp.errCode
+}
+
+type agPathnameReqT struct {
+	PID         dg.WordT
+	aosFilename string // empty to use chanNo
+	chanNo      int
+}
+type agPathnameRespT struct {
+	full    string
+	errCode dg.WordT
+}
+
+// agPathname returns the complete pathname of an existing file, for ?GNAME and ?CGNAM
+func agPathname(req agPathnameReqT) (resp agPathnameRespT) {
+	if req.aosFilename == "" {
+		agChan, isOpen := findChannel(req.PID, req.chanNo)
+		if !isOpen {
+			resp.errCode = erfno
+			return resp
+		}
+		resp.full = agChan.aosPath
+		return resp
+	}
+	if req.aosFilename[0] == '@' {
+		// generic files and devices have no host file, the console ones name the process's console
+		switch req.aosFilename {
+		case "@CONSOLE", "@INPUT", "@OUTPUT":
+			if con, errCode := findConsole(req.PID, req.aosFilename); errCode == 0 {
+				resp.full = ":PER:" + con.name
+				return resp
+			}
+		}
+		resp.full, resp.errCode = PerProcessData[int(req.PID)].completePathname(req.aosFilename)
+		return resp
+	}
+	_, resp.full, resp.errCode = agSearchPath(req.PID, req.aosFilename)
+	return resp
+}
+
+type agDirReqT struct {
+	PID         dg.WordT
+	aosFilename string
+}
+type agDirRespT struct {
+	errCode dg.WordT
+}
+
+// agDir handles ?DIR, changing the working directory
+func agDir(req agDirReqT) (resp agDirRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	full, errCode := ppd.checkDirectory(req.aosFilename)
+	if errCode != 0 {
+		resp.errCode = errCode
+		return resp
+	}
+	ppd.workingDir = full
+	logging.DebugPrint(logging.ScLog, "\tWorking directory of PID %d. is now %s\n", req.PID, full)
+	return resp
+}
+
+// checkDirectory returns the complete pathname of a directory, or an error if it is not one
+func (ppd *PerProcessDataT) checkDirectory(aosPath string) (full string, errCode dg.WordT) {
+	if full, errCode = ppd.completePathname(aosPath); errCode != 0 {
+		return "", errCode
+	}
+	path, errCode := ppd.hostPath(full)
+	if errCode != 0 {
+		return "", errCode
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", erdde
+	}
+	if !fi.IsDir() {
+		return "", erndr
+	}
+	return full, 0
+}
+
+type agSearchListReqT struct {
+	PID        dg.WordT
+	set        bool
+	searchList []string
+}
+type agSearchListRespT struct {
+	searchList []string
+	errCode    dg.WordT
+}
+
+// agSearchList handles ?GLIST and ?SLIST, every entry of a new searchlist must be a directory
+func agSearchList(req agSearchListReqT) (resp agSearchListRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	if req.set {
+		if len(req.searchList) > mxpsl {
+			resp.errCode = erpre
+			return resp
+		}
+		var sl []string
+		for _, dir := range req.searchList {
+			full, errCode := ppd.checkDirectory(dir)
+			if errCode != 0 {
+				resp.errCode = errCode
+				return resp
+			}
+			sl = append(sl, full)
+		}
+		ppd.searchList = sl
+	}
+	resp.searchList = ppd.searchList
+	return resp
+}
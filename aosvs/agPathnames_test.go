@@ -0,0 +1,139 @@
+// +build virtual !physical
+
+// agPathnames_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+func TestCompletePathname(t *testing.T) {
+	ppd := &PerProcessDataT{workingDir: ":UDD:GUEST"}
+	tests := []struct {
+		aosPath, full string
+		errCode       dg.WordT
+	}{
+		{":", ":", 0},
+		{":util:hw.pr", ":UTIL:HW.PR", 0},
+		{"hw.pr", ":UDD:GUEST:HW.PR", 0},
+		{"SUB:FILE", ":UDD:GUEST:SUB:FILE", 0},
+		{"=", ":UDD:GUEST", 0},
+		{"=FOO", ":UDD:GUEST:FOO", 0},
+		{"=:FOO", ":UDD:GUEST:FOO", 0},
+		{"^", ":UDD", 0},
+		{"^FOO", ":UDD:FOO", 0},
+		{"^^:FOO", ":FOO", 0},
+		{"^^^", "", erdde},
+		{"^^^:ETC", "", erdde},
+		{"@CONSOLE", ":PER:CONSOLE", 0},
+		{"@con10", ":PER:CON10", 0},
+		{"", "", erifc},
+		{"..", "", erifc},
+		{":UDD:..:..", "", erifc},
+		{"A::B", "", erifc},
+		{"bad/name", "", erifc},
+	}
+	for _, tt := range tests {
+		full, errCode := ppd.completePathname(tt.aosPath)
+		if full != tt.full || errCode != tt.errCode {
+			t.Errorf("%q: expected %q, error %#o, got %q, error %#o", tt.aosPath, tt.full, tt.errCode, full, errCode)
+		}
+	}
+}
+
+// testNamespace makes a namespace holding :UDD:GUEST and a lower-case util/hw.pr, with
+// one symbolic link inside it and one leading out of it
+func testNamespace(t *testing.T) (ppd *PerProcessDataT, root string) {
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "UDD", "GUEST"), filepath.Join(root, "util"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "util", "hw.pr"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "UDD"), filepath.Join(root, "IN")); err != nil {
+		t.Skip("symbolic links are not available")
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "OUT")); err != nil {
+		t.Fatal(err)
+	}
+	ppd = &PerProcessDataT{virtualRoot: root, workingDir: ":UDD:GUEST", searchList: []string{":UTIL"}}
+	return ppd, root
+}
+
+func TestHostPath(t *testing.T) {
+	ppd, root := testNamespace(t)
+	tests := []struct {
+		full, path string
+		errCode    dg.WordT
+	}{
+		{":", root, 0},
+		{":UDD:GUEST", filepath.Join(root, "UDD", "GUEST"), 0},
+		{":UTIL:HW.PR", filepath.Join(root, "util", "hw.pr"), 0},
+		{":UDD:NEWFILE", filepath.Join(root, "UDD", "NEWFILE"), 0},
+		{":IN:GUEST", filepath.Join(root, "IN", "GUEST"), 0},
+		{":OUT", "", erfad},
+		{":OUT:NEWFILE", "", erfad},
+		{":..", "", erfad},
+		{":UDD:..:..:outside", "", erfad},
+	}
+	for _, tt := range tests {
+		path, errCode := ppd.hostPath(tt.full)
+		if path != tt.path || errCode != tt.errCode {
+			t.Errorf("%q: expected %q, error %#o, got %q, error %#o", tt.full, tt.path, tt.errCode, path, errCode)
+		}
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	ppd, root := testNamespace(t)
+	tests := []struct {
+		aosPath, full, path string
+		errCode             dg.WordT
+	}{
+		{"HW.PR", ":UTIL:HW.PR", filepath.Join(root, "util", "hw.pr"), 0},
+		{"^^:UTIL:HW.PR", ":UTIL:HW.PR", filepath.Join(root, "util", "hw.pr"), 0},
+		{"=", ":UDD:GUEST", filepath.Join(root, "UDD", "GUEST"), 0},
+		{":UDD:HW.PR", "", "", erfde}, // not a simple filename, so the searchlist is not used
+		{"NOPE", "", "", erfde},
+		{"OUT", "", "", erfde},
+	}
+	for _, tt := range tests {
+		path, full, errCode := ppd.searchPath(tt.aosPath)
+		if path != tt.path || full != tt.full || errCode != tt.errCode {
+			t.Errorf("%q: expected %q %q, error %#o, got %q %q, error %#o",
+				tt.aosPath, tt.full, tt.path, tt.errCode, full, path, errCode)
+		}
+	}
+	if path, errCode := ppd.findProgram("hw"); errCode != 0 || path != filepath.Join(root, "util", "hw.pr") {
+		t.Errorf("Expected HW to be found as %s, got %q, error %#o", filepath.Join(root, "util", "hw.pr"), path, errCode)
+	}
+}
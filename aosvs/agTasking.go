@@ -24,12 +24,11 @@
 package aosvs
 
 import (
+	"fmt"
 	"log"
 	"net"
-	"os"
 	"runtime/debug"
 	"sort"
-	"strconv"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
@@ -39,25 +38,35 @@ import (
 
 type taskT struct {
 	PID, TID                 dg.WordT
+	uniqueTID                dg.WordT
 	priority                 dg.WordT
 	sixteenBit               bool
 	agentChan                chan AgentReqT
+	conn                     net.Conn
+	cpu                      *mvcpu.CPUT
+	mem                      *memory.AddrSpaceT
+	sched                    *schedulerT
 	dir                      string
 	startAddr, ringMask      dg.PhysAddrT
 	initAC2                  dg.DwordT
 	wfp, wsp, wsb, wsl, wsfh dg.PhysAddrT
+	nsp, nsl, nsfa           dg.WordT    // 16-bit tasks only, zero NSP => use program's stack
+	savedCtx                 [5]dg.WordT // page zero locations belonging to this task while it is not running
+	ctxSaved                 bool
 	killAddr                 dg.PhysAddrT
+	fpuSave                  dg.PhysAddrT // ?IFPU save area, zero if none
 	debugLogging             bool
 }
 
 type agTaskReqT struct {
-	PID, TID                 dg.WordT
+	PID, TID                 dg.WordT // TID is the requested standard TID, zero for any
+	callerTID                dg.WordT // zero if not created via ?TASK
 	priority                 dg.WordT
-	agentChan                chan AgentReqT
 	conn                     net.Conn
 	startAddr                dg.PhysAddrT
 	initAC2                  dg.DwordT
 	wfp, wsp, wsb, wsl, wsfh dg.PhysAddrT
+	nsp, nsl, nsfa           dg.WordT
 }
 type agTaskRespT struct {
 	TID     dg.WordT
@@ -66,13 +75,30 @@ type agTaskRespT struct {
 
 func agTask(req agTaskReqT) (resp agTaskRespT) {
 	var task taskT
+	ppd := PerProcessData[int(req.PID)]
+	if ppd.terminating {
+		resp.errCode = ernot
+		return resp
+	}
 	task.PID = req.PID
 	logging.DebugPrint(logging.ScLog, "\tSetting up task for PID: %d\n", req.PID)
-	task.sixteenBit = false
-	// task.tid = tid
-	task.agentChan = req.agentChan
+	task.sixteenBit = ppd.sixteenBit
+	task.agentChan = newAgentGateway()
+	task.conn = req.conn
+	if task.conn == nil {
+		task.conn, _ = ppd.conn.(net.Conn)
+	}
+	task.cpu = new(mvcpu.CPUT)
+	task.mem = ppd.mem
+	task.cpu.SetAddrSpace(task.mem)
+	task.sched = ppd.sched
 	task.startAddr = req.startAddr
 	task.ringMask = req.startAddr & 0x7000_0000
+	task.initAC2 = req.initAC2
+	task.priority = req.priority
+	if task.priority == 0 && req.callerTID != 0 {
+		task.priority, _ = ppd.sched.priorityOf(req.callerTID) // inherit the creator's priority
+	}
 	if req.wfp != 0 {
 		task.wfp = req.wfp
 	} else {
@@ -82,114 +108,374 @@ func agTask(req agTaskReqT) (resp agTaskRespT) {
 	task.wsb = req.wsb
 	task.wsl = req.wsl
 	task.wsfh = req.wsfh
+	task.nsp = req.nsp
+	task.nsl = req.nsl
+	task.nsfa = req.nsfa
+	if task.sixteenBit && task.nsp != 0 {
+		// its own narrow stack is put into page zero once the task has the CPU
+		task.savedCtx = [5]dg.WordT{dg.WordT(task.wsfh), task.nsp, task.nsp, task.nsl, task.nsfa}
+		task.ctxSaved = true
+	}
 	task.debugLogging = debugLogging // set for the package at the process level
 
-	atreq := agAllocateTIDReqT{req.PID}
+	atreq := agAllocateTIDReqT{req.PID, uint8(req.TID)}
 	logging.DebugPrint(logging.ScLog, "\tRequesting TID...\n")
 	areqResult := agAllocateTID(atreq)
 	if areqResult.standardTID == 0 {
-		log.Panicln("ERROR: Could not allocate TID for new task")
+		logging.DebugPrint(logging.ScLog, "\tCould not allocate TID for new task\n")
+		close(task.agentChan)
+		if req.TID != 0 {
+			resp.errCode = ertid
+		} else {
+			resp.errCode = ertmt
+		}
+		return resp
 	}
 	task.TID = dg.WordT(areqResult.standardTID)
+	task.uniqueTID = areqResult.uniqueTID
 	logging.DebugPrint(logging.ScLog, "\t...Got TID %d\n", task.TID)
-	ppd := PerProcessData[int(req.PID)]
-	ppd.tasks[firstTask] = &task // FIXME
-	PerProcessData[int(req.PID)] = ppd
+	ppd.tasks[task.TID] = &task
+	ppd.sched.addTask(task.TID, task.priority, task.cpu)
 	logging.DebugPrint(logging.ScLog, "\tAdding to WaitGroup...\n")
 	ppd.ActiveTasksWg.Add(1)
-	logging.DebugPrint(logging.ScLog, "\tTask %d Created, Initial PC=%#o\n", task.TID, task.startAddr)
+	logging.DebugPrint(logging.ScLog, "\tTask %d Created, Initial PC=%#o, Priority: %d.\n", task.TID, task.startAddr, task.priority)
 	logging.DebugPrint(logging.ScLog, "\tStart Addr: %#o, WFP: %#o, WSP: %#o, WSB: %#o, WSL: %#o, WSFH: %#o\n", task.startAddr, task.wfp, task.wsp, task.wsb, task.wsl, task.wsfh)
 
-	go TaskRunner(req.PID, task.TID, req.conn)
+	go TaskRunner(req.PID, task.TID, task.conn)
 
 	resp.TID = task.TID
 
 	return resp
 }
 
+type agTerminateReqT struct {
+	PID, TID dg.WordT // TID is the task causing the termination, zero if another process
+	info     termInfoT
+}
+type agTerminateRespT struct {
+	alreadyTerminating bool
+}
+
+// agTerminate marks a process as terminating and stops all of its tasks and sons
+func agTerminate(req agTerminateReqT) (resp agTerminateRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	if ppd == nil || ppd.terminating {
+		resp.alreadyTerminating = true
+		return resp
+	}
+	ppd.terminating = true
+	ppd.termInfo = req.info
+	ppd.termInfo.PID = req.PID
+	ppd.sched.terminate()
+	for sonPID, son := range PerProcessData {
+		if son.fatherPID == req.PID {
+			agTerminate(agTerminateReqT{PID: dg.WordT(sonPID), info: termInfoT{byTerm: true}})
+		}
+	}
+	logging.DebugPrint(logging.ScLog, "AGENT terminating PID %d at request of TID %d\n", req.PID, req.TID)
+	return resp
+}
+
+// pageZeroCtxLocs are the page zero locations which every task has its own copy of
+var pageZeroCtxLocs = [5]dg.PhysAddrT{014, memory.NspLoc, memory.NfpLoc, memory.NslLoc, memory.NsfaLoc}
+
+// saveContext preserves this task's page zero stack locations, and its floating-point
+// state if it has a save area, before it gives up the CPU
+func (task *taskT) saveContext() (fault *memory.ProtectionFaultT) {
+	return userAccess(func() {
+		for i, loc := range pageZeroCtxLocs {
+			task.savedCtx[i] = task.mem.ReadWord(task.ringMask | loc)
+		}
+		task.ctxSaved = true
+		if task.fpuSave != 0 {
+			task.cpu.StoreFPUState(task.fpuSave)
+		}
+	})
+}
+
+// restoreContext puts back this task's context once it has the CPU again
+func (task *taskT) restoreContext() (fault *memory.ProtectionFaultT) {
+	if !task.ctxSaved {
+		return nil
+	}
+	return userAccess(func() {
+		for i, loc := range pageZeroCtxLocs {
+			task.mem.WriteWord(task.ringMask|loc, task.savedCtx[i])
+		}
+		if task.fpuSave != 0 {
+			task.cpu.LoadFPUState(task.fpuSave)
+		}
+	})
+}
+
+// saveFPU stores the task's floating-point state in its ?IFPU save area, if any
+func (task *taskT) saveFPU() (fault *memory.ProtectionFaultT) {
+	if task.fpuSave == 0 {
+		return nil
+	}
+	return userAccess(func() { task.cpu.StoreFPUState(task.fpuSave) })
+}
+
+// popSyscallFrame removes the frame pushed by a system call, leaving AC3 as AOS/VS does
+func (task *taskT) popSyscallFrame() {
+	if task.sixteenBit {
+		nfp := task.mem.ReadWord(task.ringMask | memory.NfpLoc)
+		task.cpu.SetAc(3, dg.DwordT(nfp)|dg.DwordT(task.ringMask))
+	} else {
+		task.cpu.SetAc(3, dg.DwordT(task.cpu.GetWFP()))
+	}
+	mvcpu.WsPop(task.cpu)
+}
+
+// honourKill acts on any ?IDKIL of this task once it has the CPU, either entering its
+// kill-processing routine with the TID in AC2, or reporting that the task must end
+func (task *taskT) honourKill() (end bool, fault *memory.ProtectionFaultT) {
+	if !task.sched.takeKill(task.TID) {
+		return false, nil
+	}
+	if task.killAddr == 0 {
+		logging.DebugPrint(logging.ScLog, "\tTask %d killed\n", task.TID)
+		return true, nil
+	}
+	return false, task.enterKillRoutine()
+}
+
+// enterKillRoutine transfers control to the task's kill-processing routine, a ?KILL
+// within the routine really ends the task
+func (task *taskT) enterKillRoutine() (fault *memory.ProtectionFaultT) {
+	logging.DebugPrint(logging.ScLog, "\tTask %d entering kill routine at %#o\n", task.TID, task.killAddr)
+	if fault = task.saveFPU(); fault != nil {
+		return fault
+	}
+	task.cpu.SetPC(task.killAddr)
+	task.cpu.SetAc(2, dg.DwordT(task.TID))
+	task.killAddr = 0
+	return nil
+}
+
+// userAccess runs fn, which reads or writes user memory, returning any protection fault
+// it caused rather than letting the panic escape
+func userAccess(fn func()) (fault *memory.ProtectionFaultT) {
+	defer func() {
+		if r := recover(); r != nil {
+			pf, isFault := r.(memory.ProtectionFaultT)
+			if !isFault {
+				panic(r)
+			}
+			fault = &pf
+		}
+	}()
+	fn()
+	return nil
+}
+
+// syscallID fetches the number of the system call the task has just made
+func (task *taskT) syscallID() (callID dg.WordT, fault *memory.ProtectionFaultT) {
+	fault = userAccess(func() {
+		if task.sixteenBit {
+			ss := task.mem.NsPop(task.ringMask, false)
+			callID = task.mem.ReadWord(task.ringMask | dg.PhysAddrT(ss))
+			task.mem.NsPush(task.ringMask, ss, false)
+		} else {
+			callID = task.mem.ReadWord(dg.PhysAddrT(task.mem.ReadDWord(task.cpu.GetWSP() - 2)))
+		}
+	})
+	return callID, fault
+}
+
+// returnInfo collects the termination details of a ?RETURN, a message pointer to unmapped
+// memory is treated as a protection fault
+func (task *taskT) returnInfo() (errorCode dg.DwordT, termMessage string, flags dg.ByteT) {
+	cpu := task.cpu
+	errorCode = cpu.GetAc(0)
+	flags = dg.ByteT(memory.GetDwbits(cpu.GetAc(2), 16, 8))
+	msgLen := int(uint8(memory.GetDwbits(cpu.GetAc(2), 24, 8)))
+	if msgLen > 0 {
+		if fault := userAccess(func() {
+			termMessage = string(task.mem.ReadBytes(cpu.GetAc(1), task.ringMask, msgLen))
+		}); fault != nil {
+			return task.faultTermination(*fault)
+		}
+	}
+	return errorCode, termMessage, flags
+}
+
+// faultTermination returns the termination details for a protection fault, which ends the whole process
+func (task *taskT) faultTermination(fault memory.ProtectionFaultT) (errorCode dg.DwordT, termMessage string, flags dg.ByteT) {
+	termMessage = fmt.Sprintf("Protection fault in PID %d TID %d, %s at PC %#o", task.PID, task.TID, fault.Error(), task.cpu.GetPC())
+	log.Println(termMessage)
+	logging.DebugPrint(logging.ScLog, "%s\n", termMessage)
+	return ermpr, termMessage, Rfab | Rfec
+}
+
 // TaskRunner is a Goroutine for running a single AOS/VS task
 func TaskRunner(PID, TID dg.WordT, conn net.Conn) {
 	logging.DebugPrint(logging.ScLog, "\tTask %d starting...\n", TID)
+	ppd := getPerProcessData(PID)
+	task := ppd.tasks[TID]
+	task.runGuarded(conn)
+	task.sched.removeTask(TID, task.cpu)
+	areq := AgentReqT{agentFreeTID, agFreeTIDReqT{PID, TID, task}, nil}
+	task.agentChan <- areq
+	areq = <-task.agentChan
+	if areq.result.(agFreeTIDRespT).lastTask {
+		// killing the last task terminates the process
+		areq = AgentReqT{agentTerminate, agTerminateReqT{PID: PID, TID: TID}, nil}
+		task.agentChan <- areq
+		<-task.agentChan
+	}
+	close(task.agentChan)
+	ppd.ActiveTasksWg.Done()
+	logging.DebugPrint(logging.ScLog, "\tTask %d finished.\n", TID)
+}
+
+// runGuarded runs the task, an emulator error in it aborts only its own process,
+// whose father or console is told why like any other termination
+func (task *taskT) runGuarded(conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
+			message := fmt.Sprintf("Emulator error in PID %d TID %d: %v", task.PID, task.TID, r)
+			log.Println(message)
 			debug.PrintStack()
 			logging.DebugLogsDump("logs/")
-			os.Exit(1)
+			areq := AgentReqT{agentTerminate, agTerminateReqT{task.PID, task.TID, termInfoT{flags: Rfab | Rfcf, message: message, byTerm: true}}, nil}
+			task.agentChan <- areq
+			<-task.agentChan
 		}
 	}()
-	ppd := PerProcessData[int(PID)]
-	ppd.tasks[firstTask].run(conn)
-	ppd.ActiveTasksWg.Done()
-	logging.DebugPrint(logging.ScLog, "\tTask %d finished.\n", TID)
+	task.run(conn)
 }
 
 func (task *taskT) run(conn net.Conn) (errorCode dg.DwordT, termMessage string, flags dg.ByteT) {
 	var (
-		cpu         mvcpu.CPUT
 		syscallTrap bool
+		returned    bool // ?RETURN issued or a fault occurred, otherwise the task was killed or stopped
 		instrCounts [750]int
 	)
+	cpu := task.cpu
+	sched := task.sched
+	// faulted ends the process if the task's context or save area was in unmapped memory
+	faulted := func(fault *memory.ProtectionFaultT) bool {
+		if fault == nil {
+			return false
+		}
+		errorCode, termMessage, flags = task.faultTermination(*fault)
+		returned = true
+		return true
+	}
 
 	cpu.CPUInit(077, nil, nil)
 	cpu.SetPC(task.startAddr) // must be done before stack set up
+	if !sched.acquire(task.TID, task.cpu) {
+		return errorCode, termMessage, flags
+	}
 	cpu.SetupStack(task.wfp, task.wsp, task.wsb, task.wsl, task.wsfh)
-	adjustedWsfh := (cpu.GetPC() & 0x7000_0000) | dg.PhysAddrT(memory.ReadWord((cpu.GetPC()&0x7000_0000)|014)) // just for debugging
+	adjustedWsfh := (cpu.GetPC() & 0x7000_0000) | dg.PhysAddrT(task.mem.ReadWord((cpu.GetPC()&0x7000_0000)|014)) // just for debugging
 	logging.DebugPrint(logging.ScLog, "\tWide Stack Fault Handler reset to: %#x (%#o)\n", adjustedWsfh, adjustedWsfh)
+	cpu.SetAc(2, task.initAC2)
 	cpu.SetATU(true)
 	cpu.SetDebugLogging(task.debugLogging)
+	stopped := faulted(task.restoreContext())
+	if !stopped {
+		end, fault := task.honourKill()
+		stopped = end || faulted(fault)
+	}
 
-	for {
+	for !stopped {
 		syscallTrap, _ = cpu.Vrun(&instrCounts)
 		if syscallTrap {
 			returnAddr := dg.PhysAddrT(cpu.GetAc(3))
-			var callID dg.WordT
-			if task.sixteenBit {
-				ss := memory.NsPop(task.ringMask, false)
-				callID = memory.ReadWord(task.ringMask | dg.PhysAddrT(ss))
-				memory.NsPush(task.ringMask, ss, false)
-			} else {
-				callID = memory.ReadWord(dg.PhysAddrT(memory.ReadDWord(cpu.GetWSP() - 2)))
+			callID, fault := task.syscallID()
+			if fault != nil {
+				errorCode, termMessage, flags = task.faultTermination(*fault)
+				returned = true
+				break
 			}
 			// special handling for the ?RETURN system call
 			if callID == scReturn {
 				if task.debugLogging {
 					logging.DebugPrint(logging.DebugLog, "?RETURN")
 				}
-				errorCode = cpu.GetAc(0)
-				flags = dg.ByteT(memory.GetDwbits(cpu.GetAc(2), 16, 8))
-				msgLen := int(uint8(memory.GetDwbits(cpu.GetAc(2), 24, 8)))
-				if msgLen > 0 {
-					termMessage = string(memory.ReadBytes(cpu.GetAc(1), task.ringMask, msgLen))
+				errorCode, termMessage, flags = task.returnInfo()
+				returned = true
+				break
+			}
+			// ?KILL of the calling task never returns either
+			if callID == scKill {
+				logging.DebugPrint(logging.ScLog, "?KILL System Call for TID %d\n", task.TID)
+				if task.killAddr == 0 {
+					break
+				}
+				task.popSyscallFrame()
+				if faulted(task.enterKillRoutine()) {
+					break
 				}
+				continue
+			}
+			// other tasks may run while we are in the system call
+			if faulted(task.saveContext()) {
 				break
 			}
+			sched.release(task.TID, task.cpu)
 			var scOk bool
 			if task.sixteenBit {
-				scOk = syscall16(callID, task.PID, task.TID, task.ringMask, task.agentChan, &cpu)
-				nfp := memory.ReadWord(task.ringMask | memory.NfpLoc)
-				cpu.SetAc(3, dg.DwordT(nfp)|dg.DwordT(task.ringMask))
+				scOk = syscall16(callID, task.PID, task.TID, task.ringMask, task.agentChan, cpu, &task.savedCtx)
 			} else {
-				scOk = syscall(callID, task.PID, task.TID, task.ringMask, task.agentChan, &cpu)
-				cpu.SetAc(3, dg.DwordT(cpu.GetWFP()))
+				scOk = syscall(callID, task.PID, task.TID, task.ringMask, task.agentChan, cpu, &task.savedCtx)
 			}
-			mvcpu.WsPop(&cpu)
+			if !sched.acquire(task.TID, task.cpu) {
+				break
+			}
+			if faulted(task.restoreContext()) {
+				break
+			}
+			task.popSyscallFrame()
 			if scOk {
 				cpu.SetPC(returnAddr + 1)
 			} else {
 				cpu.SetPC(returnAddr)
 			}
+			if end, fault := task.honourKill(); end || faulted(fault) {
+				break
+			}
 			//cpu.SetAc(3, dg.DwordT(cpu.GetWFP()))
 		} else {
 			// Vrun has stopped and we're not at a system call
+			if cpu.GetSCPIO() {
+				// preempted by the scheduler, or the process is terminating
+				cpu.SetSCPIO(false)
+				if faulted(task.saveContext()) {
+					break
+				}
+				sched.release(task.TID, task.cpu)
+				if sched.acquire(task.TID, task.cpu) {
+					if faulted(task.restoreContext()) {
+						break
+					}
+					if end, fault := task.honourKill(); end || faulted(fault) {
+						break
+					}
+					continue
+				}
+				logging.DebugPrint(logging.ScLog, "\tTask %d stopped\n", task.TID)
+				break
+			}
+			// a protection fault terminates the whole process with a fault report
+			if fault, isFault := cpu.GetFault(); isFault {
+				task.saveFPU() // a fault here leaves the save area as it was
+				errorCode, termMessage, flags = task.faultTermination(fault)
+				returned = true
+			}
 			break
 		}
 	}
+	sched.release(task.TID, task.cpu)
 
 	// instruction counts, first by Mnemonic, then by count
 	m := make(map[int]string)
 	keys := make([]int, 0)
 
-	log.Println("Instruction Execution Count by Mnemonic")
+	log.Printf("Instruction Execution Count by Mnemonic for TID %d\n", task.TID)
 	for i, c := range instrCounts {
 		if instrCounts[i] > 0 {
 			log.Printf("%s\t%d\n", mvcpu.GetMnemonic(i), c)
@@ -207,18 +493,19 @@ func (task *taskT) run(conn net.Conn) (errorCode dg.DwordT, termMessage string,
 		log.Printf("%d\t%s\n", c, m[c])
 	}
 
-	switch flags {
-	case Rfwa:
-		termMessage = "WARNING: " + termMessage
-	case Rfer:
-		termMessage = "ERROR: " + termMessage
-	case Rfab:
-		termMessage = "ABORT: " + termMessage
+	if !returned {
+		return errorCode, termMessage, flags
 	}
-	if flags&Rfec != 0 {
-		termMessage += "\nError Code: " + strconv.Itoa(int(errorCode))
+
+	// ?RETURN (or a fault) terminates the whole process, not just this task
+	areq := AgentReqT{agentTerminate, agTerminateReqT{task.PID, task.TID, termInfoT{errorCode: errorCode, flags: flags, message: termMessage}}, nil}
+	task.agentChan <- areq
+	<-task.agentChan
+	if ppd := getPerProcessData(task.PID); ppd.fatherPID != 0 || ppd.cliSon {
+		return errorCode, termMessage, flags // our father gets the termination message
 	}
 
+	termMessage = termInfoT{errorCode: errorCode, flags: flags, message: termMessage}.text()
 	conn.Write([]byte(termMessage))
 
 	return errorCode, termMessage, flags
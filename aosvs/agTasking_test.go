@@ -0,0 +1,99 @@
+// +build virtual !physical
+
+// agTasking_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/memory"
+	"github.com/SMerrony/dgemug/mvcpu"
+)
+
+// returnTestTask sets up a process whose single task is about to ?RETURN with a message at msgBA
+func returnTestTask(PID dg.WordT, msgBA dg.DwordT) *taskT {
+	mem := memory.NewAddrSpace()
+	mem.WriteStringBA("BYE", 0xe000_0010)
+	task := &taskT{PID: PID, TID: 1, ringMask: 0x7000_0000, mem: mem, cpu: new(mvcpu.CPUT), sched: newScheduler()}
+	task.cpu.SetAddrSpace(mem)
+	task.cpu.SetAc(0, 0)
+	task.cpu.SetAc(1, msgBA)
+	task.cpu.SetAc(2, 3) // message length
+	PerProcessData[int(PID)] = &PerProcessDataT{mem: mem, sched: task.sched}
+	return task
+}
+
+func TestWildReturnPointer(t *testing.T) {
+	good := returnTestTask(98, 0xe000_0010)
+	wild := returnTestTask(99, 0xe100_0000) // an unmapped page
+	defer delete(PerProcessData, 98)
+	defer delete(PerProcessData, 99)
+
+	errorCode, msg, flags := good.returnInfo()
+	if errorCode != 0 || msg != "BYE" || flags != 0 {
+		t.Errorf("Expected a normal ?RETURN with message BYE, got %#o %q %#o", errorCode, msg, flags)
+	}
+	errorCode, msg, flags = wild.returnInfo()
+	if errorCode != ermpr || flags != Rfab|Rfec {
+		t.Errorf("Expected a protection fault termination, got %#o %q %#o", errorCode, msg, flags)
+	}
+	agTerminate(agTerminateReqT{wild.PID, wild.TID, termInfoT{errorCode: errorCode, flags: flags, message: msg}})
+	if !PerProcessData[99].terminating || PerProcessData[99].termInfo.errorCode != ermpr {
+		t.Error("Expected the faulting process to be terminated with ERMPR")
+	}
+	if PerProcessData[98].terminating {
+		t.Error("Expected the other process to be unaffected")
+	}
+}
+
+func TestUnmappedFPUSaveArea(t *testing.T) {
+	task := returnTestTask(97, 0)
+	defer delete(PerProcessData, 97)
+	task.fpuSave = 0x7000_0100
+	if fault := task.saveContext(); fault != nil {
+		t.Fatalf("Expected the save area to be usable, got %s", fault.Error())
+	}
+	task.fpuSave = 0x7080_0000 // as if ?MEMI had released its page
+	if task.saveContext() == nil || task.restoreContext() == nil {
+		t.Error("Expected a protection fault for an unmapped FPU save area")
+	}
+}
+
+func TestEmulatorErrorAbortsProcess(t *testing.T) {
+	task := returnTestTask(92, 0)
+	defer delete(PerProcessData, 92)
+	task.cpu = nil // makes the task panic as soon as it starts
+	task.agentChan = make(chan AgentReqT)
+	go func() {
+		areq := <-task.agentChan
+		areq.result = agTerminate(areq.reqParms.(agTerminateReqT))
+		task.agentChan <- areq
+	}()
+	task.runGuarded(nil)
+	info := PerProcessData[92].termInfo
+	if !PerProcessData[92].terminating || info.flags&Rfab != Rfab || !strings.HasPrefix(info.message, "Emulator error in PID 92") {
+		t.Errorf("Expected the process to be aborted, got %+v", info)
+	}
+}
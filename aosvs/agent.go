@@ -32,9 +32,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
+	"github.com/SMerrony/dgemug/memory"
 )
 
 // pseudo-Agent function calls...
@@ -42,6 +44,8 @@ const (
 	agentAllocatePID = iota
 	agentAllocateTID
 	agentCreateIPC
+	agentFreeTID
+	agentChannelInfo
 	agentFileClose
 	agentFileOpen
 	agentFileRead
@@ -51,8 +55,44 @@ const (
 	agentGetMessage
 	agentIlkup
 	agentSharedOpen
-	agentSharedRead
+	agentSharedFile
+	agentProcInfo
+	agentReleasePID
 	agentTask
+	agentTermProc
+	agentTerminate
+	agentChain
+	agentCreate
+	agentDelete
+	agentFileStatus
+	agentACL
+	agentDefaultACL
+	agentRename
+	agentDirOpen
+	agentNextFilename
+	agentSearchList
+	agentResolveProgram
+	agentPathname
+	agentDir
+	agentIsend
+	agentIrec
+	agentIrecCancel
+	agentServe
+	agentConnect
+	agentDrcon
+	agentCheckConn
+	agentPassConn
+	agentExec
+	agentSetChars
+	agentAttachConsole
+	agentDetachConsole
+	agentConsoleInterrupt
+	agentKi
+	agentIntwt
+	agentControl
+	agentSysprv
+	agentUidstat
+	agentSend
 )
 
 // AgentReqT is the type of messages passed to and from the pseudo-agent
@@ -66,27 +106,60 @@ const (
 	maxPID = 255
 )
 
+// PerProcessDataT holds the Agent's view of a process
 type PerProcessDataT struct {
-	invocationArgs []string
-	virtualRoot    string
-	sixteenBit     bool
-	name           string
-	conn           io.ReadWriteCloser // stream I/O port for proc's CONSOLE
-	tidsInUse      [maxTasksPerProc]bool
-	tasks          [maxTasksPerProc]*taskT
-	ActiveTasksWg  *sync.WaitGroup
+	invocationArgs  []string
+	virtualRoot     string // host directory which is the root of the AOS/VS namespace
+	workingDir      string // complete AOS/VS pathname
+	sixteenBit      bool
+	name            string
+	username        string
+	privileges      dg.WordT               // as ?PROC pprv
+	privModes       [sysprvModeMax + 1]int // privModeOff, privModeOn or privModeExcl by ?SYSPRV mode - Agent only
+	programFileName string
+	fatherPID       dg.WordT  // zero if created by the emulator itself
+	fatherWaits     bool      // father is blocked until we terminate, so no obituary is queued
+	cliSon          bool      // run by the pseudo-CLI, which reports our termination
+	created         time.Time // for ?XPSTAT
+	mem             *memory.AddrSpaceT
+	conn            io.ReadWriteCloser // stream I/O port for proc's CONSOLE
+	consoleName     string             // device name of that console, eg. CON10
+	maxTasks        int                // from the UST of the program file
+	tidsInUse       [maxTasksPerProc]bool
+	tasks           [maxTasksPerProc]*taskT
+	sched           *schedulerT
+	terminating     bool        // only touched by the Agent
+	termInfo        termInfoT   // how we terminated, valid once doneChan is closed
+	defaultACL      []aclEntryT // given to files we create, nil for the username with OWARE
+	defaultACLOff   bool
+	searchList      []string // complete AOS/VS pathnames
+	ring            int
+	ipcSpool        []ipcMsgT     // messages not yet received
+	ipcWaiters      []*ipcWaiterT // tasks blocked in ?IREC
+	isServer        bool          // has issued ?SERVE
+	kiOff           bool          // console interrupts held by ?KIOFF
+	intPending      bool          // console interrupt not yet taken by ?INTWT
+	intWaiters      []chan struct{}
+	paused          bool // by the emulator's control channel
+	doneChan        chan struct{}
+	ActiveTasksWg   *sync.WaitGroup
+	channels        map[int]*agChannelT // open files and devices, by channel number - Agent only
 }
 
 // agChannelT holds status of a file opened by the Agent for a user proc
 type agChannelT struct {
-	openerPID    int
-	path         string
+	path         string // on the host
+	aosPath      string // complete AOS/VS pathname
 	isConsole    bool
+	isDirectory  bool // opened by ?GOPEN
 	read, write  bool
-	forShared    bool     // indicated this has been ?SOPENed
-	recordLength int      // default I/O record length set at ?OPEN time
-	conn         net.Conn // stream I/O
-	file         *os.File // file I/O
+	forShared    bool      // indicated this has been ?SOPENed
+	recordLength int       // default I/O record length set at ?OPEN time
+	recordFormat int       // default record format set at ?OPEN time
+	pos          int64     // file position of the next record
+	vbBlock      []byte    // unread records of the current IBM variable block
+	console      *consoleT // stream I/O
+	file         *os.File  // file I/O
 }
 
 type agIPCT struct {
@@ -94,23 +167,23 @@ type agIPCT struct {
 	name         string
 	localPortNo  int
 	globalPortNo int
-	spool        chan []byte
 }
 
 var (
 	pidInUse       [maxPID]bool
-	PerProcessData = map[int]PerProcessDataT{}
-	console        net.Conn
-	agChannels     = map[int]*agChannelT{}
-	agIPCs         = map[string]*agIPCT{} // key is unique pathname
+	PerProcessData = map[int]*PerProcessDataT{}
+	ppdMu          sync.RWMutex           // only the Agent changes PerProcessData, it holds this while doing so
+	agentReqChan   chan AgentReqT         // the shared channel to the pseudo-Agent
+	agentMu        sync.Mutex             // serialises round-trips on agentReqChan
+	agIPCs         = map[string]*agIPCT{} // key is complete AOS/VS pathname
 )
 
 // StartAgent fires of the pseudo-agent Goroutine and returns its msg channel
-func StartAgent(conn net.Conn) chan AgentReqT {
+func StartAgent() chan AgentReqT {
 	// fake some in-use PIDs so they are not used
 	pidInUse[0], pidInUse[1], pidInUse[2], pidInUse[3], pidInUse[4] = true, true, true, true, true
 	agentChan := make(chan AgentReqT) // unbuffered to serialise requests
-	console = conn
+	agentReqChan = agentChan
 
 	go agentHandler(agentChan)
 
@@ -135,6 +208,10 @@ func agentHandler(agentChan chan AgentReqT) {
 			request.result = agAllocateTID(request.reqParms.(agAllocateTIDReqT))
 		case agentCreateIPC:
 			request.result = agCreateIPC(request.reqParms.(agCreateIPCReqT))
+		case agentFreeTID:
+			request.result = agFreeTID(request.reqParms.(agFreeTIDReqT))
+		case agentChannelInfo:
+			request.result = agChannelInfo(request.reqParms.(agChannelInfoReqT))
 		case agentFileClose:
 			request.result = agFileClose(request.reqParms.(agCloseReqT))
 		case agentFileOpen:
@@ -153,10 +230,82 @@ func agentHandler(agentChan chan AgentReqT) {
 			request.result = agIlkup(request.reqParms.(agIlkupReqT))
 		case agentSharedOpen:
 			request.result = agSharedOpen(request.reqParms.(agSharedOpenReqT))
-		case agentSharedRead:
-			request.result = agSharedRead(request.reqParms.(agSharedReadReqT))
+		case agentSharedFile:
+			request.result = agSharedFile(request.reqParms.(agSharedFileReqT))
+		case agentProcInfo:
+			request.result = agProcInfo(request.reqParms.(agProcInfoReqT))
+		case agentReleasePID:
+			request.result = agReleasePID(request.reqParms.(agReleasePIDReqT))
 		case agentTask:
 			request.result = agTask(request.reqParms.(agTaskReqT))
+		case agentTermProc:
+			request.result = agTermProc(request.reqParms.(agTermProcReqT))
+		case agentTerminate:
+			request.result = agTerminate(request.reqParms.(agTerminateReqT))
+		case agentChain:
+			request.result = agChain(request.reqParms.(agChainReqT))
+		case agentCreate:
+			request.result = agCreate(request.reqParms.(agCreateReqT))
+		case agentDelete:
+			request.result = agDelete(request.reqParms.(agDeleteReqT))
+		case agentFileStatus:
+			request.result = agFileStatus(request.reqParms.(agFileStatusReqT))
+		case agentACL:
+			request.result = agACL(request.reqParms.(agACLReqT))
+		case agentDefaultACL:
+			request.result = agDefaultACL(request.reqParms.(agDefaultACLReqT))
+		case agentRename:
+			request.result = agRename(request.reqParms.(agRenameReqT))
+		case agentDirOpen:
+			request.result = agDirOpen(request.reqParms.(agDirOpenReqT))
+		case agentNextFilename:
+			request.result = agNextFilename(request.reqParms.(agNextFilenameReqT))
+		case agentSearchList:
+			request.result = agSearchList(request.reqParms.(agSearchListReqT))
+		case agentResolveProgram:
+			request.result = agResolveProgram(request.reqParms.(agResolveProgramReqT))
+		case agentPathname:
+			request.result = agPathname(request.reqParms.(agPathnameReqT))
+		case agentDir:
+			request.result = agDir(request.reqParms.(agDirReqT))
+		case agentIsend:
+			request.result = agIsend(request.reqParms.(agIsendReqT))
+		case agentIrec:
+			request.result = agIrec(request.reqParms.(agIrecReqT))
+		case agentIrecCancel:
+			request.result = agIrecCancel(request.reqParms.(agIrecCancelReqT))
+		case agentServe:
+			request.result = agServe(request.reqParms.(agServeReqT))
+		case agentConnect:
+			request.result = agConnect(request.reqParms.(agConnectReqT))
+		case agentDrcon:
+			request.result = agDrcon(request.reqParms.(agDrconReqT))
+		case agentCheckConn:
+			request.result = agCheckConn(request.reqParms.(agCheckConnReqT))
+		case agentPassConn:
+			request.result = agPassConn(request.reqParms.(agPassConnReqT))
+		case agentExec:
+			request.result = agExec(request.reqParms.(agExecReqT))
+		case agentSetChars:
+			request.result = agSetChars(request.reqParms.(agSchrReqT))
+		case agentAttachConsole:
+			request.result = agAttachConsole(request.reqParms.(agAttachConsoleReqT))
+		case agentDetachConsole:
+			request.result = agDetachConsole(request.reqParms.(agDetachConsoleReqT))
+		case agentConsoleInterrupt:
+			request.result = agConsoleInterrupt(request.reqParms.(agConsoleInterruptReqT))
+		case agentSend:
+			request.result = agSend(request.reqParms.(agSendReqT))
+		case agentKi:
+			request.result = agKi(request.reqParms.(agKiReqT))
+		case agentIntwt:
+			request.result = agIntwt(request.reqParms.(agIntwtReqT))
+		case agentControl:
+			request.result = agControl(request.reqParms.(agControlReqT))
+		case agentSysprv:
+			request.result = agSysprv(request.reqParms.(agSysprvReqT))
+		case agentUidstat:
+			request.result = agUidstat(request.reqParms.(agUidstatReqT))
 		default:
 			log.Panicf("ERROR: Agent received unknown request type %d\n", request.action)
 		}
@@ -164,6 +313,32 @@ func agentHandler(agentChan chan AgentReqT) {
 	}
 }
 
+// newAgentGateway returns a private channel for talking to the pseudo-Agent.
+// The shared Agent channel carries both requests and replies, so once several
+// tasks are running one task could pick up another's request as its reply.
+// Each gateway performs the whole round-trip under agentMu instead.
+func newAgentGateway() chan AgentReqT {
+	gw := make(chan AgentReqT)
+	go func() {
+		for req := range gw {
+			agentMu.Lock()
+			agentReqChan <- req
+			req = <-agentReqChan
+			agentMu.Unlock()
+			gw <- req
+		}
+	}()
+	return gw
+}
+
+// getPerProcessData is used outside the Agent to safely obtain a process's data
+func getPerProcessData(PID dg.WordT) *PerProcessDataT {
+	ppdMu.RLock()
+	ppd := PerProcessData[int(PID)]
+	ppdMu.RUnlock()
+	return ppd
+}
+
 func getNextFreePID() (pid dg.WordT, ok bool) {
 	for p := 1; p < maxPID; p++ {
 		if !pidInUse[p] {
@@ -174,8 +349,25 @@ func getNextFreePID() (pid dg.WordT, ok bool) {
 	return 0, false // all PIDs in use
 }
 
-func getNextFreeTID(PID dg.WordT) (TID uint8, ok bool) {
+// getNextFreeTID allocates a standard TID, if wantTID is non-zero that specific TID is allocated
+func getNextFreeTID(PID dg.WordT, wantTID uint8) (TID uint8, ok bool) {
 	ppd := PerProcessData[int(PID)]
+	inUse := 0
+	for t := 1; t < maxTasksPerProc; t++ {
+		if ppd.tidsInUse[t] {
+			inUse++
+		}
+	}
+	if ppd.maxTasks > 0 && inUse >= ppd.maxTasks {
+		return 0, false // the program was not linked for any more tasks
+	}
+	if wantTID != 0 {
+		if int(wantTID) >= maxTasksPerProc || ppd.tidsInUse[wantTID] {
+			return 0, false
+		}
+		ppd.tidsInUse[wantTID] = true
+		return wantTID, true
+	}
 	for t := 1; t < maxTasksPerProc; t++ { // Zero TID is invalid
 		if !ppd.tidsInUse[t] {
 			ppd.tidsInUse[t] = true
@@ -186,30 +378,89 @@ func getNextFreeTID(PID dg.WordT) (TID uint8, ok bool) {
 }
 
 type agAllocatePIDReqT struct {
-	invocationArgs []string
-	virtualRoot    string
-	sixteenBit     bool
-	name           string
+	invocationArgs  []string
+	virtualRoot     string
+	workingDir      string   // ignored if we have a father, who passes on his own
+	initialDir      string   // from ?PROC, empty to inherit the father's working directory
+	searchList      []string // ditto
+	sixteenBit      bool
+	ring            int
+	name            string // empty for default
+	username        string
+	privileges      dg.WordT
+	programFileName string
+	fatherPID       dg.WordT
+	fatherWaits     bool
+	cliSon          bool
+	maxTasks        int
+	conn            net.Conn
+	mem             *memory.AddrSpaceT
 }
 type agAllocatePIDRespT struct {
-	PID dg.WordT
-	ok  bool
+	PID     dg.WordT
+	ppd     *PerProcessDataT
+	errCode dg.WordT
 }
 
 func agAllocatePID(req agAllocatePIDReqT) (resp agAllocatePIDRespT) {
-	resp.PID, resp.ok = getNextFreePID()
-	if !resp.ok {
+	name := strings.ToUpper(req.name)
+	if name != "" && findProcessByName(name) != 0 {
+		resp.errCode = erpnu
+		return resp
+	}
+	workingDir, searchList := req.workingDir, req.searchList
+	if father, found := PerProcessData[int(req.fatherPID)]; found {
+		workingDir, searchList = father.workingDir, append([]string(nil), father.searchList...)
+		if req.initialDir != "" {
+			var errCode dg.WordT
+			if workingDir, errCode = father.checkDirectory(req.initialDir); errCode != 0 {
+				resp.errCode = errCode
+				return resp
+			}
+		}
+	}
+	var ok bool
+	resp.PID, ok = getNextFreePID()
+	if !ok {
+		resp.errCode = erprn
 		return resp
 	}
+	if name == "" {
+		name = strconv.Itoa(int(resp.PID))
+	}
+	var conName string
+	if req.conn != nil {
+		conName = attachConsole(req.conn).name
+	}
 	var wg sync.WaitGroup
-	PerProcessData[int(resp.PID)] = PerProcessDataT{
-		invocationArgs: req.invocationArgs,
-		virtualRoot:    req.virtualRoot,
-		sixteenBit:     req.sixteenBit,
-		name:           req.name,
-		ActiveTasksWg:  &wg,
+	resp.ppd = &PerProcessDataT{
+		invocationArgs:  req.invocationArgs,
+		virtualRoot:     req.virtualRoot,
+		workingDir:      workingDir,
+		searchList:      searchList,
+		sixteenBit:      req.sixteenBit,
+		ring:            req.ring,
+		name:            name,
+		username:        req.username,
+		privileges:      req.privileges,
+		programFileName: req.programFileName,
+		fatherPID:       req.fatherPID,
+		fatherWaits:     req.fatherWaits,
+		cliSon:          req.cliSon,
+		created:         time.Now(),
+		maxTasks:        req.maxTasks,
+		conn:            req.conn,
+		consoleName:     conName,
+		mem:             req.mem,
+		sched:           newScheduler(),
+		doneChan:        make(chan struct{}),
+		ActiveTasksWg:   &wg,
+		channels:        map[int]*agChannelT{},
 	}
-	logging.DebugPrint(logging.ScLog, "AGENT assigned PID %d  Name: %s Args: %v\n", resp.PID, req.name, req.invocationArgs)
+	ppdMu.Lock()
+	PerProcessData[int(resp.PID)] = resp.ppd
+	ppdMu.Unlock()
+	logging.DebugPrint(logging.ScLog, "AGENT assigned PID %d  Name: %s Args: %v\n", resp.PID, name, req.invocationArgs)
 	if req.sixteenBit {
 		logging.DebugPrint(logging.ScLog, "----- 16-bit program type\n")
 	} else {
@@ -219,7 +470,8 @@ func agAllocatePID(req agAllocatePIDReqT) (resp agAllocatePIDRespT) {
 }
 
 type agAllocateTIDReqT struct {
-	PID dg.WordT
+	PID     dg.WordT
+	wantTID uint8 // zero for any free TID
 }
 type agAllocateTIDRespT struct {
 	uniqueTID   dg.WordT
@@ -230,7 +482,7 @@ type agAllocateTIDRespT struct {
 
 func agAllocateTID(req agAllocateTIDReqT) (resp agAllocateTIDRespT) {
 	var ok bool
-	resp.standardTID, ok = getNextFreeTID(req.PID)
+	resp.standardTID, ok = getNextFreeTID(req.PID, req.wantTID)
 	if !ok {
 		return resp
 	}
@@ -240,65 +492,71 @@ func agAllocateTID(req agAllocateTIDReqT) (resp agAllocateTIDRespT) {
 	return resp
 }
 
-type agGchrReqT struct {
-	PID         dg.WordT
-	getDefaults bool // otherwise get current
-	useChan     bool // otherwise use name
-	devChan     dg.WordT
-	devName     string
+type agFreeTIDReqT struct {
+	PID, TID dg.WordT
+	task     *taskT // the TID may already have been reused after a ?CHAIN
 }
-type agGchrRespT struct {
-	words [3]dg.WordT
+type agFreeTIDRespT struct {
+	lastTask bool // no tasks remain in the process
 }
 
-func agGetChars(req agGchrReqT) (resp agGchrRespT) {
-
+// agFreeTID releases the TID of a task which has ended
+func agFreeTID(req agFreeTIDReqT) (resp agFreeTIDRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	if ppd.tasks[req.TID] != req.task {
+		return resp
+	}
+	ppd.tidsInUse[req.TID] = false
+	ppd.tasks[req.TID] = nil
+	resp.lastTask = true
+	for t := 1; t < maxTasksPerProc; t++ {
+		if ppd.tidsInUse[t] {
+			resp.lastTask = false
+			break
+		}
+	}
+	logging.DebugPrint(logging.ScLog, "AGENT freed TID %d for PID %d, last task: %v\n", req.TID, req.PID, resp.lastTask)
 	return resp
 }
 
 type agGtMesReqT struct {
-	PID  dg.WordT
-	greq dg.WordT
-	gnum dg.WordT
-	gsw  dg.DwordT
+	PID    dg.WordT
+	greq   dg.WordT
+	gnum   dg.WordT
+	swName string // the switch sought by ?GTSW
 }
 type agGtMesRespT struct {
 	ac0, ac1 dg.DwordT
 	result   string
+	errCode  dg.WordT
 }
 
 func agGetMessage(req agGtMesReqT) (resp agGtMesRespT) {
-	switch req.greq {
-	case gmes: // get entire message
-		first := true
-		for _, arg := range PerProcessData[int(req.PID)].invocationArgs {
-			if first {
-				first = false
-			} else {
-				resp.result += " "
-			}
-			resp.result += strings.ToUpper(arg)
+	args := PerProcessData[int(req.PID)].invocationArgs
+	upper := func(s string) string {
+		if req.greq&gdlc != 0 {
+			return s
 		}
+		return strings.ToUpper(s)
+	}
+	reqType := req.greq &^ gdlc
+	if (reqType == garg || reqType == gtsw || reqType == gsws) && int(req.gnum) > len(args)-1 {
+		logging.DebugPrint(logging.ScLog, "?GTMES attempted to retrieve non-extant argument no. %d.\n", req.gnum)
+		resp.errCode = erarg
+		return resp
+	}
+	switch reqType {
+	case gmes: // get entire message
+		resp.result = upper(strings.Join(args, " "))
 		resp.ac0 = gfcf
 		resp.ac1 = dg.DwordT(len(resp.result)) >> 1 // words not bytes
 	case gcmd: // get a parsed version of the command line
-		first := true
-		for _, arg := range PerProcessData[int(req.PID)].invocationArgs {
-			if first {
-				first = false
-			} else {
-				resp.result += ","
-			}
-			resp.result += strings.ToUpper(arg)
-		}
+		resp.result = upper(strings.Join(args, ","))
 		resp.ac1 = dg.DwordT(len(resp.result))
 	case gcnt:
-		resp.ac0 = dg.DwordT(len(PerProcessData[int(req.PID)].invocationArgs) - 1)
-	case garg: // get the nth arg - special handing for integers
-		if int(req.gnum) > len(PerProcessData[int(req.PID)].invocationArgs)-1 {
-			log.Panicf("ERROR: ?GTMES attempted to retrieve non-extant argument no. %d.", req.gnum)
-		}
-		argS := PerProcessData[int(req.PID)].invocationArgs[int(req.gnum)]
+		resp.ac0 = dg.DwordT(len(args) - 1)
+	case garg: // get the nth arg without its switches - special handing for integers
+		argS := parseCLIArg(args[int(req.gnum)]).text
 		i, err := strconv.ParseInt(argS, 10, 16)
 		if err == nil { // integer-only case
 			resp.ac1 = dg.DwordT(i)
@@ -307,32 +565,67 @@ func agGetMessage(req agGtMesReqT) (resp agGtMesRespT) {
 			resp.result = argS + "\x00"
 			resp.ac0 = dg.DwordT(len(argS))
 		}
-	case gtsw:
-		// TODO faked for now
-		logging.DebugPrint(logging.ScLog, "WARNING: Faking empty ?GTSW response to ?GTMES system call\n")
-		resp.ac0 = 0xffff_ffff
-		resp.ac1 = 0
-	case gsws:
-		// TODO faked for now
-		logging.DebugPrint(logging.ScLog, "WARNING: Faking empty ?GSWS response to ?GTMES system call\n")
-		resp.ac0 = 0
-		resp.ac1 = 0
+	case gtsw: // test for a single switch, returning any value it has
+		sw, found := parseCLIArg(args[int(req.gnum)]).findSwitch(req.swName)
+		switch {
+		case !found:
+			resp.ac0 = 0xffff_ffff
+		case !sw.hasValue:
+			resp.ac0 = 0
+		default:
+			val, isNum, tooBig := sw.numericValue()
+			switch {
+			case tooBig:
+				resp.errCode = erisv
+			case isNum:
+				resp.ac1 = dg.DwordT(val)
+			default:
+				resp.result = upper(sw.value) + "\x00"
+				resp.ac0 = dg.DwordT(len(sw.value))
+			}
+		}
+	case gsws: // all the single-letter switches of an argument
+		resp.ac0, resp.ac1 = parseCLIArg(args[int(req.gnum)]).switchMask()
 	default:
-		log.Panicf("ERROR: ?GTMES request type %#x not yet supported\n", req.greq)
+		logging.DebugPrint(logging.ScLog, "?GTMES request type %#x not supported\n", req.greq)
+		resp.errCode = erpre
 	}
 	logging.DebugPrint(logging.ScLog, "?GTMES returning %s\n", resp.result)
 	return resp
 }
 
-// type agUidstatReqT struct {
-// 	PID, TID int
-// }
-// type agUidstatRespT struct {
-// 	uniqueTID   uint16
-// 	tsw         dg.WordT
-// 	standardTID uint8
-// 	priority    dg.WordT
-// }
-// func agUidstat(req agUidstatReqT) (resp agUidstatRespT) {
-
-// }
+type agUidstatReqT struct {
+	PID, TID dg.WordT // TID is a unique TID, or a standard one if standard is set
+	standard bool
+}
+type agUidstatRespT struct {
+	uniqueTID, standardTID dg.WordT
+	tsw, priority          dg.WordT
+	errCode                dg.WordT
+}
+
+// agUidstat returns the IDs, status word and priority of a task in the caller's process
+func agUidstat(req agUidstatReqT) (resp agUidstatRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	TID := req.TID
+	if !req.standard {
+		if TID>>8 != req.PID&0xff {
+			resp.errCode = ertid
+			return resp
+		}
+		TID &= 0xff
+	}
+	if TID == 0 || TID >= maxTasksPerProc || ppd.tasks[TID] == nil {
+		resp.errCode = ertid
+		return resp
+	}
+	var ok bool
+	resp.tsw, resp.priority, ok = ppd.sched.status(TID)
+	if !ok {
+		resp.errCode = ertid
+		return resp
+	}
+	resp.uniqueTID = ppd.tasks[TID].uniqueTID
+	resp.standardTID = TID
+	return resp
+}
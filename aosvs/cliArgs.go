@@ -0,0 +1,151 @@
+// +build virtual !physical
+
+// cliArgs.go - CLI-style splitting of invocation messages and parsing of switches
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// cliSwitchT is one /NAME or /NAME=VALUE switch attached to an argument
+type cliSwitchT struct {
+	name, value string
+	hasValue    bool
+}
+
+// cliArgT is an argument broken into its text and its switches
+type cliArgT struct {
+	text     string
+	switches []cliSwitchT
+}
+
+// SplitCLIArgs breaks a command line into arguments in the way the CLI does,
+// arguments are separated by spaces or commas except within double-quotes.
+// The quotes are retained so that switch parsing can respect them.
+func SplitCLIArgs(line string) (args []string) {
+	var (
+		arg      strings.Builder
+		inQuotes bool
+		started  bool
+	)
+	for _, c := range line {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			started = true
+			arg.WriteRune(c)
+		case !inQuotes && (c == ' ' || c == '\t' || c == ','):
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			started = true
+			arg.WriteRune(c)
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// parseCLIArg separates an argument from its switches, switch names are upper-cased
+// but values are left alone, quotes are removed from both
+func parseCLIArg(arg string) (parsed cliArgT) {
+	var parts []string
+	var part strings.Builder
+	inQuotes := false
+	for _, c := range arg {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '/' && !inQuotes:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(c)
+		}
+	}
+	parts = append(parts, part.String())
+	parsed.text = parts[0]
+	for _, sw := range parts[1:] {
+		if sw == "" {
+			continue
+		}
+		var s cliSwitchT
+		if eq := strings.IndexByte(sw, '='); eq != -1 {
+			s.name, s.value, s.hasValue = sw[:eq], sw[eq+1:], true
+		} else {
+			s.name = sw
+		}
+		s.name = strings.ToUpper(s.name)
+		parsed.switches = append(parsed.switches, s)
+	}
+	return parsed
+}
+
+// findSwitch returns the named switch of the argument if it was given
+func (a cliArgT) findSwitch(name string) (sw cliSwitchT, found bool) {
+	name = strings.ToUpper(strings.TrimPrefix(name, "/"))
+	for _, sw = range a.switches {
+		if sw.name == name {
+			return sw, true
+		}
+	}
+	return sw, false
+}
+
+// numericValue returns the value of a switch if it is a decimal number,
+// isNum is false for non-numeric values and tooBig is set if it will not fit in a word
+func (s cliSwitchT) numericValue() (val uint64, isNum, tooBig bool) {
+	val, err := strconv.ParseUint(s.value, 10, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return 0, true, true
+		}
+		return 0, false, false
+	}
+	return val, true, val > 0xffff
+}
+
+// switchMask returns the single-letter switches of an argument as the pair of
+// bit maps used by ?GSWS, A-P in the first word and Q-Z in the second
+func (a cliArgT) switchMask() (aToP, qToZ dg.DwordT) {
+	for _, sw := range a.switches {
+		if len(sw.name) != 1 || sw.name[0] < 'A' || sw.name[0] > 'Z' {
+			continue
+		}
+		bit := int(sw.name[0] - 'A')
+		if bit < 16 {
+			aToP |= 0x8000 >> bit
+		} else {
+			qToZ |= 0x8000 >> (bit - 16)
+		}
+	}
+	return aToP, qToZ
+}
@@ -0,0 +1,121 @@
+// +build virtual !physical
+
+// cliArgs_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+func TestSplitCLIArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+	}{
+		{"", nil},
+		{"PROG", []string{"PROG"}},
+		{"  PROG  A,B\tC ", []string{"PROG", "A", "B", "C"}},
+		{"PROG/L=LIST FILE1/S", []string{"PROG/L=LIST", "FILE1/S"}},
+		{`PROG "A B",C`, []string{"PROG", `"A B"`, "C"}},
+		{`PROG "A,B"/S`, []string{"PROG", `"A,B"/S`}},
+		{`PROG ""`, []string{"PROG", `""`}},
+	}
+	for _, tt := range tests {
+		if args := SplitCLIArgs(tt.line); !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.args, args)
+		}
+	}
+}
+
+func TestParseCLIArg(t *testing.T) {
+	tests := []struct {
+		arg    string
+		parsed cliArgT
+	}{
+		{"FILE", cliArgT{text: "FILE"}},
+		{"file/s", cliArgT{"file", []cliSwitchT{{name: "S"}}}},
+		{"5/X", cliArgT{"5", []cliSwitchT{{name: "X"}}}},
+		{"/L=list/2", cliArgT{"", []cliSwitchT{{"L", "list", true}, {name: "2"}}}},
+		{`"A/B"/S="x y"`, cliArgT{"A/B", []cliSwitchT{{"S", "x y", true}}}},
+		{"A//S=", cliArgT{"A", []cliSwitchT{{"S", "", true}}}},
+	}
+	for _, tt := range tests {
+		if parsed := parseCLIArg(tt.arg); !reflect.DeepEqual(parsed, tt.parsed) {
+			t.Errorf("%q: expected %+v, got %+v", tt.arg, tt.parsed, parsed)
+		}
+	}
+}
+
+func TestFindSwitchAndValue(t *testing.T) {
+	arg := parseCLIArg("PROG/N=123/BIG=70000/S=name/F")
+	tests := []struct {
+		name          string
+		found         bool
+		val           uint64
+		isNum, tooBig bool
+	}{
+		{"/N", true, 123, true, false},
+		{"n", true, 123, true, false},
+		{"BIG", true, 0, true, true},
+		{"S", true, 0, false, false},
+		{"F", true, 0, false, false},
+		{"X", false, 0, false, false},
+	}
+	for _, tt := range tests {
+		sw, found := arg.findSwitch(tt.name)
+		if found != tt.found {
+			t.Errorf("%s: expected found %v, got %v", tt.name, tt.found, found)
+			continue
+		}
+		if !found {
+			continue
+		}
+		val, isNum, tooBig := sw.numericValue()
+		if isNum != tt.isNum || tooBig != tt.tooBig || (isNum && !tooBig && val != tt.val) {
+			t.Errorf("%s: expected %d %v %v, got %d %v %v", tt.name, tt.val, tt.isNum, tt.tooBig, val, isNum, tooBig)
+		}
+	}
+}
+
+func TestSwitchMask(t *testing.T) {
+	tests := []struct {
+		arg        string
+		aToP, qToZ dg.DwordT
+	}{
+		{"PROG", 0, 0},
+		{"PROG/A", 0x8000, 0},
+		{"PROG/P", 0x0001, 0},
+		{"PROG/Q", 0, 0x8000},
+		{"PROG/Z", 0, 0x0040},
+		{"PROG/a/c/L=X/LONG/1", 0xa010, 0},
+	}
+	for _, tt := range tests {
+		aToP, qToZ := parseCLIArg(tt.arg).switchMask()
+		if aToP != tt.aToP || qToZ != tt.qToZ {
+			t.Errorf("%q: expected %#x %#x, got %#x %#x", tt.arg, tt.aToP, tt.qToZ, aToP, qToZ)
+		}
+	}
+}
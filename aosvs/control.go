@@ -0,0 +1,220 @@
+// +build virtual !physical
+
+// control.go - run limits and the emulator's supervisory control of processes
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+// RunLimitsT limits every process the emulator runs, zero values mean no limit
+type RunLimitsT struct {
+	MaxInstrs uint64        // instructions executed
+	MaxTime   time.Duration // elapsed time since the process was created
+	MaxPages  int           // unshared memory pages
+}
+
+var runLimits RunLimitsT
+
+const limitCheckInterval = 100 * time.Millisecond
+
+// SetRunLimits sets the limits for processes created from now on
+func SetRunLimits(limits RunLimitsT) {
+	runLimits = limits
+}
+
+// SetDebugLogging turns debug logging on or off, including for running processes
+func SetDebugLogging(on bool) {
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentControl, agControlReqT{action: controlLogging, on: on}, nil}
+	<-gw
+}
+
+// DebugLogging reports whether debug logging is on
+func DebugLogging() bool {
+	return debugLogging
+}
+
+// TaskInfoT describes a task for the control channel
+type TaskInfoT struct {
+	TID       int
+	Priority  int
+	PC        uint32
+	Running   bool // holds the process's CPU
+	Suspended bool
+}
+
+// ProcessInfoT describes a process for the control channel
+type ProcessInfoT struct {
+	PID       int
+	FatherPID int
+	Name      string
+	Username  string
+	Program   string
+	Console   string
+	Created   time.Time
+	Instrs    uint64
+	Paused    bool
+	Tasks     []TaskInfoT
+}
+
+// Processes returns the state of every process, in PID order
+func Processes() []ProcessInfoT {
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentControl, agControlReqT{action: controlList}, nil}
+	areq := <-gw
+	return areq.result.(agControlRespT).procs
+}
+
+// PauseProcess stops or restarts all the tasks of a process
+func PauseProcess(PID int, pause bool) error {
+	return control(agControlReqT{action: controlPause, PID: dg.WordT(PID), on: pause})
+}
+
+// AbortProcess terminates a process, its father gets an abort with the given message
+func AbortProcess(PID int, message string) error {
+	return control(agControlReqT{action: controlAbort, PID: dg.WordT(PID), message: message})
+}
+
+func control(req agControlReqT) error {
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentControl, req, nil}
+	areq := <-gw
+	if areq.result.(agControlRespT).errCode != 0 {
+		return fmt.Errorf("no such process: %d", req.PID)
+	}
+	return nil
+}
+
+// control channel actions
+const (
+	controlList = iota
+	controlPause
+	controlAbort
+	controlLogging
+)
+
+type agControlReqT struct {
+	action  int
+	PID     dg.WordT
+	on      bool
+	message string
+}
+type agControlRespT struct {
+	procs   []ProcessInfoT
+	errCode dg.WordT
+}
+
+// agControl handles requests from the emulator's control channel
+func agControl(req agControlReqT) (resp agControlRespT) {
+	switch req.action {
+	case controlList:
+		for PID, ppd := range PerProcessData {
+			resp.procs = append(resp.procs, ProcessInfoT{
+				PID:       PID,
+				FatherPID: int(ppd.fatherPID),
+				Name:      ppd.name,
+				Username:  ppd.username,
+				Program:   ppd.programFileName,
+				Console:   ppd.consoleName,
+				Created:   ppd.created,
+				Instrs:    ppd.sched.instrCount(),
+				Paused:    ppd.paused,
+				Tasks:     ppd.sched.taskStates(),
+			})
+		}
+		sort.Slice(resp.procs, func(i, j int) bool { return resp.procs[i].PID < resp.procs[j].PID })
+	case controlLogging:
+		debugLogging = req.on
+		for _, ppd := range PerProcessData {
+			ppd.sched.setDebugLogging(req.on)
+		}
+	case controlPause:
+		ppd, found := PerProcessData[int(req.PID)]
+		if !found {
+			resp.errCode = erprh
+			return resp
+		}
+		ppd.paused = req.on
+		ppd.sched.setPaused(req.on)
+	case controlAbort:
+		if _, found := PerProcessData[int(req.PID)]; !found {
+			resp.errCode = erprh
+			return resp
+		}
+		agAbort(req.PID, req.message)
+	}
+	return resp
+}
+
+// agAbort terminates a process with an abort message, which is reported like that of any
+// other termination
+func agAbort(PID dg.WordT, message string) {
+	ppd := PerProcessData[int(PID)]
+	if ppd == nil || ppd.terminating {
+		return
+	}
+	logging.DebugPrint(logging.ScLog, "AGENT aborting PID %d: %s\n", PID, message)
+	agTerminate(agTerminateReqT{PID: PID, info: termInfoT{flags: Rfab | Rfcf, message: message, byTerm: true}})
+}
+
+// limitWatcher aborts a process which exceeds the instruction or time limit
+func limitWatcher(PID dg.WordT, ppd *PerProcessDataT) {
+	limits := runLimits
+	if limits.MaxInstrs == 0 && limits.MaxTime == 0 {
+		return
+	}
+	var deadline <-chan time.Time
+	if limits.MaxTime > 0 {
+		timer := time.NewTimer(limits.MaxTime)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(limitCheckInterval)
+	defer ticker.Stop()
+	var message string
+	for message == "" {
+		select {
+		case <-ppd.doneChan:
+			return
+		case <-deadline:
+			message = "TIME LIMIT EXCEEDED"
+		case <-ticker.C:
+			if limits.MaxInstrs > 0 && ppd.sched.instrCount() > limits.MaxInstrs {
+				message = "INSTRUCTION LIMIT EXCEEDED"
+			}
+		}
+	}
+	gw := newAgentGateway()
+	defer close(gw)
+	gw <- AgentReqT{agentControl, agControlReqT{action: controlAbort, PID: PID, message: message}, nil}
+	<-gw
+}
@@ -0,0 +1,347 @@
+// +build virtual !physical
+
+// ermes.go - error message texts for ?ERMSG
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// ermesTable holds the text of every system error code defined in PARU.32,
+// it is used when ?ERMSG is not given an error message file of its own
+var ermesTable = map[dg.WordT]string{
+	ericm: "ILLEGAL SYSTEM COMMAND",
+	erfno: "CHANNEL NOT OPEN",
+	eropr: "CHANNEL ALREADY OPEN",
+	ersal: "SHARED I/O REQ NOT MAP SLOT ALIGNED",
+	ermem: "INSUFFICIENT MEMORY AVAILABLE",
+	eradr: "ILLEGAL STARTING ADDRESS",
+	erovn: "ILLEGAL OVERLAY NUMBER",
+	ertim: "ILLEGAL TIME ARGUMENT",
+	ernot: "NO TASK CONTROL BLOCK AVAILABLE",
+	erxmt: "SIGNAL TO ADDRESS ALREADY IN USE",
+	erqts: "ERROR IN QTASK REQUEST",
+	ertid: "TASK I.D. ERROR",
+	erdch: "DATA CHANNEL MAP FULL",
+	ermpr: "SYSTEM CALL PARAMETER ADDRESS ERROR",
+	erabt: "TASK NOT FOUND FOR ABORT",
+	erirb: "INSUFFICIENT ROOM IN BUFFER",
+	erspc: "FILE SPACE EXHAUSTED",
+	ersft: "USER STACK FAULT",
+	erdde: "DIRECTORY DOES NOT EXIST",
+	erifc: "ILLEGAL FILENAME CHARACTER",
+	erfde: "FILE DOES NOT EXIST",
+	ernae: "FILE NAME ALREADY EXISTS",
+	ernad: "NON-DIRECTORY ARGUMENT IN PATHNAME",
+	ereof: "END OF FILE",
+	erdid: "DIRECTORY DELETE ERROR",
+	erwad: "WRITE ACCESS DENIED",
+	errad: "READ ACCESS DENIED",
+	erawd: "APPEND AND/OR WRITE ACCESS DENIED",
+	ernmc: "NO CHANNELS AVAILABLE",
+	ersrl: "RELEASE OF NON-ACTIVE SHARED SLOT",
+	erprp: "ILLEGAL PRIORITY",
+	erbmx: "ILLEGAL MAX SIZE ON PROCESS CREATE",
+	erpty: "ILLEGAL PROCESS TYPE",
+	ercon: "CONSOLE DEVICE SPECIFICATION ERROR",
+	ernsw: "SWAP FILE SPACE EXHAUSTED",
+	eribs: "DEVICE ALREADY IN SYSTEM",
+	erdnm: "ILLEGAL DEVICE CODE",
+	ershp: "ERROR ON SHARED PARTITION SET",
+	errmp: "ERROR ON REMAP CALL",
+	ergsg: "ILLEGAL AGENT GATE CALL",
+	erprn: "NUMBER OF PROCESSES EXCEEDS MAX",
+	ernef: "IPC MESSAGE EXCEEDS BUFFER LENGTH",
+	erivp: "INVALID PORT NUMBER",
+	ernms: "NO MATCHING SEND",
+	ernor: "NO OUTSTANDING RECEIVE",
+	eriop: "ILLEGAL ORIGIN PORT",
+	eridp: "ILLEGAL DESTINATION PORT",
+	ersen: "INVALID SHARED LIBRARY REFERENCE",
+	erirl: "ILLEGAL RECORD LENGTH SPECIFIED(=0)",
+	erarc: "ATTEMPT TO RELEASE CONSOLE DEVICE",
+	erdai: "DEVICE ALREADY IN USE",
+	eraru: "ATTEMPT TO RELEASE UNASSIGNED DEVICE",
+	eracu: "ATTEMPT TO CLOSE UNOPEN CHANNEL/DEVICE",
+	eritc: "I/O TERMINATED BY CLOSE",
+	erltl: "LINE TOO LONG",
+	erpar: "PARITY ERROR",
+	erexc: "RESDENT PROC TRIED TO PUSH (.EXEC)",
+	erndr: "NOT A DIRECTORY",
+	ernsa: "SHARED I/O REQUEST NOT TO SHARED AREA",
+	ersnm: "ATTEMPT TO CREATE > MAX # SONS",
+	erfil: "FILE READ ERROR",
+	erdto: "DEVICE TIMEOUT",
+	eriot: "WRONG TYPE I/O FOR OPEN TYPE",
+	erftl: "FILENAME TOO LONG",
+	erbof: "POSITIONING BEFORE BEGINNING OF FILE",
+	erprv: "CALLER NOT PRIVILEGED FOR THIS ACTION",
+	ersim: "SIMULTANEOUS REQUESTS ON SAME CHANNEL",
+	erift: "ILLEGAL FILE TYPE",
+	ernrd: "INSUFFICIENT ROOM IN DIRECTORY",
+	erilo: "ILLEGAL OPEN",
+	erprh: "ATTEMPT TO ACCESS PROC NOT IN HIERARCHY",
+	erblr: "ATTEMPT TO BLOCK UNBLOCKABLE PROC",
+	erpre: "INVALID SYSTEM CALL PARAMETER",
+	erges: "ATTEMPT TO START MULTIPLE AGENTS",
+	erciu: "CHANNEL IN USE",
+	ericb: "INSUFFICIENT CONTIGUOUS DISK BLOCKS",
+	ersto: "STACK OVERFLOW",
+	eribm: "INCONSISTENT BIT MAP DATA",
+	erbsz: "ILLEGAL BLOCK SIZE FOR DEVICE",
+	erxmz: "ATTEMPT TO XMT ILLEGAL MESSAGE",
+	erpuf: "PHYSICAL UNIT FAILURE",
+	erpwl: "PHYSICAL WRITE LOCK",
+	eruol: "PHYSICAL UNIT OFFLINE",
+	erioo: "ILLEGAL OPEN OPTION FOR FILE TYPE",
+	erndv: "TOO MANY OR TOO FEW DEVICE NAMES",
+	ermis: "DISK AND FILE SYS REV #'S DON'T MATCH",
+	eridd: "INCONSISTENT DIB DATA",
+	erild: "INCONSISTENT LD",
+	eridu: "INCOMPLETE LD",
+	eridt: "ILLEGAL DEVICE NAME TYPE",
+	erpdf: "ERROR IN PROCESS UST DEFINITION",
+	erviu: "LD IN USE, CANNOT RELEASE",
+	ersre: "SEARCH LIST RESOLUTION ERROR",
+	ercgf: "CAN'T GET IPC DATA FROM FATHER",
+	erilb: "ILLEGAL LIBRARY NUMBER GIVEN",
+	errfm: "ILLEGAL RECORD FORMAT",
+	erarg: "TOO MANY OR TOO FEW ARGUMENTS TO PMGR",
+	erigm: "ILLEGAL ?GTMES PARAMETERS",
+	ericl: "ILLEGAL CLI MESSAGE",
+	ermrd: "MESSAGE RECEIVE DISABLED",
+	ernac: "NOT A CONSOLE DEVICE",
+	ermil: "ATTEMPT TO EXCEED MAX INDEX LEVEL",
+	ericn: "ILLEGAL CHANNEL",
+	ernrr: "NO RECEIVER WAITING",
+	ersrr: "SHORT RECEIVE REQUEST",
+	ertin: "TRANSMITTER INOPERATIVE",
+	erunm: "ILLEGAL USER NAME",
+	eriln: "ILLEGAL LINK #",
+	erdpe: "DISK POSITIONING ERROR",
+	ertxt: "MSG TEXT LONGER THAN SPEC'D.",
+	erstr: "SHORT TRANSMISSION",
+	erhis: "ERROR ON HISTOGRAM INIT/DELETE",
+	erirv: "ILLEGAL RETRY VALUE",
+	erass: "ASSIGN ERROR - ALREADY YOUR DEVICE",
+	erpet: "MAG TAPE REQ PAST LOGICAL END OF TAPE",
+	ersts: "STACK TOO SMALL (?TASK)",
+	ertmt: "TOO MANY TASKS REQUESTED (?TASK)",
+	ersoc: "SPOOLER OPEN RETRY COUNT EXCEEDED",
+	eracl: "ILLEGAL ACL",
+	erwpb: "?STMAP BUFFER INVALID OR WRITE PROTECTED",
+	erinp: "IPC FILE NOT OPENED BY ANOTHER PROC",
+	erfpu: "FPU HARDWARE NOT INSTALLED",
+	erpnm: "ILLEGAL PROCESS NAME",
+	erpnu: "PROCESS NAME ALREADY IN USE",
+	erdct: "DISCONNECT ERROR (MODEM CONTROLLED)",
+	eripr: "NONBLOCKING PROC REQUEST ERROR",
+	ersni: "SYSTEM NOT INSTALLED",
+	erlvl: "MAX DIRECTORY TREE DEPTH EXCEEDED",
+	erroo: "RELEASING OUT-OF-USE OVERLAY",
+	errdl: "RESOURCE DEADLOCK",
+	ereo1: "FILE IS OPEN, CAN'T EXCLUSIVE OPEN",
+	ereo2: "FILE IS EXCLUSIVE OPENED, CAN'T OPEN",
+	eripd: "INIT PRIVILEGE DENIED",
+	ermim: "MULTIPLE ?IMSG CALLS TO SAME DCT",
+	erlnk: "ILLEGAL LINK",
+	eridf: "ILLEGAL DUMP FORMAT",
+	erxna: "EXEC NOT AVAILABLE (MOUNT, ETC.)",
+	erxuf: "EXEC REQUEST FUNCTION UNKNOWN",
+	ereso: "ONLY EXEC'S SONS CAN DO THAT",
+	errbo: "REFUSED BY OPERATOR",
+	erwmt: "VOLUME NOT MOUNTED",
+	erisv: "ILLEGAL SWITCH VALUE (>65K DECIMAL)",
+	erifn: "INPUT FILE DOES NOT EXIST",
+	erofn: "OUTPUT FILE DOES NOT EXIST",
+	erlfn: "LIST FILE DOES NOT EXIST",
+	erdfn: "DATA FILE DOES NOT EXIST",
+	ergfe: "RECURSIVE GENERIC FILE OPEN FAILURE",
+	ernmw: "NO MESSAGE WAITING",
+	ernud: "USER DATA AREA DOES NOT EXIST",
+	erdvc: "ILLEGAL DEVICE TYPE FROM VSGEN",
+	errst: "RESTART OF SYSTEM CALL",
+	erfur: "PROBABLY FATAL HARDWARE RUNTIME ERROR",
+	ercft: "USER COMMERCIAL STACK FAULT",
+	erfft: "USER FLOATING POINT STACK FAULT",
+	eruae: "USER DATA AREA ALREADY EXISTS",
+	eriso: "ILLEGAL SCREEN_EDIT REQUEST (PMGR)",
+	erddh: "?SEND DESTINATION DEVICE HELD BY ^S",
+	erovr: "DATA OVERRUN ERROR",
+	ercpd: "CONTROL POINT DIRECTORY MAX SIZE EXCEEDED",
+	ernsd: "SYS OR BOOT DISK NOT PART OF MASTER LD",
+	erusy: "UNIVERSAL SYSTEM, YOU CAN'T DO THAT",
+	eread: "EXECUTE ACCESS DENIED",
+	erfix: "CAN'T INIT LD, RUN FIXUP ON IT",
+	erfad: "FILE ACCESS DENIED",
+	erdad: "DIRECTORY ACCESS DENIED",
+	eriad: "ATTEMPT TO DEFINE > 1 SPECIAL PROC",
+	erind: "NO SPECIAL PROCESS IS DEFINED",
+	erpro: "ATTEMPT TO ISSUE MCA REQUEST WITH",
+	erdio: "ATTEMPT TO ISSUE MCA DIRECT I/O WITH",
+	erltk: "LAST TASK WAS KILLED",
+	erlrf: "RESOURCE LOAD OR RELEASE FAILURE",
+	ernnl: "ZERO LENGTH FILENAME SPECIFIED",
+	ervwp: "INVALID ADDRESS PASSED AS SYSTEM CALL ARGUMENT",
+	ervbp: "INVALID BYTE POINTER PASSED AS SYS CALL ARGUMENT",
+	erdpt: "DIFFERENT TYPE PROCESS(32/16 BIT) WITHOUT PRIVILEGE",
+	erral: "RING ALREADY LOADED",
+	errni: "RING NUMBER INVALID",
+	errtb: "RING TOO BIG",
+	erwsm: "SET WKG SET MIN, NOT PRIVILEGED",
+	ertne: "PMGR- TRACING NOT ENABLED",
+	ertae: "PMGR- TRACING ALREADY ENABLED",
+	ernuf: "PMGR- TRACING FILE NOT A USER DATA FILE",
+	errna: "PMGR- REQUESTOR NOT TRACING AUTHORIZED",
+	erpnl: "PMGR- PATHNAME LENGTH AREA",
+	ersnf: "SYMBOL NOT FOUND IN .ST FILE",
+	ersnr: "SOURCE NOT RESIDENT ON ?LMAP",
+	erdnr: "DESTINATION NOT RESIDENT ON ?LMAP",
+	eribp: "BKPT SEEN IN USER PROGRAM WHEN DEBUG NOT INIT'ED",
+	erbst: "BAD SYMBOL TABLE FORMAT SEEN (?GTNA CALL)",
+	erpdo: "PAGE FILE DIRECTORY OVERFLOW",
+	ermwt: "MORE THAN ONE WS TRACE DEFINED ON TARGET",
+	erhwt: "BOTH TRACE AND HISTOGRAM CALLED, OR > 1 TRACE",
+	erdtc: "DIFFERENT TYPE CHAIN",
+	erwst: "NO WS TRACE DEFINED ON THIS TARGET",
+	erwss: "INVALID WKG SET MAX/MIN",
+	erwsb: "INVALID WORKING SET TRACE BUFFER",
+	erwsf: "WORKING SET NOT SWAPPABLE",
+	erawm: "TRYING TO WIRE MORE PAGES THAN WS MAX",
+	ertpw: "TOO MANY PAGES WIRED",
+	eracc: "ACCESS DENIED ON ?VALAD",
+	errnl: "RING NOT LOADED",
+	ertal: "TOO MANY ARGUMENTS ON LCALL",
+	erxbl: "?IXIT FROM BASE LEVEL",
+	erppr: "PMGR PANIC REQUESTED BY NON-PMGR PROCESS",
+	ersci: "SYSTEM CALL AT INTERRUPT LEVEL",
+	ernip: "PMGR -- NOT AN IAC PMGR",
+	ernid: "PMGR -- NOT AN IAC-DRIVEN DEVICE",
+	ersgo: "?SIGNL ALREADY OUTSTANDING",
+	erufr: "UNKNOWN REQUEST FUNCTION",
+	erifs: "ILLEGAL FED STRING",
+	era1o: "ATTEMPT TO 1ST OPEN AN OPEN FILE",
+	erifi: "INVALID PROTECTED FILE ID",
+	erapu: "ATTEMPT TO PASS UNHELD ACCESS PRIVILEGES",
+	ernbk: "NO BREAKFILE ENABLED FOR THIS RING",
+	ercds: "PMGR: MODEM DISCONNECT IN PROGRESS - CAN'T OPEN",
+	ertnf: "TASK IS NOT FAULTING",
+	ernmt: "MAP TARGET DOES NOT EXIST",
+	ermte: "MAP TARGET (ALREADY) MAPPED ELSEWHERE",
+	ermsi: "MAP SPECIFICATION ILLEGAL FOR TARGET",
+	errau: "MAP REGION ALREADY IN USE",
+	erjai: "JP ALREADY INITIALIZED",
+	erjni: "JP NOT INITIALIZED",
+	erlne: "LP DOES NOT EXIST",
+	erlai: "LP ALREADY EXISTS",
+	erljp: "ATTEMPT TO RELEASE LAST JP ATTACHED TO AN LP",
+	erijp: "INVALID JPID",
+	erilp: "INVALID LPID",
+	erjst: "JP RUNNING ONE OR MORE SYSTEM TASKS",
+	erjaa: "JP ALREADY ATTACHED TO LP",
+	erjna: "JP NOT ATTACHED TO LP",
+	ermlp: "ATTEMPT TO EXCEED MAXIMUM LP COUNT",
+	erjpa: "CANNOT DELETE LP WITH JP ATTACHED",
+	eriti: "INVALID TIME INTERVAL",
+	erici: "INVALID CLASS ID",
+	ercpc: "INVALID CLASS PERCENTAGE",
+	erihl: "INVALID HIERARCHICAL LEVEL",
+	erclu: "CLASS IN USE",
+	erimp: "ILLEGAL BIT MAP",
+	erilv: "ILLEGAL LOCALITY VALUE",
+	erlp0: "CANNOT DELETE LP 0",
+	ernmp: "NOT A MULTI-PROCESSOR SYSTEM",
+	ercne: "CLASS DOES NOT EXIST",
+	erhlp: "ILLEGAL HIERARCHY LEVEL / PERCENTAGE PAIR",
+	ericd: "ILLEGAL FUNCTION CODE",
+	erjps: "JP IS IN A BAD STATE",
+	ercmm: "MICROCODE IS INCOMPATIBLE WITH CURRENT SYSTEM",
+	ermcr: "INCORRECT MICROCODE REVISION",
+	ermff: "MICROCODE FILE FORMAT ERROR",
+	erucp: "INVALID CPU MODEL NUMBER",
+	ercso: "CLASS SCHEDULING IS ENABLED",
+	erhlt: "NON-SEQUENTIAL HIERARCHY LEVELS DESIGNATED",
+	erpor: "PID IS OUT OF RANGE FOR THIS PROCESS",
+	erpno: "PROCESS NOT AN OPERATOR",
+	erbce: "MAX BLOCK COUNT EXCEEDED",
+	erdeb: "DAEMON ERROR IN ERROR BUFFER",
+	erdrf: "DAEMON RESOURCE FAILURE",
+	erlas: "LOG ALREADY STARTED",
+	ercl0: "CANNOT DELETE CLASS 0",
+	erpvm: "UNKNOWN PRIVILEGE MODE",
+	erpvx: "PRIVILEGE HELD EXCLUSIVELY BY OTHER PROCESS",
+	erpvo: "PRIVILEGE CANNOT BE HELD EXCLUSIVELY",
+	erpvp: "OTHER PROCESSES USING PRIVILEGE",
+	erwcp: "WORKING SET CHANGE ONLY PARTLY DONE",
+	erfrd: "FAULT RECURSION DEPTH EXCEEDED",
+	eralp: "PROCESS'S CLASS NOT SCHEDULABLE ON AN ACTIVE LP",
+	ercll: "INVALID CELL COUNT",
+	ernml: "NO MICROCODE LOADED IN THIS JP",
+	eregn: "END OF GET NEXT SEQUENCE",
+	erctd: "CORRUPTED TASK CONTROL BLOCK DATA DETECTED",
+	eriwr: "INVALID WINDOW REFERENCE.",
+	erwnn: "MAXIMUM NUMBER OF WINDOWS EXCEEDED.",
+	erwmd: "WINDOW MARKED FOR DELETION.",
+	erigp: "INVALID GRAPHICS PARAMETER.",
+	eripp: "INVALID POINTER DEVICE PARAMETER.",
+	erivs: "INVALID VIEW OR SCAN PORT.",
+	eriwo: "INVALID WINDOWING OPERATION.",
+	eriwp: "INVALID WINDOWING PARAMETER.",
+	erade: "ASSOCIATION DOES NOT EXIST.",
+	eruwe: "UNKNOWN WINDOWING SUBSYSTEM ERROR",
+	ernsp: "HARDWARE/MICROCODE DOES NOT SUPPORT PIXEL MAPS",
+	erifl: "IAC FAILURE",
+	ertmo: "TOO MANY OPENS ON THIS DEVICE.",
+	ernas: "TARGET PROCESS IS NOT A SERVER",
+	erasv: "CALLER IS ALREADY A SERVER",
+	eracn: "CONNECTION ALREADY EXISTS",
+	ercnx: "CONNECTION DOES NOT EXIST",
+}
+
+// readErmes loads an ERMES-style text file, each line holds an octal error code
+// followed by its message, blank lines and those starting with ';' are ignored
+func readErmes(r io.Reader) map[dg.WordT]string {
+	msgs := make(map[dg.WordT]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' {
+			continue
+		}
+		sep := strings.IndexAny(line, " \t")
+		if sep < 0 {
+			continue
+		}
+		code, err := strconv.ParseUint(line[:sep], 8, 16)
+		if err != nil {
+			continue
+		}
+		msgs[dg.WordT(code)] = strings.TrimSpace(line[sep:])
+	}
+	return msgs
+}
@@ -0,0 +1,110 @@
+// +build virtual !physical
+
+// ermes_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/memory"
+	"github.com/SMerrony/dgemug/mvcpu"
+)
+
+func TestReadErmes(t *testing.T) {
+	text := "; test messages\n" +
+		"\n" +
+		"20 INSUFFICIENT ROOM\n" +
+		"  77\tSEVENTY-SEVEN  \n" +
+		"nonsense\n" +
+		"89 NOT OCTAL\n" +
+		"200000 TOO BIG\n" +
+		"21 FIRST\n" +
+		"21 SECOND\n"
+	expected := map[dg.WordT]string{020: "INSUFFICIENT ROOM", 077: "SEVENTY-SEVEN", 021: "SECOND"}
+	if msgs := readErmes(strings.NewReader(text)); !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("Expected %v, got %v", expected, msgs)
+	}
+}
+
+// ermsgTestParms returns ?ERMSG parameters for a buffer at ring 7 byte address 0200,
+// with an Agent that knows only of an error message file on channel 5
+func ermsgTestParms(t *testing.T, code dg.WordT, ac1 dg.DwordT) syscallParmsT {
+	path := filepath.Join(t.TempDir(), "ERMES")
+	if err := os.WriteFile(path, []byte("77 SEVENTY-SEVEN\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	agentChan := make(chan AgentReqT)
+	go func() {
+		for areq := range agentChan {
+			resp := agChannelInfoRespT{errCode: eracu}
+			if areq.reqParms.(agChannelInfoReqT).chanNo == 5 {
+				resp = agChannelInfoRespT{path: path}
+			}
+			areq.result = resp
+			agentChan <- areq
+		}
+	}()
+	t.Cleanup(func() { close(agentChan) })
+	mem := memory.NewAddrSpace()
+	cpu := new(mvcpu.CPUT)
+	cpu.SetAddrSpace(mem)
+	cpu.SetAc(0, dg.DwordT(code))
+	cpu.SetAc(1, ac1)
+	cpu.SetAc(2, 0200)
+	return syscallParmsT{cpu: cpu, mem: mem, PID: 1, TID: 1, ringMask: 0x7000_0000, agentChan: agentChan}
+}
+
+func TestErmsg(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   dg.WordT
+		ac1    dg.DwordT
+		ok     bool
+		result dg.DwordT
+		msg    string
+	}{
+		{"system message", erirb, ermsgDefaultFile<<16 | 80, true, 27, "INSUFFICIENT ROOM IN BUFFER"},
+		{"exact fit", erirb, ermsgDefaultFile<<16 | 27, true, 27, "INSUFFICIENT ROOM IN BUFFER"},
+		{"short buffer", erirb, ermsgDefaultFile<<16 | 26, false, erirb, ""},
+		{"unknown code", 07777, ermsgDefaultFile<<16 | 80, true, 23, "UNKNOWN ERROR CODE 7777"},
+		{"no default", 07777, ermsgDefaultFile<<16 | ermsgNoDefault | 80, false, erfde, ""},
+		{"message file", 077, 5<<16 | 80, true, 13, "SEVENTY-SEVEN"},
+		{"not in message file", erirb, 5<<16 | ermsgNoDefault | 80, false, erfde, ""},
+		{"closed channel", erirb, 6<<16 | 80, false, eracu, ""},
+	}
+	for _, tt := range tests {
+		p := ermsgTestParms(t, tt.code, tt.ac1)
+		if ok := scErmsg(p); ok != tt.ok || p.cpu.GetAc(0) != tt.result {
+			t.Errorf("%s: expected %v with AC0 %#o, got %v with %#o", tt.name, tt.ok, tt.result, ok, p.cpu.GetAc(0))
+			continue
+		}
+		if msg := readString(p.mem, 0200, p.ringMask); msg != tt.msg {
+			t.Errorf("%s: expected message %q, got %q", tt.name, tt.msg, msg)
+		}
+	}
+}
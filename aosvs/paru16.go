@@ -47,6 +47,11 @@ const (
 	idel16 = imrs16 + 1 // DELIMITER TABLE ADDRESS
 
 	iblt16 = idel16 + 1 // PACKET LENGTH
+
+	etsp16 = idel16 + 1 // SCREEN MANAGEMENT PACKET
+	etft16 = etsp16 + 1 // SELECTED FIELD TRANSLATION PACKET
+	etlt16 = etft16 + 1 // LABELED TAPE PACKET
+	enet16 = etlt16 + 1 // RESERVED
 )
 
 const (
@@ -59,6 +64,29 @@ const (
 	gtln16 = gres16 + 1 // PACKET LENGTH
 )
 
+const (
+	// PACKET FOR TASK DEFINITION (?TASK)
+	//
+	dlnk16  = 0           // NON-ZERO = SHORT PACKET, ZERO = EXTENDED
+	dpri16  = dlnk16 + 1  // PRIORITY, ZERO TO USE CALLER'S
+	did16   = dpri16 + 1  // I.D., ZERO FOR NONE
+	dpc16   = did16 + 1   // STARTING ADDRESS
+	dac216  = dpc16 + 1   // INITIAL AC2 CONTENTS
+	dstb16  = dac216 + 1  // STACK BASE, MINUS ONE FOR NO STACK
+	dsflt16 = dstb16 + 1  // STACK FAULT ROUTINE ADDR OR -1 IF SAME AS CURRENT
+	dssz16  = dsflt16 + 1 // STACK SIZE, IGNORED IF NO STACK
+	dflgs16 = dssz16 + 1  // FLAGS
+	dres16  = dflgs16 + 1 // RESERVED FOR SYSTEM
+	dnum16  = dres16 + 1  // NUMBER OF TASKS TO CREATE
+	dslth16 = dnum16 + 1  // LENGTH OF SHORT PACKET
+
+	dsh16   = dnum16 + 1 // STARTING HOUR, -1 IF IMMEDIATE
+	dsms16  = dsh16 + 1  // STARTING SECOND IN HOUR, IGNORED IF IMMEDIATE
+	dcc16   = dsms16 + 1 // NUMBER OF TIMES TO CREATE TASK(S)
+	dci16   = dcc16 + 1  // CREATION INCREMENT IN SECONDS
+	dxlth16 = dci16 + 1  // LENGTH OF EXTENDED PACKET
+)
+
 // This is just here as a sanity check; the locations DO correspond to those in paru32
 const (
 	// UST.16
@@ -319,6 +319,11 @@ const (
 	ernsp = eruwe + 1 // HARDWARE/MICROCODE DOES NOT SUPPORT PIXEL MAPS
 	erifl = ernsp + 1 // IAC FAILURE
 	ertmo = erifl + 1 // TOO MANY OPENS ON THIS DEVICE.
+	// CONNECTION MANAGEMENT
+	ernas = ertmo + 1 // TARGET PROCESS IS NOT A SERVER
+	erasv = ernas + 1 // CALLER IS ALREADY A SERVER
+	eracn = erasv + 1 // CONNECTION ALREADY EXISTS
+	ercnx = eracn + 1 // CONNECTION DOES NOT EXIST
 )
 
 // System Constants
@@ -401,24 +406,23 @@ const (
 	//   ustpb=  2B15    // PID SIZE TYPE 'HYBRID' (<256)
 	//   ustpc=  3B15    // PID SIZE TYPE 'ANYPID' (>256)
 	//
-	// // TASK STATUS BITS (RETURNED BY tidstat CALL)
-
-	//   tspn=  1B0     // TASK PENDED
-	//   tssg=  1B1     // WAITING FOR .XMTW/.REC
-	//   tssp=  1B2     // SUSPENDED
-	//   tsrc=  1B3     // WAITING FOR TRCON
-	//   tsov=  1B4     // WAITING FOR OVERLAY
-	//   tswp=  1B5     // UNPEND TASK VIA WDPOP
-	//   tsgs=  1B6     // TASK PENDED DUE TO AGENT SYNCHRONIZATION
-	//   tsab=  1B7     // PENDED AWAITING AGENT ABORT PROCESSING
-	//   tstl=  1B8     // PENDED AWAITING tunlock FROM ANOTHER TASK
-	//   tsyg=  1B9     // TASK HAS BEEN signled (NOT A PEND BIT)
-	//   tsdr=  1B10    // TASK PENDED FROM drsch
-	//   tslk=  1B11    // TASK PENDED ON A flock REQUEST
-	//   tsxr=  1B12    // TASK PENDED ON XMT OR REC
-	//   twsg=  1B13    // TASK wtsignl PENDED
-	//   tsut=  1B14    // AWAITING RETURN FROM USER utsk CODE
-	//   tsuk=  1B15    // AWAITING RETURN FROM USER ukil CODE
+	// TASK STATUS BITS (RETURNED BY tidstat CALL)
+	tspn = 1 << 15 //1B0     // TASK PENDED
+	tssg = 1 << 14 //1B1     // WAITING FOR .XMTW/.REC
+	tssp = 1 << 13 //1B2     // SUSPENDED
+	tsrc = 1 << 12 //1B3     // WAITING FOR TRCON
+	tsov = 1 << 11 //1B4     // WAITING FOR OVERLAY
+	tswp = 1 << 10 //1B5     // UNPEND TASK VIA WDPOP
+	tsgs = 1 << 9  //1B6     // TASK PENDED DUE TO AGENT SYNCHRONIZATION
+	tsab = 1 << 8  //1B7     // PENDED AWAITING AGENT ABORT PROCESSING
+	tstl = 1 << 7  //1B8     // PENDED AWAITING tunlock FROM ANOTHER TASK
+	tsyg = 1 << 6  //1B9     // TASK HAS BEEN signled (NOT A PEND BIT)
+	tsdr = 1 << 5  //1B10    // TASK PENDED FROM drsch
+	tslk = 1 << 4  //1B11    // TASK PENDED ON A flock REQUEST
+	tsxr = 1 << 3  //1B12    // TASK PENDED ON XMT OR REC
+	twsg = 1 << 2  //1B13    // TASK wtsignl PENDED
+	tsut = 1 << 1  //1B14    // AWAITING RETURN FROM USER utsk CODE
+	tsuk = 1 << 0  //1B15    // AWAITING RETURN FROM USER ukil CODE
 
 	// PACKET FOR TASK DEFINITION (task)
 	dlnk   = 0          // NON-ZERO = SHORT PACKET, ZERO = EXTENDED
@@ -573,6 +577,33 @@ const (
 	Rfec = 1 << 4 // 1B3             // ERROR CODE FLAGIF SET, AC0 CONTAINS ERROR CODE
 )
 
+// PACKET FOR PROCESS CREATION (proc)
+const (
+	pflg dg.PhysAddrT = 0        // PROCESS CREATION FLAGS (SEE BELOW)
+	ppri              = pflg + 1 // PROCESS PRIORITY (-1 FOR CALLER'S)
+	psnm              = ppri + 1 // BYTE POINTER TO PROGRAM NAME
+	pipc              = psnm + 2 // POINTER TO IPC HEADER (-1 FOR NONE)
+	pnm               = pipc + 2 // BYTE POINTER TO PROCESS NAME (-1 FOR DEFAULT)
+	pmem              = pnm + 2  // MAXIMUM MEMORY PAGES
+	pdir              = pmem + 2 // BYTE POINTER TO INITIAL DIRECTORY (-1 FOR CALLER'S)
+	pcon              = pdir + 2 // BYTE POINTER TO CONSOLE NAME (-1 FOR CALLER'S)
+	pcal              = pcon + 2 // MAX CONCURRENT SYSTEM CALLS
+	pwss              = pcal + 1 // MAXIMUM WORKING SET SIZE
+	punm              = pwss + 2 // BYTE POINTER TO USERNAME (-1 FOR CALLER'S)
+	pprv              = punm + 2 // PRIVILEGES
+	ppcr              = pprv + 1 // MAXIMUM NUMBER OF SONS
+	pwmi              = ppcr + 1 // WORKING SET MINIMUM
+	pifp              = pwmi + 2 // BYTE POINTER TO @INPUT FILE NAME
+	pofp              = pifp + 2 // BYTE POINTER TO @OUTPUT FILE NAME
+	plfp              = pofp + 2 // BYTE POINTER TO @LIST FILE NAME
+	pdfp              = plfp + 2 // BYTE POINTER TO @DATA FILE NAME
+	plth              = pdfp + 2 // PACKET LENGTH
+
+	// FLAGS (pflg)
+	pfex = 1 << 15 //1B0 SWAP FATHER UNTIL SON TERMINATES
+	pfbk = 1 << 14 //1B1 BLOCK CALLING TASK UNTIL SON TERMINATES
+)
+
 // PACKET TO GET INITIAL MESSAGE (gtmes)
 const (
 	greq dg.PhysAddrT = 0        // REQUEST TYPE (SEE BELOW)
@@ -1139,6 +1170,55 @@ const (
 	clth  = cmrs + 1 // LENGTH OF THE PARAMETER BLOCK
 )
 
+// TIME BLOCK FOR create
+const (
+	tcth = 0        // TIME CREATED (DAYS SINCE 31-DEC-67)
+	tctl = tcth + 1 // TIME CREATED (SECONDS/2 SINCE MIDNIGHT)
+	tath = tctl + 1 // TIME LAST ACCESSED (HIGH)
+	tatl = tath + 1 // TIME LAST ACCESSED (LOW)
+	tmth = tatl + 1 // TIME LAST MODIFIED (HIGH)
+	tmtl = tmth + 1 // TIME LAST MODIFIED (LOW)
+	tlth = tmtl + 1 // LENGTH OF TIME BLOCK
+)
+
+// PACKET RETURNED BY fstat
+const (
+	styp = 0        // RECORD FORMAT (LH) AND ENTRY TYPE (RH)
+	ssts = 1        // STATUS BITS
+	stch = 2        // TIME CREATED (HIGH)
+	stcl = stch + 1 // TIME CREATED (LOW)
+	stah = stcl + 1 // TIME LAST ACCESSED (HIGH)
+	stal = stah + 1 // TIME LAST ACCESSED (LOW)
+	stmh = stal + 1 // TIME LAST MODIFIED (HIGH)
+	stml = stmh + 1 // TIME LAST MODIFIED (LOW)
+	ssfa = stml + 1 // FILE ADDRESS (HIGH)
+	sfal = ssfa + 1 // FILE ADDRESS (LOW)
+	slau = sfal + 1 // FILE LENGTH IN BYTES (HIGH)
+	slal = slau + 1 // FILE LENGTH IN BYTES (LOW)
+	sefw = slal + 1 // FILE ELEMENT SIZE (BLOCKS)
+	sidx = sefw + 1 // NUMBER OF INDEX LEVELS
+	sopn = sidx + 1 // OPEN COUNT
+	scsh = slau     // CURRENT SPACE IN BLOCKS (HIGH) - DIRECTORIES
+	scsl = slal     // CURRENT SPACE IN BLOCKS (LOW) - DIRECTORIES
+	smsh = sopn + 1 // MAX SPACE IN BLOCKS (HIGH) - CONTROL POINT DIRS
+	smsl = smsh + 1 // MAX SPACE IN BLOCKS (LOW) - CONTROL POINT DIRS
+	slth = smsl + 1 // LENGTH OF THE PACKET
+
+	// STATUS BITS (ssts)
+	sudf = 0x8000 >> 0 // FILE HAS A UDA
+	sacl = 0x8000 >> 1 // FILE HAS AN ACL
+	sopb = 0x8000 >> 2 // FILE IS OPEN
+)
+
+// PACKET FOR gnfn
+const (
+	nfky = 0        // KEY FOR NEXT ENTRY - ZERO ON FIRST CALL
+	nfrs = nfky + 2 // RESERVED
+	nfnm = nfrs + 2 // BYTE POINTER TO FILENAME BUFFER
+	nftp = nfnm + 2 // BYTE POINTER TO TEMPLATE (-1 FOR ALL FILES)
+	nfln = nftp + 2 // LENGTH OF PACKET
+)
+
 const (
 	// :::EXEC PARAMETERS
 	//
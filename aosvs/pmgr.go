@@ -0,0 +1,264 @@
+// +build virtual !physical
+
+// pmgr.go - a pseudo-PMGR which keeps track of processes and their relationships
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+	"github.com/SMerrony/dgemug/memory"
+)
+
+const defaultUsername = "XYZZY"
+
+// termInfoT describes how a process terminated, it is passed on to the father
+type termInfoT struct {
+	PID       dg.WordT
+	errorCode dg.DwordT
+	flags     dg.ByteT // as supplied to ?RETURN
+	message   string
+	byTerm    bool // terminated via ?TERM (or our father's termination) rather than ?RETURN
+}
+
+// words formats the termination message for a father:
+//
+//	word 0    - PID of the terminated son
+//	word 1    - ?RETURN flags in the right byte, 1B0 if the son was ?TERMinated
+//	words 2-3 - error code
+//	words 4.. - the son's ?RETURN message, packed two bytes per word
+func (ti termInfoT) words() (wds []dg.WordT) {
+	flagWd := dg.WordT(ti.flags)
+	if ti.byTerm {
+		flagWd |= 0x8000
+	}
+	wds = append(wds, ti.PID, flagWd, dg.WordT(ti.errorCode>>16), dg.WordT(ti.errorCode))
+	for c := 0; c < len(ti.message); c += 2 {
+		wd := dg.WordT(ti.message[c]) << 8
+		if c+1 < len(ti.message) {
+			wd |= dg.WordT(ti.message[c+1])
+		}
+		wds = append(wds, wd)
+	}
+	return wds
+}
+
+// text formats the termination message as it is shown on a console
+func (ti termInfoT) text() (msg string) {
+	switch ti.flags & Rfab { // severity field
+	case Rfwa:
+		msg = "WARNING: "
+	case Rfer:
+		msg = "ERROR: "
+	case Rfab:
+		msg = "ABORT: "
+	}
+	msg += ti.message
+	if ti.flags&Rfec != 0 {
+		msg += "\nError Code: " + strconv.Itoa(int(ti.errorCode))
+	}
+	return msg
+}
+
+// procInfoT is a snapshot of the interesting parts of a process record
+type procInfoT struct {
+	PID, fatherPID  dg.WordT
+	name, username  string
+	privileges      dg.WordT
+	superuser       bool // in Superuser mode
+	programFileName string
+	sixteenBit      bool
+	created         time.Time
+}
+
+// findProcessByName returns the PID of the named process, or zero - Agent only
+func findProcessByName(name string) dg.WordT {
+	for PID, ppd := range PerProcessData {
+		if ppd.name == name {
+			return dg.WordT(PID)
+		}
+	}
+	return 0
+}
+
+type agProcInfoReqT struct {
+	PID  dg.WordT // zero to look up by name
+	name string
+}
+type agProcInfoRespT struct {
+	info  procInfoT
+	found bool
+}
+
+func agProcInfo(req agProcInfoReqT) (resp agProcInfoRespT) {
+	PID := req.PID
+	if PID == 0 {
+		PID = findProcessByName(strings.ToUpper(req.name))
+	}
+	ppd, found := PerProcessData[int(PID)]
+	if !found {
+		return resp
+	}
+	resp.found = true
+	resp.info = procInfoT{
+		PID:             PID,
+		fatherPID:       ppd.fatherPID,
+		name:            ppd.name,
+		username:        ppd.username,
+		privileges:      ppd.privileges,
+		superuser:       ppd.privModes[sysprvSuser] != privModeOff,
+		programFileName: ppd.programFileName,
+		sixteenBit:      ppd.sixteenBit,
+		created:         ppd.created,
+	}
+	return resp
+}
+
+// getProcInfo asks the pseudo-Agent for the record of a process by PID or, if PID is zero, by name
+func getProcInfo(agentChan chan AgentReqT, PID dg.WordT, name string) (info procInfoT, found bool) {
+	areq := AgentReqT{agentProcInfo, agProcInfoReqT{PID, name}, nil}
+	agentChan <- areq
+	areq = <-agentChan
+	resp := areq.result.(agProcInfoRespT)
+	return resp.info, resp.found
+}
+
+type agReleasePIDReqT struct {
+	PID dg.WordT
+}
+type agReleasePIDRespT struct {
+	ok bool
+}
+
+// agReleasePID forgets a process whose tasks have all ended, sending its father a termination
+// message on ?SPTM unless he is waiting for us, a process without a father has the
+// message of an abort shown on its console
+func agReleasePID(req agReleasePIDReqT) (resp agReleasePIDRespT) {
+	ppd, found := PerProcessData[int(req.PID)]
+	if !found {
+		return resp
+	}
+	if !ppd.terminating {
+		ppd.termInfo.PID = req.PID // ended by killing its last task
+	}
+	if ppd.fatherPID == 0 && !ppd.cliSon && ppd.termInfo.byTerm && ppd.conn != nil {
+		if msg := ppd.termInfo.text(); msg != "" {
+			ppd.conn.Write([]byte(msg))
+		}
+	}
+	if father, found := PerProcessData[int(ppd.fatherPID)]; found && !ppd.fatherWaits {
+		father.deliverIPC(obituary(req.PID, ppd.ring, 0, ppd.termInfo.words()), false)
+	}
+	releaseConnections(req.PID, ppd)
+	releaseIPCs(req.PID)
+	ppd.closeChannels()
+	ppdMu.Lock()
+	delete(PerProcessData, int(req.PID))
+	ppdMu.Unlock()
+	pidInUse[req.PID] = false
+	logging.DebugPrint(logging.ScLog, "AGENT released PID %d\n", req.PID)
+	resp.ok = true
+	return resp
+}
+
+// reaper waits for all of a process's tasks to end, then has the pseudo-Agent release it
+func reaper(PID dg.WordT, ppd *PerProcessDataT) {
+	ppd.ActiveTasksWg.Wait()
+	agentChan := newAgentGateway()
+	areq := AgentReqT{agentReleasePID, agReleasePIDReqT{PID}, nil}
+	agentChan <- areq
+	<-agentChan
+	close(agentChan)
+	ppd.mem.Release()
+	close(ppd.doneChan)
+}
+
+type agTermProcReqT struct {
+	callerPID, targetPID dg.WordT // targetPID zero => look up targetName
+	targetName           string
+}
+type agTermProcRespT struct {
+	errCode dg.WordT
+}
+
+// agTermProc handles ?TERM, a process may only terminate itself or one of its descendants
+func agTermProc(req agTermProcReqT) (resp agTermProcRespT) {
+	target := req.targetPID
+	if target == 0 {
+		target = findProcessByName(strings.ToUpper(req.targetName))
+		if target == 0 {
+			resp.errCode = erpnm
+			return resp
+		}
+	}
+	ppd, found := PerProcessData[int(target)]
+	if !found {
+		resp.errCode = erprh
+		return resp
+	}
+	for anc := target; anc != req.callerPID; anc = ppd.fatherPID {
+		if ppd = PerProcessData[int(anc)]; ppd == nil || ppd.fatherPID == 0 {
+			resp.errCode = erprh
+			return resp
+		}
+	}
+	agTerminate(agTerminateReqT{PID: target, info: termInfoT{byTerm: true}})
+	return resp
+}
+
+type agChainReqT struct {
+	PID             dg.WordT
+	invocationArgs  []string
+	programFileName string
+	sixteenBit      bool
+	maxTasks        int
+	mem             *memory.AddrSpaceT // the new program is already loaded into this
+}
+type agChainRespT struct {
+	errCode dg.WordT
+}
+
+// agChain stops every task of a process so that a new program can be loaded in its place,
+// the process keeps its PID, name and sons
+func agChain(req agChainReqT) (resp agChainRespT) {
+	ppd := PerProcessData[int(req.PID)]
+	if ppd.terminating {
+		resp.errCode = ernot
+		return resp
+	}
+	ppd.sched.terminate()
+	ppd.invocationArgs = req.invocationArgs
+	ppd.programFileName = req.programFileName
+	ppd.sixteenBit = req.sixteenBit
+	ppd.maxTasks = req.maxTasks
+	ppd.mem = req.mem
+	for t := range ppd.tasks {
+		ppd.tidsInUse[t] = false
+		ppd.tasks[t] = nil
+	}
+	logging.DebugPrint(logging.ScLog, "AGENT chaining PID %d to %s\n", req.PID, req.programFileName)
+	return resp
+}
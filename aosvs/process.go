@@ -29,6 +29,8 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
+	"path/filepath"
+	"strings"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/memory"
@@ -68,56 +70,156 @@ type ProcessT struct {
 	name            string
 	programFileName string
 	console         net.Conn
+	mem             *memory.AddrSpaceT
 	ust             ustT
 }
 
 var debugLogging bool
 
-// CreateProcess creates, but does not start, an emulated AOS/VS Process
-func CreateProcess(args []string, vRoot string, prName string, ring int, con net.Conn, agentChan chan AgentReqT, debugLog bool) (err error) {
+// procSpecT describes a process to be created
+type procSpecT struct {
+	args        []string
+	vRoot       string
+	workingDir  string // the first process's working directory, for sons the ?PROC initial directory
+	prName      string
+	ring        int
+	conn        net.Conn
+	fatherPID   dg.WordT // zero if created by the emulator itself
+	fatherWaits bool
+	name        string // empty for the default name
+	username    string
+	privileges  dg.WordT
+	searchList  []string // nil for the default one
+	cliSon      bool
+}
+
+// CreateProcess creates and starts an emulated AOS/VS Process which has no father,
+// an empty username gives the default one, whose profile is used
+func CreateProcess(args []string, vRoot string, prName string, ring int, con net.Conn, username string, debugLog bool) (ppd *PerProcessDataT, err error) {
 	debugLogging = debugLog
-	progWds, err := readProgram(prName)
+	if username == "" {
+		username = defaultUsername
+	}
+	profile, found := findUserProfile(username)
+	if !found {
+		return nil, fmt.Errorf("no user profile for %s", strings.ToUpper(username))
+	}
+	workingDir, searchList, errCode := profile.startingEnv(vRoot, initialDirectory(vRoot, prName))
+	if errCode != 0 {
+		return nil, fmt.Errorf("initial directory %s of %s is not usable, error code %#o", profile.initialDir, profile.username, errCode)
+	}
+	_, ppd, errCode = createProcess(procSpecT{
+		args:       args,
+		vRoot:      vRoot,
+		workingDir: workingDir,
+		prName:     prName,
+		ring:       ring,
+		conn:       con,
+		username:   profile.username,
+		privileges: profile.privileges,
+		searchList: searchList,
+	})
+	if errCode != 0 {
+		return nil, fmt.Errorf("could not create process from %s, error code %#o", prName, errCode)
+	}
+	return ppd, nil
+}
+
+// initialDirectory returns the AOS/VS pathname of the directory holding a program, or the root
+// if it is outside the namespace
+func initialDirectory(vRoot, prName string) string {
+	root, err1 := filepath.Abs(vRoot)
+	prDir, err2 := filepath.Abs(filepath.Dir(prName))
+	rel, err3 := filepath.Rel(root, prDir)
+	if err1 != nil || err2 != nil || err3 != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return rootDir
+	}
+	return rootDir + strings.ToUpper(strings.ReplaceAll(rel, string(filepath.Separator), ":"))
+}
+
+// createProcess loads a program into a new process and starts its initial task
+func createProcess(spec procSpecT) (PID dg.WordT, ppd *PerProcessDataT, errCode dg.WordT) {
+	progWds, err := readProgram(spec.prName)
 	if err != nil {
-		return err
+		log.Printf("ERROR: %s\n", err.Error())
+		return 0, nil, erfde
 	}
 	var proc ProcessT
-	proc.programFileName = prName
-	proc.console = con
+	proc.programFileName = spec.prName
+	proc.console = spec.conn
+	proc.mem = memory.NewAddrSpace()
 
 	// get info from PR preamble
 	proc.loadUST(progWds)
 	proc.printUST()
 
+	searchList := spec.searchList
+	if searchList == nil {
+		searchList = defaultSearchList
+	}
+
+	agentChan := newAgentGateway()
+	defer close(agentChan)
+
 	// Announce ourself to pseudo-Agent and get PID
 	var areq AgentReqT
 	areq.action = agentAllocatePID
-	areq.reqParms = agAllocatePIDReqT{invocationArgs: args, virtualRoot: vRoot, sixteenBit: proc.ust.prType&0x8000 != 0}
+	areq.reqParms = agAllocatePIDReqT{
+		invocationArgs:  spec.args,
+		virtualRoot:     spec.vRoot,
+		workingDir:      spec.workingDir,
+		initialDir:      spec.workingDir,
+		searchList:      searchList,
+		sixteenBit:      proc.ust.prType&0x8000 != 0,
+		ring:            spec.ring,
+		name:            spec.name,
+		username:        spec.username,
+		privileges:      spec.privileges,
+		programFileName: spec.prName,
+		fatherPID:       spec.fatherPID,
+		fatherWaits:     spec.fatherWaits,
+		cliSon:          spec.cliSon,
+		maxTasks:        int(proc.ust.taskCount),
+		conn:            spec.conn,
+		mem:             proc.mem,
+	}
 	agentChan <- areq
 	areq = <-agentChan
-	if !areq.result.(agAllocatePIDRespT).ok {
-		log.Panic("ERROR: Could not get PID from pseudo-Agent")
+	resp := areq.result.(agAllocatePIDRespT)
+	if resp.errCode != 0 {
+		return 0, nil, resp.errCode
 	}
-	proc.PID = areq.result.(agAllocatePIDRespT).PID
+	proc.PID = resp.PID
 	log.Printf("INFO: Obtained PID %d for process\n", proc.PID)
-	log.Printf("INFO: Preparing ring %d process with up to %d tasks\n", ring, proc.ust.taskCount)
-	log.Printf("----  PR: %s  Args: %v\n", prName, args)
-	if proc.ust.sharedBlockCount != 0 {
-		log.Println("WARNING: Shared pages not yet fully supported")
-	}
+	log.Printf("INFO: Preparing ring %d process with up to %d tasks\n", spec.ring, proc.ust.taskCount)
+	log.Printf("----  PR: %s  Args: %v\n", spec.prName, spec.args)
+
+	proc.load(progWds, spec.ring)
+	errCode = proc.startInitialTask(agentChan, progWds, spec.conn)
+
+	go reaper(proc.PID, resp.ppd)
+	go limitWatcher(proc.PID, resp.ppd)
 
+	return proc.PID, resp.ppd, errCode
+}
+
+// load maps (copies) the program into the process's address space, the shared portion
+// is really shared with any other process running the same program
+func (proc *ProcessT) load(progWds []dg.WordT, ring int) {
 	segBase := dg.PhysAddrT(ring) << 28
 
-	// map (load) program into RAM
 	// unshared portion
-	memory.MapSlice(segBase, progWds[8192:proc.ust.sharedStartPageInPR<<10-8], false)
+	proc.mem.MapSlice(segBase, progWds[8192:proc.ust.sharedStartPageInPR<<10-8], false)
 	// shared portion
-	memory.MapSlice(segBase+dg.PhysAddrT(proc.ust.sharedStartBlock)<<10, progWds[proc.ust.sharedStartPageInPR<<10:], true)
+	proc.mem.MapSharedSlice(proc.programFileName, segBase+dg.PhysAddrT(proc.ust.sharedStartBlock)<<10, progWds[proc.ust.sharedStartPageInPR<<10:])
+	proc.mem.FixUnsharedBase(ring)
+}
 
-	// set up initial task
+// startInitialTask has the pseudo-Agent create and start the first task of a loaded program
+func (proc *ProcessT) startInitialTask(agentChan chan AgentReqT, progWds []dg.WordT, con net.Conn) (errCode dg.WordT) {
 	var taskReq agTaskReqT
 	taskReq.PID = proc.PID
 	taskReq.TID = firstTask
-	taskReq.agentChan = agentChan
 	taskReq.conn = con
 	taskReq.initAC2 = 0
 	taskReq.priority = 0
@@ -128,11 +230,14 @@ func CreateProcess(args []string, vRoot string, prName string, ring int, con net
 	taskReq.wsl = dg.PhysAddrT(memory.DwordFromTwoWords(progWds[wslInPr], progWds[wslInPr+1]))
 	taskReq.wsp = dg.PhysAddrT(memory.DwordFromTwoWords(progWds[wspInPr], progWds[wspInPr+1]))
 
-	areq = AgentReqT{agentTask, taskReq, nil}
+	areq := AgentReqT{agentTask, taskReq, nil}
 	agentChan <- areq
 	areq = <-agentChan
-
-	return nil
+	errCode = areq.result.(agTaskRespT).errCode
+	if errCode != 0 {
+		log.Printf("ERROR: Could not create initial task, error code %#o\n", errCode)
+	}
+	return errCode
 }
 
 func readProgram(prName string) (wordImg []dg.WordT, err error) {
@@ -0,0 +1,416 @@
+// +build virtual !physical
+
+// pseudoCLI.go - a minimal CLI-like command interpreter which the emulator runs on a console
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// The pseudo-CLI is not an emulated program, it runs in the emulator and uses its console
+// as a process would.  The programs it runs have no father, but it waits for each one and
+// shows its ?RETURN message.  Commands may be abbreviated, XEQ also to X, and a line may hold
+// several commands separated by semicolons.  Any other command is looked for as a macro
+// file NAME.CLI via the searchlist, in which %0% is the macro name, %n% its nth argument
+// and %n-% the nth and all following arguments.  A macro stops after an error in the
+// pseudo-CLI or a program which returns with ABORT severity.
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+const (
+	cliPrompt      = ")"
+	cliMacroSuffix = ".CLI"
+	maxMacroDepth  = 16
+)
+
+type cliT struct {
+	con        *consoleT
+	username   string
+	privileges dg.WordT
+	env        *PerProcessDataT // working directory and searchlist, never known to the Agent
+	depth      int              // of macro calls
+	done       bool             // BYE
+}
+
+type cliCmdT struct {
+	name             string
+	minArgs, maxArgs int // maxArgs is -1 for any number
+	fn               func(cli *cliT, args []string) (ok bool)
+}
+
+var cliCommands = []cliCmdT{
+	{"BYE", 0, 0, (*cliT).bye},
+	{"COMMENT", 0, -1, (*cliT).comment},
+	{"DIRECTORY", 0, 1, (*cliT).directory},
+	{"FILESTATUS", 0, -1, (*cliT).filestatus},
+	{"SEARCHLIST", 0, mxpsl, (*cliT).searchlist},
+	{"TYPE", 1, -1, (*cliT).typeFiles},
+	{"WRITE", 0, -1, (*cliT).write},
+	{"XEQ", 1, -1, (*cliT).xeq},
+}
+
+// RunCLI runs the pseudo-CLI on an attached console until BYE or the console goes,
+// vRoot is the host directory which is the AOS/VS root and the initial working directory
+// unless the user's profile gives another
+func RunCLI(conn net.Conn, username, vRoot string) error {
+	if username == "" {
+		username = defaultUsername
+	}
+	profile, found := findUserProfile(username)
+	if !found {
+		return fmt.Errorf("no user profile for %s", strings.ToUpper(username))
+	}
+	workingDir, searchList, errCode := profile.startingEnv(vRoot, rootDir)
+	if errCode != 0 {
+		return fmt.Errorf("initial directory %s of %s is not usable, error code %#o", profile.initialDir, profile.username, errCode)
+	}
+	gw := newAgentGateway()
+	gw <- AgentReqT{agentAttachConsole, agAttachConsoleReqT{conn}, nil}
+	areq := <-gw
+	close(gw)
+	cli := &cliT{
+		con:        areq.result.(agAttachConsoleRespT).con,
+		username:   profile.username,
+		privileges: profile.privileges,
+		env: &PerProcessDataT{
+			virtualRoot: vRoot,
+			workingDir:  workingDir,
+			searchList:  searchList,
+		},
+	}
+	for !cli.done {
+		cli.con.writeConsole([]byte(cliPrompt))
+		line, errCode := cli.con.readConsole(0, false, nil)
+		if errCode != 0 {
+			return nil // the console has gone
+		}
+		cli.execute(strings.TrimRight(string(line), "\r\n"))
+	}
+	return nil
+}
+
+// execute runs each command on a line, it stops at the first which fails
+func (cli *cliT) execute(line string) (ok bool) {
+	for _, cmdLine := range splitCommands(line) {
+		args := SplitCLIArgs(cmdLine)
+		if len(args) == 0 {
+			continue
+		}
+		if !cli.command(args) {
+			return false
+		}
+		if cli.done {
+			break
+		}
+	}
+	return true
+}
+
+// splitCommands splits a line at semicolons which are not within double-quotes
+func splitCommands(line string) (cmds []string) {
+	inQuotes := false
+	start := 0
+	for i, c := range line {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ';' && !inQuotes:
+			cmds = append(cmds, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(cmds, line[start:])
+}
+
+// command runs a built-in command or, failing that, a macro
+func (cli *cliT) command(args []string) (ok bool) {
+	name := strings.ToUpper(parseCLIArg(args[0]).text)
+	cmd := findCLICommand(name)
+	if cmd == nil {
+		return cli.macro(args)
+	}
+	params := args[1:]
+	if len(params) < cmd.minArgs {
+		return cli.showError("TOO FEW ARGUMENTS", cmd.name)
+	}
+	if cmd.maxArgs >= 0 && len(params) > cmd.maxArgs {
+		return cli.showError("TOO MANY ARGUMENTS", cmd.name)
+	}
+	return cmd.fn(cli, params)
+}
+
+// findCLICommand returns the command a name is, or is an unambiguous abbreviation of
+func findCLICommand(name string) (cmd *cliCmdT) {
+	if name == "X" {
+		name = "XEQ"
+	}
+	for c := range cliCommands {
+		switch {
+		case cliCommands[c].name == name:
+			return &cliCommands[c]
+		case strings.HasPrefix(cliCommands[c].name, name):
+			if cmd != nil {
+				return nil
+			}
+			cmd = &cliCommands[c]
+		}
+	}
+	return cmd
+}
+
+// macro runs each line of a macro file
+func (cli *cliT) macro(args []string) (ok bool) {
+	name := strings.ToUpper(parseCLIArg(args[0]).text)
+	if name == "" {
+		return cli.showError("NOT A COMMAND OR MACRO", args[0])
+	}
+	macName := name
+	if !strings.HasSuffix(macName, cliMacroSuffix) {
+		macName += cliMacroSuffix
+	}
+	path, _, errCode := cli.env.searchPath(macName)
+	if errCode != 0 {
+		return cli.showError("NOT A COMMAND OR MACRO", name)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return cli.showErrorCode(erfde, name)
+	}
+	if cli.depth == maxMacroDepth {
+		return cli.showError("MACROS NESTED TOO DEEPLY", name)
+	}
+	cli.depth++
+	defer func() { cli.depth-- }()
+	for _, line := range strings.Split(string(text), "\n") {
+		if !cli.execute(expandMacroArgs(strings.TrimRight(line, "\r"), args)) {
+			return false
+		}
+		if cli.done {
+			break
+		}
+	}
+	return true
+}
+
+// expandMacroArgs replaces the %n%, %n-% and %-% argument references on a line of a macro
+func expandMacroArgs(line string, args []string) string {
+	var out strings.Builder
+	for {
+		start := strings.IndexByte(line, '%')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(line[start+1:], '%')
+		if end == -1 {
+			break
+		}
+		end += start + 1
+		out.WriteString(line[:start])
+		spec := line[start+1 : end]
+		if spec == "-" {
+			spec = "1-"
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(spec, "-"))
+		if err != nil || n < 0 {
+			// not an argument reference, the closing % may begin one
+			out.WriteString(line[start:end])
+			line = line[end:]
+			continue
+		}
+		switch {
+		case n >= len(args):
+		case strings.HasSuffix(spec, "-"):
+			out.WriteString(strings.Join(args[n:], ","))
+		default:
+			out.WriteString(args[n])
+		}
+		line = line[end+1:]
+	}
+	out.WriteString(line)
+	return out.String()
+}
+
+func (cli *cliT) bye(args []string) (ok bool) {
+	cli.writeLine("AOS/VS PSEUDO-CLI TERMINATING")
+	cli.done = true
+	return true
+}
+
+func (cli *cliT) comment(args []string) (ok bool) {
+	return true
+}
+
+// directory shows or changes the working directory
+func (cli *cliT) directory(args []string) (ok bool) {
+	if len(args) == 0 {
+		cli.writeLine(cli.env.workingDir)
+		return true
+	}
+	name := parseCLIArg(args[0]).text
+	full, errCode := cli.env.checkDirectory(name)
+	if errCode != 0 {
+		return cli.showErrorCode(errCode, name)
+	}
+	cli.env.workingDir = full
+	return true
+}
+
+// filestatus lists the files in the working directory which match any of the templates
+func (cli *cliT) filestatus(args []string) (ok bool) {
+	templates := []string{"+"}
+	if len(args) > 0 {
+		templates = nil
+		for _, arg := range args {
+			templates = append(templates, strings.ToUpper(parseCLIArg(arg).text))
+		}
+	}
+	path, errCode := cli.env.hostPath(cli.env.workingDir)
+	if errCode != 0 {
+		return cli.showErrorCode(errCode, cli.env.workingDir)
+	}
+	names, err := listDir(path)
+	if err != nil {
+		return cli.showErrorCode(erdde, cli.env.workingDir)
+	}
+	cli.writeLine("\nDIRECTORY " + cli.env.workingDir + "\n")
+	for _, name := range names {
+		for _, template := range templates {
+			if matchTemplate(template, name) {
+				cli.writeLine(name)
+				break
+			}
+		}
+	}
+	return true
+}
+
+// searchlist shows or sets the searchlist, every entry must be a directory
+func (cli *cliT) searchlist(args []string) (ok bool) {
+	if len(args) == 0 {
+		cli.writeLine(strings.Join(cli.env.searchList, ","))
+		return true
+	}
+	var sl []string
+	for _, arg := range args {
+		name := parseCLIArg(arg).text
+		full, errCode := cli.env.checkDirectory(name)
+		if errCode != 0 {
+			return cli.showErrorCode(errCode, name)
+		}
+		sl = append(sl, full)
+	}
+	cli.env.searchList = sl
+	return true
+}
+
+// typeFiles copies each file to the console
+func (cli *cliT) typeFiles(args []string) (ok bool) {
+	for _, arg := range args {
+		name := parseCLIArg(arg).text
+		path, _, errCode := cli.env.searchPath(name)
+		if errCode != 0 {
+			return cli.showErrorCode(errCode, name)
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return cli.showErrorCode(erift, name)
+		}
+		cli.con.writeConsole(text)
+		cli.freshLine()
+	}
+	return true
+}
+
+// write shows its arguments separated by commas
+func (cli *cliT) write(args []string) (ok bool) {
+	cli.writeLine(strings.ReplaceAll(strings.Join(args, ","), "\"", ""))
+	return true
+}
+
+// xeq runs a program and waits for it, showing any ?RETURN message
+func (cli *cliT) xeq(args []string) (ok bool) {
+	progName := parseCLIArg(args[0]).text
+	prPath, errCode := cli.env.findProgram(progName)
+	if errCode != 0 {
+		return cli.showErrorCode(errCode, progName)
+	}
+	_, ppd, errCode := createProcess(procSpecT{
+		args:       args,
+		vRoot:      cli.env.virtualRoot,
+		workingDir: cli.env.workingDir,
+		prName:     prPath,
+		ring:       7,
+		conn:       cli.con.conn,
+		username:   cli.username,
+		privileges: cli.privileges,
+		searchList: append([]string(nil), cli.env.searchList...),
+		cliSon:     true,
+	})
+	if ppd == nil {
+		return cli.showErrorCode(errCode, progName)
+	}
+	<-ppd.doneChan
+	cli.freshLine()
+	if errCode != 0 {
+		return cli.showErrorCode(errCode, progName)
+	}
+	if msg := ppd.termInfo.text(); msg != "" {
+		cli.writeLine(msg)
+	}
+	return ppd.termInfo.flags&Rfab != Rfab
+}
+
+// showError shows an error found by the pseudo-CLI itself
+func (cli *cliT) showError(text, arg string) (ok bool) {
+	cli.freshLine()
+	cli.writeLine("ERROR: " + text + ", " + arg)
+	return false
+}
+
+// showErrorCode shows a system error
+func (cli *cliT) showErrorCode(errCode dg.WordT, arg string) (ok bool) {
+	text, found := ermesTable[errCode]
+	if !found {
+		text = fmt.Sprintf("UNKNOWN ERROR CODE %o", errCode)
+	}
+	return cli.showError(text, arg)
+}
+
+func (cli *cliT) writeLine(text string) {
+	cli.con.writeConsole([]byte(text + "\n"))
+}
+
+// freshLine starts a new line unless the cursor is already at the start of one
+func (cli *cliT) freshLine() {
+	dc := cli.con.chars
+	dc.mu.Lock()
+	col := dc.col
+	dc.mu.Unlock()
+	if col != 0 {
+		cli.writeLine("")
+	}
+}
@@ -0,0 +1,73 @@
+// +build virtual !physical
+
+// pseudoCLI_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandMacroArgs(t *testing.T) {
+	args := []string{"MAC", "A", "B/S", "C"}
+	tests := []struct {
+		line, expanded string
+	}{
+		{"TYPE FILE", "TYPE FILE"},
+		{"WRITE %0%", "WRITE MAC"},
+		{"WRITE %1% %3%", "WRITE A C"},
+		{"WRITE %2%", "WRITE B/S"},
+		{"WRITE %4%", "WRITE "},
+		{"WRITE %2-%", "WRITE B/S,C"},
+		{"WRITE %-%", "WRITE A,B/S,C"},
+		{"WRITE %0-%", "WRITE MAC,A,B/S,C"},
+		{"WRITE %9-%", "WRITE "},
+		{"WRITE 50%", "WRITE 50%"},
+		{"WRITE 50% OF %1%", "WRITE 50% OF A"},
+		{"WRITE %X%1%", "WRITE %XA"},
+		{"WRITE %%", "WRITE %%"},
+	}
+	for _, tt := range tests {
+		if expanded := expandMacroArgs(tt.line, args); expanded != tt.expanded {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.expanded, expanded)
+		}
+	}
+}
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		line string
+		cmds []string
+	}{
+		{"", []string{""}},
+		{"DIR", []string{"DIR"}},
+		{"DIR;TYPE A", []string{"DIR", "TYPE A"}},
+		{"DIR; TYPE A;", []string{"DIR", " TYPE A", ""}},
+		{`WRITE "A;B";BYE`, []string{`WRITE "A;B"`, "BYE"}},
+	}
+	for _, tt := range tests {
+		if cmds := splitCommands(tt.line); !reflect.DeepEqual(cmds, tt.cmds) {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.cmds, cmds)
+		}
+	}
+}
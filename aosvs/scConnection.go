@@ -23,19 +23,118 @@
 
 package aosvs
 
-import "github.com/SMerrony/dgemug/logging"
+import (
+	"strings"
 
-func scCon(p syscallParmsT) bool {
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+// connect handles ?CON and ?DCON which identify the server in the same way
+func connect(p syscallParmsT, disconnect bool) bool {
 	ac1 := p.cpu.GetAc(1)
+	req := agConnectReqT{
+		PID:        p.PID,
+		ring:       int(p.ringMask >> 28),
+		wantsObit:  ac1&mcobit != 0,
+		disconnect: disconnect,
+	}
 	if ac1&mcpid != 0 {
 		// ac0 is a b.p. to a process name
-		serverName := readString(p.cpu.GetAc(0), p.cpu.GetPC())
-		logging.DebugPrint(logging.ScLog, "----- Faking connection to proc name %s\n", serverName)
+		req.serverName = strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
 	} else {
 		// ac0 is a PID
-		serverPID := int(p.cpu.GetAc(0))
-		logging.DebugPrint(logging.ScLog, "----- Faking connection to PID %d\n", serverPID)
+		req.serverPID = dg.WordT(p.cpu.GetAc(0))
+		if req.serverPID == 0 {
+			p.cpu.SetAc(0, erprh)
+			return false
+		}
+	}
+	if ac1&mcrng != 0 {
+		req.ring = int(p.cpu.GetAc(2) & 7)
+	}
+	areq := AgentReqT{agentConnect, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agConnectRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	logging.DebugPrint(logging.ScLog, "----- Connection to server PID %d. changed\n", resp.serverPID)
+	p.cpu.SetAc(0, dg.DwordT(resp.serverPID))
+	return true
+}
+
+func scCon(p syscallParmsT) bool {
+	return connect(p, false)
+}
+
+func scDcon(p syscallParmsT) bool {
+	return connect(p, true)
+}
+
+// serve handles ?SERVE and ?RESIGN
+func serve(p syscallParmsT, resign bool) bool {
+	areq := AgentReqT{agentServe, agServeReqT{p.PID, resign}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agServeRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
 	}
+	return true
+}
 
+func scServe(p syscallParmsT) bool {
+	return serve(p, false)
+}
+
+func scResign(p syscallParmsT) bool {
+	return serve(p, true)
+}
+
+// scDrcon breaks the connection with the customer whose PID is in AC0
+func scDrcon(p syscallParmsT) bool {
+	areq := AgentReqT{agentDrcon, agDrconReqT{p.PID, dg.WordT(p.cpu.GetAc(0))}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agDrconRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+// scCtod checks for a connection with the process whose PID is in AC0, returning the
+// customer's ring in AC1 and, in AC2, 1 if the caller is the server or 0 if it is the customer
+func scCtod(p syscallParmsT) bool {
+	areq := AgentReqT{agentCheckConn, agCheckConnReqT{p.PID, dg.WordT(p.cpu.GetAc(0))}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agCheckConnRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	p.cpu.SetAc(1, dg.DwordT(resp.ring))
+	if resp.isServer {
+		p.cpu.SetAc(2, 1)
+	} else {
+		p.cpu.SetAc(2, 0)
+	}
+	return true
+}
+
+// scPcnx passes the customer whose PID is in AC0 to the server whose PID is in AC1
+func scPcnx(p syscallParmsT) bool {
+	req := agPassConnReqT{p.PID, dg.WordT(p.cpu.GetAc(0)), dg.WordT(p.cpu.GetAc(1))}
+	areq := AgentReqT{agentPassConn, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agPassConnRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	return true
 }
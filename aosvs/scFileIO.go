@@ -24,7 +24,6 @@
 package aosvs
 
 import (
-	"log"
 	"strings"
 
 	"github.com/SMerrony/dgemug/dg"
@@ -34,8 +33,8 @@ import (
 
 func scClose(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	channel := memory.ReadWord(pktAddr + ich)
-	var creq = agCloseReqT{int(channel)}
+	channel := p.mem.ReadWord(pktAddr + ich)
+	var creq = agCloseReqT{p.PID, int(channel)}
 	var areq = AgentReqT{agentFileClose, creq, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
@@ -46,65 +45,119 @@ func scClose(p syscallParmsT) bool {
 	return true
 }
 
-func scGchr(p syscallParmsT) bool {
-	var areq AgentReqT
-	var gchrReq agGchrReqT
+// devCharsTarget decodes the device a characteristics call refers to, AC1 1B0 means AC0 holds a channel number
+func devCharsTarget(p syscallParmsT) (useChan bool, devChan dg.WordT, devName string) {
 	if memory.TestDwbit(p.cpu.GetAc(1), 0) {
-		// AC0 should contain a channel #
-		if memory.TestDwbit(p.cpu.GetAc(1), 1) {
-			// get default chars
-			gchrReq = agGchrReqT{p.PID, true, true, dg.WordT(p.cpu.GetAc(0)), ""}
-		} else {
-			// get current chars
-			gchrReq = agGchrReqT{p.PID, false, true, dg.WordT(p.cpu.GetAc(0)), ""}
-		}
-	} else {
-		// AC0 should contain BP to device name
-		bpPathname := p.cpu.GetAc(0)
-		path := strings.ToUpper(readString(bpPathname, p.ringMask))
-		if memory.TestDwbit(p.cpu.GetAc(1), 1) {
-			// get default chars
-			gchrReq = agGchrReqT{p.PID, true, false, 0, path}
-		} else {
-			// get current chars
-			gchrReq = agGchrReqT{p.PID, false, false, 0, path}
-		}
+		return true, dg.WordT(p.cpu.GetAc(0)), ""
 	}
-	areq.action = agentGetChars
-	areq.reqParms = gchrReq
+	return false, 0, strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+}
+
+// getChars returns the current or, if AC1 1B1 is set, the default characteristics
+func getChars(p syscallParmsT, length int) bool {
+	useChan, devChan, devName := devCharsTarget(p)
+	getDefaults := memory.TestDwbit(p.cpu.GetAc(1), 1)
+	var areq = AgentReqT{agentGetChars, agGchrReqT{p.PID, getDefaults, useChan, devChan, devName, length}, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
+	resp := areq.result.(agGchrRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
 	wrAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
-	memory.WriteWord(dg.PhysAddrT(wrAddr), areq.result.(agGchrRespT).words[0])
-	memory.WriteWord(dg.PhysAddrT(wrAddr+1), areq.result.(agGchrRespT).words[1])
-	memory.WriteWord(dg.PhysAddrT(wrAddr+2), areq.result.(agGchrRespT).words[2])
+	for w, wd := range resp.words {
+		p.mem.WriteWord(wrAddr+dg.PhysAddrT(w), wd)
+	}
 	return true
 }
 
-func scGechr(p syscallParmsT) bool {
+// setChars changes the characteristics to the packet at AC2
+func setChars(p syscallParmsT, length int) bool {
+	useChan, devChan, devName := devCharsTarget(p)
+	pkt := readPacket(p.mem, dg.PhysAddrT(p.cpu.GetAc(2))|p.ringMask, length)
+	var areq = AgentReqT{agentSetChars, agSchrReqT{p.PID, useChan, devChan, devName, pkt}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agSchrRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+// extCharsLen returns the packet length given in the right half of AC1 for ?GECHR and ?SECHR
+func extCharsLen(p syscallParmsT) (length int, ok bool) {
+	length = int(p.cpu.GetAc(1) & 0xffff)
+	if length < clmin || length > clmax {
+		p.cpu.SetAc(0, erpre)
+		return 0, false
+	}
+	return length, true
+}
+
+func scGchr(p syscallParmsT) bool {
+	return getChars(p, clmin)
+}
 
+func scGechr(p syscallParmsT) bool {
+	length, ok := extCharsLen(p)
+	if !ok {
+		return false
+	}
+	if !getChars(p, length) {
+		return false
+	}
+	p.cpu.SetAc(1, dg.DwordT(length))
 	return true
 }
 
 func scSchr(p syscallParmsT) bool {
+	return setChars(p, clmin)
+}
 
+func scSechr(p syscallParmsT) bool {
+	length, ok := extCharsLen(p)
+	if !ok {
+		return false
+	}
+	return setChars(p, length)
+}
+
+func scGclose(p syscallParmsT) bool {
+	var areq = AgentReqT{agentFileClose, agCloseReqT{p.PID, int(p.cpu.GetAc(1))}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if areq.result.(agCloseRespT).errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(areq.result.(agCloseRespT).errCode))
+		return false
+	}
 	return true
 }
 
 func scGopen(p syscallParmsT) bool {
-	filename := readString(p.cpu.GetAc(0), p.cpu.GetPC())
+	filename := strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
 	logging.DebugPrint(logging.ScLog, "----- Filename: %s\n", filename)
-	panic("NYI")
+	var areq = AgentReqT{agentDirOpen, agDirOpenReqT{p.PID, filename}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agDirOpenRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	p.cpu.SetAc(1, dg.DwordT(resp.chanNo))
+	return true
 }
 
 func scOpen(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | (p.ringMask)
-	fileSpec := memory.ReadWord(pktAddr + isti)
-	fileType := memory.ReadWord(pktAddr + isto)
-	// blockSize := memory.ReadWord(pktAddr+imrs)
-	recLen := int(int16(memory.ReadWord(pktAddr + ircl)))
-	bpPathname := memory.ReadDWord(pktAddr + ifnp)
-	path := strings.ToUpper(readString(bpPathname, p.ringMask))
+	fileSpec := p.mem.ReadWord(pktAddr + isti)
+	fileType := p.mem.ReadWord(pktAddr + isto)
+	// blockSize := p.mem.ReadWord(pktAddr+imrs)
+	recLen := int(int16(p.mem.ReadWord(pktAddr + ircl)))
+	bpPathname := p.mem.ReadDWord(pktAddr + ifnp)
+	path := strings.ToUpper(readString(p.mem, bpPathname, p.ringMask))
 	logging.DebugPrint(logging.ScLog, "?OPEN Pathname: %s, Type: %#x, FileSpec: %#x, RecLen: %d.\n", path, fileType, fileSpec, recLen)
 	var areq AgentReqT
 	var openReq = agOpenReqT{p.PID, path, fileSpec, recLen}
@@ -116,19 +169,19 @@ func scOpen(p syscallParmsT) bool {
 		p.cpu.SetAc(0, areq.result.(agOpenRespT).ac0)
 		return false
 	}
-	memory.WriteWord(pktAddr+ich, areq.result.(agOpenRespT).channelNo)
+	p.mem.WriteWord(pktAddr+ich, areq.result.(agOpenRespT).channelNo)
 	logging.DebugPrint(logging.ScLog, "----- Returned channel # %d\n", areq.result.(agOpenRespT).channelNo)
 	return true
 }
 
 func scOpen16(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | (p.ringMask)
-	fileSpec := memory.ReadWord(pktAddr + isti16)
-	fileType := memory.ReadWord(pktAddr + isto16)
-	// blockSize := memory.ReadWord(pktAddr+imrs16)
-	recLen := int(int16(memory.ReadWord(pktAddr + ircl16)))
-	bpPathname := dg.DwordT(memory.ReadWord(pktAddr + ifnp16))
-	path := strings.ToUpper(readString(bpPathname, p.ringMask))
+	fileSpec := p.mem.ReadWord(pktAddr + isti16)
+	fileType := p.mem.ReadWord(pktAddr + isto16)
+	// blockSize := p.mem.ReadWord(pktAddr+imrs16)
+	recLen := int(int16(p.mem.ReadWord(pktAddr + ircl16)))
+	bpPathname := dg.DwordT(p.mem.ReadWord(pktAddr + ifnp16))
+	path := strings.ToUpper(readString(p.mem, bpPathname, p.ringMask))
 	logging.DebugPrint(logging.ScLog, "?OPEN (16-bit) Pathname: %s, Type: %#x, FileSpec: %#x, RecLen: %d.\n", path, fileType, fileSpec, recLen)
 	var areq AgentReqT
 	var openReq = agOpenReqT{p.PID, path, fileSpec, recLen}
@@ -140,156 +193,197 @@ func scOpen16(p syscallParmsT) bool {
 		p.cpu.SetAc(0, areq.result.(agOpenRespT).ac0)
 		return false
 	}
-	memory.WriteWord(pktAddr+ich16, areq.result.(agOpenRespT).channelNo)
+	p.mem.WriteWord(pktAddr+ich16, areq.result.(agOpenRespT).channelNo)
 	return true
 }
 
 func scRead(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	channel := int(memory.ReadWord(pktAddr + ich))
-	specs := memory.ReadWord(pktAddr + isti)
-	length := int(int16(memory.ReadWord(pktAddr + ircl)))
-	dest := memory.ReadDWord(pktAddr + ibad)
-	readLine := (memory.ReadWord(pktAddr+isti) & ibin) != 0
+	channel := int(p.mem.ReadWord(pktAddr + ich))
+	specs := p.mem.ReadWord(pktAddr + isti)
+	length := int(int16(p.mem.ReadWord(pktAddr + ircl)))
+	dest := p.mem.ReadDWord(pktAddr + ibad)
+	readLine := (p.mem.ReadWord(pktAddr+isti) & ibin) != 0
 	if specs&ipkl != 0 {
-		if memory.TestDwbit(memory.ReadDWord(pktAddr+etsp), 0) {
-			smPktAddr := dg.PhysAddrT(memory.ReadDWord(pktAddr+etsp) & 0x7fff_ffff)
-			memory.WriteDWord(pktAddr+etsp, dg.DwordT(smPktAddr))
-			flagWd := memory.ReadWord(smPktAddr)
-			if flagWd != 0 {
-				logging.DebugPrint(logging.ScLog, "\t?ESSE: %v\n", flagWd&esse != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESRD: %v\n", flagWd&esrd != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESNR: %v\n", flagWd&esnr != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESED: %v\n", flagWd&esed != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESCP: %v\n", flagWd&escp != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESDD: %v\n", flagWd&esdd != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESRP: %v\n", flagWd&esrp != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESNE: %v\n", flagWd&esne != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESGT: %v\n", flagWd&esgt != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESBE: %v\n", flagWd&esbe != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESPE: %v\n", flagWd&espe != 0)
-				logging.DebugPrint(logging.ScLog, "\t?ESEP: %#x\n", memory.ReadWord(smPktAddr+1))
-				logging.DebugPrint(logging.ScLog, "\t?ESCR: %#x\n", memory.ReadWord(smPktAddr+2))
-				logging.DebugPrint(logging.ScLog, "\tExtended packet ignored...\n")
-			} else {
-				logging.DebugPrint(logging.ScLog, "\tFlag word is zero - ignoring\n")
-			}
+		if memory.TestDwbit(p.mem.ReadDWord(pktAddr+etsp), 0) {
+			smPktAddr := dg.PhysAddrT(p.mem.ReadDWord(pktAddr+etsp) & 0x7fff_ffff)
+			p.mem.WriteDWord(pktAddr+etsp, dg.DwordT(smPktAddr))
+			screenManagement(p, smPktAddr)
 		}
 	}
 	logging.DebugPrint(logging.ScLog, "?READ (32-bit) Channel: %#x, Specs: %#x, Bytes: %#x, Dest: %#x, Line Mode: %v\n", channel, specs, length, dest, readLine)
-	var readReq = agReadReqT{channel, specs, length, readLine}
-	var areq = AgentReqT{agentFileRead, readReq, nil}
-	p.agentChan <- areq
-	areq = <-p.agentChan
-	resp := areq.result.(agReadRespT)
-	memory.WriteWord(pktAddr+irlr, dg.WordT(len(resp.data)))
-	writeBytes(dest, p.ringMask, resp.data)
+	position := int64(int32(p.mem.ReadDWord(pktAddr + irnh)))
+	resp := readFile(p, agReadReqT{p.PID, channel, specs, length, readLine, position})
+	p.mem.WriteWord(pktAddr+irlr, dg.WordT(len(resp.data)))
+	writeBytes(p.mem, dest, p.ringMask, resp.data)
 	if resp.ac0 != 0 {
 		p.cpu.SetAc(0, dg.DwordT(resp.ac0))
+		return false
 	}
 	return true
 }
 
 func scRead16(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
-	channel := int(memory.ReadWord(pktAddr + ich16))
-	specs := memory.ReadWord(pktAddr + isti16)
-	length := int(int16(memory.ReadWord(pktAddr + ircl16)))
-	dest := dg.DwordT(memory.ReadWord(pktAddr + ibad16))
-	readLine := (memory.ReadWord(pktAddr+isti16) & ibin) == 0
+	channel := int(p.mem.ReadWord(pktAddr + ich16))
+	specs := p.mem.ReadWord(pktAddr + isti16)
+	length := int(int16(p.mem.ReadWord(pktAddr + ircl16)))
+	dest := dg.DwordT(p.mem.ReadWord(pktAddr + ibad16))
+	readLine := (p.mem.ReadWord(pktAddr+isti16) & ibin) == 0
 	if specs&ipkl != 0 {
-		log.Panic("ERROR: ?READ (16-bit) extended packet not yet implemented")
+		if smPkt := p.mem.ReadWord(pktAddr + etsp16); smPkt != 0 {
+			screenManagement(p, dg.PhysAddrT(smPkt)|p.ringMask)
+		}
 	}
 	logging.DebugPrint(logging.ScLog, "?READ (16-bit) Channel: %#x, Specs: %#x, Bytes: %#x, Dest: %#x, Line Mode: %v\n", channel, specs, length, dest, readLine)
-	var readReq = agReadReqT{channel, specs, length, readLine}
-	var areq = AgentReqT{agentFileRead, readReq, nil}
-	p.agentChan <- areq
-	areq = <-p.agentChan
-	resp := areq.result.(agReadRespT)
-	memory.WriteWord(pktAddr+irlr16, dg.WordT(len(resp.data)))
-	writeBytes(dest, p.ringMask, resp.data)
+	position := int64(int32(p.mem.ReadDWord(pktAddr + irnh16)))
+	resp := readFile(p, agReadReqT{p.PID, channel, specs, length, readLine, position})
+	p.mem.WriteWord(pktAddr+irlr16, dg.WordT(len(resp.data)))
+	writeBytes(p.mem, dest, p.ringMask, resp.data)
+	if resp.ac0 != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.ac0))
+		return false
+	}
 	return true
 }
 
+// screenManagement reads the screen management packet of an extended ?READ packet, which is ignored
+func screenManagement(p syscallParmsT, smPktAddr dg.PhysAddrT) {
+	flagWd := p.mem.ReadWord(smPktAddr)
+	if flagWd == 0 {
+		logging.DebugPrint(logging.ScLog, "\tFlag word is zero - ignoring\n")
+		return
+	}
+	logging.DebugPrint(logging.ScLog, "\t?ESSE: %v\n", flagWd&esse != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESRD: %v\n", flagWd&esrd != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESNR: %v\n", flagWd&esnr != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESED: %v\n", flagWd&esed != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESCP: %v\n", flagWd&escp != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESDD: %v\n", flagWd&esdd != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESRP: %v\n", flagWd&esrp != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESNE: %v\n", flagWd&esne != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESGT: %v\n", flagWd&esgt != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESBE: %v\n", flagWd&esbe != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESPE: %v\n", flagWd&espe != 0)
+	logging.DebugPrint(logging.ScLog, "\t?ESEP: %#x\n", p.mem.ReadWord(smPktAddr+1))
+	logging.DebugPrint(logging.ScLog, "\t?ESCR: %#x\n", p.mem.ReadWord(smPktAddr+2))
+	logging.DebugPrint(logging.ScLog, "\tExtended packet ignored...\n")
+}
+
 func scSend(p syscallParmsT) bool {
 	msgLen := int(p.cpu.GetAc(2) & 0x00ff)
-	msg := memory.ReadBytes(p.cpu.GetAc(1), p.cpu.GetPC(), msgLen)
-	// flag := memory.GetDwbits(p.cpu.GetAc(2), 22, 2)
-	// switch flag {
-	// case 0x00: // AC0 contains a PID
-	// case 0x01: // AC0 is a bp to a proc name
-	// case 0x02: // AC0 is a bp to a console name
-	// case 0x03: // undefined
-	// }
-	agWrite := agWriteReqT{0, false, false, true, int(int16(msgLen)), msg, 0}
-	areq := AgentReqT{agentFileWrite, agWrite, nil}
-	logging.DebugPrint(logging.ScLog, "\tWriting <%s> to @CONSOLE\n", string(msg))
+	msg := p.mem.ReadBytes(p.cpu.GetAc(1), p.cpu.GetPC(), msgLen)
+	req := agSendReqT{PID: p.PID, targetType: memory.GetDwbits(p.cpu.GetAc(2), 22, 2)}
+	switch req.targetType {
+	case sendToPID:
+		req.targetPID = dg.WordT(p.cpu.GetAc(0))
+	case sendToProc, sendToConsole:
+		req.targetName = strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	}
+	areq := AgentReqT{agentSend, req, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
+	resp := areq.result.(agSendRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	logging.DebugPrint(logging.ScLog, "\tWriting <%s> to @%s\n", string(msg), resp.con.name)
+	resp.con.writeConsole(msg)
 	return true
 }
 
 func scWrite(p syscallParmsT) bool {
 	pkt := dg.PhysAddrT(p.cpu.GetAc(2))
-	channel := int(memory.ReadWord(pkt + ich))
-	specsWd := memory.ReadWord(pkt + isti)
+	channel := int(p.mem.ReadWord(pkt + ich))
+	specsWd := p.mem.ReadWord(pkt + isti)
 	extendedPkt := specsWd&ipkl != 0
 	absPositioning := specsWd&ipst != 0
-	dataSens := specsWd&rtds != 0
-	recLen := int(int16(memory.ReadWord(pkt + ircl)))
-	byteslice := memory.ReadBytes(memory.ReadDWord(pkt+ibad), p.ringMask, int(recLen))
-	if dataSens {
-		// this is done here to avoid passing excess data into the Agent
-		maxLen := recLen
-		if recLen == -1 {
-			maxLen = agChannels[channel].recordLength
-		}
-		var tooLong bool
-		byteslice, tooLong = getDataSensitivePortion(byteslice, maxLen)
-		if tooLong {
-			p.cpu.SetAc(0, dg.DwordT(erltl))
-			return false
-		}
+	recLen := int(int16(p.mem.ReadWord(pkt + ircl)))
+	memLen, errCode := writeLength(p, channel, specsWd, recLen)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
 	}
-	position := int32(memory.ReadDWord(pkt + irnh))
-	var writeReq = agWriteReqT{channel, extendedPkt, absPositioning, dataSens, recLen, byteslice, position}
-	var areq = AgentReqT{agentFileWrite, writeReq, nil}
-	p.agentChan <- areq
-	areq = <-p.agentChan
-	if areq.result.(agWriteRespT).errCode != 0 {
-		p.cpu.SetAc(0, dg.DwordT(areq.result.(agWriteRespT).errCode))
+	byteslice := p.mem.ReadBytes(p.mem.ReadDWord(pkt+ibad), p.ringMask, memLen)
+	position := int64(int32(p.mem.ReadDWord(pkt + irnh)))
+	resp := writeFile(p, agWriteReqT{p.PID, channel, extendedPkt, absPositioning, specsWd, recLen, byteslice, position})
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
 		return false
 	}
-	memory.WriteWord(pkt+irlr, areq.result.(agWriteRespT).bytesTxfrd)
+	p.mem.WriteWord(pkt+irlr, resp.bytesTxfrd)
 	return true
 }
 
 func scWrite16(p syscallParmsT) bool {
 	pkt := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
-	channel := int(memory.ReadWord(pkt + ich16))
-	specsWd := memory.ReadWord(pkt + isti16)
+	channel := int(p.mem.ReadWord(pkt + ich16))
+	specsWd := p.mem.ReadWord(pkt + isti16)
 	extendedPkt := specsWd&ipkl != 0
 	absPositioning := specsWd&ipst != 0
-	dataSens := specsWd&rtds != 0
-	recLen := int(int16(memory.ReadWord(pkt + ircl16)))
-	byteslice := memory.ReadBytes(dg.DwordT(memory.ReadWord(pkt+ibad16)), p.ringMask, int(recLen))
-	if dataSens {
-		maxLen := recLen
-		if recLen == -1 {
-			maxLen = agChannels[channel].recordLength
-		}
-		var tooLong bool
-		byteslice, tooLong = getDataSensitivePortion(byteslice, maxLen)
-		if tooLong {
-			p.cpu.SetAc(0, dg.DwordT(erltl))
-			return false
+	recLen := int(int16(p.mem.ReadWord(pkt + ircl16)))
+	memLen, errCode := writeLength(p, channel, specsWd, recLen)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	byteslice := p.mem.ReadBytes(dg.DwordT(p.mem.ReadWord(pkt+ibad16)), p.ringMask, memLen)
+	position := int64(int32(p.mem.ReadDWord(pkt + irnh16)))
+	resp := writeFile(p, agWriteReqT{p.PID, channel, extendedPkt, absPositioning, specsWd, recLen, byteslice, position})
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	p.mem.WriteWord(pkt+irlr16, resp.bytesTxfrd)
+	return true
+}
+
+// readFile has the Agent read from a channel, or reads the console itself so that the Agent
+// is not held up waiting for the user
+func readFile(p syscallParmsT, req agReadReqT) agReadRespT {
+	areq := AgentReqT{agentFileRead, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agReadRespT)
+	if resp.console != nil {
+		resp.data, resp.ac0 = resp.console.readConsole(req.length, req.specs&ibin != 0, getPerProcessData(p.PID).sched.termination())
+		logging.DebugPrint(logging.ScLog, "?READ - returning <%v>\n", resp.data)
+	}
+	return resp
+}
+
+// writeFile has the Agent write to a channel, or writes the console itself as output may be held by CTRL-S
+func writeFile(p syscallParmsT, req agWriteReqT) agWriteRespT {
+	areq := AgentReqT{agentFileWrite, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agWriteRespT)
+	if resp.console != nil {
+		resp.bytesTxfrd = dg.WordT(resp.console.writeConsole(resp.data))
+		if debugLogging {
+			logging.DebugPrint(logging.ScLog, "\twrote %d., bytes <%v> to @CONSOLE\n", resp.bytesTxfrd, resp.data)
+			logging.DebugPrint(logging.ScLog, "\t\tString: <%s>\n", string(resp.data))
 		}
 	}
-	position := int32(memory.ReadDWord(pkt + irnh16))
-	var writeReq = agWriteReqT{channel, extendedPkt, absPositioning, specsWd&rtds != 0, recLen, byteslice, position}
-	var areq = AgentReqT{agentFileWrite, writeReq, nil}
+	return resp
+}
+
+// writeLength returns the number of bytes a ?WRITE may take from memory, asking the Agent
+// for the channel's defaults if the packet does not override them
+func writeLength(p syscallParmsT, channel int, specs dg.WordT, recLen int) (int, dg.WordT) {
+	if recLen != -1 && specs&icrf != 0 {
+		return recLen, 0
+	}
+	areq := AgentReqT{agentChannelInfo, agChannelInfoReqT{p.PID, channel}, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
-	memory.WriteWord(pkt+irlr16, areq.result.(agWriteRespT).bytesTxfrd)
-	return true
+	resp := areq.result.(agChannelInfoRespT)
+	if resp.errCode != 0 {
+		return 0, resp.errCode
+	}
+	if recLen == -1 || resp.recordFormat == rtfx && specs&icrf == 0 {
+		recLen = resp.recordLength
+	}
+	return recLen, 0
 }
@@ -24,8 +24,8 @@
 package aosvs
 
 import (
-	"log"
 	"strings"
+	"time"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
@@ -35,68 +35,308 @@ import (
 func scCreate(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | (p.ringMask)
 	bpFilename := p.cpu.GetAc(0)
-	filename := strings.ToUpper(readString(bpFilename, p.ringMask))
-	fileType := memory.ReadWord(pktAddr+cftyp) & 0x00ff
+	filename := strings.ToUpper(readString(p.mem, bpFilename, p.ringMask))
+	fileType := p.mem.ReadWord(pktAddr+cftyp) & 0x00ff
 	switch fileType {
 	case fipc:
-		localPortNo := int(memory.ReadWord(pktAddr + cpor))
+		localPortNo := int(p.mem.ReadWord(pktAddr + cpor))
 		// not handling ?CTIM
 		var acl string
-		bpACL := memory.ReadDWord(pktAddr + cacp)
+		bpACL := p.mem.ReadDWord(pktAddr + cacp)
 		switch bpACL {
 		case 0xffff_ffff:
 			acl = "[DEFACL]"
 		case 0:
 			acl = ""
 		default:
-			acl = readString(bpACL, p.ringMask)
+			acl = readString(p.mem, bpACL, p.ringMask)
 		}
 		logging.DebugPrint(logging.ScLog, "----- IPC File: %s Local Port #: %d, ACL: %s\n", filename, localPortNo, acl)
-		crIPCreq := agCreateIPCReqT{p.PID, filename, localPortNo, acl}
+		crIPCreq := agCreateIPCReqT{p.PID, int(p.ringMask >> 28), filename, localPortNo, acl}
 		areq := AgentReqT{agentCreateIPC, crIPCreq, nil}
 		p.agentChan <- areq
 		areq = <-p.agentChan
+		if errCode := areq.result.(dg.WordT); errCode != 0 {
+			p.cpu.SetAc(0, dg.DwordT(errCode))
+			return false
+		}
+	case flnk, fgfn, fmtf:
+		p.cpu.SetAc(0, erift)
+		return false
 	default:
-		log.Panicf("ERROR: ?CREATE called for %s - not yet implemented", filename)
+		req := agCreateReqT{
+			PID:         p.PID,
+			aosFilename: filename,
+			fileType:    int(fileType),
+			recFormat:   int(p.mem.ReadWord(pktAddr+cftyp) >> 8),
+		}
+		if req.recFormat == 0 {
+			req.recFormat = rtds
+		}
+		if fileType < ldir || fileType > hdir {
+			req.elemSize = int(p.mem.ReadWord(pktAddr + cdel))
+		}
+		if timeBlk := p.mem.ReadDWord(pktAddr + ctim); timeBlk != 0xffff_ffff {
+			tb := readPacket(p.mem, dg.PhysAddrT(timeBlk)|p.ringMask, tlth)
+			req.times = []time.Time{goTime(tb[tcth], tb[tctl]), goTime(tb[tath], tb[tatl]), goTime(tb[tmth], tb[tmtl])}
+		}
+		switch bpACL := p.mem.ReadDWord(pktAddr + cacp); bpACL {
+		case 0xffff_ffff:
+			req.defaultACL = true
+		case 0:
+			// no ACL - nobody has access
+		default:
+			var ok bool
+			if req.acl, ok = decodeACL(readACL(p.mem, bpACL, p.ringMask)); !ok {
+				p.cpu.SetAc(0, eracl)
+				return false
+			}
+		}
+		logging.DebugPrint(logging.ScLog, "----- File: %s Type: %d. Record Format: %d.\n", filename, fileType, req.recFormat)
+		areq := AgentReqT{agentCreate, req, nil}
+		p.agentChan <- areq
+		areq = <-p.agentChan
+		if errCode := areq.result.(agCreateRespT).errCode; errCode != 0 {
+			p.cpu.SetAc(0, dg.DwordT(errCode))
+			return false
+		}
 	}
-
 	return true
 }
 
 func scDacl(p syscallParmsT) bool {
-	// TODO this is all faked...
+	req := agDefaultACLReqT{PID: p.PID}
 	switch dg.WordT(p.cpu.GetAc(0)) { // make 16-bit safe
-	case 0xffff:
-		log.Panic("ERROR: Setting new DefACL not yet implemented in ?DACL")
-	case 0:
-		defacl := []byte("XYZZY")
-		defacl = append(defacl, 0)
-		defacl = append(defacl, faco+facw+faca+facw+face)
-		defacl = append(defacl, 0)
-		memory.WriteBytesBA(defacl, p.cpu.GetAc(1))
-	case 1:
-		log.Panic("ERROR: Turning off DefACL not yet implemented in ?DACL")
+	case 0xffff: // set a new default ACL
+		var ok bool
+		if req.acl, ok = decodeACL(readACL(p.mem, p.cpu.GetAc(1), p.ringMask)); !ok {
+			p.cpu.SetAc(0, eracl)
+			return false
+		}
+		req.set = true
+	case 0: // just get the default ACL
+	case 1: // turn off the default ACL
+		req.unset = true
+	default:
+		p.cpu.SetAc(0, erpre)
+		return false
+	}
+	areq := AgentReqT{agentDefaultACL, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if !req.set {
+		p.mem.WriteBytesBA(encodeACL(areq.result.(agDefaultACLRespT).acl), p.cpu.GetAc(1))
+	}
+	return true
+}
+
+func scDelete(p syscallParmsT) bool {
+	filename := strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	areq := AgentReqT{agentDelete, agDeleteReqT{p.PID, filename}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agDeleteRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+func scFstat(p syscallParmsT) bool {
+	req := agFileStatusReqT{PID: p.PID}
+	if p.cpu.GetAc(1)&0x8000_0000 != 0 { // 1B0 => AC0 holds a channel number
+		req.chanNo = int(p.cpu.GetAc(0))
+	} else {
+		req.aosFilename = strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	}
+	areq := AgentReqT{agentFileStatus, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agFileStatusRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	pkt := make([]dg.WordT, slth)
+	pkt[styp] = dg.WordT(resp.meta.RecFormat)<<8 | dg.WordT(resp.meta.FileType)
+	if len(resp.meta.UDA) > 0 {
+		pkt[ssts] |= sudf
+	}
+	if len(resp.meta.ACL) > 0 {
+		pkt[ssts] |= sacl
+	}
+	if resp.openCnt > 0 {
+		pkt[ssts] |= sopb
+	}
+	pkt[stch], pkt[stcl] = aosvsTime(resp.meta.Created)
+	pkt[stah], pkt[stal] = aosvsTime(resp.meta.Accessed)
+	pkt[stmh], pkt[stml] = aosvsTime(resp.meta.Modified)
+	if resp.meta.FileType >= ldir && resp.meta.FileType <= hdir {
+		blocks := (resp.length + 511) / 512
+		pkt[scsh], pkt[scsl] = dg.WordT(blocks>>16), dg.WordT(blocks)
+	} else {
+		pkt[slau], pkt[slal] = dg.WordT(resp.length>>16), dg.WordT(resp.length)
+		pkt[sefw] = dg.WordT(resp.meta.ElemSize)
+	}
+	pkt[sopn] = dg.WordT(resp.openCnt)
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	for w, wd := range pkt {
+		p.mem.WriteWord(pktAddr+dg.PhysAddrT(w), wd)
+	}
+	return true
+}
+
+// scGacl and scSacl share the ?GACL/?SACL logic
+func scGacl(p syscallParmsT) bool {
+	return getSetACL(p, false)
+}
+
+func scSacl(p syscallParmsT) bool {
+	return getSetACL(p, true)
+}
+
+func getSetACL(p syscallParmsT, set bool) bool {
+	req := agACLReqT{PID: p.PID, set: set}
+	req.aosFilename = strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	if set {
+		var ok bool
+		if req.acl, ok = decodeACL(readACL(p.mem, p.cpu.GetAc(1), p.ringMask)); !ok {
+			p.cpu.SetAc(0, eracl)
+			return false
+		}
+	}
+	areq := AgentReqT{agentACL, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agACLRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	if !set {
+		p.mem.WriteBytesBA(encodeACL(resp.acl), p.cpu.GetAc(1))
 	}
 	return true
 }
 
+func scCgnam(p syscallParmsT) bool {
+	req := agPathnameReqT{PID: p.PID, chanNo: int(p.cpu.GetAc(0))}
+	return returnPathname(p, req)
+}
+
+func scDir(p syscallParmsT) bool {
+	dir := strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	areq := AgentReqT{agentDir, agDirReqT{p.PID, dir}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agDirRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+func scGlist(p syscallParmsT) bool {
+	areq := AgentReqT{agentSearchList, agSearchListReqT{PID: p.PID}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	var ba []byte
+	for _, path := range areq.result.(agSearchListRespT).searchList {
+		ba = append(ba, []byte(path)...)
+		ba = append(ba, 0)
+	}
+	ba = append(ba, 0)
+	p.mem.WriteBytesBA(ba, p.cpu.GetAc(0))
+	return true
+}
+
 func scGname(p syscallParmsT) bool {
-	bpFilename := p.cpu.GetAc(0)
-	filename := strings.ToUpper(readString(bpFilename, p.ringMask))
-	if filename[0] == '@' {
-		filename = ":PER:" + filename[1:] // convert @ to :PER
-	}
-	filename = strings.ReplaceAll(filename, "/", ":") // convert any / to :
-	bpPathname := p.cpu.GetAc(1)
-	writeBytes(bpPathname, p.ringMask, []byte(filename))
-	p.cpu.SetAc(2, dg.DwordT(len(filename)))
-	logging.DebugPrint(logging.ScLog, "?GNAME returning %s for %s\n", readString(bpFilename, p.ringMask), filename)
+	filename := strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	req := agPathnameReqT{PID: p.PID, aosFilename: strings.ReplaceAll(filename, "/", ":")}
+	return returnPathname(p, req)
+}
+
+// returnPathname handles the common part of ?GNAME and ?CGNAM, returning a complete pathname in
+// the buffer at AC1 whose length is in AC2
+func returnPathname(p syscallParmsT, req agPathnameReqT) bool {
+	areq := AgentReqT{agentPathname, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agPathnameRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	if len(resp.full) >= int(dg.WordT(p.cpu.GetAc(2))) {
+		p.cpu.SetAc(0, erirb)
+		return false
+	}
+	writeBytes(p.mem, p.cpu.GetAc(1), p.ringMask, append([]byte(resp.full), 0))
+	p.cpu.SetAc(2, dg.DwordT(len(resp.full)))
+	logging.DebugPrint(logging.ScLog, "\tReturning pathname %s\n", resp.full)
+	return true
+}
+
+func scGnfn(p syscallParmsT) bool {
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	req := agNextFilenameReqT{
+		PID:    p.PID,
+		chanNo: int(p.cpu.GetAc(1)),
+		key:    int(p.mem.ReadDWord(pktAddr + nfky)),
+	}
+	if bpTemplate := p.mem.ReadDWord(pktAddr + nftp); bpTemplate != 0xffff_ffff {
+		req.template = strings.ToUpper(readString(p.mem, bpTemplate, p.ringMask))
+	}
+	areq := AgentReqT{agentNextFilename, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agNextFilenameRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	writeBytes(p.mem, p.mem.ReadDWord(pktAddr+nfnm), p.ringMask, append([]byte(resp.filename), 0))
+	p.mem.WriteDWord(pktAddr+nfky, dg.DwordT(resp.nextKey))
+	return true
+}
+
+func scSlist(p syscallParmsT) bool {
+	req := agSearchListReqT{PID: p.PID, set: true}
+	bp := p.cpu.GetAc(0)
+	for {
+		dir := strings.ToUpper(readString(p.mem, bp, p.ringMask))
+		if dir == "" {
+			break
+		}
+		req.searchList = append(req.searchList, dir)
+		bp += dg.DwordT(len(dir) + 1)
+	}
+	areq := AgentReqT{agentSearchList, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agSearchListRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+func scRename(p syscallParmsT) bool {
+	oldName := strings.ToUpper(readString(p.mem, p.cpu.GetAc(0), p.ringMask))
+	newName := strings.ToUpper(readString(p.mem, p.cpu.GetAc(1), p.ringMask))
+	areq := AgentReqT{agentRename, agRenameReqT{p.PID, oldName, newName}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agRenameRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	return true
 }
 
 func scRecreate(p syscallParmsT) bool {
 	bpFilename := p.cpu.GetAc(0)
-	filename := strings.ToUpper(readString(bpFilename, p.ringMask))
+	filename := strings.ToUpper(readString(p.mem, bpFilename, p.ringMask))
 	var recReq = agRecreateReqT{PID: p.PID, aosFilename: filename}
 	var areq = AgentReqT{agentFileRecreate, recReq, nil}
 	p.agentChan <- areq
@@ -108,3 +348,22 @@ func scRecreate(p syscallParmsT) bool {
 	}
 	return true
 }
+
+// readACL fetches an ACL from memory without reading beyond its terminating NUL
+func readACL(mem *memory.AddrSpaceT, bp dg.DwordT, ringMask dg.PhysAddrT) (ba []byte) {
+	atStart := true // of a template
+	for len(ba) < mxacl {
+		b := mem.ReadBytes(bp+dg.DwordT(len(ba)), ringMask, 1)[0]
+		ba = append(ba, b)
+		switch {
+		case atStart && b == 0:
+			return ba
+		case b == 0: // the access byte follows
+			ba = append(ba, mem.ReadBytes(bp+dg.DwordT(len(ba)), ringMask, 1)[0])
+			atStart = true
+		default:
+			atStart = false
+		}
+	}
+	return ba
+}
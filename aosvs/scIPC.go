@@ -33,7 +33,7 @@ import (
 
 func scIlkup(p syscallParmsT) bool {
 	bpPathname := p.cpu.GetAc(0)
-	path := strings.ToUpper(readString(bpPathname, p.ringMask))
+	path := strings.ToUpper(readString(p.mem, bpPathname, p.ringMask))
 	agIlkupReq := agIlkupReqT{p.PID, path}
 	areq := AgentReqT{agentIlkup, agIlkupReq, nil}
 	p.agentChan <- areq
@@ -55,21 +55,114 @@ func scIlkup(p syscallParmsT) bool {
 }
 
 func scIrec(p syscallParmsT) bool {
-	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	sysFlags := memory.ReadWord(pktAddr + isfl)
-	usrFlags := memory.ReadWord(pktAddr + iufl)
-	originGlobalPortNo := memory.ReadDWord(pktAddr + ioph)
-	destLocalPortNo := memory.ReadWord(pktAddr + idpn)
-	bufLen := memory.ReadWord(pktAddr + ilth)
-	bufAddr := memory.ReadDWord(pktAddr + iptr)
-	logging.DebugPrint(logging.ScLog, "\tSys Flags: %#x \tUser Flags: %#x\n", sysFlags, usrFlags)
-	logging.DebugPrint(logging.ScLog, "\tOrigin Port: %#x\n", originGlobalPortNo)
-	logging.DebugPrint(logging.ScLog, "\tDest Local Port: %#x \t Buff Len: %d.\n", destLocalPortNo, bufLen)
-	logging.DebugPrint(logging.ScLog, "\tBuff Addr: %#x (%#o)\n", bufAddr, bufAddr)
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	sysFlags := p.mem.ReadWord(pktAddr + isfl)
+	if errCode := receiveIPC(p, pktAddr, ipcWaiterT{
+		localPort:     p.mem.ReadWord(pktAddr + idpn),
+		origin:        p.mem.ReadDWord(pktAddr + ioph),
+		bufLen:        int(p.mem.ReadWord(pktAddr + ilth)),
+		spoolOverflow: memory.TestWbit(sysFlags, ibsov),
+	}, memory.TestWbit(sysFlags, ibnbk), pktAddr+ilth, pktAddr+iptr); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	return true
 }
 
 func scIsend(p syscallParmsT) bool {
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	if errCode := sendIPC(p, pktAddr); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
 
+// scIsr handles ?IS.R - send a message then wait for the reply from the same port
+func scIsr(p syscallParmsT) bool {
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	if errCode := sendIPC(p, pktAddr); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	if errCode := receiveIPC(p, pktAddr, ipcWaiterT{
+		localPort: p.mem.ReadWord(pktAddr + iopn),
+		origin:    p.mem.ReadDWord(pktAddr + idph),
+		bufLen:    int(p.mem.ReadWord(pktAddr + irlt)),
+	}, false, pktAddr+irlt, pktAddr+irpt); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	return true
 }
+
+// sendIPC sends the message described by an ?ISEND header
+func sendIPC(p syscallParmsT, pktAddr dg.PhysAddrT) (errCode dg.WordT) {
+	sysFlags := p.mem.ReadWord(pktAddr + isfl)
+	req := agIsendReqT{
+		PID:     p.PID,
+		ring:    int(p.ringMask >> 28),
+		dest:    p.mem.ReadDWord(pktAddr + idph),
+		origin:  p.mem.ReadWord(pktAddr + iopn),
+		noSpool: memory.TestWbit(sysFlags, ibnsp),
+		msg: ipcMsgT{
+			sysFlags: sysFlags,
+			usrFlags: p.mem.ReadWord(pktAddr + iufl),
+		},
+	}
+	msgLen := int(p.mem.ReadWord(pktAddr + ilth))
+	if msgLen > 0 {
+		msgAddr := dg.PhysAddrT(p.mem.ReadDWord(pktAddr+iptr)) | p.ringMask
+		for w := 0; w < msgLen; w++ {
+			req.msg.words = append(req.msg.words, p.mem.ReadWord(msgAddr+dg.PhysAddrT(w)))
+		}
+	}
+	logging.DebugPrint(logging.ScLog, "\tSending %d. words to port %#o from local port %d.\n", msgLen, req.dest, req.origin)
+	areq := AgentReqT{agentIsend, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	return areq.result.(agIsendRespT).errCode
+}
+
+// receiveIPC waits for a message, unless noWait is set, then fills in the ?IREC header, the
+// message length at lenAddr, and copies as much of the message as fits into the buffer at bufPtr
+func receiveIPC(p syscallParmsT, pktAddr dg.PhysAddrT, waiter ipcWaiterT, noWait bool, lenAddr, bufPtr dg.PhysAddrT) (errCode dg.WordT) {
+	logging.DebugPrint(logging.ScLog, "\tReceiving on local port %d. from %#o, buffer length %d.\n",
+		waiter.localPort, waiter.origin, waiter.bufLen)
+	areq := AgentReqT{agentIrec, agIrecReqT{p.PID, waiter, noWait}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agIrecRespT)
+	recv := resp.recv
+	if resp.waiter != nil {
+		sched := getPerProcessData(p.PID).sched
+		select {
+		case recv = <-resp.waiter.ch:
+		case <-sched.termination():
+			areq = AgentReqT{agentIrecCancel, agIrecCancelReqT{p.PID, resp.waiter}, nil}
+			p.agentChan <- areq
+			<-p.agentChan
+			return eridp
+		}
+	}
+	if recv.errCode != 0 && recv.errCode != ernef {
+		return recv.errCode
+	}
+	msg := recv.msg
+	p.mem.WriteWord(pktAddr+isfl, msg.sysFlags)
+	p.mem.WriteWord(pktAddr+iufl, msg.usrFlags)
+	p.mem.WriteDWord(pktAddr+ioph, msg.origin)
+	p.mem.WriteWord(pktAddr+idpn, msg.destLocal)
+	p.mem.WriteWord(lenAddr, dg.WordT(len(msg.words)))
+	if recv.errCode == ernef && waiter.spoolOverflow {
+		return ernef // the message stays spooled
+	}
+	bufAddr := dg.PhysAddrT(p.mem.ReadDWord(bufPtr)) | p.ringMask
+	for w := 0; w < len(msg.words) && w < waiter.bufLen; w++ {
+		p.mem.WriteWord(bufAddr+dg.PhysAddrT(w), msg.words[w])
+	}
+	if msg.connBits != 0 {
+		p.mem.WriteWord(bufPtr+1, msg.connBits)
+	}
+	return recv.errCode
+}
@@ -25,6 +25,7 @@ package aosvs
 
 import (
 	"log"
+	"os"
 	"strings"
 
 	"github.com/SMerrony/dgemug/dg"
@@ -32,60 +33,153 @@ import (
 	"github.com/SMerrony/dgemug/memory"
 )
 
+// The unshared area of a ring starts at its page zero and grows upwards via ?MEMI, the shared
+// area lies above it and is moved or resized via ?SSHPT.  A 16-bit program sees only the
+// first 32 pages of its ring.
+const (
+	pagesPerRing       = 0x1000_0000 >> 10
+	pagesPer16bitAS    = 32
+	noUnsharedPages    = 0xffff_ffff
+	sixteenBitAddrMask = 0x7fff
+	pageBytes          = 2048
+	blocksPerPage      = 4 // disk blocks of 512 bytes
+)
+
+// memLayoutT describes the areas of a ring in ring-relative page numbers
+type memLayoutT struct {
+	ring          int
+	ringBase      dg.DwordT // absolute number of the ring's page zero
+	limit         dg.DwordT // number of pages in the logical address space
+	unsharedPages dg.DwordT // pages 0 to unsharedPages-1 are unshared
+	firstShared   dg.DwordT // limit if there are no shared pages
+	sharedPages   dg.DwordT
+}
+
+func getMemLayout(p syscallParmsT, sixteenBit bool) (ml memLayoutT) {
+	ml.ring = int(p.ringMask >> 28)
+	ml.ringBase = dg.DwordT(ml.ring) * pagesPerRing
+	ml.limit = pagesPerRing
+	if sixteenBit {
+		ml.limit = pagesPer16bitAS
+	}
+	if lastUnshared := p.mem.GetLastUnsharedPage(ml.ring); lastUnshared != noUnsharedPages {
+		ml.unsharedPages = lastUnshared - ml.ringBase + 1
+	}
+	ml.sharedPages = dg.DwordT(p.mem.GetNumSharedPages(ml.ring))
+	ml.firstShared = ml.limit
+	if ml.sharedPages > 0 {
+		ml.firstShared = p.mem.GetFirstSharedPage(ml.ring) - ml.ringBase
+	}
+	return ml
+}
+
+// highestUnsharedAddr is the logical address of the last word of the unshared area
+func (ml memLayoutT) highestUnsharedAddr(p syscallParmsT, sixteenBit bool) dg.DwordT {
+	addr := ml.unsharedPages<<10 - 1
+	if sixteenBit {
+		return addr & sixteenBitAddrMask
+	}
+	return addr | dg.DwordT(p.ringMask)
+}
+
 func scGshpt(p syscallParmsT) bool {
-	p.cpu.SetAc(0, memory.GetFirstSharedPage()&0x0003_ffff)
-	p.cpu.SetAc(1, dg.DwordT(memory.GetNumSharedPages()))
+	return gshpt(p, false)
+}
+
+func scGshpt16(p syscallParmsT) bool {
+	return gshpt(p, true)
+}
+
+// gshpt returns the first page of the shared area in AC0 and its size in AC1
+func gshpt(p syscallParmsT, sixteenBit bool) bool {
+	ml := getMemLayout(p, sixteenBit)
+	p.cpu.SetAc(0, ml.firstShared)
+	p.cpu.SetAc(1, ml.sharedPages)
+	logging.DebugPrint(logging.ScLog, "\tShared area starts at page %#o, %d. pages\n", ml.firstShared, ml.sharedPages)
 	return true
 }
 
 func scMem(p syscallParmsT) bool {
-	highestUnsharedInUse := memory.GetLastUnsharedPage() //& 0x0003_ffff // assumed in current ring
-	lowShared := memory.GetFirstSharedPage()             //& 0x0003_ffff
-	unusedUnshared := int32(lowShared) - int32(highestUnsharedInUse) - 4
-	if unusedUnshared < 0 {
-		unusedUnshared = 0
-	}
-	inUsePages := highestUnsharedInUse & (0x0fff_ffff >> 10)
-	p.cpu.SetAc(0, dg.DwordT(unusedUnshared))
-	p.cpu.SetAc(1, inUsePages)
-	p.cpu.SetAc(2, highestUnsharedInUse<<10|dg.DwordT(p.ringMask))
-	//p.cpu.SetAc(2, inUsePages+dg.DwordT(p.ringMask)-1)
-	logging.DebugPrint(logging.ScLog, "\tMax Unshared Available: %d., Unshared in Use: %d., Highest in Use: %#o (%#x)\n",
-		unusedUnshared, inUsePages, highestUnsharedInUse<<10, highestUnsharedInUse<<10)
-	logging.DebugPrint(logging.ScLog, "\tN.B. Lowest shared page is %#o (%#x)\n", lowShared<<10, lowShared<<10)
+	return mem(p, false)
+}
+
+func scMem16(p syscallParmsT) bool {
+	return mem(p, true)
+}
+
+// mem returns the number of unshared pages that could still be added in AC0, the number in
+// use in AC1 and the highest unshared address in AC2
+func mem(p syscallParmsT, sixteenBit bool) bool {
+	ml := getMemLayout(p, sixteenBit)
+	var available dg.DwordT
+	if ml.firstShared > ml.unsharedPages {
+		available = ml.firstShared - ml.unsharedPages
+	}
+	if limit := dg.DwordT(runLimits.MaxPages); limit > 0 {
+		switch {
+		case ml.unsharedPages >= limit:
+			available = 0
+		case limit-ml.unsharedPages < available:
+			available = limit - ml.unsharedPages
+		}
+	}
+	p.cpu.SetAc(0, available)
+	p.cpu.SetAc(1, ml.unsharedPages)
+	p.cpu.SetAc(2, ml.highestUnsharedAddr(p, sixteenBit))
+	logging.DebugPrint(logging.ScLog, "\tMax Unshared Available: %d., Unshared in Use: %d., Highest in Use: %#o\n",
+		available, ml.unsharedPages, ml.highestUnsharedAddr(p, sixteenBit))
+	logging.DebugPrint(logging.ScLog, "\tN.B. Lowest shared page is %#o\n", ml.firstShared)
 	return true
 }
 
 func scMemi(p syscallParmsT) bool {
-	numPages := int32(int16(p.cpu.GetAc(0)))
+	return memi(p, false)
+}
+
+func scMemi16(p syscallParmsT) bool {
+	return memi(p, true)
+}
+
+// memi adds (or, if negative, releases) the number of unshared pages in AC0, returning
+// the new highest unshared address in AC1
+func memi(p syscallParmsT, sixteenBit bool) bool {
+	ml := getMemLayout(p, sixteenBit)
+	numPages := int(int16(p.cpu.GetAc(0)))
 	logging.DebugPrint(logging.ScLog, "\tRequested page count: %d, (%#x)\n", numPages, p.cpu.GetAc(0))
-	//var lastPage int
 	switch {
 	case numPages > 0: // add pages
+		if ml.unsharedPages+dg.DwordT(numPages) > ml.firstShared ||
+			runLimits.MaxPages > 0 && int(ml.unsharedPages)+numPages > runLimits.MaxPages {
+			p.cpu.SetAc(0, ermem)
+			return false
+		}
 		logging.DebugPrint(logging.ScLog, "\tAdding %d. page(s)\n", numPages)
-		for numPages > 0 {
-			//lastPage = memory.AddUnsharedPage()
-			memory.AddUnsharedPage()
-			numPages--
+		for ; numPages > 0; numPages-- {
+			p.mem.AddUnsharedPage(ml.ring)
 		}
-		//p.cpu.SetAc(1, (dg.DwordT(lastPage<<10)|dg.DwordT(p.ringMask))-1)
-		highestUnsharedInUse := memory.GetLastUnsharedPage()
-		p.cpu.SetAc(1, highestUnsharedInUse<<10|dg.DwordT(p.ringMask))
-		logging.DebugPrint(logging.ScLog, "\tHighest in use is now  %#o (%#x)\n", memory.GetLastUnsharedPage()<<10, memory.GetLastUnsharedPage()<<10)
 	case numPages < 0: // remove pages
-		log.Panicln("ERROR: Unmapping via ?MEMI not yet supported")
+		logging.DebugPrint(logging.ScLog, "\tReleasing %d. page(s)\n", -numPages)
+		if !p.mem.ReleaseUnsharedPages(ml.ring, -numPages) {
+			p.cpu.SetAc(0, ermem)
+			return false
+		}
 	}
+	ml = getMemLayout(p, sixteenBit)
+	p.cpu.SetAc(1, ml.highestUnsharedAddr(p, sixteenBit))
+	logging.DebugPrint(logging.ScLog, "\tHighest in use is now %#o\n", ml.highestUnsharedAddr(p, sixteenBit))
 	return true
 }
 
 // AOS/VS treats this as a memory operation, not a file one...
+// scSopen opens a file for shared page access, AC2 is zero for read-only access
 func scSopen(p syscallParmsT) bool {
 	bpFilename := p.cpu.GetAc(0)
-	filename := strings.ToUpper(readString(bpFilename, p.ringMask))
+	filename := strings.ToUpper(readString(p.mem, bpFilename, p.ringMask))
+	chanNo := -1
 	if p.cpu.GetAc(1) != 0xffff_ffff {
-		log.Panicln("ERROR: ?SOPEN of specific channel not yet implemented")
+		chanNo = int(p.cpu.GetAc(1))
 	}
-	var sopenReq = agSharedOpenReqT{p.PID, filename, p.cpu.GetAc(2) == 0}
+	var sopenReq = agSharedOpenReqT{p.PID, filename, p.cpu.GetAc(2) == 0, chanNo}
 	var areq = AgentReqT{agentSharedOpen, sopenReq, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
@@ -97,44 +191,138 @@ func scSopen(p syscallParmsT) bool {
 	return true
 }
 
+// sharedFile asks the pseudo-Agent for the host file ?SOPENed on a channel
+func sharedFile(p syscallParmsT, chanNo int) (resp agSharedFileRespT) {
+	areq := AgentReqT{agentSharedFile, agSharedFileReqT{p.PID, chanNo}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	return areq.result.(agSharedFileRespT)
+}
+
+// filePageIO returns the functions which read and (if writable) write a page of a host file,
+// the file is opened afresh each time so that they work whether or not the channel is still open
+func filePageIO(path string, filePage int64, writable bool) (load func() []dg.WordT, store func([]dg.WordT)) {
+	offset := filePage * pageBytes
+	load = func() []dg.WordT {
+		buf := make([]byte, pageBytes)
+		if fp, err := os.Open(path); err == nil {
+			fp.ReadAt(buf, offset) // the page is zero-filled beyond the end of the file
+			fp.Close()
+		}
+		logging.DebugPrint(logging.ScLog, "\tLoaded page %d. of %s\n", filePage, path)
+		return memory.WordsFromBytes(buf)
+	}
+	if writable {
+		store = func(wds []dg.WordT) {
+			fp, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err == nil {
+				_, err = fp.WriteAt(memory.BytesFromWords(wds), offset)
+				fp.Close()
+			}
+			if err != nil {
+				log.Printf("WARNING: Could not write back page %d. of %s - %v\n", filePage, path, err)
+			}
+		}
+	}
+	return load, store
+}
+
+// scSpage maps pages of a file opened via ?SOPEN into the shared area, they are not read
+// from the file until they are first used
 func scSpage(p syscallParmsT) bool {
 	fileChan := int(p.cpu.GetAc(1))
-	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	diskBytesCount := int(memory.ReadWord(pktAddr+psti)) * 512
-	diskStartBlock := int64(memory.ReadDWord(pktAddr+prnh)) * 512
-	memStartAddr := dg.PhysAddrT(memory.ReadDWord(pktAddr + pcad))
-	asrRec := agSharedReadReqT{fileChan, diskBytesCount, diskStartBlock}
-	areq := AgentReqT{agentSharedRead, asrRec, nil}
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	numBlocks := int64(p.mem.ReadWord(pktAddr + psti))
+	startBlock := int64(p.mem.ReadDWord(pktAddr + prnh))
+	memStartAddr := dg.PhysAddrT(p.mem.ReadDWord(pktAddr+pcad)) | p.ringMask
+	if startBlock%blocksPerPage != 0 || memStartAddr&0x3ff != 0 || numBlocks == 0 {
+		p.cpu.SetAc(0, erpre)
+		return false
+	}
+	sf := sharedFile(p, fileChan)
+	if sf.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(sf.errCode))
+		return false
+	}
+	firstPage := int(memStartAddr >> 10)
+	for pg := int64(0); pg*blocksPerPage < numBlocks; pg++ {
+		filePage := startBlock/blocksPerPage + pg
+		load, store := filePageIO(sf.path, filePage, sf.writable)
+		if !p.mem.MapFilePage(firstPage+int(pg), sf.path, filePage, load, store) {
+			p.cpu.SetAc(0, ermem)
+			return false
+		}
+	}
+	logging.DebugPrint(logging.ScLog, "\tMapped %d. blocks from block %d. of %s at %#o\n", numBlocks, startBlock, sf.path, memStartAddr)
+	return true
+}
+
+// scRpage releases the shared file page containing the address in AC0
+func scRpage(p syscallParmsT) bool {
+	if !p.mem.UnmapFilePage(int((dg.PhysAddrT(p.cpu.GetAc(0)) | p.ringMask) >> 10)) {
+		p.cpu.SetAc(0, ervwp)
+		return false
+	}
+	return true
+}
+
+// scFlush writes the shared file page containing the address in AC0 back to its file
+func scFlush(p syscallParmsT) bool {
+	if !p.mem.FlushFilePage(int((dg.PhysAddrT(p.cpu.GetAc(0)) | p.ringMask) >> 10)) {
+		p.cpu.SetAc(0, ervwp)
+		return false
+	}
+	return true
+}
+
+// scSclose writes back and releases every page mapped from the file ?SOPENed on the
+// channel in AC1, then closes it
+func scSclose(p syscallParmsT) bool {
+	fileChan := int(p.cpu.GetAc(1))
+	sf := sharedFile(p, fileChan)
+	if sf.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(sf.errCode))
+		return false
+	}
+	for _, page := range p.mem.FilePages(sf.path) {
+		p.mem.FlushFilePage(page)
+		p.mem.UnmapFilePage(page)
+	}
+	areq := AgentReqT{agentFileClose, agCloseReqT{p.PID, fileChan}, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
-	if areq.result.(agSharedReadRespT).ac0 != 0 {
-		p.cpu.SetAc(0, areq.result.(agSharedReadRespT).ac0)
+	if errCode := areq.result.(agCloseRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
 		return false
 	}
-	//writeBytes(memStartAddr, p.ringMask, areq.result.(agSharedReadRespT).data)
-	words := memory.WordsFromBytes(areq.result.(agSharedReadRespT).data)
-	memory.MapSlice(memStartAddr, words, true)
 	return true
 }
 
-func scSshpt(p syscallParmsT) bool { // TODO removing pages
-	firstPageNo := p.cpu.GetAc(0)
-	newSize := p.cpu.GetAc(1)
-	//initialLastPage := firstPageNo + dg.DwordT(memory.GetNumSharedPages())
-	if firstPageNo < memory.GetFirstSharedPage()&0x0003_ffff {
-		// try to add pages at start
-		var pg int
-		for pg = int(firstPageNo); pg < int(memory.GetFirstSharedPage()&0x0003_ffff); pg++ {
-			if memory.IsPageMapped(pg) {
-				p.cpu.SetAc(0, ermem)
-				return false
+// scSshpt moves and/or resizes the shared area so that it starts at the page in AC0 and has
+// the number of pages in AC1, shared pages that fall outside the new area are unmapped
+func scSshpt(p syscallParmsT) bool {
+	ml := getMemLayout(p, false)
+	newFirst, newSize := p.cpu.GetAc(0), p.cpu.GetAc(1)
+	newEnd := newFirst + newSize
+	if newFirst < ml.unsharedPages || newEnd > ml.limit || newEnd < newFirst {
+		p.cpu.SetAc(0, ermem)
+		return false
+	}
+	if ml.sharedPages > 0 {
+		lastShared := p.mem.GetLastSharedPage(ml.ring) - ml.ringBase
+		for pg := ml.firstShared; pg <= lastShared; pg++ {
+			if (pg < newFirst || pg >= newEnd) && p.mem.IsPageMapped(int(ml.ringBase+pg)) {
+				if !p.mem.UnmapFilePage(int(ml.ringBase + pg)) {
+					p.mem.UnmapPage(int(ml.ringBase+pg), true)
+				}
 			}
-			memory.MapPage(pg, true)
 		}
 	}
-	// now at end
-	for memory.GetNumSharedPages() < int(newSize) {
-		memory.MapPage(int(memory.GetLastSharedPage())+1, true)
+	for pg := newFirst; pg < newEnd; pg++ {
+		if !p.mem.IsPageMapped(int(ml.ringBase + pg)) {
+			p.mem.ReserveSharedPage(int(ml.ringBase + pg))
+		}
 	}
+	logging.DebugPrint(logging.ScLog, "\tShared area is now %d. pages from page %#o\n", newSize, newFirst)
 	return true
 }
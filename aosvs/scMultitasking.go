@@ -1,6 +1,6 @@
 // +build virtual !physical
 
-// scM.go - File I/O System Call Emulation
+// scMultitasking.go - Multitasking System Call Emulation
 
 // Copyright ©2020 Steve Merrony
 
@@ -24,57 +24,424 @@
 package aosvs
 
 import (
-	"log"
 	"time"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
 	"github.com/SMerrony/dgemug/memory"
+	"github.com/SMerrony/dgemug/mvcpu"
 )
 
+// scIfpu initialises the FPU, AC0 is the address of the task's floating-point save area
+// (see ifpu), or zero
 func scIfpu(p syscallParmsT) bool {
-	// TODO should reserve FPU save area
+	return ifpu(p, dg.PhysAddrT(p.cpu.GetAc(0)))
+}
+
+// scIfpu16 is as scIfpu, with a save area address in the caller's 16-bit address space
+func scIfpu16(p syscallParmsT) bool {
+	addr := dg.PhysAddrT(p.cpu.GetAc(0) & 0xffff)
+	if addr != 0 {
+		addr |= p.ringMask
+	}
+	return ifpu(p, addr)
+}
+
+// ifpu initialises the calling task's FPU and reserves the mvcpu.FPUSaveWords save area at
+// addr, which must lie in mapped memory of the caller's ring.  The task's floating-point
+// state is kept there whenever it gives up the CPU or enters a kill routine.  A zero addr
+// just initialises the FPU.
+func ifpu(p syscallParmsT, addr dg.PhysAddrT) bool {
+	if addr != 0 {
+		last := addr + mvcpu.FPUSaveWords - 1
+		if addr&0x7000_0000 != p.ringMask || last&0x7000_0000 != p.ringMask ||
+			!p.mem.IsPageMapped(int(addr>>10)) || !p.mem.IsPageMapped(int(last>>10)) {
+			p.cpu.SetAc(0, ermpr)
+			return false
+		}
+	}
+	p.cpu.ResetFPU()
+	if addr != 0 {
+		p.cpu.StoreFPUState(addr)
+	}
+	getPerProcessData(p.PID).tasks[p.TID].fpuSave = addr
+	logging.DebugPrint(logging.ScLog, "\tFPU initialised for TID %d, save area at %#o\n", p.TID, addr)
 	return true
 }
 
-// func scKilad(cpu *mvcpu.CPUT, agentChan chan AgentReqT) bool {
+// scKilad defines the calling task's kill-processing routine at AC0, zero removes it
+func scKilad(p syscallParmsT) bool {
+	getPerProcessData(p.PID).tasks[p.TID].killAddr = dg.PhysAddrT(p.cpu.GetAc(0))
+	return true
+}
 
-// 	return true
-// }
+func scKilad16(p syscallParmsT) bool {
+	addr := dg.PhysAddrT(p.cpu.GetAc(0) & 0xffff)
+	if addr != 0 {
+		addr |= p.ringMask
+	}
+	getPerProcessData(p.PID).tasks[p.TID].killAddr = addr
+	return true
+}
 
 func scTask(p syscallParmsT) bool {
 	tpa := dg.PhysAddrT(p.cpu.GetAc(2))
-	if memory.ReadDWord(tpa+dlnk) == 0 {
-		log.Panicln("?TASK extended packets not yet implemented")
+	var tskData agTaskReqT
+	tskData.PID = p.PID
+	tskData.callerTID = p.TID
+	tskData.priority = p.mem.ReadWord(tpa + dpri)
+	if tskData.priority > 255 {
+		p.cpu.SetAc(0, erprp)
+		return false
 	}
+	tskData.TID = p.mem.ReadWord(tpa + did)
+	tskData.startAddr = dg.PhysAddrT(p.mem.ReadDWord(tpa + dpc))
+	tskData.initAC2 = p.mem.ReadDWord(tpa + dac2)
+	stackBase := dg.PhysAddrT(p.mem.ReadDWord(tpa + dstb))
+	stackSize := dg.PhysAddrT(p.mem.ReadDWord(tpa + dssz))
+	if stackBase == 0xffff_ffff {
+		stackBase, stackSize = 0, 0 // no stack
+	}
+	if sflt := p.mem.ReadWord(tpa + dsflt); sflt == 0xffff {
+		tskData.wsfh = dg.PhysAddrT(p.pageZeroWord(014)) // same as current
+	} else {
+		tskData.wsfh = dg.PhysAddrT(sflt)
+	}
+	num := int(p.mem.ReadWord(tpa + dnum))
+	logging.DebugPrint(logging.ScLog, "?TASK Pri: %d, ID: %d, PC: %#o, Stack Base: %#o, Size: %#o, Num: %d\n",
+		tskData.priority, tskData.TID, tskData.startAddr, stackBase, stackSize, num)
+
+	if p.mem.ReadDWord(tpa+dlnk) == 0 {
+		// extended packet
+		startHour := p.mem.ReadWord(tpa + dsh)
+		if startHour != 0xffff {
+			go delayedTasks(tskData, num, stackBase, stackSize, false,
+				int(startHour), int(p.mem.ReadWord(tpa+dsms)), int(p.mem.ReadWord(tpa+dcc)), int(p.mem.ReadWord(tpa+dci)))
+			return true
+		}
+	}
+
+	if errCode := createTasks(p.agentChan, tskData, num, stackBase, stackSize, false); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+func scTask16(p syscallParmsT) bool {
+	tpa := p.ringMask | dg.PhysAddrT(p.cpu.GetAc(2))
 	var tskData agTaskReqT
-	tskData.priority = memory.ReadWord(tpa + dpri)
-	tskData.TID = memory.ReadWord(tpa + did)
-	tskData.startAddr = dg.PhysAddrT(memory.ReadDWord(tpa + dpc))
-	tskData.initAC2 = memory.ReadDWord(tpa + dac2)
-	tskData.wsb = dg.PhysAddrT(memory.ReadDWord(tpa + dstb))
-	tskData.wsfh = (p.cpu.GetPC() & 0x7000_0000) | dg.PhysAddrT(memory.ReadWord(tpa+dsflt))
-	tskData.wsl = tskData.wsb + dg.PhysAddrT(memory.ReadDWord(tpa+dssz))
+	tskData.PID = p.PID
+	tskData.callerTID = p.TID
+	tskData.priority = p.mem.ReadWord(tpa + dpri16)
+	if tskData.priority > 255 {
+		p.cpu.SetAc(0, erprp)
+		return false
+	}
+	tskData.TID = p.mem.ReadWord(tpa + did16)
+	tskData.startAddr = p.ringMask | dg.PhysAddrT(p.mem.ReadWord(tpa+dpc16))
+	tskData.initAC2 = dg.DwordT(p.mem.ReadWord(tpa + dac216))
+	stackBase := dg.PhysAddrT(p.mem.ReadWord(tpa + dstb16))
+	stackSize := dg.PhysAddrT(p.mem.ReadWord(tpa + dssz16))
+	if stackBase == 0xffff {
+		stackBase, stackSize = 0, 0 // no stack
+	}
+	if sflt := p.mem.ReadWord(tpa + dsflt16); sflt == 0xffff {
+		tskData.nsfa = p.pageZeroWord(memory.NsfaLoc) // same as current
+	} else {
+		tskData.nsfa = sflt
+	}
+	tskData.wsfh = dg.PhysAddrT(p.pageZeroWord(014)) // leave untouched
+	num := int(p.mem.ReadWord(tpa + dnum16))
+	logging.DebugPrint(logging.ScLog, "?TASK (16-bit) Pri: %d, ID: %d, PC: %#o, Stack Base: %#o, Size: %#o, Num: %d\n",
+		tskData.priority, tskData.TID, tskData.startAddr, stackBase, stackSize, num)
+
+	if p.mem.ReadWord(tpa+dlnk16) == 0 {
+		// extended packet
+		startHour := p.mem.ReadWord(tpa + dsh16)
+		if startHour != 0xffff {
+			go delayedTasks(tskData, num, stackBase, stackSize, true,
+				int(startHour), int(p.mem.ReadWord(tpa+dsms16)), int(p.mem.ReadWord(tpa+dcc16)), int(p.mem.ReadWord(tpa+dci16)))
+			return true
+		}
+	}
 
+	if errCode := createTasks(p.agentChan, tskData, num, stackBase, stackSize, true); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	return true
 }
 
+// createTasks asks the pseudo-Agent to create num tasks from the given template,
+// each getting its own consecutive stack of stackSize words starting at stackBase
+func createTasks(agentChan chan AgentReqT, tmpl agTaskReqT, num int, stackBase, stackSize dg.PhysAddrT, sixteenBit bool) (errCode dg.WordT) {
+	if num == 0 {
+		num = 1
+	}
+	for t := 0; t < num; t++ {
+		req := tmpl
+		if num > 1 && req.TID != 0 {
+			req.TID += dg.WordT(t)
+		}
+		if stackBase != 0 {
+			base := stackBase + dg.PhysAddrT(t)*stackSize
+			if sixteenBit {
+				req.nsp = dg.WordT(base)
+				req.nsl = dg.WordT(base + stackSize)
+			} else {
+				req.wsb = base
+				req.wsp = base
+				req.wsl = base + stackSize
+			}
+		}
+		areq := AgentReqT{agentTask, req, nil}
+		agentChan <- areq
+		areq = <-agentChan
+		if errCode = areq.result.(agTaskRespT).errCode; errCode != 0 {
+			return errCode
+		}
+		logging.DebugPrint(logging.ScLog, "----- Created TID %d\n", areq.result.(agTaskRespT).TID)
+	}
+	return 0
+}
+
+// delayedTasks creates the task(s) of an extended ?TASK packet at the requested time,
+// repeating count times every incr seconds
+func delayedTasks(tmpl agTaskReqT, num int, stackBase, stackSize dg.PhysAddrT, sixteenBit bool, startHour, startSec, count, incr int) {
+	agentChan := newAgentGateway()
+	defer close(agentChan)
+	termChan := getPerProcessData(tmpl.PID).sched.termination()
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), startHour, 0, startSec, 0, now.Location())
+	if start.Before(now) {
+		start = start.Add(24 * time.Hour)
+	}
+	delay := time.Until(start)
+	if count == 0 {
+		count = 1
+	}
+	for c := 0; c < count; c++ {
+		select {
+		case <-termChan:
+			return
+		case <-time.After(delay):
+		}
+		if errCode := createTasks(agentChan, tmpl, num, stackBase, stackSize, sixteenBit); errCode != 0 {
+			logging.DebugPrint(logging.ScLog, "----- Delayed ?TASK creation failed with error code %#o\n", errCode)
+			return
+		}
+		delay = time.Duration(incr) * time.Second
+	}
+}
+
+// scUidstat returns the status of the task whose unique TID is in AC1 (-1 for the caller)
+// in the packet at AC2
 func scUidstat(p syscallParmsT) bool {
-	reqTID := p.cpu.GetAc(1)
-	if reqTID != 0xffff_ffff {
-		log.Panicln("?UIDSTAT request for another TID not yet implemented")
+	req := agUidstatReqT{PID: p.PID, TID: dg.WordT(p.cpu.GetAc(1))}
+	if p.cpu.GetAc(1) == 0xffff_ffff {
+		req.TID, req.standard = p.TID, true
+	}
+	areq := AgentReqT{agentUidstat, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agUidstatRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
 	}
 	retPacketAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	memory.WriteWord(retPacketAddr, dg.WordT(p.TID))
-	memory.WriteWord(retPacketAddr+1, 0)
-	memory.WriteWord(retPacketAddr+2, dg.WordT(p.TID))
-	memory.WriteWord(retPacketAddr+3, 0)
-	logging.DebugPrint(logging.ScLog, "-------- Returning UTID: %#o, STID: %#o\n", dg.WordT(p.TID), p.TID)
+	p.mem.WriteWord(retPacketAddr, resp.uniqueTID)
+	p.mem.WriteWord(retPacketAddr+1, resp.tsw)
+	p.mem.WriteWord(retPacketAddr+2, resp.standardTID)
+	p.mem.WriteWord(retPacketAddr+3, resp.priority)
+	logging.DebugPrint(logging.ScLog, "-------- Returning UTID: %#o, STID: %#o, TSW: %#o\n", resp.uniqueTID, resp.standardTID, resp.tsw)
+	return true
+}
+
+// scIdstat returns the status word of the task whose standard TID is in AC1 in AC0,
+// and its priority in AC2
+func scIdstat(p syscallParmsT) bool {
+	areq := AgentReqT{agentUidstat, agUidstatReqT{PID: p.PID, TID: dg.WordT(p.cpu.GetAc(1)), standard: true}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agUidstatRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	p.cpu.SetAc(0, dg.DwordT(resp.tsw))
+	p.cpu.SetAc(2, dg.DwordT(resp.priority))
 	return true
 }
 
 func scWdelay(p syscallParmsT) bool {
 	delayMs := int(p.cpu.GetAc(0))
-	time.Sleep(time.Millisecond * time.Duration(delayMs))
+	sched := getPerProcessData(p.PID).sched
+	unpendCh := sched.startPend(p.TID)
+	// wake early if the process is terminating or we are ?UNPENDed
+	select {
+	case <-sched.termination():
+	case <-unpendCh:
+	case <-time.After(time.Millisecond * time.Duration(delayMs)):
+	}
+	return true
+}
+
+// scKioff holds console interrupts until ?KION
+func scKioff(p syscallParmsT) bool {
+	areq := AgentReqT{agentKi, agKiReqT{PID: p.PID, on: false}, nil}
+	p.agentChan <- areq
+	<-p.agentChan
+	return true
+}
+
+// scKion lets console interrupts through again, delivering any held one
+func scKion(p syscallParmsT) bool {
+	areq := AgentReqT{agentKi, agKiReqT{PID: p.PID, on: true}, nil}
+	p.agentChan <- areq
+	<-p.agentChan
+	return true
+}
+
+// scIntwt waits for a console interrupt (CTRL-C CTRL-A)
+func scIntwt(p syscallParmsT) bool {
+	areq := AgentReqT{agentIntwt, agIntwtReqT{p.PID}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if waiter := areq.result.(agIntwtRespT).waiter; waiter != nil {
+		select {
+		case <-waiter:
+		case <-getPerProcessData(p.PID).sched.termination():
+		}
+	}
+	return true
+}
+
+// scDrsch disables rescheduling so that only the calling task runs
+func scDrsch(p syscallParmsT) bool {
+	getPerProcessData(p.PID).sched.disable(p.TID)
+	return true
+}
+
+// scDfrsch disables rescheduling and returns the previous state in AC0: 0 => was enabled, 1 => was disabled
+func scDfrsch(p syscallParmsT) bool {
+	if getPerProcessData(p.PID).sched.disable(p.TID) {
+		p.cpu.SetAc(0, 1)
+	} else {
+		p.cpu.SetAc(0, 0)
+	}
+	return true
+}
+
+func scErsch(p syscallParmsT) bool {
+	getPerProcessData(p.PID).sched.enable()
+	logging.DebugPrint(logging.ScLog, "----- Rescheduling enabled by TID %d\n", p.TID)
+	return true
+}
+
+// scPri changes the priority of the calling task to that in AC1
+func scPri(p syscallParmsT) bool {
+	pri := p.cpu.GetAc(1)
+	if pri > maxTaskPriority {
+		p.cpu.SetAc(0, erprp)
+		return false
+	}
+	getPerProcessData(p.PID).sched.setPriority(p.TID, dg.WordT(pri))
+	return true
+}
+
+// scIdpri changes the priority of the task whose ID is in AC1 to that in AC0
+func scIdpri(p syscallParmsT) bool {
+	pri := p.cpu.GetAc(0)
+	if pri > maxTaskPriority {
+		p.cpu.SetAc(0, erprp)
+		return false
+	}
+	if !getPerProcessData(p.PID).sched.setPriority(dg.WordT(p.cpu.GetAc(1)), dg.WordT(pri)) {
+		p.cpu.SetAc(0, ertid)
+		return false
+	}
+	return true
+}
+
+// scSus suspends the calling task until another readies it
+func scSus(p syscallParmsT) bool {
+	getPerProcessData(p.PID).sched.setSuspended(p.TID, true)
+	return true
+}
+
+// scIdsus suspends the task whose ID is in AC1
+func scIdsus(p syscallParmsT) bool {
+	if !getPerProcessData(p.PID).sched.setSuspended(dg.WordT(p.cpu.GetAc(1)), true) {
+		p.cpu.SetAc(0, ertid)
+		return false
+	}
+	return true
+}
+
+// scIdrdy readies the suspended task whose ID is in AC1
+func scIdrdy(p syscallParmsT) bool {
+	if !getPerProcessData(p.PID).sched.setSuspended(dg.WordT(p.cpu.GetAc(1)), false) {
+		p.cpu.SetAc(0, ertid)
+		return false
+	}
+	return true
+}
+
+// scIdkil kills the task whose ID is in AC1, its kill-processing routine is run if it has one
+func scIdkil(p syscallParmsT) bool {
+	if !getPerProcessData(p.PID).sched.kill(dg.WordT(p.cpu.GetAc(1))) {
+		p.cpu.SetAc(0, ertid)
+		return false
+	}
+	return true
+}
+
+// scUnpend wakes the task whose ID is in AC1 from a ?REC, ?XMTW or ?WDELAY
+func scUnpend(p syscallParmsT) bool {
+	if !getPerProcessData(p.PID).sched.unpend(dg.WordT(p.cpu.GetAc(1))) {
+		p.cpu.SetAc(0, ertid)
+		return false
+	}
 	return true
 }
+
+// getMailbox returns the ?XMT/?REC mailbox addressed by AC0
+func getMailbox(p syscallParmsT, sixteenBit bool) mailboxT {
+	if sixteenBit {
+		return mailboxT{p.mem, p.ringMask | dg.PhysAddrT(p.cpu.GetAc(0)&0xffff), false}
+	}
+	return mailboxT{p.mem, dg.PhysAddrT(p.cpu.GetAc(0)), true}
+}
+
+func xmt(p syscallParmsT, sixteenBit, wait bool) bool {
+	msg := p.cpu.GetAc(1)
+	if sixteenBit {
+		msg &= 0xffff
+	}
+	if errCode := getPerProcessData(p.PID).sched.xmt(p.TID, getMailbox(p, sixteenBit), msg, wait); errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	return true
+}
+
+func rec(p syscallParmsT, sixteenBit, wait bool) bool {
+	msg, errCode := getPerProcessData(p.PID).sched.rec(p.TID, getMailbox(p, sixteenBit), wait)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	p.cpu.SetAc(1, msg)
+	return true
+}
+
+func scXmt(p syscallParmsT) bool     { return xmt(p, false, false) }
+func scXmt16(p syscallParmsT) bool   { return xmt(p, true, false) }
+func scXmtw(p syscallParmsT) bool    { return xmt(p, false, true) }
+func scXmtw16(p syscallParmsT) bool  { return xmt(p, true, true) }
+func scRec(p syscallParmsT) bool     { return rec(p, false, true) }
+func scRec16(p syscallParmsT) bool   { return rec(p, true, true) }
+func scRecnw(p syscallParmsT) bool   { return rec(p, false, false) }
+func scRecnw16(p syscallParmsT) bool { return rec(p, true, false) }
@@ -25,6 +25,8 @@ package aosvs
 
 import (
 	"log"
+	"net"
+	"strings"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
@@ -32,9 +34,21 @@ import (
 )
 
 func scDadid(p syscallParmsT) bool {
-	// TODO this should really get passed off to a central 'process manager' (pseudo-EXEC/PMGR?)
-	// fake PIDs
-	switch p.cpu.GetAc(0) {
+	target := dg.WordT(p.cpu.GetAc(0))
+	if target == 0xffff {
+		target = p.PID
+	}
+	info, found := getProcInfo(p.agentChan, target, "")
+	if !found {
+		p.cpu.SetAc(0, erprh)
+		return false
+	}
+	if info.fatherPID != 0 {
+		p.cpu.SetAc(1, dg.DwordT(info.fatherPID))
+		return true
+	}
+	// the emulator created this process, so fake a plausible father
+	switch target {
 	case 1:
 		p.cpu.SetAc(1, 0)
 	case 2:
@@ -59,16 +73,31 @@ func scDadid(p syscallParmsT) bool {
 	return true
 }
 
+// scGunm returns the username and privileges of the caller, the process whose PID is in AC0,
+// or the process named by the byte pointer in AC1
 func scGunm(p syscallParmsT) bool {
-	// TODO this should really get passed off to a central 'process manager' (pseudo-EXEC/PMGR?)
-	if dg.WordT(p.cpu.GetAc(0)) == 0xffff {
-		p.cpu.SetAc(0, 1)      // Claim not to be in SU mode
-		p.cpu.SetAc(1, 0x001f) // Claim to have nearly all privileges
-		memory.WriteStringBA("XYZZY", p.cpu.GetAc(2))
-		logging.DebugPrint(logging.ScLog, "?GUNM returning 'XYYZY'\n")
+	target := dg.WordT(p.cpu.GetAc(0))
+	var name string
+	switch {
+	case target == 0xffff:
+		target = p.PID
+	case p.cpu.GetAc(1) != 0xffff_ffff:
+		target, name = 0, readString(p.mem, p.cpu.GetAc(1), p.ringMask)
+	}
+	info, found := getProcInfo(p.agentChan, target, name)
+	if !found {
+		p.cpu.SetAc(0, erprh)
+		return false
+	}
+	if info.superuser {
+		p.cpu.SetAc(0, 0)
 	} else {
-		log.Panic("ERROR: ?GUNM request type not yet implemented")
+		p.cpu.SetAc(0, 1) // not in Superuser mode
 	}
+	p.cpu.SetAc(1, dg.DwordT(info.privileges))
+	p.mem.WriteStringBA(info.username, p.cpu.GetAc(2))
+	p.mem.WriteByteBA(p.cpu.GetAc(2)+dg.DwordT(len(info.username)), 0)
+	logging.DebugPrint(logging.ScLog, "?GUNM returning '%s' for PID %d\n", info.username, target)
 	return true
 }
 
@@ -76,28 +105,256 @@ func scPname(p syscallParmsT) bool {
 	switch p.cpu.GetAc(1) {
 	case 0xffff_ffff: // get PID of caller
 		p.cpu.SetAc(1, dg.DwordT(p.PID))
-	case 0:
-		log.Panic("ERROR: ?PNAME for target proc PID not yet implemented")
-	default:
-		log.Panic("ERROR: ?PNAME for target proc name not yet implemented")
+	case 0: // get PID of named process
+		name := readString(p.mem, p.cpu.GetAc(0), p.ringMask)
+		info, found := getProcInfo(p.agentChan, 0, name)
+		if !found {
+			p.cpu.SetAc(0, erpnm)
+			return false
+		}
+		p.cpu.SetAc(1, dg.DwordT(info.PID))
+	default: // get name of process with PID in AC1
+		info, found := getProcInfo(p.agentChan, dg.WordT(p.cpu.GetAc(1)), "")
+		if !found {
+			p.cpu.SetAc(0, erprh)
+			return false
+		}
+		p.mem.WriteStringBA(info.name, p.cpu.GetAc(0))
+		p.mem.WriteByteBA(p.cpu.GetAc(0)+dg.DwordT(len(info.name)), 0)
+	}
+	return true
+}
+
+// scProc creates a son process in its own address space
+func scProc(p syscallParmsT) bool {
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	flags := p.mem.ReadWord(pktAddr + pflg)
+	father := getPerProcessData(p.PID)
+	progName := readString(p.mem, p.mem.ReadDWord(pktAddr+psnm), p.ringMask)
+	prPath, errCode := resolveProgram(p.agentChan, p.PID, progName)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	var name string
+	if bpName := p.mem.ReadDWord(pktAddr + pnm); bpName != 0xffff_ffff {
+		name = readString(p.mem, bpName, p.ringMask)
+		if len(name) > mxpn {
+			p.cpu.SetAc(0, erpnm)
+			return false
+		}
+	}
+	username := father.username
+	if bpUser := p.mem.ReadDWord(pktAddr + punm); bpUser != 0xffff_ffff && bpUser != 0 {
+		username = strings.ToUpper(readString(p.mem, bpUser, p.ringMask))
+	}
+	if username != father.username {
+		if father.privileges&pvui == 0 {
+			p.cpu.SetAc(0, erprv)
+			return false
+		}
+		if len(username) > mxun || !KnownUser(username) {
+			p.cpu.SetAc(0, erunm)
+			return false
+		}
+	}
+	// a son may only have privileges his father holds
+	privileges := father.privileges
+	if pprvWd := p.mem.ReadWord(pktAddr + pprv); pprvWd != 0xffff {
+		if pprvWd&^father.privileges != 0 {
+			p.cpu.SetAc(0, erprv)
+			return false
+		}
+		privileges = pprvWd
+	}
+	var initialDir string
+	if bpDir := p.mem.ReadDWord(pktAddr + pdir); bpDir != 0xffff_ffff {
+		initialDir = strings.ToUpper(readString(p.mem, bpDir, p.ringMask))
+	}
+	args := []string{progName}
+	ipcHdr := dg.PhysAddrT(p.mem.ReadDWord(pktAddr + pipc))
+	if ipcHdr != 0xffff_ffff {
+		ipcHdr |= p.ringMask
+		// the initial IPC message becomes the son's ?GTMES message
+		msgLen := int(p.mem.ReadWord(ipcHdr+ilth)) * 2
+		if msgLen > 0 {
+			msg := p.mem.ReadBytes(p.mem.ReadDWord(ipcHdr+iptr)<<1, p.ringMask, msgLen)
+			args = append(args, SplitCLIArgs(strings.TrimRight(string(msg), "\x00"))...)
+		}
+	}
+	logging.DebugPrint(logging.ScLog, "?PROC Program: %s, Flags: %#x, Name: '%s', User: %s\n", prPath, flags, name, username)
+
+	conn, _ := father.conn.(net.Conn)
+	spec := procSpecT{
+		args:        args,
+		vRoot:       father.virtualRoot,
+		workingDir:  initialDir,
+		prName:      prPath,
+		ring:        int(p.ringMask >> 28),
+		conn:        conn,
+		fatherPID:   p.PID,
+		fatherWaits: flags&(pfex|pfbk) != 0,
+		name:        name,
+		username:    username,
+		privileges:  privileges,
+	}
+	if flags&(pfex|pfbk) == 0 {
+		// non-blocking, we will get an obituary when the son terminates
+		sonPID, _, errCode := createProcess(spec)
+		if errCode != 0 {
+			p.cpu.SetAc(0, dg.DwordT(errCode))
+			return false
+		}
+		p.cpu.SetAc(1, dg.DwordT(sonPID))
+		return true
+	}
+
+	// a swapped father does nothing until the son terminates, otherwise only the calling task waits
+	var prev dg.WordT
+	if flags&pfex != 0 {
+		prev = father.sched.freeze(p.TID)
+	}
+	sonPID, son, errCode := createProcess(spec)
+	if son != nil {
+		<-son.doneChan
+	}
+	if flags&pfex != 0 {
+		father.sched.thaw(prev)
+	}
+	if son == nil {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+
+	if ipcHdr != 0xffff_ffff {
+		// return the son's termination message in the IPC buffer
+		bufAddr := dg.PhysAddrT(p.mem.ReadDWord(ipcHdr+iptr)) | p.ringMask
+		bufLen := int(p.mem.ReadWord(ipcHdr + ilth))
+		wds := son.termInfo.words()
+		if len(wds) > bufLen {
+			wds = wds[:bufLen]
+		}
+		for i, wd := range wds {
+			p.mem.WriteWord(bufAddr+dg.PhysAddrT(i), wd)
+		}
+		p.mem.WriteWord(ipcHdr+ilth, dg.WordT(len(wds)))
+	}
+	p.cpu.SetAc(1, dg.DwordT(sonPID))
+	return true
+}
+
+// scChain replaces the caller's program with another, the calling task never returns
+func scChain(p syscallParmsT) bool {
+	ppd := getPerProcessData(p.PID)
+	progName := readString(p.mem, p.cpu.GetAc(0), p.ringMask)
+	prPath, errCode := resolveProgram(p.agentChan, p.PID, progName)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	progWds, err := readProgram(prPath)
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		p.cpu.SetAc(0, erfde)
+		return false
+	}
+	args := []string{progName}
+	if bpMsg := p.cpu.GetAc(1); bpMsg != 0 && bpMsg != 0xffff_ffff {
+		args = append(args, SplitCLIArgs(readString(p.mem, bpMsg, p.ringMask))...)
+	}
+	var proc ProcessT
+	proc.PID = p.PID
+	proc.programFileName = prPath
+	proc.mem = memory.NewAddrSpace()
+	proc.loadUST(progWds)
+	proc.load(progWds, int(p.ringMask>>28))
+	logging.DebugPrint(logging.ScLog, "?CHAIN to %s, Args: %v\n", prPath, args)
+
+	areq := AgentReqT{agentChain, agChainReqT{
+		PID:             p.PID,
+		invocationArgs:  args,
+		programFileName: prPath,
+		sixteenBit:      proc.ust.prType&0x8000 != 0,
+		maxTasks:        int(proc.ust.taskCount),
+		mem:             proc.mem,
+	}, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agChainRespT).errCode; errCode != 0 {
+		proc.mem.Release()
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+
+	// every task, including this one, is now stopping - start the new program once they have
+	ppd.sched.waitIdle()
+	p.mem.Release()
+	ppd.sched.reset()
+	conn, _ := ppd.conn.(net.Conn)
+	if proc.startInitialTask(p.agentChan, progWds, conn) != 0 {
+		areq = AgentReqT{agentTerminate, agTerminateReqT{PID: p.PID, TID: p.TID}, nil}
+		p.agentChan <- areq
+		<-p.agentChan
+	}
+	return true
+}
+
+// scTerm terminates the process with the PID in AC0, or named by AC1 if AC0 is -1
+func scTerm(p syscallParmsT) bool {
+	req := agTermProcReqT{callerPID: p.PID, targetPID: dg.WordT(p.cpu.GetAc(0))}
+	if req.targetPID == 0xffff {
+		req.targetPID = 0
+		req.targetName = readString(p.mem, p.cpu.GetAc(1), p.ringMask)
+	}
+	logging.DebugPrint(logging.ScLog, "?TERM PID: %d, Name: '%s'\n", req.targetPID, req.targetName)
+	areq := AgentReqT{agentTermProc, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	if errCode := areq.result.(agTermProcRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
 	}
 	return true
 }
 
 func scSysprv(p syscallParmsT) bool {
-	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	funcCode := memory.ReadWord(pktAddr + sysprvPktFunc)
-	switch funcCode {
-	case sysprvGet:
-		memory.WriteWord(pktAddr+sysprvPktFlags, 0)
-	case sysprvEnter:
-		log.Panicln("ERROR: Enter func not yet implemented in ?SYSPRV")
-	case sysprvEnterExcl:
-		log.Panicln("ERROR: Enter Exclusive func not yet implemented in ?SYSPRV")
-	case sysprvLeave:
-		log.Println("WARNING: Leave func not yet implemented in ?SYSPRV - Ignoring")
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	funcCode := p.mem.ReadWord(pktAddr + sysprvPktFunc)
+	mode := p.mem.ReadWord(pktAddr + sysprvPktMode)
+	flags, errCode := sysprv(p.agentChan, p.PID, funcCode, mode)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	p.mem.WriteWord(pktAddr+sysprvPktFlags, flags)
+	logging.DebugPrint(logging.ScLog, "?SYSPRV function %d mode %d flags now %#x\n", funcCode, mode, flags)
+	return true
+}
+
+// scSuser turns Superuser mode off if AC0 is 0, on if it is 1, or just examines it if -1,
+// AC0 returns 1 if it is now on
+func scSuser(p syscallParmsT) bool {
+	var funcCode dg.WordT
+	switch p.cpu.GetAc(0) {
+	case 0:
+		funcCode = sysprvLeave
+	case 1:
+		funcCode = sysprvEnter
+	case 0xffff_ffff:
+		funcCode = sysprvGet
 	default:
-		log.Panicf("ERROR: ?SYSPRV called with unknown function code %d", funcCode)
+		p.cpu.SetAc(0, erpre)
+		return false
+	}
+	flags, errCode := sysprv(p.agentChan, p.PID, funcCode, sysprvSuser)
+	if errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
+	if flags&(0x8000>>sysprvFlagsCaller) != 0 {
+		p.cpu.SetAc(0, 1)
+	} else {
+		p.cpu.SetAc(0, 0)
 	}
 	return true
 }
@@ -24,34 +24,104 @@
 package aosvs
 
 import (
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/SMerrony/dgemug/dg"
 	"github.com/SMerrony/dgemug/logging"
-	"github.com/SMerrony/dgemug/memory"
+)
+
+// ?ERMSG AC1 fields, the left half holds the channel of an error message file
+const (
+	ermsgDefaultFile = 0xffff // channel -1 => use the system's messages
+	ermsgNoDefault   = 0x8000 // 1B16 => fail rather than return a default message for an unknown code
+	ermsgLenMask     = 0x7fff // byte length of the caller's buffer
 )
 
 func scErmsg(p syscallParmsT) bool {
-	// fake an error message for now
-	msg := "VSemuG Dummy Error Message"
-	bp := p.cpu.GetAc(2)
-	memory.WriteStringBA(msg, bp)
+	code := dg.WordT(p.cpu.GetAc(0))
+	ac1 := p.cpu.GetAc(1)
+	chanNo := dg.WordT(ac1 >> 16)
+	buffLen := int(ac1 & ermsgLenMask)
+	msgs := ermesTable
+	if chanNo != ermsgDefaultFile {
+		areq := AgentReqT{agentChannelInfo, agChannelInfoReqT{p.PID, int(chanNo)}, nil}
+		p.agentChan <- areq
+		areq = <-p.agentChan
+		resp := areq.result.(agChannelInfoRespT)
+		if resp.errCode != 0 {
+			p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+			return false
+		}
+		ermes, err := os.Open(resp.path)
+		if err != nil {
+			p.cpu.SetAc(0, erfde)
+			return false
+		}
+		msgs = readErmes(ermes)
+		ermes.Close()
+	}
+	msg, found := msgs[code]
+	if !found {
+		if ac1&ermsgNoDefault != 0 {
+			p.cpu.SetAc(0, erfde)
+			return false
+		}
+		msg = fmt.Sprintf("UNKNOWN ERROR CODE %o", code)
+	}
+	logging.DebugPrint(logging.ScLog, "\tError code %#o is <%s>\n", code, msg)
+	if len(msg) > buffLen {
+		p.cpu.SetAc(0, erirb)
+		return false
+	}
+	p.mem.WriteStringBA(msg, p.cpu.GetAc(2)|dg.DwordT(p.ringMask<<1))
 	p.cpu.SetAc(0, dg.DwordT(len(msg)))
 	return true
 }
 
+// xfqnm follows the PARU.32 ?EXEC packet words, giving a queue name for ?XFBAT and ?XFUSR
+const xfqnm = xfp4 + 1 // BYTE POINTER TO QUEUE NAME (0 => DEFAULT QUEUE)
+
 func scExec(p syscallParmsT) bool {
-	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	execFunc := memory.ReadWord(pktAddr)
-	switch execFunc {
-	case xfsts:
-		memory.WriteWord(pktAddr+xfp1, 9) // PID 9
-		bp := memory.ReadDWord(pktAddr + xfp2)
-		if bp != 0 {
-			memory.WriteStringBA("CON10", bp) // Claim to be @CON10
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	req := agExecReqT{PID: p.PID, function: p.mem.ReadWord(pktAddr)}
+	logging.DebugPrint(logging.ScLog, "\t?EXEC function %#o\n", req.function)
+	switch req.function {
+	case xfsub, xfoth, xfbat, xflpt, xfplt, xfptp, xfusr:
+		req.aosPath = strings.ToUpper(readString(p.mem, p.mem.ReadDWord(pktAddr+xfp2), p.ringMask))
+		if req.function == xfbat || req.function == xfusr {
+			if bp := p.mem.ReadDWord(pktAddr + xfqnm); bp != 0 {
+				req.queue = readString(p.mem, bp, p.ringMask)
+			}
 		}
-	default:
-		logging.DebugPrint(logging.ScLog, "WARNING: ?EXEC system call not yet implemented - fn code was: %#o\n", execFunc)
+	case xfqst:
+		req.queue = readString(p.mem, p.mem.ReadDWord(pktAddr+xfp2), p.ringMask)
+	case xfhol, xfunh, xfcan:
+		req.seqNo = p.mem.ReadWord(pktAddr + xfp1)
+	}
+	areq := AgentReqT{agentExec, req, nil}
+	p.agentChan <- areq
+	areq = <-p.agentChan
+	resp := areq.result.(agExecRespT)
+	if resp.errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(resp.errCode))
+		return false
+	}
+	switch req.function {
+	case xfsts, xfxts:
+		p.mem.WriteWord(pktAddr+xfp1, resp.execSonPID)
+		if req.function == xfxts {
+			p.mem.WriteWord(pktAddr+xfp4, resp.execSonPID&0x7fff)
+		}
+		if bp := p.mem.ReadDWord(pktAddr + xfp2); bp != 0 {
+			p.mem.WriteStringBA(resp.consoleName, bp|dg.DwordT(p.ringMask<<1))
+		}
+	case xfsub, xfoth, xfbat, xflpt, xfplt, xfptp, xfusr:
+		p.mem.WriteWord(pktAddr+xfp1, resp.seqNo)
+	case xfqst:
+		p.mem.WriteWord(pktAddr+xfp1, dg.WordT(resp.qType))
 	}
 	return true
 }
@@ -71,29 +141,43 @@ func scGhrz(p syscallParmsT) bool {
 
 func scGtmes(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	var gtMesReq = agGtMesReqT{p.PID, memory.ReadWord(pktAddr + greq), memory.ReadWord(pktAddr + gnum), memory.ReadDWord(pktAddr + gsw)}
+	var gtMesReq = agGtMesReqT{PID: p.PID, greq: p.mem.ReadWord(pktAddr + greq), gnum: p.mem.ReadWord(pktAddr + gnum)}
+	if gtMesReq.greq&^gdlc == gtsw {
+		gtMesReq.swName = readString(p.mem, p.mem.ReadDWord(pktAddr+gsw), p.ringMask)
+	}
 	var areq = AgentReqT{agentGetMessage, gtMesReq, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
+	if errCode := areq.result.(agGtMesRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	p.cpu.SetAc(0, areq.result.(agGtMesRespT).ac0)
 	p.cpu.SetAc(1, areq.result.(agGtMesRespT).ac1)
-	gresBA := memory.ReadDWord(pktAddr+gres) | dg.DwordT((p.ringMask)<<1)
+	gresBA := p.mem.ReadDWord(pktAddr+gres) | dg.DwordT((p.ringMask)<<1)
 	if gresBA != 0xffff_ffff && len(areq.result.(agGtMesRespT).result) > 0 {
-		memory.WriteStringBA(areq.result.(agGtMesRespT).result, gresBA|dg.DwordT((p.ringMask)<<1))
+		p.mem.WriteStringBA(areq.result.(agGtMesRespT).result, gresBA|dg.DwordT((p.ringMask)<<1))
 	}
 	return true
 }
 func scGtmes16(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
-	var gtMesReq = agGtMesReqT{p.PID, memory.ReadWord(pktAddr + greq16), memory.ReadWord(pktAddr + gnum16), dg.DwordT(memory.ReadWord(pktAddr + gsw16))}
+	var gtMesReq = agGtMesReqT{PID: p.PID, greq: p.mem.ReadWord(pktAddr + greq16), gnum: p.mem.ReadWord(pktAddr + gnum16)}
+	if gtMesReq.greq&^gdlc == gtsw {
+		gtMesReq.swName = readString(p.mem, dg.DwordT(p.mem.ReadWord(pktAddr+gsw16)), p.ringMask)
+	}
 	var areq = AgentReqT{agentGetMessage, gtMesReq, nil}
 	p.agentChan <- areq
 	areq = <-p.agentChan
+	if errCode := areq.result.(agGtMesRespT).errCode; errCode != 0 {
+		p.cpu.SetAc(0, dg.DwordT(errCode))
+		return false
+	}
 	p.cpu.SetAc(0, areq.result.(agGtMesRespT).ac0)
 	p.cpu.SetAc(1, areq.result.(agGtMesRespT).ac1)
-	gresBA := dg.DwordT(memory.ReadWord(pktAddr + gres16))
+	gresBA := dg.DwordT(p.mem.ReadWord(pktAddr + gres16))
 	if gresBA != 0xffff && len(areq.result.(agGtMesRespT).result) > 0 {
-		memory.WriteStringBA(areq.result.(agGtMesRespT).result, gresBA|dg.DwordT((p.ringMask)<<1))
+		p.mem.WriteStringBA(areq.result.(agGtMesRespT).result, gresBA|dg.DwordT((p.ringMask)<<1))
 	}
 	return true
 }
@@ -109,21 +193,43 @@ func scGtod(p syscallParmsT) bool {
 
 func scInfo(p syscallParmsT) bool {
 	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2))
-	memory.WriteWord(pktAddr+sirn, 0x0746) // system rev - faked to 7.70
-	if memory.ReadDWord(pktAddr+siln) != 0 {
-		memory.WriteStringBA("MASTERLDU", memory.ReadDWord(pktAddr+siln)) // fake master LDU name
+	p.mem.WriteWord(pktAddr+sirn, 0x0746) // system rev - faked to 7.70
+	if p.mem.ReadDWord(pktAddr+siln) != 0 {
+		p.mem.WriteStringBA("MASTERLDU", p.mem.ReadDWord(pktAddr+siln)) // fake master LDU name
 	}
-	if memory.ReadDWord(pktAddr+siid) != 0 {
-		memory.WriteStringBA("VSEMUG", memory.ReadDWord(pktAddr+siid)) // fake System ID
+	if p.mem.ReadDWord(pktAddr+siid) != 0 {
+		p.mem.WriteStringBA("VSEMUG", p.mem.ReadDWord(pktAddr+siid)) // fake System ID
 	}
-	if memory.ReadDWord(pktAddr+sios) != 0 {
-		memory.WriteStringBA(":VSEMUG", memory.ReadDWord(pktAddr+siid)) // fake OS pathname
+	if p.mem.ReadDWord(pktAddr+sios) != 0 {
+		p.mem.WriteStringBA(":VSEMUG", p.mem.ReadDWord(pktAddr+siid)) // fake OS pathname
 	}
-	memory.WriteWord(pktAddr+ssin, savs) // claim to be AOS/VS!
+	p.mem.WriteWord(pktAddr+ssin, savs) // claim to be AOS/VS!
 	return true
 }
 
 func scXpstat(p syscallParmsT) bool {
-
+	target := dg.WordT(p.cpu.GetAc(0))
+	if target == 0xffff {
+		target = p.PID
+	}
+	info, found := getProcInfo(p.agentChan, target, "")
+	if !found {
+		p.cpu.SetAc(0, erprh)
+		return false
+	}
+	pktAddr := dg.PhysAddrT(p.cpu.GetAc(2)) | p.ringMask
+	p.mem.WriteWord(pktAddr+xpfp, info.fatherPID)
+	p.mem.WriteWord(pktAddr+xppd, info.PID)
+	p.mem.WriteDWord(pktAddr+xprh, dg.DwordT(time.Since(info.created).Seconds()))
+	p.mem.WriteWord(pktAddr+xppv, info.privileges)
+	if bpUser := p.mem.ReadDWord(pktAddr + xpun); bpUser != 0 {
+		unLen := len(info.username)
+		if bufLen := int(p.mem.ReadWord(pktAddr + xpnbs)); unLen > bufLen {
+			unLen = bufLen
+		}
+		p.mem.WriteStringBA(info.username[:unLen], bpUser)
+		p.mem.WriteWord(pktAddr+xpnrs, dg.WordT(unLen))
+	}
+	logging.DebugPrint(logging.ScLog, "?XPSTAT for PID %d, Father: %d\n", info.PID, info.fatherPID)
 	return true
 }
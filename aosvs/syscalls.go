@@ -25,6 +25,7 @@ package aosvs
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 
 	"github.com/SMerrony/dgemug/logging"
@@ -36,9 +37,22 @@ import (
 
 type syscallParmsT struct {
 	cpu       *mvcpu.CPUT
+	mem       *memory.AddrSpaceT // the calling process's address space
 	PID, TID  dg.WordT
 	ringMask  dg.PhysAddrT
 	agentChan chan AgentReqT
+	pageZero  *[5]dg.WordT // the caller's page zero context, another task may be running
+}
+
+// pageZeroWord returns a word of the caller's page zero, taking the task-specific
+// locations from its saved context
+func (p syscallParmsT) pageZeroWord(loc dg.PhysAddrT) dg.WordT {
+	for i, ctxLoc := range pageZeroCtxLocs {
+		if ctxLoc == loc && p.pageZero != nil {
+			return p.pageZero[i]
+		}
+	}
+	return p.mem.ReadWord(p.ringMask | loc)
 }
 
 type syscallDescT struct {
@@ -69,40 +83,56 @@ const (
 )
 
 const scReturn = 0310 // We need special access to this call number - it is handled differently
+const scKill = 0501   // ditto - ?KILL of the calling task
 
 var syscalls = map[dg.WordT]syscallDescT{
 	0:    {"?CREATE", "?CREA", scFileManage, scCreate, nil},
-	1:    {"?DELETE", "?DELE", scFileManage, nil, nil},
-	3:    {"?MEM", "?MEM", scMemory, scMem, scMem},
-	014:  {"?MEMI", "?MEMI", scMemory, scMemi, scMemi},
+	1:    {"?DELETE", "?DELE", scFileManage, scDelete, nil},
+	2:    {"?RENAME", "?RENA", scFileManage, scRename, nil},
+	3:    {"?MEM", "?MEM", scMemory, scMem, scMem16},
+	014:  {"?MEMI", "?MEMI", scMemory, scMemi, scMemi16},
 	025:  {"?ISEND", "?ISEN", scIPC, scIsend, nil},
 	026:  {"?IREC", "?IREC", scIPC, scIrec, nil},
 	027:  {"?ILKUP", "?ILKU", scIPC, scIlkup, nil},
+	030:  {"?PROC", "?PROC", scProcess, scProc, nil},
+	031:  {"?TERM", "?TERM", scProcess, scTerm, nil},
 	036:  {"?GTOD", "?GTOD", scSystem, scGtod, scGtod},
 	041:  {"?GDAY", "?GDAY", scSystem, scGday, scGday},
 	044:  {"?SSHPT", "?SSHP", scMemory, scSshpt, nil},
 	056:  {"?GOPEN", "?GOPE", scFileIO, scGopen, nil},
-	057:  {"?GCLOSE", "?GCLO", scFileIO, nil, nil},
+	057:  {"?GCLOSE", "?GCLO", scFileIO, scGclose, nil},
 	060:  {"?SPAGE", "?SPAG", scMemory, scSpage, nil},
+	061:  {"?RPAGE", "?RPAG", scMemory, scRpage, nil},
+	062:  {"?FLUSH", "?FLUS", scMemory, scFlush, nil},
 	063:  {"?SOPEN", "?SOPE", scMemory, scSopen, nil},
+	064:  {"?SCLOSE", "?SCLO", scMemory, scSclose, nil},
 	070:  {"?PRIPR", "?PRIP", scProcess, scDummy, scDummy},
 	072:  {"?GUNM", "?GUNM", scProcess, scGunm, nil},
-	073:  {"?GSHPT", "?GSHP", scMemory, scGshpt, scGshpt},
+	073:  {"?GSHPT", "?GSHP", scMemory, scGshpt, scGshpt16},
 	074:  {"?GHRZ", "?GHRZ", scSystem, scGhrz, scGhrz},
-	077:  {"?FSTAT", "?FSTA", scFileManage, nil, nil},
-	0102: {"?GLIST", "?GLIS", scFileManage, nil, nil},
+	077:  {"?FSTAT", "?FSTA", scFileManage, scFstat, nil},
+	0100: {"?DIR", "?DIR", scFileManage, scDir, nil},
+	0101: {"?SLIST", "?SLIS", scFileManage, scSlist, nil},
+	0102: {"?GLIST", "?GLIS", scFileManage, scGlist, nil},
+	0103: {"?GNFN", "?GNFN", scFileManage, scGnfn, nil},
 	0111: {"?GNAME", "?GNAM", scFileManage, scGname, scGname},
-	0113: {"?SUSER", "?SUSE", scProcess, scDummy, nil},
+	0113: {"?SUSER", "?SUSE", scProcess, scSuser, nil},
 	0116: {"?PNAME", "?PNAM", scProcess, scPname, nil},
+	0122: {"?CHAIN", "?CHAI", scProcess, scChain, nil},
 	0127: {"?DADID", "?DADI", scProcess, scDadid, scDadid},
-	0142: {"?IS.R", "?IS.R", scIPC, nil, nil},
+	0142: {"?IS.R", "?IS.R", scIPC, scIsr, nil},
 	0157: {"?SINFO", "?SINF", scSystem, scInfo, nil},
-	0163: {"?CGNAM", "?CGNA", scFileManage, nil, nil},
+	0163: {"?CGNAM", "?CGNA", scFileManage, scCgnam, nil},
+	0164: {"?SACL", "?SACL", scFileManage, scSacl, nil},
+	0165: {"?GACL", "?GACL", scFileManage, scGacl, nil},
 	0166: {"?DACL", "?DACL", scFileManage, scDacl, nil},
 	0167: {"?CON", "?CON", scConnection, scCon, nil},
-	0170: {"?DCON", "?DCON", scConnection, scDummy, nil},
-	0171: {"?SERVE", "?SERV", scConnection, scDummy, nil},
-	0172: {"?RESIGN", "?RESI", scConnection, nil, nil},
+	0170: {"?DCON", "?DCON", scConnection, scDcon, nil},
+	0171: {"?SERVE", "?SERV", scConnection, scServe, nil},
+	0172: {"?RESIGN", "?RESI", scConnection, scResign, nil},
+	0173: {"?DRCON", "?DRCO", scConnection, scDrcon, nil},
+	0174: {"?CTOD", "?CTOD", scConnection, scCtod, nil},
+	0175: {"?PCNX", "?PCNX", scConnection, scPcnx, nil},
 	0263: {"?WDELAY", "?WDEL", scMultitasking, scWdelay, nil},
 	0265: {"?LEFE", "?LEFE", scUserDev, scLefe, scLefe},
 	0300: {"?OPEN", "?OPEN", scFileIO, scOpen, scOpen16},
@@ -111,107 +141,138 @@ var syscalls = map[dg.WordT]syscallDescT{
 	0303: {"?WRITE", "?WRIT", scFileIO, scWrite, scWrite16},
 	0311: {"?ERMSG", "?ERMS", scSystem, scErmsg, nil},
 	0312: {"?GCHR", "?GCHR", scFileIO, scGchr, scGchr},
-	0313: {"?SCHR", "?SCHR", scFileIO, scDummy, nil},
+	0313: {"?SCHR", "?SCHR", scFileIO, scSchr, nil},
 	0316: {"?SEND", "?SEND", scFileIO, scSend, nil},
 	0330: {"?EXEC", "?EXEC", scSystem, scExec, nil},
 	0307: {"?GTMES", "?GTME", scSystem, scGtmes, scGtmes16},
 	0333: {"?UIDSTAT", "?UIDS", scMultitasking, scUidstat, nil},
 	0336: {"?RECREATE", "?RECR", scFileManage, scRecreate, scRecreate},
 	0415: {"?GECHR", "?GECH", scFileIO, scGechr, nil},
-	0500: {"?TASK", "?TASK", scMultitasking, nil, nil},
-	0503: {"?PRI", "?PRI", scMultitasking, scDummy, scDummy},
-	0505: {"?KILAD", "?KILA", scMultitasking, scDummy, nil},
-	0527: {"?DRSCH", "?DRSC", scMultitasking, scDummy, scDummy}, // Suspend all other tasks
-	0542: {"?IFPU", "?IFPU", scMultitasking, scIfpu, scIfpu},
-	0550: {"?DFRSCH", "?DFRS", scMultitasking, scDummy, nil},
+	0416: {"?SECHR", "?SECH", scFileIO, scSechr, nil},
+	0500: {"?TASK", "?TASK", scMultitasking, scTask, scTask16},
+	0502: {"?SUS", "?SUS", scMultitasking, scSus, scSus},
+	0503: {"?PRI", "?PRI", scMultitasking, scPri, scPri},
+	0504: {"?REC", "?REC", scMultitasking, scRec, scRec16},
+	0505: {"?KILAD", "?KILA", scMultitasking, scKilad, scKilad16},
+	0506: {"?RECNW", "?RECN", scMultitasking, scRecnw, scRecnw16},
+	0507: {"?XMT", "?XMT", scMultitasking, scXmt, scXmt16},
+	0510: {"?XMTW", "?XMTW", scMultitasking, scXmtw, scXmtw16},
+	0511: {"?IDKIL", "?IDKI", scMultitasking, scIdkil, scIdkil},
+	0512: {"?IDPRI", "?IDPR", scMultitasking, scIdpri, scIdpri},
+	0513: {"?IDRDY", "?IDRD", scMultitasking, scIdrdy, scIdrdy},
+	0514: {"?IDSUS", "?IDSU", scMultitasking, scIdsus, scIdsus},
+	0515: {"?IDSTAT", "?IDST", scMultitasking, scIdstat, scIdstat},
+	0516: {"?UNPEND", "?UNPE", scMultitasking, scUnpend, scUnpend},
+	0527: {"?DRSCH", "?DRSC", scMultitasking, scDrsch, scDrsch}, // Suspend all other tasks
+	0530: {"?ERSCH", "?ERSC", scMultitasking, scErsch, scErsch}, // Resume other tasks
+	0534: {"?KIOFF", "?KIOF", scMultitasking, scKioff, scKioff},
+	0535: {"?KION", "?KION", scMultitasking, scKion, scKion},
+	0536: {"?INTWT", "?INTW", scMultitasking, scIntwt, scIntwt},
+	0542: {"?IFPU", "?IFPU", scMultitasking, scIfpu, scIfpu16},
+	0550: {"?DFRSCH", "?DFRS", scMultitasking, scDfrsch, scDfrsch},
 	0573: {"?SYSPRV", "?SYSP", scProcess, scSysprv, nil},
 	0576: {"?XPSTAT", "?XPST", scProcess, scXpstat, nil},
 }
 
 // syscall redirects System Call according to the syscalls map
-func syscall(callID dg.WordT, PID, TID dg.WordT, ringMask dg.PhysAddrT, agent chan AgentReqT, cpu *mvcpu.CPUT) (ok bool) {
+func syscall(callID dg.WordT, PID, TID dg.WordT, ringMask dg.PhysAddrT, agent chan AgentReqT, cpu *mvcpu.CPUT, pageZero *[5]dg.WordT) (ok bool) {
+	defer addressFault(cpu, &ok)
 	call, defined := syscalls[callID]
 	if !defined {
-		log.Panicf("ERROR: System call No. %#o not yet defined at PC=%#x", callID, cpu.GetPC())
+		panic(fmt.Sprintf("system call No. %#o not yet defined at PC=%#x", callID, cpu.GetPC()))
 	}
 	if call.fn == nil {
-		log.Panicf("ERROR: System call No. %s not yet implemented at PC=%#x", call.name, cpu.GetPC())
+		panic(fmt.Sprintf("system call %s not yet implemented at PC=%#x", call.name, cpu.GetPC()))
 	}
 	if cpu.GetDebugLogging() {
 		//log.Printf("%s System Call...\n", call.name)
 		logging.DebugPrint(logging.DebugLog, "%s System Call...\n", call.name)
 		logging.DebugPrint(logging.ScLog, "%s System Call...\n", call.name)
 	}
-	return call.fn(syscallParmsT{cpu, PID, TID, ringMask, agent})
+	return call.fn(syscallParmsT{cpu, cpu.GetAddrSpace(), PID, TID, ringMask, agent, pageZero})
 }
 
 // syscall16 redirects a 16-bit System Call according to the syscalls map
-func syscall16(callID dg.WordT, PID, TID dg.WordT, ringMask dg.PhysAddrT, agent chan AgentReqT, cpu *mvcpu.CPUT) (ok bool) {
+func syscall16(callID dg.WordT, PID, TID dg.WordT, ringMask dg.PhysAddrT, agent chan AgentReqT, cpu *mvcpu.CPUT, pageZero *[5]dg.WordT) (ok bool) {
+	defer addressFault(cpu, &ok)
 	call, defined := syscalls[callID]
 	if !defined {
-		log.Panicf("ERROR: System call No. %#o not yet defined at PC=%#x", callID, cpu.GetPC())
+		panic(fmt.Sprintf("system call No. %#o not yet defined at PC=%#x", callID, cpu.GetPC()))
 	}
 	if call.fn16 == nil {
-		log.Panicf("ERROR: 16-bit System call No. %s not yet implemented at PC=%#x", call.name, cpu.GetPC())
+		panic(fmt.Sprintf("16-bit system call %s not yet implemented at PC=%#x", call.name, cpu.GetPC()))
 	}
 	if cpu.GetDebugLogging() {
 		//log.Printf("%s System Call (16-bit)...\n", call.name)
 		logging.DebugPrint(logging.DebugLog, "%s System Call (16-bit)...\n", call.name)
 		logging.DebugPrint(logging.ScLog, "%s System Call (16-bit)...\n", call.name)
 	}
-	return call.fn16(syscallParmsT{cpu, PID, TID, ringMask, agent})
+	return call.fn16(syscallParmsT{cpu, cpu.GetAddrSpace(), PID, TID, ringMask, agent, pageZero})
+}
+
+// addressFault turns an access to unmapped memory by a system call into an ERVWP error return
+func addressFault(cpu *mvcpu.CPUT, ok *bool) {
+	if r := recover(); r != nil {
+		pf, isFault := r.(memory.ProtectionFaultT)
+		if !isFault {
+			panic(r)
+		}
+		log.Printf("WARNING: System call at PC=%#x attempted %s\n", cpu.GetPC(), pf.Error())
+		cpu.SetAc(0, ervwp)
+		*ok = false
+	}
 }
 
 // readPacket just loads a chunk of memory into a slice of words
 // TODO maybe this should be in ram_virtual.go as 'ReadWords' for efficiency?
-func readPacket(addr dg.PhysAddrT, pktLen int) (pkt []dg.WordT) {
+func readPacket(mem *memory.AddrSpaceT, addr dg.PhysAddrT, pktLen int) (pkt []dg.WordT) {
 	pkt = make([]dg.WordT, pktLen, pktLen)
 	for w := range pkt {
-		pkt[w] = memory.ReadWord(addr + dg.PhysAddrT(w))
+		pkt[w] = mem.ReadWord(addr + dg.PhysAddrT(w))
 	}
 	return pkt
 }
 
 // readBytes reads characters from memory up to the first NUL from the given doubleword byte address
-func readBytes(bpAddr dg.DwordT, pc dg.PhysAddrT) []byte {
+func readBytes(mem *memory.AddrSpaceT, bpAddr dg.DwordT, pc dg.PhysAddrT) []byte {
 	buff := bytes.NewBufferString("")
 	lobyte := (bpAddr & 0x0001) == 1
 	wdAddr := dg.PhysAddrT(bpAddr>>1) | (pc & 0x7000_0000)
-	c := memory.ReadByte(wdAddr, lobyte)
+	c := mem.ReadByteWA(wdAddr, lobyte)
 	for c != 0 {
 		buff.WriteByte(byte(c))
 		if lobyte {
 			wdAddr++
 		}
 		lobyte = !lobyte
-		c = memory.ReadByte(wdAddr, lobyte)
+		c = mem.ReadByteWA(wdAddr, lobyte)
 	}
 	return buff.Bytes()
 }
 
 // readString reads characters from memory up to the first NUL from the given doubleword byte address
-func readString(bpAddr dg.DwordT, pc dg.PhysAddrT) string {
+func readString(mem *memory.AddrSpaceT, bpAddr dg.DwordT, pc dg.PhysAddrT) string {
 	buff := bytes.NewBufferString("")
 	lobyte := (bpAddr & 0x0001) == 1
 	wdAddr := dg.PhysAddrT(bpAddr>>1) | (pc & 0x7000_0000)
-	c := memory.ReadByte(wdAddr, lobyte)
+	c := mem.ReadByteWA(wdAddr, lobyte)
 	for c != 0 {
 		buff.WriteByte(byte(c))
 		if lobyte {
 			wdAddr++
 		}
 		lobyte = !lobyte
-		c = memory.ReadByte(wdAddr, lobyte)
+		c = mem.ReadByteWA(wdAddr, lobyte)
 	}
 	return buff.String()
 }
 
 // writeBytes writes the whole byte array into memory at the given doubleword byte address
-func writeBytes(bpAddr dg.DwordT, pc dg.PhysAddrT, arr []byte) {
+func writeBytes(mem *memory.AddrSpaceT, bpAddr dg.DwordT, pc dg.PhysAddrT, arr []byte) {
 	lobyte := (bpAddr & 0x0001) == 1
 	wdAddr := dg.PhysAddrT(bpAddr>>1) | (pc & 0x7000_0000)
 	for c := 0; c < len(arr); c++ {
-		memory.WriteByte(wdAddr, lobyte, dg.ByteT(arr[c]))
+		mem.WriteByteWA(wdAddr, lobyte, dg.ByteT(arr[c]))
 		if lobyte {
 			wdAddr++
 		}
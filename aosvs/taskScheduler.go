@@ -0,0 +1,509 @@
+// +build virtual !physical
+
+// taskScheduler.go - per-process scheduling of AOS/VS tasks
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// As on a real uniprocessor AOS/VS system only one task of a process executes
+// instructions at any time.  A task must hold the process's 'CPU' to run, it gives it
+// up while it is in a system call (so that pended calls do not hold up other tasks),
+// and is preempted at the next instruction if a higher priority task becomes ready.
+// Tasks of equal priority take turns at each system call.
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/logging"
+	"github.com/SMerrony/dgemug/memory"
+	"github.com/SMerrony/dgemug/mvcpu"
+)
+
+const maxTaskPriority = 255 // lowest priority
+
+type schedTaskT struct {
+	priority  dg.WordT
+	cpu       *mvcpu.CPUT
+	suspended bool
+	waiting   bool   // wants the CPU
+	waitSeq   uint64 // FIFO ordering of waiting tasks
+	unpended  bool   // set by ?UNPEND, cleared when a pend starts
+	unpendCh  chan struct{}
+	xmtRec    bool // pended in ?XMTW or ?REC
+	killed    bool // by ?IDKIL, not yet noticed by the task
+}
+
+type schedulerT struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	tasks       map[dg.WordT]*schedTaskT
+	running     dg.WordT // TID holding the CPU, zero if none
+	drschTID    dg.WordT // TID which disabled rescheduling, zero if enabled
+	nextSeq     uint64
+	terminating bool
+	termChan    chan struct{} // closed when the process is terminating
+	paused      bool          // by the emulator's control channel
+	retired     uint64        // instructions executed by tasks which have gone
+}
+
+// mailboxT is an intertask message location for ?XMT/?REC, one word for 16-bit tasks
+type mailboxT struct {
+	mem  *memory.AddrSpaceT
+	addr dg.PhysAddrT
+	wide bool
+}
+
+func (mb mailboxT) read() dg.DwordT {
+	if mb.wide {
+		return mb.mem.ReadDWord(mb.addr)
+	}
+	return dg.DwordT(mb.mem.ReadWord(mb.addr))
+}
+
+func (mb mailboxT) write(msg dg.DwordT) {
+	if mb.wide {
+		mb.mem.WriteDWord(mb.addr, msg)
+	} else {
+		mb.mem.WriteWord(mb.addr, dg.WordT(msg))
+	}
+}
+
+func newScheduler() *schedulerT {
+	s := &schedulerT{tasks: map[dg.WordT]*schedTaskT{}, termChan: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// termination returns a channel which is closed when the process terminates
+func (s *schedulerT) termination() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.termChan
+}
+
+func (s *schedulerT) addTask(TID, priority dg.WordT, cpu *mvcpu.CPUT) {
+	s.mu.Lock()
+	s.tasks[TID] = &schedTaskT{priority: priority, cpu: cpu, unpendCh: make(chan struct{}, 1)}
+	s.mu.Unlock()
+}
+
+// removeTask forgets a task which has ended, unless its TID has already been reused
+func (s *schedulerT) removeTask(TID dg.WordT, cpu *mvcpu.CPUT) {
+	s.mu.Lock()
+	if st := s.tasks[TID]; st == nil || st.cpu != cpu {
+		s.mu.Unlock()
+		return
+	}
+	s.retired += cpu.GetInstrCount()
+	delete(s.tasks, TID)
+	if s.running == TID {
+		s.running = 0
+	}
+	if s.drschTID == TID {
+		s.drschTID = 0 // rescheduling cannot stay disabled by a dead task
+	}
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// terminate releases every task waiting on the scheduler, none will get the CPU again
+func (s *schedulerT) terminate() {
+	s.mu.Lock()
+	if !s.terminating {
+		s.terminating = true
+		close(s.termChan)
+		for _, st := range s.tasks {
+			st.cpu.SetSCPIO(true) // stops Vrun at the next instruction
+		}
+	}
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// waitIdle blocks until no task holds the CPU
+func (s *schedulerT) waitIdle() {
+	s.mu.Lock()
+	for s.running != 0 {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+}
+
+// reset forgets all tasks and makes the scheduler usable again after terminate, for ?CHAIN
+func (s *schedulerT) reset() {
+	s.mu.Lock()
+	for _, st := range s.tasks {
+		s.retired += st.cpu.GetInstrCount()
+	}
+	s.tasks = map[dg.WordT]*schedTaskT{}
+	s.running, s.drschTID = 0, 0
+	s.terminating = false
+	s.termChan = make(chan struct{})
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// eligible reports whether a task could be given the CPU, s.mu must be held
+func (s *schedulerT) eligible(TID dg.WordT) bool {
+	st := s.tasks[TID]
+	return st != nil && !st.suspended && !s.paused && (s.drschTID == 0 || s.drschTID == TID)
+}
+
+// best returns the TID of the waiting task which should get the CPU next, s.mu must be held
+func (s *schedulerT) best() (TID dg.WordT) {
+	var bestTask *schedTaskT
+	for t, st := range s.tasks {
+		if !st.waiting || !s.eligible(t) {
+			continue
+		}
+		if bestTask == nil || st.priority < bestTask.priority ||
+			(st.priority == bestTask.priority && st.waitSeq < bestTask.waitSeq) {
+			bestTask = st
+			TID = t
+		}
+	}
+	return TID
+}
+
+// preemptIfNeeded stops the running task if it should no longer have the CPU, s.mu must be held
+func (s *schedulerT) preemptIfNeeded() {
+	if s.running == 0 {
+		return
+	}
+	rt := s.tasks[s.running]
+	if rt == nil {
+		return
+	}
+	if !s.eligible(s.running) {
+		rt.cpu.SetSCPIO(true)
+		return
+	}
+	if b := s.best(); b != 0 && s.tasks[b].priority < rt.priority {
+		rt.cpu.SetSCPIO(true)
+	}
+}
+
+// gone reports whether a task should stop waiting because the process is terminating
+// or chaining, s.mu must be held
+func (s *schedulerT) gone(TID dg.WordT, st *schedTaskT) bool {
+	return s.terminating || s.tasks[TID] != st
+}
+
+// acquire blocks until the task may run, a false return means the task must end
+func (s *schedulerT) acquire(TID dg.WordT, cpu *mvcpu.CPUT) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil || st.cpu != cpu {
+		return false
+	}
+	st.waiting = true
+	st.waitSeq = s.nextSeq
+	s.nextSeq++
+	s.preemptIfNeeded()
+	for !s.gone(TID, st) && (s.running != 0 || s.best() != TID) {
+		s.cond.Wait()
+	}
+	st.waiting = false
+	if s.gone(TID, st) {
+		return false
+	}
+	s.running = TID
+	return true
+}
+
+// release gives up the CPU
+func (s *schedulerT) release(TID dg.WordT, cpu *mvcpu.CPUT) {
+	s.mu.Lock()
+	if st := s.tasks[TID]; s.running == TID && st != nil && st.cpu == cpu {
+		s.running = 0
+	}
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// disable turns off rescheduling on behalf of TID, returning the previous state
+func (s *schedulerT) disable(TID dg.WordT) (wasDisabled bool) {
+	s.mu.Lock()
+	wasDisabled = s.drschTID != 0
+	s.drschTID = TID
+	s.preemptIfNeeded()
+	s.mu.Unlock()
+	logging.DebugPrint(logging.ScLog, "----- Rescheduling disabled by TID %d\n", TID)
+	return wasDisabled
+}
+
+// freeze stops every task but the caller and waits until none is running,
+// the previous rescheduling state is returned for thaw
+func (s *schedulerT) freeze(TID dg.WordT) (prevDrschTID dg.WordT) {
+	s.mu.Lock()
+	prevDrschTID = s.drschTID
+	s.drschTID = TID
+	s.preemptIfNeeded()
+	for s.running != 0 {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+	return prevDrschTID
+}
+
+func (s *schedulerT) thaw(prevDrschTID dg.WordT) {
+	s.mu.Lock()
+	s.drschTID = prevDrschTID
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *schedulerT) enable() {
+	s.mu.Lock()
+	s.drschTID = 0
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *schedulerT) priorityOf(TID dg.WordT) (priority dg.WordT, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return 0, false
+	}
+	return st.priority, true
+}
+
+func (s *schedulerT) setPriority(TID, priority dg.WordT) (ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return false
+	}
+	st.priority = priority
+	s.preemptIfNeeded()
+	s.cond.Broadcast()
+	return true
+}
+
+func (s *schedulerT) setSuspended(TID dg.WordT, suspended bool) (ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return false
+	}
+	st.suspended = suspended
+	s.preemptIfNeeded()
+	s.cond.Broadcast()
+	return true
+}
+
+// unpend wakes a task pended in ?REC, ?XMTW or ?WDELAY
+func (s *schedulerT) unpend(TID dg.WordT) (ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return false
+	}
+	st.unpended = true
+	select {
+	case st.unpendCh <- struct{}{}:
+	default:
+	}
+	s.cond.Broadcast()
+	return true
+}
+
+// startPend clears any stale ?UNPEND for a task about to pend and returns its unpend channel
+func (s *schedulerT) startPend(TID dg.WordT) (unpendCh chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return nil
+	}
+	st.unpended = st.killed
+	if st.killed {
+		return st.unpendCh // leave the kill's wake-up in place
+	}
+	select {
+	case <-st.unpendCh:
+	default:
+	}
+	return st.unpendCh
+}
+
+// xmt places a message in a mailbox, optionally waiting for it to be received
+func (s *schedulerT) xmt(TID dg.WordT, mbox mailboxT, msg dg.DwordT, wait bool) (errCode dg.WordT) {
+	if msg == 0 {
+		return erxmz
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mbox.read() != 0 {
+		return erxmt
+	}
+	mbox.write(msg)
+	s.cond.Broadcast()
+	if !wait {
+		return 0
+	}
+	st := s.tasks[TID]
+	if st == nil {
+		return 0
+	}
+	st.unpended = st.killed
+	st.xmtRec = true
+	for mbox.read() != 0 && !st.unpended && !s.gone(TID, st) {
+		s.cond.Wait()
+	}
+	st.xmtRec = false
+	return 0
+}
+
+// rec collects a message from a mailbox, optionally pending until one arrives
+func (s *schedulerT) rec(TID dg.WordT, mbox mailboxT, wait bool) (msg dg.DwordT, errCode dg.WordT) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return 0, ernmw
+	}
+	st.unpended = st.killed
+	for {
+		if msg = mbox.read(); msg != 0 {
+			st.xmtRec = false
+			mbox.write(0)
+			s.cond.Broadcast() // wake any ?XMTW sender
+			return msg, 0
+		}
+		if !wait || st.unpended || s.gone(TID, st) {
+			st.xmtRec = false
+			return 0, ernmw
+		}
+		st.xmtRec = true
+		s.cond.Wait()
+	}
+}
+
+// kill marks a task as killed by ?IDKIL, it is stopped at its next instruction or woken
+// from any ?REC, ?XMTW or ?WDELAY, a suspended task is readied so that it notices
+func (s *schedulerT) kill(TID dg.WordT) (ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return false
+	}
+	st.killed = true
+	st.suspended = false
+	st.unpended = true
+	select {
+	case st.unpendCh <- struct{}{}:
+	default:
+	}
+	st.cpu.SetSCPIO(true)
+	s.cond.Broadcast()
+	return true
+}
+
+// takeKill reports whether a task has been killed since it last asked
+func (s *schedulerT) takeKill(TID dg.WordT) (killed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.tasks[TID]; st != nil && st.killed {
+		st.killed = false
+		return true
+	}
+	return false
+}
+
+// status returns the task status word and priority of a task for ?UIDSTAT and ?IDSTAT,
+// a task which is neither running nor waiting for the CPU is in a system call
+func (s *schedulerT) status(TID dg.WordT) (tsw, priority dg.WordT, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.tasks[TID]
+	if st == nil {
+		return 0, 0, false
+	}
+	if st.suspended {
+		tsw |= tssp
+	}
+	switch {
+	case st.xmtRec:
+		tsw |= tspn | tsxr
+	case s.running != TID && !st.waiting:
+		tsw |= tspn
+	}
+	if st.waiting && s.drschTID != 0 && s.drschTID != TID {
+		tsw |= tsdr
+	}
+	return tsw, st.priority, true
+}
+
+// setPaused stops or restarts every task of the process, tasks in system calls stop when they return
+func (s *schedulerT) setPaused(paused bool) {
+	s.mu.Lock()
+	s.paused = paused
+	s.preemptIfNeeded()
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// instrCount returns the number of instructions the process has executed
+func (s *schedulerT) instrCount() (count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count = s.retired
+	for _, st := range s.tasks {
+		count += st.cpu.GetInstrCount()
+	}
+	return count
+}
+
+// taskStates returns the state of each task for the control channel
+func (s *schedulerT) taskStates() (states []TaskInfoT) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for TID, st := range s.tasks {
+		states = append(states, TaskInfoT{
+			TID:       int(TID),
+			Priority:  int(st.priority),
+			PC:        uint32(st.cpu.GetPC()),
+			Running:   s.running == TID,
+			Suspended: st.suspended,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].TID < states[j].TID })
+	return states
+}
+
+// setDebugLogging changes debug logging for every task of the process
+func (s *schedulerT) setDebugLogging(on bool) {
+	s.mu.Lock()
+	for _, st := range s.tasks {
+		st.cpu.SetDebugLogging(on)
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,187 @@
+// +build virtual !physical
+
+// taskScheduler_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+	"github.com/SMerrony/dgemug/memory"
+	"github.com/SMerrony/dgemug/mvcpu"
+)
+
+// waitUntil polls cond under the scheduler lock, failing the test if it never holds
+func waitUntil(t *testing.T, s *schedulerT, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		s.mu.Lock()
+		ok := cond()
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+// testTasks adds a task with the given priority for each TID from 1
+func testTasks(s *schedulerT, priorities ...dg.WordT) (cpus []*mvcpu.CPUT) {
+	cpus = append(cpus, nil) // so that cpus[TID] works
+	for i, pri := range priorities {
+		cpu := new(mvcpu.CPUT)
+		s.addTask(dg.WordT(i+1), pri, cpu)
+		cpus = append(cpus, cpu)
+	}
+	return cpus
+}
+
+// acquireLater starts TID waiting for the CPU and reports it on got once it runs
+func acquireLater(t *testing.T, s *schedulerT, TID dg.WordT, cpu *mvcpu.CPUT, got chan dg.WordT) {
+	go func() {
+		if s.acquire(TID, cpu) {
+			got <- TID
+		}
+	}()
+	waitUntil(t, s, "a task to wait", func() bool { return s.tasks[TID].waiting || s.running == TID })
+}
+
+func TestAcquireOrder(t *testing.T) {
+	s := newScheduler()
+	cpus := testTasks(s, 10, 20, 5, 5)
+	got := make(chan dg.WordT, 4)
+
+	if !s.acquire(1, cpus[1]) {
+		t.Fatal("Expected the first task to get the CPU")
+	}
+	acquireLater(t, s, 2, cpus[2], got)
+	acquireLater(t, s, 3, cpus[3], got)
+	acquireLater(t, s, 4, cpus[4], got)
+	if !cpus[1].GetSCPIO() {
+		t.Error("Expected the running task to be preempted by a higher priority one")
+	}
+	select {
+	case TID := <-got:
+		t.Fatalf("Expected no task to run while TID 1 holds the CPU, TID %d did", TID)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// highest priority first, then in order of waiting
+	current := dg.WordT(1)
+	for _, want := range []dg.WordT{3, 4, 2} {
+		s.release(current, cpus[current])
+		if current = <-got; current != want {
+			t.Errorf("Expected TID %d to get the CPU, got TID %d", want, current)
+		}
+	}
+	s.release(current, cpus[current])
+	if s.running != 0 {
+		t.Errorf("Expected the CPU to be free, TID %d has it", s.running)
+	}
+}
+
+func TestSuspendReady(t *testing.T) {
+	s := newScheduler()
+	cpus := testTasks(s, 10, 20)
+	got := make(chan dg.WordT, 2)
+
+	s.setSuspended(1, true)
+	acquireLater(t, s, 1, cpus[1], got)
+	acquireLater(t, s, 2, cpus[2], got)
+	if TID := <-got; TID != 2 {
+		t.Fatalf("Expected the lower priority task to run while the other is suspended, got TID %d", TID)
+	}
+	if tsw, _, _ := s.status(1); tsw&tssp == 0 {
+		t.Errorf("Expected a suspended task status, got %#o", tsw)
+	}
+
+	s.setSuspended(1, false)
+	if !cpus[2].GetSCPIO() {
+		t.Error("Expected readying a higher priority task to preempt the running one")
+	}
+	s.release(2, cpus[2])
+	if TID := <-got; TID != 1 {
+		t.Errorf("Expected the readied task to run, got TID %d", TID)
+	}
+	s.release(1, cpus[1])
+
+	if s.setSuspended(9, true) {
+		t.Error("Expected suspending a non-existent task to fail")
+	}
+}
+
+func TestXmtRec(t *testing.T) {
+	s := newScheduler()
+	testTasks(s, 10, 10)
+	mem := memory.NewAddrSpace()
+	mem.MapPage(7, false)
+	mbox := mailboxT{mem: mem, addr: 7 << 10, wide: true}
+
+	if errCode := s.xmt(1, mbox, 0, false); errCode != erxmz {
+		t.Errorf("Expected ERXMZ for a zero message, got %#o", errCode)
+	}
+	if _, errCode := s.rec(2, mbox, false); errCode != ernmw {
+		t.Errorf("Expected ERNMW for ?REC on an empty mailbox without waiting, got %#o", errCode)
+	}
+
+	// a pended ?REC is paired with the next ?XMT
+	received := make(chan dg.DwordT)
+	go func() {
+		msg, _ := s.rec(2, mbox, true)
+		received <- msg
+	}()
+	waitUntil(t, s, "?REC to pend", func() bool { return s.tasks[2].xmtRec })
+	if tsw, _, _ := s.status(2); tsw&(tspn|tsxr) != tspn|tsxr {
+		t.Errorf("Expected ?REC to show as pended, got %#o", tsw)
+	}
+	if errCode := s.xmt(1, mbox, 0x1234_5678, false); errCode != 0 {
+		t.Errorf("Expected ?XMT to succeed, got %#o", errCode)
+	}
+	if msg := <-received; msg != 0x1234_5678 {
+		t.Errorf("Expected ?REC to get the message, got %#x", msg)
+	}
+	if mbox.read() != 0 {
+		t.Error("Expected ?REC to empty the mailbox")
+	}
+
+	// ?XMTW waits for its message to be collected, a second one finds the mailbox full
+	sent := make(chan dg.WordT)
+	go func() { sent <- s.xmt(1, mbox, 42, true) }()
+	waitUntil(t, s, "?XMTW to pend", func() bool { return s.tasks[1].xmtRec })
+	if errCode := s.xmt(2, mbox, 43, true); errCode != erxmt {
+		t.Errorf("Expected ERXMT for ?XMTW to a full mailbox, got %#o", errCode)
+	}
+	select {
+	case <-sent:
+		t.Fatal("Expected ?XMTW to wait until its message is received")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if msg, errCode := s.rec(2, mbox, false); msg != 42 || errCode != 0 {
+		t.Errorf("Expected ?REC to get the first message, got %d %#o", msg, errCode)
+	}
+	if errCode := <-sent; errCode != 0 {
+		t.Errorf("Expected ?XMTW to complete once received, got %#o", errCode)
+	}
+}
@@ -0,0 +1,403 @@
+// +build virtual !physical
+
+// telnetConsole.go - a TELNET console which makes ANSI terminals look like DASHERs
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// TELNET commands and options
+const (
+	telSE   = 240
+	telSB   = 250
+	telWILL = 251
+	telWONT = 252
+	telDO   = 253
+	telDONT = 254
+	telIAC  = 255
+
+	telOptBinary = 0
+	telOptEcho   = 1
+	telOptSGA    = 3
+	telOptTType  = 24
+	telOptNAWS   = 31
+
+	telTTypeIS   = 0
+	telTTypeSEND = 1
+)
+
+// DASHER codes translated for ANSI terminals, the rest are in dg/characters.go
+const (
+	dasherHome        = 010
+	dasherBlinkOn     = 016
+	dasherBlinkOff    = 017
+	dasherCursorUp    = 027
+	dasherCursorRight = 030
+	dasherCursorDown  = 032
+	dasherRS          = 036
+)
+
+const negotiationWait = 500 * time.Millisecond
+
+// TelnetConsoleT wraps a console connection, it negotiates TELNET options with the client,
+// strips TELNET commands from the input and, if the client's terminal type is not a DASHER,
+// translates DASHER control codes to ANSI sequences on output and ANSI keys to DASHER ones on input.
+type TelnetConsoleT struct {
+	net.Conn
+	in       *bufio.Reader
+	pending  []byte // input already decoded
+	lastCR   bool   // drop the LF or NUL a client sends after CR
+	wMu      sync.Mutex
+	outState int    // progress through a multi-byte DASHER sequence
+	outArgs  []byte // its arguments so far
+	echo     bool   // client has agreed that we echo
+	binary   bool   // client sends binary
+	termType string // from TTYPE, empty if the client did not say
+	ansi     bool   // translate between DASHER and ANSI
+	width    int    // from NAWS, zero if unknown
+	height   int    // from NAWS
+	optsMu   sync.Mutex
+}
+
+// NewTelnetConsole negotiates TELNET options on a newly accepted console connection,
+// waiting briefly for the client's answers so that its terminal type and size are known
+func NewTelnetConsole(conn net.Conn) *TelnetConsoleT {
+	tc := &TelnetConsoleT{Conn: conn, in: bufio.NewReader(conn)}
+	tc.command(telWILL, telOptEcho)
+	tc.command(telWILL, telOptSGA)
+	tc.command(telDO, telOptSGA)
+	tc.command(telWILL, telOptBinary)
+	tc.command(telDO, telOptBinary)
+	tc.command(telDO, telOptTType)
+	tc.command(telDO, telOptNAWS)
+	conn.SetReadDeadline(time.Now().Add(negotiationWait))
+	for {
+		c, err := tc.in.ReadByte()
+		if err != nil {
+			break
+		}
+		tc.input(c)
+	}
+	conn.SetReadDeadline(time.Time{})
+	tc.in = bufio.NewReader(conn) // discard the timeout
+	return tc
+}
+
+// command sends a TELNET negotiation
+func (tc *TelnetConsoleT) command(verb, option byte) {
+	tc.send([]byte{telIAC, verb, option})
+}
+
+// send writes TELNET commands to the client without interleaving them with output
+func (tc *TelnetConsoleT) send(b []byte) {
+	tc.wMu.Lock()
+	tc.Conn.Write(b)
+	tc.wMu.Unlock()
+}
+
+// Echoing reports whether the client expects the host to echo
+func (tc *TelnetConsoleT) Echoing() bool {
+	tc.optsMu.Lock()
+	defer tc.optsMu.Unlock()
+	return tc.echo
+}
+
+// Size returns the client's window size, if it has told us
+func (tc *TelnetConsoleT) Size() (width, height int) {
+	tc.optsMu.Lock()
+	defer tc.optsMu.Unlock()
+	return tc.width, tc.height
+}
+
+// TermType returns the terminal type the client gave, if any
+func (tc *TelnetConsoleT) TermType() string {
+	tc.optsMu.Lock()
+	defer tc.optsMu.Unlock()
+	return tc.termType
+}
+
+// adaptChars sets console characteristics to match what the client negotiated
+func (tc *TelnetConsoleT) adaptChars(chars *[clmax]dg.WordT) {
+	if tc.Echoing() {
+		chars[ch1] |= ceos
+	}
+	if width, height := tc.Size(); width > 0 && height > 0 && width < 256 && height < 256 {
+		chars[ch3] = dg.WordT(height<<8 | width)
+	}
+}
+
+// Read returns input with TELNET commands removed and, for ANSI clients, keys translated to DASHER codes
+func (tc *TelnetConsoleT) Read(b []byte) (n int, err error) {
+	for len(tc.pending) == 0 {
+		c, err := tc.in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		tc.input(c)
+	}
+	n = copy(b, tc.pending)
+	tc.pending = tc.pending[n:]
+	return n, nil
+}
+
+// input decodes one byte from the client, reading more if it starts a command or key sequence
+func (tc *TelnetConsoleT) input(c byte) {
+	if c == telIAC {
+		tc.telnetCommand()
+		return
+	}
+	if tc.lastCR {
+		tc.lastCR = false
+		if c == 0 || c == dg.ASCIINL {
+			return
+		}
+	}
+	tc.optsMu.Lock()
+	ansi, binary := tc.ansi, tc.binary
+	tc.optsMu.Unlock()
+	if !ansi {
+		if c == dg.ASCIICR && !binary {
+			tc.lastCR = true
+		}
+		tc.pending = append(tc.pending, c)
+		return
+	}
+	switch c {
+	case dg.ASCIICR:
+		tc.lastCR = !binary
+		tc.pending = append(tc.pending, dg.ASCIINL)
+	case dg.ASCIIBS:
+		tc.pending = append(tc.pending, dg.DasherDELETE)
+	case dg.ASCIIESC:
+		tc.pending = append(tc.pending, tc.ansiKey()...)
+	default:
+		tc.pending = append(tc.pending, c)
+	}
+}
+
+// ansiKeys maps the ANSI cursor and function key sequences to DASHER codes
+var ansiKeys = map[string][]byte{
+	"[A": {dasherCursorUp}, "[B": {dasherCursorDown}, "[C": {dasherCursorRight}, "[D": {dg.DasherCURSORLEFT},
+	"[H": {dasherHome}, "OA": {dasherCursorUp}, "OB": {dasherCursorDown}, "OC": {dasherCursorRight},
+	"OD": {dg.DasherCURSORLEFT}, "OH": {dasherHome}, "[1~": {dasherHome},
+	"OP": {dasherRS, 'q'}, "OQ": {dasherRS, 'r'}, "OR": {dasherRS, 's'}, "OS": {dasherRS, 't'},
+	"[11~": {dasherRS, 'q'}, "[12~": {dasherRS, 'r'}, "[13~": {dasherRS, 's'}, "[14~": {dasherRS, 't'},
+	"[15~": {dasherRS, 'u'}, "[17~": {dasherRS, 'v'}, "[18~": {dasherRS, 'w'}, "[19~": {dasherRS, 'x'},
+	"[20~": {dasherRS, 'y'}, "[21~": {dasherRS, 'z'}, "[23~": {dasherRS, '{'}, "[24~": {dasherRS, '|'},
+}
+
+// ansiKey reads the rest of an escape sequence and returns its DASHER equivalent
+func (tc *TelnetConsoleT) ansiKey() []byte {
+	var seq []byte
+	for len(seq) < 6 {
+		c, err := tc.in.ReadByte()
+		if err != nil {
+			break
+		}
+		seq = append(seq, c)
+		if len(seq) == 1 && c != '[' && c != 'O' {
+			break // not a key sequence
+		}
+		if len(seq) > 1 && (c >= 'A' && c <= 'Z' || c == '~') {
+			break
+		}
+	}
+	if key, found := ansiKeys[string(seq)]; found {
+		return key
+	}
+	return append([]byte{dg.ASCIIESC}, seq...)
+}
+
+// telnetCommand handles a TELNET command following an IAC
+func (tc *TelnetConsoleT) telnetCommand() {
+	verb, err := tc.in.ReadByte()
+	if err != nil {
+		return
+	}
+	switch verb {
+	case telIAC:
+		tc.pending = append(tc.pending, telIAC)
+	case telWILL, telWONT, telDO, telDONT:
+		option, err := tc.in.ReadByte()
+		if err != nil {
+			return
+		}
+		tc.negotiate(verb, option)
+	case telSB:
+		var sub []byte
+		for {
+			c, err := tc.in.ReadByte()
+			if err != nil {
+				return
+			}
+			if c == telIAC {
+				if c, err = tc.in.ReadByte(); err != nil || c == telSE {
+					break
+				}
+			}
+			sub = append(sub, c)
+		}
+		tc.subnegotiation(sub)
+	}
+}
+
+// negotiate records the client's answer to, or request for, an option and refuses those we do not support
+func (tc *TelnetConsoleT) negotiate(verb, option byte) {
+	tc.optsMu.Lock()
+	defer tc.optsMu.Unlock()
+	switch option {
+	case telOptEcho:
+		tc.echo = verb == telDO
+	case telOptBinary:
+		if verb == telWILL || verb == telWONT {
+			tc.binary = verb == telWILL
+		}
+	case telOptSGA:
+	case telOptTType:
+		if verb == telWILL {
+			tc.send([]byte{telIAC, telSB, telOptTType, telTTypeSEND, telIAC, telSE})
+		}
+	case telOptNAWS:
+	default:
+		switch verb {
+		case telWILL:
+			tc.command(telDONT, option)
+		case telDO:
+			tc.command(telWONT, option)
+		}
+	}
+}
+
+// subnegotiation handles the client's terminal type and window size
+func (tc *TelnetConsoleT) subnegotiation(sub []byte) {
+	if len(sub) == 0 {
+		return
+	}
+	tc.optsMu.Lock()
+	defer tc.optsMu.Unlock()
+	switch sub[0] {
+	case telOptTType:
+		if len(sub) > 1 && sub[1] == telTTypeIS {
+			tc.termType = strings.ToUpper(string(sub[2:]))
+			tc.ansi = !isDasher(tc.termType)
+		}
+	case telOptNAWS:
+		if len(sub) == 5 {
+			tc.width = int(sub[1])<<8 | int(sub[2])
+			tc.height = int(sub[3])<<8 | int(sub[4])
+		}
+	}
+}
+
+// isDasher reports whether a TELNET terminal type is a DASHER, eg. D200, D410 or DASHER
+func isDasher(termType string) bool {
+	return strings.HasPrefix(termType, "DASHER") ||
+		len(termType) > 1 && termType[0] == 'D' && termType[1] >= '0' && termType[1] <= '9'
+}
+
+// Write sends output, translated for ANSI clients, with IACs doubled
+func (tc *TelnetConsoleT) Write(b []byte) (n int, err error) {
+	tc.optsMu.Lock()
+	ansi := tc.ansi
+	tc.optsMu.Unlock()
+	tc.wMu.Lock()
+	defer tc.wMu.Unlock()
+	out := b
+	if ansi {
+		out = tc.toANSI(b)
+	}
+	out = bytes.ReplaceAll(out, []byte{telIAC}, []byte{telIAC, telIAC})
+	if _, err = tc.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// states of the DASHER output parser
+const (
+	outNormal = iota
+	outWindowAddr
+	outRS
+)
+
+// dasherToANSI gives the ANSI equivalent of single-byte DASHER codes
+var dasherToANSI = map[byte]string{
+	dg.ASCIINL:          "\r\n",
+	dasherHome:          "\x1b[H",
+	dg.DasherERASEEOL:   "\x1b[K",
+	dg.DasherERASEPAGE:  "\x1b[H\x1b[2J",
+	dasherBlinkOn:       "\x1b[5m",
+	dasherBlinkOff:      "\x1b[25m",
+	dg.DasherUNDERLINE:  "\x1b[4m",
+	dg.DasherNORMAL:     "\x1b[24m",
+	dasherCursorUp:      "\x1b[A",
+	dasherCursorRight:   "\x1b[C",
+	dg.DasherCURSORLEFT: "\x1b[D",
+	dasherCursorDown:    "\x1b[B",
+	dg.DasherDIMON:      "\x1b[2m",
+	dg.DasherDIMOFF:     "\x1b[22m",
+}
+
+// toANSI translates DASHER output, sequences may be split across writes
+func (tc *TelnetConsoleT) toANSI(b []byte) []byte {
+	var out []byte
+	for _, c := range b {
+		switch tc.outState {
+		case outWindowAddr:
+			tc.outArgs = append(tc.outArgs, c)
+			if len(tc.outArgs) == 2 {
+				out = append(out, fmt.Sprintf("\x1b[%d;%dH", tc.outArgs[1]&0x7f+1, tc.outArgs[0]&0x7f+1)...)
+				tc.outState = outNormal
+			}
+		case outRS:
+			switch c {
+			case 'D':
+				out = append(out, "\x1b[7m"...)
+			case 'E':
+				out = append(out, "\x1b[27m"...)
+			}
+			tc.outState = outNormal // other D410 sequences are dropped
+		default:
+			switch {
+			case c == dg.DasherWRITEWINDOWADDR:
+				tc.outState, tc.outArgs = outWindowAddr, tc.outArgs[:0]
+			case c == dasherRS:
+				tc.outState = outRS
+			case dasherToANSI[c] != "":
+				out = append(out, dasherToANSI[c]...)
+			default:
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+// +build virtual !physical
+
+// telnetConsole_test.go
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// captureConn records what is written to a client
+type captureConn struct {
+	net.Conn
+	out bytes.Buffer
+}
+
+func (cc *captureConn) Write(b []byte) (int, error) {
+	return cc.out.Write(b)
+}
+
+// readAll decodes everything a client sent
+func readAll(tc *TelnetConsoleT) string {
+	var in []byte
+	buf := make([]byte, 64)
+	for {
+		n, err := tc.Read(buf)
+		if err != nil {
+			return string(in)
+		}
+		in = append(in, buf[:n]...)
+	}
+}
+
+func TestTelnetInput(t *testing.T) {
+	tests := []struct {
+		name        string
+		ansi        bool
+		binary      bool
+		client, got string
+	}{
+		{"CR NUL folded", false, false, "A\r\x00B", "A\rB"},
+		{"CR LF folded", false, false, "A\r\nB\r", "A\rB\r"},
+		{"binary CR kept apart", false, true, "A\r\x00B\r\n", "A\r\x00B\r\n"},
+		{"IAC undoubled", false, true, "A\xff\xffB", "A\xffB"},
+		{"negotiation stripped", false, false, "A\xff\xfb\x03B\xff\xfa\x18\x00VT100\xff\xf0C", "ABC"},
+		{"ANSI CR LF", true, false, "DIR\r\nX\r\x00", "DIR\nX\n"},
+		{"ANSI cursor keys", true, false, "\x1b[A\x1bOB\x1b[C\x1b[D\x1b[H", "\027\032\030\031\010"},
+		{"ANSI function keys", true, false, "\x1bOP\x1b[24~", "\036q\036|"},
+		{"ANSI backspace", true, false, "AB\bC", "AB\177C"},
+		{"ANSI unknown escape", true, false, "\x1b[Z\x1bx", "\x1b[Z\x1bx"},
+	}
+	for _, test := range tests {
+		tc := &TelnetConsoleT{Conn: &captureConn{}, in: bufio.NewReader(strings.NewReader(test.client)), ansi: test.ansi, binary: test.binary}
+		if got := readAll(tc); got != test.got {
+			t.Errorf("%s: expected %q, got %q", test.name, test.got, got)
+		}
+	}
+}
+
+func TestTelnetOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		ansi       bool
+		host, sent string
+	}{
+		{"IAC doubled", false, "A\xffB", "A\xff\xffB"},
+		{"DASHER untranslated", false, "\014HI\n", "\014HI\n"},
+		{"NL", true, "HI\n", "HI\r\n"},
+		{"erase page and line", true, "\014X\013", "\x1b[H\x1b[2JX\x1b[K"},
+		{"attributes", true, "\024U\025\034D\035\036DR\036E", "\x1b[4mU\x1b[24m\x1b[2mD\x1b[22m\x1b[7mR\x1b[27m"},
+		{"window address", true, "\020\005\002*", "\x1b[3;6H*"},
+		{"other D410 sequence dropped", true, "\036FQA", "QA"},
+		{"IAC doubled after translation", true, "\xff\n", "\xff\xff\r\n"},
+	}
+	for _, test := range tests {
+		cc := &captureConn{}
+		tc := &TelnetConsoleT{Conn: cc, ansi: test.ansi}
+		if n, err := tc.Write([]byte(test.host)); n != len(test.host) || err != nil {
+			t.Errorf("%s: expected %d bytes written, got %d %v", test.name, len(test.host), n, err)
+		}
+		if got := cc.out.String(); got != test.sent {
+			t.Errorf("%s: expected %q, got %q", test.name, test.sent, got)
+		}
+	}
+
+	// a window address split across writes
+	cc := &captureConn{}
+	tc := &TelnetConsoleT{Conn: cc, ansi: true}
+	tc.Write([]byte{dg.DasherWRITEWINDOWADDR, 0})
+	tc.Write([]byte{0, 'X'})
+	if got := cc.out.String(); got != "\x1b[1;1HX" {
+		t.Errorf("Expected a split window address to be translated, got %q", got)
+	}
+}
+
+func TestTelnetSplitCommand(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	tc := &TelnetConsoleT{Conn: server, in: bufio.NewReader(server), binary: true}
+	got := make(chan string)
+	go func() { got <- readAll(tc) }()
+
+	client.Write([]byte{'A', telIAC})
+	client.Write([]byte{telIAC, 'B', telIAC, telDO})
+	reply := make([]byte, 3)
+	go client.Write([]byte{99, 'C'})
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if n, _ := client.Read(reply); n != 3 || !bytes.Equal(reply, []byte{telIAC, telWONT, 99}) {
+		t.Errorf("Expected a split DO for an unknown option to be refused, got %v", reply[:n])
+	}
+	client.Write([]byte{telIAC})
+	client.Write([]byte{telWILL, telOptTType})
+	if n, _ := client.Read(reply); n != 3 || reply[1] != telSB {
+		t.Errorf("Expected a split WILL TTYPE to be answered with SEND, got %v", reply[:n])
+	}
+	client.Read(make([]byte, 3))
+	server.Close()
+	if in := <-got; in != "A\xffBC" {
+		t.Errorf("Expected IAC sequences split across reads to be decoded, got %q", in)
+	}
+}
@@ -0,0 +1,240 @@
+// +build virtual !physical
+
+// userProfiles.go - user profiles, privileges and privileged modes
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package aosvs
+
+// The operator keeps the user profiles in a text file with one profile per line:
+//
+//	USERNAME  PRIVILEGES  [INITIAL-DIRECTORY  [SEARCHLIST]]
+//
+// the privileges are a comma-separated list of SUPERUSER, SUPERPROCESS, SYSMGR and
+// USERNAME (may give sons another username), or ALL, the searchlist is a comma-separated
+// list of directories, and '-' may be given for no privileges or the default directory or
+// searchlist.  Blank lines and those starting with ';' are ignored.  Without a profile file
+// every username is accepted but has no privileges.
+//
+// A process may enter the privileged modes it has privileges for with ?SYSPRV (or ?SUSER for
+// Superuser), in Superuser mode the ACL of every file grants it all access.
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// privilege bits, as in ?PROC pprv and returned by ?GUNM
+const (
+	pvsm  = 1 << 0 // 1B15 SYSTEM MANAGER
+	pvsp  = 1 << 1 // 1B14 SUPERPROCESS
+	pvsu  = 1 << 2 // 1B13 SUPERUSER
+	pvui  = 1 << 3 // 1B12 MAY CHANGE USERNAME OF SONS
+	pvAll = pvsm | pvsp | pvsu | pvui
+)
+
+var privilegeNames = map[string]dg.WordT{
+	"ALL":          pvAll,
+	"SUPERUSER":    pvsu,
+	"SUPERPROCESS": pvsp,
+	"SYSMGR":       pvsm,
+	"USERNAME":     pvui,
+}
+
+// privileges needed for each ?SYSPRV mode
+var modePrivileges = [sysprvModeMax + 1]dg.WordT{
+	sysprvSuser:    pvsu,
+	sysprvSprocess: pvsp,
+	sysprvSysmgr:   pvsm,
+}
+
+// states of a privileged mode in PerProcessDataT.privModes
+const (
+	privModeOff = iota
+	privModeOn
+	privModeExcl
+)
+
+type userProfileT struct {
+	username   string
+	privileges dg.WordT
+	initialDir string   // empty for the default
+	searchList []string // nil for the default
+}
+
+var userProfiles map[string]*userProfileT // nil if there is no profile file
+
+// LoadUserProfiles reads the profile file, after which only the usernames in it are accepted
+func LoadUserProfiles(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	profiles := map[string]*userProfileT{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(strings.ToUpper(scanner.Text()))
+		if len(fields) == 0 || fields[0][0] == ';' {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 4 {
+			return fmt.Errorf("%s line %d: expected username, privileges, initial directory and searchlist", filename, lineNo)
+		}
+		if len(fields[0]) > mxun {
+			return fmt.Errorf("%s line %d: username %s is too long", filename, lineNo, fields[0])
+		}
+		up := &userProfileT{username: fields[0]}
+		if fields[1] != "-" {
+			for _, name := range strings.Split(fields[1], ",") {
+				priv, found := privilegeNames[name]
+				if !found {
+					return fmt.Errorf("%s line %d: unknown privilege %s", filename, lineNo, name)
+				}
+				up.privileges |= priv
+			}
+		}
+		if len(fields) > 2 && fields[2] != "-" {
+			up.initialDir = fields[2]
+		}
+		if len(fields) > 3 && fields[3] != "-" {
+			up.searchList = strings.Split(fields[3], ",")
+		}
+		profiles[up.username] = up
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	userProfiles = profiles
+	return nil
+}
+
+// KnownUser reports whether a username may be used
+func KnownUser(username string) bool {
+	_, found := findUserProfile(username)
+	return found
+}
+
+// findUserProfile returns a user's profile, without a profile file no-one has any privileges
+func findUserProfile(username string) (up *userProfileT, found bool) {
+	username = strings.ToUpper(username)
+	if userProfiles == nil {
+		return &userProfileT{username: username}, true
+	}
+	up, found = userProfiles[username]
+	return up, found
+}
+
+// startingEnv returns the working directory and searchlist a user starts with,
+// defaultDir is used if the profile gives no initial directory
+func (up *userProfileT) startingEnv(vRoot, defaultDir string) (workingDir string, searchList []string, errCode dg.WordT) {
+	workingDir = defaultDir
+	if up.initialDir != "" {
+		env := &PerProcessDataT{virtualRoot: vRoot, workingDir: rootDir}
+		if workingDir, errCode = env.checkDirectory(up.initialDir); errCode != 0 {
+			return "", nil, errCode
+		}
+	}
+	searchList = defaultSearchList
+	if up.searchList != nil {
+		searchList = up.searchList
+	}
+	return workingDir, append([]string(nil), searchList...), 0
+}
+
+// access returns the access a process has to a file, in Superuser mode it has all access - Agent only
+func (ppd *PerProcessDataT) access(acl []aclEntryT) byte {
+	if ppd.privModes[sysprvSuser] != privModeOff {
+		return oware
+	}
+	return aclAccess(acl, ppd.username)
+}
+
+type agSysprvReqT struct {
+	PID      dg.WordT
+	funcCode dg.WordT
+	mode     dg.WordT
+}
+type agSysprvRespT struct {
+	flags   dg.WordT // sysprvPktFlags bits
+	errCode dg.WordT
+}
+
+// agSysprv handles ?SYSPRV and ?SUSER, entering, leaving or examining a privileged mode
+func agSysprv(req agSysprvReqT) (resp agSysprvRespT) {
+	if req.mode < sysprvModeMin || req.mode > sysprvModeMax {
+		resp.errCode = erpvm
+		return resp
+	}
+	ppd := PerProcessData[int(req.PID)]
+	var othersOn, othersExcl bool
+	for PID, other := range PerProcessData {
+		if dg.WordT(PID) != req.PID && other.privModes[req.mode] != privModeOff {
+			othersOn = true
+			othersExcl = othersExcl || other.privModes[req.mode] == privModeExcl
+		}
+	}
+	switch req.funcCode {
+	case sysprvGet:
+	case sysprvEnter, sysprvEnterExcl:
+		switch {
+		case ppd.privileges&modePrivileges[req.mode] == 0:
+			resp.errCode = erprv
+		case othersExcl:
+			resp.errCode = erpvx
+		case req.funcCode == sysprvEnterExcl && othersOn:
+			resp.errCode = erpvp
+		case req.funcCode == sysprvEnterExcl:
+			ppd.privModes[req.mode] = privModeExcl
+		default:
+			ppd.privModes[req.mode] = privModeOn
+		}
+	case sysprvLeave:
+		ppd.privModes[req.mode] = privModeOff
+	default:
+		resp.errCode = erpre
+	}
+	if ppd.privModes[req.mode] != privModeOff {
+		resp.flags |= 0x8000 >> sysprvFlagsCaller
+	}
+	if ppd.privModes[req.mode] == privModeExcl {
+		resp.flags |= 0x8000 >> sysprvFlagsCallersExcl
+	}
+	if othersOn {
+		resp.flags |= 0x8000 >> sysprvFlagsOthers
+	}
+	if othersExcl {
+		resp.flags |= 0x8000 >> sysprvFlagsOthersExcl
+	}
+	return resp
+}
+
+// sysprv asks the pseudo-Agent to enter, leave or examine a privileged mode
+func sysprv(agentChan chan AgentReqT, PID, funcCode, mode dg.WordT) (flags, errCode dg.WordT) {
+	areq := AgentReqT{agentSysprv, agSysprvReqT{PID, funcCode, mode}, nil}
+	agentChan <- areq
+	areq = <-agentChan
+	resp := areq.result.(agSysprvRespT)
+	return resp.flags, resp.errCode
+}
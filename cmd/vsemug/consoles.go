@@ -0,0 +1,179 @@
+// consoles.go - the console manager for multiple users
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// N.B. Build with "-tags virtual"
+
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/SMerrony/dgemug/aosvs"
+	"github.com/SMerrony/dgemug/dg"
+)
+
+const (
+	maxUsernameLen = 15
+	maxLineLen     = 80
+)
+
+// serveConsoles listens on every console port and runs the program or pseudo-CLI for each connection,
+// it never returns
+func serveConsoles() {
+	host, portStr, err := net.SplitHostPort(*consoleAddrFlag)
+	if err != nil {
+		log.Println("ERROR: Invalid -consoleaddr: ", err.Error())
+		os.Exit(1)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		log.Println("ERROR: Invalid -consoleaddr port: ", err.Error())
+		os.Exit(1)
+	}
+	for c := 0; c < *consolesFlag; c++ {
+		addr := net.JoinHostPort(host, strconv.Itoa(port+c))
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Println("ERROR: Could not listen on console port: ", err.Error())
+			os.Exit(1)
+		}
+		log.Printf("INFO: Waiting for terminal connections to %s\n", addr)
+		go acceptConsoles(l)
+	}
+	select {}
+}
+
+// acceptConsoles handles the connections to one console port, with -login any number at once,
+// otherwise one after another
+func acceptConsoles(l net.Listener) {
+	for {
+		rawConn, err := l.Accept()
+		if err != nil {
+			log.Println("ERROR: Could not accept on console port: ", err.Error())
+			return
+		}
+		if *loginFlag {
+			go consoleSession(rawConn)
+		} else {
+			consoleSession(rawConn)
+		}
+	}
+}
+
+// consoleSession runs the program or pseudo-CLI on a newly connected console until it terminates
+func consoleSession(rawConn net.Conn) {
+	conn := aosvs.NewTelnetConsole(rawConn)
+	defer conn.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			debug.PrintStack()
+			conn.Write([]byte("\n *** VSemuG Internal Panic ***\n"))
+		}
+	}()
+	conn.Write([]byte("\n *** Welcome to the VSemuG AOS/VS Emulator ***" + "\n"))
+	var username string
+	if *loginFlag {
+		var ok bool
+		if username, ok = login(conn); !ok {
+			return
+		}
+	}
+	// the emulator reads the console from now on
+	name := aosvs.AttachConsole(conn)
+	defer aosvs.DetachConsole(name)
+	conn.Write([]byte(" *** You are on @" + name + " ***" + "\n"))
+	if *cliFlag {
+		log.Printf("INFO: Starting the pseudo-CLI on @%s for %s\n", name, username)
+		if err := aosvs.RunCLI(conn, username, virtualRoot()); err != nil {
+			conn.Write([]byte(err.Error() + "\n"))
+		}
+		return
+	}
+	log.Printf("INFO: Starting %s on @%s for %s\n", *prFlag, name, username)
+	ppd, err := aosvs.CreateProcess(programArgs(), virtualRoot(), *prFlag, 7, conn, username, aosvs.DebugLogging())
+	if err != nil {
+		conn.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	ppd.ActiveTasksWg.Wait()
+	conn.Write([]byte("\n *** Process terminated ***\n"))
+}
+
+// login prompts until it gets a valid username, it fails if the connection goes
+func login(conn *aosvs.TelnetConsoleT) (username string, ok bool) {
+	for {
+		conn.Write([]byte("\nUsername: "))
+		line, err := readLine(conn)
+		if err != nil {
+			return "", false
+		}
+		username = strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case username == "":
+		case len(username) > maxUsernameLen || strings.IndexFunc(username, badUsernameChar) != -1:
+			conn.Write([]byte("\nInvalid username\n"))
+		case !aosvs.KnownUser(username):
+			conn.Write([]byte("\nUnknown username\n"))
+		default:
+			conn.Write([]byte{dg.ASCIINL})
+			return username, true
+		}
+	}
+}
+
+func badUsernameChar(r rune) bool {
+	return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '$')
+}
+
+// readLine reads a line from a console, echoing it if the terminal expects us to
+func readLine(conn *aosvs.TelnetConsoleT) (line string, err error) {
+	var b []byte
+	c := make([]byte, 1)
+	for {
+		if _, err = conn.Read(c); err != nil {
+			return "", err
+		}
+		switch c[0] {
+		case dg.ASCIICR, dg.ASCIINL:
+			return string(b), nil
+		case dg.ASCIIBS, dg.DasherDELETE:
+			if len(b) > 0 {
+				b = b[:len(b)-1]
+				if conn.Echoing() {
+					conn.Write([]byte{dg.DasherCURSORLEFT, ' ', dg.DasherCURSORLEFT})
+				}
+			}
+		default:
+			if c[0] < ' ' || c[0] > '~' || len(b) == maxLineLen {
+				continue
+			}
+			b = append(b, c[0])
+			if conn.Echoing() {
+				conn.Write(c)
+			}
+		}
+	}
+}
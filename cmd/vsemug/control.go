@@ -0,0 +1,172 @@
+// control.go - the supervisory control channel and signal handling
+
+// Copyright ©2020 Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// N.B. Build with "-tags virtual"
+
+package main
+
+// The control channel takes one command per line, see controlHelp.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SMerrony/dgemug/aosvs"
+	"github.com/SMerrony/dgemug/logging"
+)
+
+const controlHelp = `ps            list processes and their tasks
+pause PID     stop all tasks of a process
+resume PID    restart a paused process
+abort PID     terminate a process
+log on|off    turn debug logging on or off
+dump          write the debug logs to logs/
+quit          terminate all processes and exit the emulator
+`
+
+const shutdownWait = 2 * time.Second // for processes to end before we exit
+
+// serveControl accepts control channel connections
+func serveControl(addr string) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Println("ERROR: Could not listen on control port: ", err.Error())
+		os.Exit(1)
+	}
+	log.Printf("INFO: Control channel on %s\n", addr)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Println("ERROR: Could not accept on control port: ", err.Error())
+			return
+		}
+		go controlSession(conn)
+	}
+}
+
+func controlSession(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	fmt.Fprint(conn, "> ")
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			controlCommand(conn, fields)
+		}
+		fmt.Fprint(conn, "> ")
+	}
+}
+
+// controlCommand carries out one control channel command
+func controlCommand(w io.Writer, fields []string) {
+	var (
+		PID int
+		err error
+	)
+	switch fields[0] {
+	case "ps":
+		listProcesses(w)
+	case "pause", "resume":
+		if PID, err = pidArg(fields); err == nil {
+			err = aosvs.PauseProcess(PID, fields[0] == "pause")
+		}
+	case "abort":
+		if PID, err = pidArg(fields); err == nil {
+			err = aosvs.AbortProcess(PID, "ABORTED BY OPERATOR")
+		}
+	case "log":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			err = errors.New("usage: log on|off")
+			break
+		}
+		aosvs.SetDebugLogging(fields[1] == "on")
+	case "dump":
+		dumpLogs()
+	case "quit":
+		shutdown("control channel")
+	default:
+		fmt.Fprint(w, controlHelp)
+	}
+	if err != nil {
+		fmt.Fprintln(w, err.Error())
+	}
+}
+
+func pidArg(fields []string) (PID int, err error) {
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("usage: %s PID", fields[0])
+	}
+	if PID, err = strconv.Atoi(fields[1]); err != nil {
+		return 0, fmt.Errorf("invalid PID: %s", fields[1])
+	}
+	return PID, nil
+}
+
+// listProcesses describes every process and its tasks
+func listProcesses(w io.Writer) {
+	procs := aosvs.Processes()
+	if len(procs) == 0 {
+		fmt.Fprintln(w, "No processes")
+	}
+	for _, p := range procs {
+		state := "running"
+		if p.Paused {
+			state = "paused"
+		}
+		fmt.Fprintf(w, "PID %d. %s  father %d.  user %s  @%s  %s  %d. instructions  up %v  %s\n",
+			p.PID, p.Name, p.FatherPID, p.Username, p.Console, p.Program, p.Instrs,
+			time.Since(p.Created).Round(time.Second), state)
+		for _, t := range p.Tasks {
+			fmt.Fprintf(w, "\tTID %d.  priority %d.  PC %#o  running %v  suspended %v\n",
+				t.TID, t.Priority, t.PC, t.Running, t.Suspended)
+		}
+	}
+}
+
+func dumpLogs() {
+	logging.DebugLogsDump("logs/")
+	log.Println("INFO: Debug logs written to logs/")
+}
+
+// shutdown aborts every process, giving them a moment to end, and exits
+func shutdown(why string) {
+	log.Printf("INFO: Shutting down on %s\n", why)
+	for _, p := range aosvs.Processes() {
+		if p.FatherPID == 0 {
+			aosvs.AbortProcess(p.PID, "EMULATOR SHUTDOWN")
+		}
+	}
+	for end := time.Now().Add(shutdownWait); time.Now().Before(end) && len(aosvs.Processes()) > 0; {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if aosvs.DebugLogging() {
+		dumpLogs()
+	}
+	os.Exit(0)
+}
@@ -24,14 +24,15 @@
 package main
 
 import (
+	"bufio"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime/debug"
 	"strings"
-	"time"
 
 	"github.com/SMerrony/dgemug/aosvs"
 	"github.com/SMerrony/dgemug/logging"
@@ -44,16 +45,56 @@ import (
 
 // program options - Change arg slicing in main if these are changed
 var (
-	argsFlag        = flag.String("args", "", "arguments to pass to program (surround multiple args with double-quotes)")
+	argsFlag        = flag.String("args", "", "arguments and /switches to pass to program, parsed as by the CLI")
+	cliFlag         = flag.Bool("cli", false, "run the pseudo-CLI on every console connection instead of a program")
+	configFlag      = flag.String("config", "", "file of options, one 'name value' per line, the command line overrides it")
 	consoleAddrFlag = flag.String("consoleaddr", "localhost:10001", "network interface/port for @CONSOLE for 1st process, others will be assigned sequentially")
+	consolesFlag    = flag.Int("consoles", 1, "number of console ports, starting at the -consoleaddr port")
+	controlAddrFlag = flag.String("controladdr", "", "network interface/port for the control channel (default: none)")
+	debugFlag       = flag.Bool("debug", false, "debug logging, SLOWS execution dramatically")
+	loginFlag       = flag.Bool("login", false, "prompt for a username and run the program for every connection to a console port")
+	maxInstrsFlag   = flag.Uint64("maxinstrs", 0, "abort a process after this many instructions (default: no limit)")
+	maxMemFlag      = flag.Int("maxmem", 0, "maximum unshared memory pages of a process (default: no limit)")
+	maxTimeFlag     = flag.Duration("maxtime", 0, "abort a process after this long, eg. 10m (default: no limit)")
 	prFlag          = flag.String("pr", "", "program to run at startup")
+	rootFlag        = flag.String("root", "", "host directory which is the AOS/VS root directory (default: that holding the -pr program, or the current one)")
+	usersFlag       = flag.String("users", "", "file of user profiles, only the users in it may log in (default: anyone, without privileges)")
 )
 
 func main() {
+	flag.Parse()
+	if *configFlag != "" {
+		if err := readConfig(*configFlag); err != nil {
+			log.Println("ERROR: ", err.Error())
+			os.Exit(1)
+		}
+	}
+	if *prFlag == "" && !*cliFlag {
+		log.Println("ERROR: Please supply an initial PR file to run")
+		os.Exit(1)
+	}
 
-	debugLogging := true // SLOWS execution dramatically
+	if *usersFlag != "" {
+		if err := aosvs.LoadUserProfiles(*usersFlag); err != nil {
+			log.Println("ERROR: ", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	memory.MemInit()
+	mvcpu.InstructionsInit()
+	aosvs.StartAgent() // start the pseudo-Agent which will serialise syscalls in the process's tasks
+	aosvs.SetDebugLogging(*debugFlag)
+	aosvs.SetRunLimits(aosvs.RunLimitsT{MaxInstrs: *maxInstrsFlag, MaxTime: *maxTimeFlag, MaxPages: *maxMemFlag})
+	handleSignals()
+	if *controlAddrFlag != "" {
+		go serveControl(*controlAddrFlag)
+	}
+
+	if *consolesFlag > 1 || *loginFlag || *cliFlag {
+		serveConsoles()
+	}
 
-	flag.Parse()
 	log.Printf("INFO: Waiting for terminal connection to %s\n", *consoleAddrFlag)
 	l, err := net.Listen("tcp", *consoleAddrFlag)
 	if err != nil {
@@ -62,11 +103,12 @@ func main() {
 	}
 	defer l.Close()
 
-	conn, err := l.Accept()
+	rawConn, err := l.Accept()
 	if err != nil {
 		log.Println("ERROR: Could not accept on @CONSOLE port: ", err.Error())
 		os.Exit(1)
 	}
+	conn := aosvs.NewTelnetConsole(rawConn)
 	conn.Write([]byte("\n *** Welcome to the VSemuG AOS/VS Emulator ***" + "\n"))
 	defer func() {
 		if r := recover(); r != nil {
@@ -75,47 +117,75 @@ func main() {
 		}
 	}()
 
-	if *prFlag == "" {
-		exitNicely(conn, "Please supply an initial PR file to run"+"\n")
+	ppd, err := aosvs.CreateProcess(programArgs(), virtualRoot(), *prFlag, 7, conn, "", aosvs.DebugLogging())
+	if err != nil {
+		exitNicely(conn, err.Error())
 	}
 
-	// DEBUGGING ONLY... (exits in x minutes)
-	go stopper(5, conn)
+	ppd.ActiveTasksWg.Wait()
 
-	memory.MemInit()
-	mvcpu.InstructionsInit()
+	exitNicely(conn, "")
+}
+
+// programArgs returns the invocation arguments of the -pr program
+func programArgs() []string {
 	args := make([]string, 1)
 	// Stripping path as slashes will confuse AOS/VS argument parsing
-	// We are taking the virtual root from the path of the PR file for now
 	args[0] = filepath.Base(*prFlag)
-	vRoot := filepath.Dir(*prFlag)
-	if *argsFlag != "" {
-		args = append(args, strings.Fields(*argsFlag)...)
+	// leading switches belong to the program name, as they would on a CLI command
+	for _, arg := range aosvs.SplitCLIArgs(*argsFlag) {
+		if len(args) == 1 && strings.HasPrefix(arg, "/") {
+			args[0] += arg
+		} else {
+			args = append(args, arg)
+		}
 	}
+	return args
+}
 
-	agentChan := aosvs.StartAgent(conn) // start the pseudo-Agent which will serialise syscalls in the process's tasks
-
-	err = aosvs.CreateProcess(args, vRoot, *prFlag, 7, conn, agentChan, debugLogging) // TODO - Eventually this should be a call to ?PROC
-	if err != nil {
-		exitNicely(conn, err.Error())
+func virtualRoot() string {
+	if *rootFlag != "" {
+		return *rootFlag
 	}
-
-	ppd := aosvs.PerProcessData[5]
-	ppd.ActiveTasksWg.Wait()
-
-	exitNicely(conn, "")
+	return filepath.Dir(*prFlag)
 }
 
 func exitNicely(con net.Conn, msg string) {
 	con.Write([]byte(msg))
 	con.Write([]byte("\n *** Exiting Emulator ***\n"))
 	log.Println(msg)
-	logging.DebugLogsDump("logs/")
+	if aosvs.DebugLogging() {
+		logging.DebugLogsDump("logs/")
+	}
 	os.Exit(1)
 }
 
-// stopper will panic 5 minutes after it is started - for desparated debugging only!
-func stopper(mins int, con net.Conn) {
-	time.Sleep(time.Minute * time.Duration(mins))
-	exitNicely(con, "STOPPING: Stopper func has timed-out")
+// readConfig sets the options in a file which were not given on the command line
+func readConfig(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		name, value := strings.TrimPrefix(fields[0], "-"), "true"
+		if len(fields) == 2 {
+			value = strings.TrimSpace(fields[1])
+		}
+		if name == "config" || given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s line %d: %v", filename, lineNo, err)
+		}
+	}
+	return scanner.Err()
 }
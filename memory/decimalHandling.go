@@ -68,8 +68,8 @@ func DecIntToInt(decType int, dec string) (i int) {
 }
 
 // ReadDec returns a string of the Decimal value pointed to by the given byte address
-func ReadDec(ba dg.PhysAddrT, size int) (dec string) {
-	bytes := ReadNBytes(ba, size)
+func (as *AddrSpaceT) ReadDec(ba dg.PhysAddrT, size int) (dec string) {
+	bytes := as.ReadNBytes(ba, size)
 	dec = strings.TrimSpace(string(bytes))
 	return dec
 }
@@ -34,25 +34,35 @@ const (
 	NsfaLoc = 043
 )
 
-// NsPush - PUSH a word onto the Narrow Stack
+// NsPush - PUSH a word onto the Narrow Stack of the default address space
 func NsPush(seg dg.PhysAddrT, data dg.WordT, debugging bool) {
+	defaultAS.NsPush(seg, data, debugging)
+}
+
+// NsPop - POP a word off the Narrow Stack of the default address space
+func NsPop(seg dg.PhysAddrT, debugging bool) dg.WordT {
+	return defaultAS.NsPop(seg, debugging)
+}
+
+// NsPush - PUSH a word onto the Narrow Stack
+func (as *AddrSpaceT) NsPush(seg dg.PhysAddrT, data dg.WordT, debugging bool) {
 	// TODO overflow/underflow handling - either here or in instruction?
 
-	newNsp := ReadWord(NspLoc|seg) + 1
-	WriteWord(NspLoc|seg, newNsp)
-	WriteWord(dg.PhysAddrT(newNsp)|seg, data)
+	newNsp := as.ReadWord(NspLoc|seg) + 1
+	as.WriteWord(NspLoc|seg, newNsp)
+	as.WriteWord(dg.PhysAddrT(newNsp)|seg, data)
 	if debugging {
 		logging.DebugPrint(logging.DebugLog, "... NsPush pushed %#o onto the Narrow Stack at location: %#o\n", data, newNsp)
 	}
 }
 
 // NsPop - POP a word off the Narrow Stack
-func NsPop(seg dg.PhysAddrT, debugging bool) dg.WordT {
+func (as *AddrSpaceT) NsPop(seg dg.PhysAddrT, debugging bool) dg.WordT {
 	// TODO segment handling
 	// TODO overflow/underflow handling - either here or in instruction?
-	oldNSP := ReadWord(NspLoc | seg)
-	data := ReadWord(dg.PhysAddrT(oldNSP) | seg)
-	WriteWord(NspLoc|seg, oldNSP-1)
+	oldNSP := as.ReadWord(NspLoc | seg)
+	data := as.ReadWord(dg.PhysAddrT(oldNSP) | seg)
+	as.WriteWord(NspLoc|seg, oldNSP-1)
 	if debugging {
 		logging.DebugPrint(logging.DebugLog, "... NsPop  popped %#o off  the Narrow Stack at location: %#o\n", data, oldNSP)
 	}
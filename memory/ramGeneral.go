@@ -23,18 +23,33 @@ package memory
 
 import (
 	"bytes"
+	"fmt"
 
 	"github.com/SMerrony/dgemug/dg"
 )
 
+// ProtectionFaultT is the panic value raised when unmapped memory is accessed,
+// the CPU recovers it and reports a protection fault instead of crashing
+type ProtectionFaultT struct {
+	Addr  dg.PhysAddrT
+	Write bool
+}
+
+func (pf ProtectionFaultT) Error() string {
+	if pf.Write {
+		return fmt.Sprintf("write to unmapped address %#o", pf.Addr)
+	}
+	return fmt.Sprintf("read from unmapped address %#o", pf.Addr)
+}
+
 // GetSegment - return the segment number for the supplied address
 func GetSegment(addr dg.PhysAddrT) int {
 	return int((addr & 0x70000000) >> 28)
 }
 
-// ReadByte - read a byte from memory using word address and low-byte flag (true => lower (rightmost) byte)
-func ReadByte(wordAddr dg.PhysAddrT, loByte bool) dg.ByteT {
-	wd := ReadWord(wordAddr)
+// ReadByteWA - read a byte from memory using word address and low-byte flag (true => lower (rightmost) byte)
+func (as *AddrSpaceT) ReadByteWA(wordAddr dg.PhysAddrT, loByte bool) dg.ByteT {
+	wd := as.ReadWord(wordAddr)
 	if !loByte {
 		wd >>= 8
 	}
@@ -42,12 +57,12 @@ func ReadByte(wordAddr dg.PhysAddrT, loByte bool) dg.ByteT {
 }
 
 // ReadNBytes loads n bytes into a slice which is returned - no ring-forcing is performed
-func ReadNBytes(ba dg.PhysAddrT, n int) []byte {
+func (as *AddrSpaceT) ReadNBytes(ba dg.PhysAddrT, n int) []byte {
 	buff := bytes.NewBufferString("")
 	lobyte := (ba & 0x0001) == 1
 	wdAddr := dg.PhysAddrT(ba >> 1)
 	for b := 0; b < n; b++ {
-		c := ReadByte(wdAddr, lobyte)
+		c := as.ReadByteWA(wdAddr, lobyte)
 		buff.WriteByte(byte(c))
 		if lobyte {
 			wdAddr++
@@ -58,7 +73,7 @@ func ReadNBytes(ba dg.PhysAddrT, n int) []byte {
 }
 
 // ReadByteEclipseBA - read a byte - special version for Eclipse Byte-Addressing
-func ReadByteEclipseBA(pcAddr dg.PhysAddrT, byteAddr16 dg.WordT) dg.ByteT {
+func (as *AddrSpaceT) ReadByteEclipseBA(pcAddr dg.PhysAddrT, byteAddr16 dg.WordT) dg.ByteT {
 	var (
 		hiLo bool
 		addr dg.PhysAddrT
@@ -66,37 +81,73 @@ func ReadByteEclipseBA(pcAddr dg.PhysAddrT, byteAddr16 dg.WordT) dg.ByteT {
 	hiLo = TestWbit(byteAddr16, 15) // determine which byte to get
 	addr = dg.PhysAddrT(byteAddr16) >> 1
 	addr |= (pcAddr & 0x7000_0000)
-	return ReadByte(addr, hiLo)
+	return as.ReadByteWA(addr, hiLo)
 }
 
-// WriteByte takes a normal word addr, low-byte flag and datum byte
-
-func WriteByte(wordAddr dg.PhysAddrT, loByte bool, b dg.ByteT) {
-	wd := ReadWord(wordAddr)
+// WriteByteWA takes a normal word addr, low-byte flag and datum byte
+func (as *AddrSpaceT) WriteByteWA(wordAddr dg.PhysAddrT, loByte bool, b dg.ByteT) {
+	wd := as.ReadWord(wordAddr)
 	if loByte {
 		wd = (wd & 0xff00) | dg.WordT(b)
 	} else {
 		wd = dg.WordT(b)<<8 | (wd & 0x00ff)
 	}
-	WriteWord(wordAddr, wd)
+	as.WriteWord(wordAddr, wd)
 }
 
 // WriteByteBA writes a byte to a standard Byte Addressed location
-func WriteByteBA(byteAddr dg.DwordT, b dg.ByteT) {
+func (as *AddrSpaceT) WriteByteBA(byteAddr dg.DwordT, b dg.ByteT) {
 	loByte := (byteAddr & 0x01) == 1
-	WriteByte(dg.PhysAddrT(byteAddr>>1), loByte, b)
+	as.WriteByteWA(dg.PhysAddrT(byteAddr>>1), loByte, b)
 }
 
 // ReadDWord does what it says on the tin
-func ReadDWord(addr dg.PhysAddrT) dg.DwordT {
+func (as *AddrSpaceT) ReadDWord(addr dg.PhysAddrT) dg.DwordT {
 	var hiWd, loWd dg.WordT
-	hiWd = ReadWord(addr)
-	loWd = ReadWord(addr + 1)
+	hiWd = as.ReadWord(addr)
+	loWd = as.ReadWord(addr + 1)
 	return DwordFromTwoWords(hiWd, loWd)
 }
 
+// WriteDWord writes a doubleword into memory at the given physical address
+func (as *AddrSpaceT) WriteDWord(wordAddr dg.PhysAddrT, dwd dg.DwordT) {
+	as.WriteWord(wordAddr, DwordGetUpperWord(dwd))
+	as.WriteWord(wordAddr+1, DwordGetLowerWord(dwd))
+}
+
+// The following operate on the default address space
+
+// ReadByte - read a byte from memory using word address and low-byte flag (true => lower (rightmost) byte)
+func ReadByte(wordAddr dg.PhysAddrT, loByte bool) dg.ByteT {
+	return defaultAS.ReadByteWA(wordAddr, loByte)
+}
+
+// ReadNBytes loads n bytes into a slice which is returned - no ring-forcing is performed
+func ReadNBytes(ba dg.PhysAddrT, n int) []byte {
+	return defaultAS.ReadNBytes(ba, n)
+}
+
+// ReadByteEclipseBA - read a byte - special version for Eclipse Byte-Addressing
+func ReadByteEclipseBA(pcAddr dg.PhysAddrT, byteAddr16 dg.WordT) dg.ByteT {
+	return defaultAS.ReadByteEclipseBA(pcAddr, byteAddr16)
+}
+
+// WriteByte takes a normal word addr, low-byte flag and datum byte
+func WriteByte(wordAddr dg.PhysAddrT, loByte bool, b dg.ByteT) {
+	defaultAS.WriteByteWA(wordAddr, loByte, b)
+}
+
+// WriteByteBA writes a byte to a standard Byte Addressed location
+func WriteByteBA(byteAddr dg.DwordT, b dg.ByteT) {
+	defaultAS.WriteByteBA(byteAddr, b)
+}
+
+// ReadDWord does what it says on the tin
+func ReadDWord(addr dg.PhysAddrT) dg.DwordT {
+	return defaultAS.ReadDWord(addr)
+}
+
 // WriteDWord writes a doubleword into memory at the given physical address
 func WriteDWord(wordAddr dg.PhysAddrT, dwd dg.DwordT) {
-	WriteWord(wordAddr, DwordGetUpperWord(dwd))
-	WriteWord(wordAddr+1, DwordGetLowerWord(dwd))
+	defaultAS.WriteDWord(wordAddr, dwd)
 }
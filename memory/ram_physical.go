@@ -41,6 +41,36 @@ var (
 	memSizeWords dg.PhysAddrT // just for efficiency
 )
 
+// AddrSpaceT is the view of memory used by a CPU, a physical machine has only the one
+type AddrSpaceT struct{}
+
+var defaultAS *AddrSpaceT
+
+// DefaultAddrSpace returns the address space used by the package-level functions
+func DefaultAddrSpace() *AddrSpaceT {
+	return defaultAS
+}
+
+// ReadWord returns the DG Word at the specified physical address
+func (as *AddrSpaceT) ReadWord(wordAddr dg.PhysAddrT) dg.WordT {
+	return ReadWord(wordAddr)
+}
+
+// ReadWordTrap returns the DG Word at the specified physical address
+func (as *AddrSpaceT) ReadWordTrap(wordAddr dg.PhysAddrT) (dg.WordT, bool) {
+	return ReadWordTrap(wordAddr)
+}
+
+// WriteWord writes a DG Word at the specified physical address
+func (as *AddrSpaceT) WriteWord(wordAddr dg.PhysAddrT, datum dg.WordT) {
+	WriteWord(wordAddr, datum)
+}
+
+// ReadDwordTrap returns the doubleword at the given physical address
+func (as *AddrSpaceT) ReadDwordTrap(wordAddr dg.PhysAddrT) (dg.DwordT, bool) {
+	return ReadDwordTrap(wordAddr)
+}
+
 // MemInit should be called at machine start
 func MemInit(wordSize int, doLog bool) {
 	ram = make([]dg.WordT, wordSize)
@@ -33,45 +33,79 @@ import (
 const (
 	memPageSizeWords = 1024
 	ring7page0       = 0x7000_0000 >> 10
+	numRings         = 8
+	pagesPerRing     = 0x1000_0000 >> 10
+	noSharedPage     = 0x7fff_ffff // dummy high value for firstSharedPage
 )
 
 type pageT struct {
-	words [memPageSizeWords]dg.WordT
+	words  [memPageSizeWords]dg.WordT
+	shared bool         // may be mapped into several address spaces, guarded by sharedMu
+	io     *filePageIOT // non-nil for a page of a disk file
 }
 
-var (
-	virtualRam       map[int]pageT
-	virtualRamMu     sync.RWMutex
-	lastUnsharedPage int = -1
-	firstSharedPage  int = 0x7fff_ffff // dummy high value
+// ringT holds the page-allocation bookkeeping for one ring (segment) of an address space
+type ringT struct {
+	lastUnsharedPage int
+	firstSharedPage  int
 	numSharedPages   int
-	// lastSharedPage   int
+	unsharedBase     int // highest unshared page that may not be released
+}
+
+// AddrSpaceT is the virtual memory of a single process
+type AddrSpaceT struct {
+	mu         sync.RWMutex
+	pages      map[int]*pageT
+	rings      [numRings]ringT
+	sharedKeys []string             // shared images attached via MapSharedSlice
+	filePages  map[int]filePageKeyT // pages mapped via MapFilePage
+	reserved   map[int]bool         // empty shared pages mapped via ReserveSharedPage
+}
+
+// sharedImageT is a set of pages shared by every process which maps the same program file
+type sharedImageT struct {
+	pages map[int]*pageT
+	users int
+}
+
+var (
+	defaultAS    *AddrSpaceT
+	sharedMu     sync.RWMutex // guards the contents of every shared page
+	sharedImages = map[string]*sharedImageT{}
+	sharedImgMu  sync.Mutex
 )
 
-func IsPageMapped(page int) (locked bool) {
-	virtualRamMu.RLock()
-	_, locked = virtualRam[page]
-	virtualRamMu.RUnlock()
-	return locked
+// NewAddrSpace creates an address space with only user page 0 mapped
+func NewAddrSpace() *AddrSpaceT {
+	as := &AddrSpaceT{pages: make(map[int]*pageT), filePages: make(map[int]filePageKeyT), reserved: make(map[int]bool)}
+	for r := range as.rings {
+		as.rings[r] = ringT{lastUnsharedPage: -1, firstSharedPage: noSharedPage, unsharedBase: -1}
+	}
+	as.MapPage(ring7page0, false)
+	return as
+}
+
+// DefaultAddrSpace returns the address space used by the package-level functions
+func DefaultAddrSpace() *AddrSpaceT {
+	return defaultAS
+}
+
+// ringOf returns the bookkeeping for the ring containing the given page, as.mu must be held
+func (as *AddrSpaceT) ringOf(page int) *ringT {
+	return &as.rings[(page/pagesPerRing)%numRings]
+}
+
+// IsPageMapped reports whether the page is mapped in this address space
+func (as *AddrSpaceT) IsPageMapped(page int) (mapped bool) {
+	as.mu.RLock()
+	_, mapped = as.pages[page]
+	as.mu.RUnlock()
+	return mapped
 }
 
 // MapPage maps (allocates) a 1kW page of virtual memory for the process
-func MapPage(page int, shared bool) {
-	if IsPageMapped(page) {
-		log.Panicf("ERROR: Attempt to map already-mapped memory page %#o", page)
-	}
-	virtualRamMu.Lock()
-	var emptyPage pageT
-	virtualRam[page] = emptyPage
-	if !shared {
-		lastUnsharedPage = page
-	} else {
-		numSharedPages++
-	}
-	if shared && (page < firstSharedPage) {
-		firstSharedPage = page
-	}
-	virtualRamMu.Unlock()
+func (as *AddrSpaceT) MapPage(page int, shared bool) {
+	as.mapPage(page, &pageT{shared: shared})
 	if shared {
 		log.Printf("DEBUG: Mapped shared page %#x for %#x", page, page<<10)
 	} else {
@@ -79,153 +113,364 @@ func MapPage(page int, shared bool) {
 	}
 }
 
-// GetFirstSharedPage is a getter for the lowest shared page currently mapped
-func GetFirstSharedPage() dg.DwordT {
-	virtualRamMu.RLock()
-	p := firstSharedPage
-	virtualRamMu.RUnlock()
+// ReserveSharedPage maps an empty shared page, as ?SSHPT does, which MapFilePage may later replace
+func (as *AddrSpaceT) ReserveSharedPage(page int) {
+	as.MapPage(page, true)
+	as.mu.Lock()
+	as.reserved[page] = true
+	as.mu.Unlock()
+}
+
+func (as *AddrSpaceT) mapPage(page int, pg *pageT) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if _, mapped := as.pages[page]; mapped {
+		log.Panicf("ERROR: Attempt to map already-mapped memory page %#o", page)
+	}
+	as.pages[page] = pg
+	r := as.ringOf(page)
+	if !pg.shared {
+		r.lastUnsharedPage = page
+	} else {
+		r.numSharedPages++
+		if page < r.firstSharedPage {
+			r.firstSharedPage = page
+		}
+	}
+}
+
+// GetFirstSharedPage is a getter for the lowest shared page currently mapped in the given ring
+func (as *AddrSpaceT) GetFirstSharedPage(ring int) dg.DwordT {
+	as.mu.RLock()
+	p := as.rings[ring].firstSharedPage
+	as.mu.RUnlock()
 	return dg.DwordT(p)
 }
 
-// GetLastSharedPage calculates the last shared page mapped
-func GetLastSharedPage() dg.DwordT {
-	virtualRamMu.RLock()
-	lup := firstSharedPage + numSharedPages
-	virtualRamMu.RUnlock()
+// GetLastSharedPage calculates the last shared page mapped in the given ring
+func (as *AddrSpaceT) GetLastSharedPage(ring int) dg.DwordT {
+	as.mu.RLock()
+	lup := as.rings[ring].firstSharedPage + as.rings[ring].numSharedPages - 1
+	as.mu.RUnlock()
 	return dg.DwordT(lup)
 }
 
-// AddUnsharedPage appends an unshared page to virtual memory
-func AddUnsharedPage() int {
-	virtualRamMu.RLock()
-	nextPage := lastUnsharedPage + 1
-	virtualRamMu.RUnlock()
-	MapPage(nextPage, false)
+// AddUnsharedPage appends an unshared page to the given ring
+func (as *AddrSpaceT) AddUnsharedPage(ring int) int {
+	as.mu.RLock()
+	nextPage := as.rings[ring].lastUnsharedPage + 1
+	as.mu.RUnlock()
+	if nextPage == 0 {
+		nextPage = ring * pagesPerRing
+	}
+	as.MapPage(nextPage, false)
 	return nextPage
 }
 
-// GetLastUnsharedPage is a getter for the highest unshared page currently mapped
-func GetLastUnsharedPage() dg.DwordT {
-	virtualRamMu.RLock()
-	p := lastUnsharedPage
-	virtualRamMu.RUnlock()
+// FixUnsharedBase prevents the unshared pages currently mapped in the given ring,
+// i.e. those of the program itself, from being released by ReleaseUnsharedPages
+func (as *AddrSpaceT) FixUnsharedBase(ring int) {
+	as.mu.Lock()
+	as.rings[ring].unsharedBase = as.rings[ring].lastUnsharedPage
+	as.mu.Unlock()
+}
+
+// ReleaseUnsharedPages unmaps count pages from the top of the given ring's unshared area,
+// nothing is released if that would include any below the base
+func (as *AddrSpaceT) ReleaseUnsharedPages(ring int, count int) (ok bool) {
+	as.mu.Lock()
+	r := &as.rings[ring]
+	if r.lastUnsharedPage-count < r.unsharedBase {
+		as.mu.Unlock()
+		return false
+	}
+	for ; count > 0; count-- {
+		delete(as.pages, r.lastUnsharedPage)
+		r.lastUnsharedPage--
+	}
+	lastUnshared := r.lastUnsharedPage
+	as.mu.Unlock()
+	log.Printf("DEBUG: Last unshared page is now: %#x (%#o)\n", lastUnshared, lastUnshared)
+	return true
+}
+
+// GetLastUnsharedPage is a getter for the highest unshared page currently mapped in the given ring
+func (as *AddrSpaceT) GetLastUnsharedPage(ring int) dg.DwordT {
+	as.mu.RLock()
+	p := as.rings[ring].lastUnsharedPage
+	as.mu.RUnlock()
 	return dg.DwordT(p)
 }
 
-// GetNumSharedPages is a getter for the number of shared pages currently mapped
-func GetNumSharedPages() int {
-	virtualRamMu.RLock()
-	p := numSharedPages
-	virtualRamMu.RUnlock()
+// GetNumSharedPages is a getter for the number of shared pages currently mapped in the given ring
+func (as *AddrSpaceT) GetNumSharedPages(ring int) int {
+	as.mu.RLock()
+	p := as.rings[ring].numSharedPages
+	as.mu.RUnlock()
 	return p
 }
 
-func isAddrMapped(addr dg.PhysAddrT) (mapped bool) {
-	virtualRamMu.RLock()
-	_, mapped = virtualRam[int(addr>>10)]
-	virtualRamMu.RUnlock()
-	return mapped
+func (as *AddrSpaceT) isAddrMapped(addr dg.PhysAddrT) bool {
+	return as.IsPageMapped(int(addr >> 10))
 }
 
 // MapSlice maps (copies) the provided slice to virtual memory starting at the given address
-func MapSlice(addr dg.PhysAddrT, wds []dg.WordT, shared bool) {
+func (as *AddrSpaceT) MapSlice(addr dg.PhysAddrT, wds []dg.WordT, shared bool) {
 	for offset, word := range wds {
 		loc := addr + dg.PhysAddrT(offset)
 		// check each time we hit a page boundary to see if it's mapped
-		if ((loc & 0x3ff) == 0) && !isAddrMapped(loc) {
-			MapPage(int(loc>>10), shared)
+		if ((loc & 0x3ff) == 0) && !as.isAddrMapped(loc) {
+			as.MapPage(int(loc>>10), shared)
+		}
+		as.WriteWord(loc, word)
+	}
+}
+
+// MapSharedSlice maps the shared pages identified by key, the first address space to do so
+// loads them from wds, later ones get the very same pages
+func (as *AddrSpaceT) MapSharedSlice(key string, addr dg.PhysAddrT, wds []dg.WordT) {
+	sharedImgMu.Lock()
+	defer sharedImgMu.Unlock()
+	img, found := sharedImages[key]
+	if !found {
+		img = &sharedImageT{pages: map[int]*pageT{}}
+		for offset := 0; offset < len(wds); offset += memPageSizeWords {
+			pg := &pageT{shared: true}
+			copy(pg.words[:], wds[offset:])
+			img.pages[int((addr+dg.PhysAddrT(offset))>>10)] = pg
+		}
+		sharedImages[key] = img
+		log.Printf("DEBUG: Loaded %d. shared pages for %s", len(img.pages), key)
+	}
+	for page, pg := range img.pages {
+		as.mapPage(page, pg)
+	}
+	img.users++
+	as.mu.Lock()
+	as.sharedKeys = append(as.sharedKeys, key)
+	as.mu.Unlock()
+}
+
+// Release detaches the address space from any shared images and file pages, which are
+// discarded once no address space uses them.  The pages remain readable by any straggler.
+func (as *AddrSpaceT) Release() {
+	as.releaseFilePages()
+	as.mu.Lock()
+	keys := as.sharedKeys
+	as.sharedKeys = nil
+	as.mu.Unlock()
+	sharedImgMu.Lock()
+	for _, key := range keys {
+		if img := sharedImages[key]; img != nil {
+			img.users--
+			if img.users == 0 {
+				delete(sharedImages, key)
+			}
+		}
+	}
+	sharedImgMu.Unlock()
+}
+
+// findFirstSharedPage recalculates the ring's lowest shared page after unmapping the page
+// that was the lowest, the caller must hold the lock
+func (as *AddrSpaceT) findFirstSharedPage(r *ringT, unmapped int) {
+	r.firstSharedPage = noSharedPage
+	ringBase := unmapped - unmapped%pagesPerRing
+	for page, pg := range as.pages {
+		if pg.shared && page >= ringBase && page < ringBase+pagesPerRing && page < r.firstSharedPage {
+			r.firstSharedPage = page
 		}
-		WriteWord(loc, word)
 	}
 }
 
 // UnmapPage unmaps (deallocates) a 1kW page of virtual memory from the process
-func UnmapPage(page int, shared bool) {
-	virtualRamMu.Lock()
-	if _, mapped := virtualRam[page]; !mapped {
+func (as *AddrSpaceT) UnmapPage(page int, shared bool) {
+	as.mu.Lock()
+	if _, mapped := as.pages[page]; !mapped {
 		log.Panicf("ERROR: Attempt to unmap a non-mapped memory page #%x (%#o)", page, page)
 	}
-	delete(virtualRam, page)
+	delete(as.pages, page)
+	delete(as.reserved, page)
+	r := as.ringOf(page)
 	if !shared {
-		lastUnsharedPage--
+		r.lastUnsharedPage--
+	} else {
+		r.numSharedPages--
+		if page == r.firstSharedPage {
+			as.findFirstSharedPage(r, page)
+		}
 	}
-	virtualRamMu.Unlock()
+	lastUnshared := r.lastUnsharedPage
+	as.mu.Unlock()
 	log.Printf("DEBUG: Unpapped page %#x", page)
 	if !shared {
-		log.Printf("DEBUG: ...Last unshared page is now: %#x (%#o)\n", lastUnsharedPage, lastUnsharedPage)
+		log.Printf("DEBUG: ...Last unshared page is now: %#x (%#o)\n", lastUnshared, lastUnshared)
 	}
 }
 
-// MemInit must be called when the virtual machine is started
-func MemInit() {
-	virtualRam = make(map[int]pageT)
-	// always map user page 0
-	MapPage(ring7page0, false)
-}
-
 // ReadBytes - read specified # of bytes from 32-bit BA into slice
-func ReadBytes(ba32 dg.DwordT, pc dg.PhysAddrT, num int) (res []byte) {
+func (as *AddrSpaceT) ReadBytes(ba32 dg.DwordT, pc dg.PhysAddrT, num int) (res []byte) {
 	var c dg.DwordT
 	for c = 0; c < dg.DwordT(num); c++ {
 		if (ba32+c)&0x01 == 1 {
-			res = append(res, byte(ReadByte(dg.PhysAddrT((ba32+c)>>1)|(pc&0x7000_0000), true)))
+			res = append(res, byte(as.ReadByteWA(dg.PhysAddrT((ba32+c)>>1)|(pc&0x7000_0000), true)))
 		} else {
-			res = append(res, byte(ReadByte(dg.PhysAddrT((ba32+c)>>1)|(pc&0x7000_0000), false)))
+			res = append(res, byte(as.ReadByteWA(dg.PhysAddrT((ba32+c)>>1)|(pc&0x7000_0000), false)))
 		}
 	}
 	return res
 }
 
 // WriteBytesBA copies a byte array to the specified address
-func WriteBytesBA(b []byte, byteAddr dg.DwordT) {
+func (as *AddrSpaceT) WriteBytesBA(b []byte, byteAddr dg.DwordT) {
 	for c := 0; c < len(b); c++ {
-		WriteByteBA(byteAddr+dg.DwordT(c), dg.ByteT(b[c]))
+		as.WriteByteBA(byteAddr+dg.DwordT(c), dg.ByteT(b[c]))
 	}
 }
 
 // WriteStringBA copies a string to the specified address
-func WriteStringBA(s string, byteAddr dg.DwordT) {
+func (as *AddrSpaceT) WriteStringBA(s string, byteAddr dg.DwordT) {
 	for c := 0; c < len(s); c++ {
-		WriteByteBA(byteAddr+dg.DwordT(c), dg.ByteT(s[c]))
+		as.WriteByteBA(byteAddr+dg.DwordT(c), dg.ByteT(s[c]))
 	}
 }
 
 // ReadWord reads a single 16-bit word from the specified address
-func ReadWord(addr dg.PhysAddrT) (wd dg.WordT) {
-	virtualRamMu.RLock()
-	page, found := virtualRam[int(addr>>10)]
+func (as *AddrSpaceT) ReadWord(addr dg.PhysAddrT) (wd dg.WordT) {
+	as.mu.RLock()
+	page, found := as.pages[int(addr>>10)]
 	if !found {
-		log.Panicf("ERROR: Attempt to read from unmapped page %#x at address: %#x (%#o)", addr>>10, addr, addr)
+		as.mu.RUnlock()
+		log.Printf("ERROR: Attempt to read from unmapped page %#x at address: %#x (%#o)", addr>>10, addr, addr)
+		panic(ProtectionFaultT{Addr: addr})
 	}
-	wd = page.words[int(addr&0x3ff)]
-	virtualRamMu.RUnlock()
+	page.demand()
+	if page.shared {
+		sharedMu.RLock()
+		wd = page.words[int(addr&0x3ff)]
+		sharedMu.RUnlock()
+	} else {
+		wd = page.words[int(addr&0x3ff)]
+	}
+	as.mu.RUnlock()
 
 	return wd
 }
 
-func ReadWordTrap(addr dg.PhysAddrT) (dg.WordT, bool) {
-	if !isAddrMapped(addr) {
+func (as *AddrSpaceT) ReadWordTrap(addr dg.PhysAddrT) (dg.WordT, bool) {
+	if !as.isAddrMapped(addr) {
 		log.Printf("ERROR: Attempt to read unmapped word at %#x\n", addr)
 		return 0, false
 	}
-	return ReadWord(addr), true
+	return as.ReadWord(addr), true
 }
 
-func WriteWord(addr dg.PhysAddrT, datum dg.WordT) {
-	virtualRamMu.Lock()
-	page, found := virtualRam[int(addr>>10)]
+func (as *AddrSpaceT) WriteWord(addr dg.PhysAddrT, datum dg.WordT) {
+	as.mu.Lock()
+	page, found := as.pages[int(addr>>10)]
 	if !found {
-		log.Panicf("ERROR: Attempt to write to unmapped page %#x for addr %#x (%#o)", addr>>10, addr, addr)
+		as.mu.Unlock()
+		log.Printf("ERROR: Attempt to write to unmapped page %#x for addr %#x (%#o)", addr>>10, addr, addr)
+		panic(ProtectionFaultT{Addr: addr, Write: true})
+	}
+	page.demand()
+	if page.shared {
+		sharedMu.Lock()
+		page.words[int(addr&0x3ff)] = datum
+		sharedMu.Unlock()
+	} else {
+		page.words[int(addr&0x3ff)] = datum
 	}
-	page.words[int(addr&0x3ff)] = datum
-	virtualRam[int(addr>>10)] = page
-	virtualRamMu.Unlock()
+	as.mu.Unlock()
 }
 
-func ReadDwordTrap(addr dg.PhysAddrT) (dg.DwordT, bool) {
-	if !isAddrMapped(addr) {
+func (as *AddrSpaceT) ReadDwordTrap(addr dg.PhysAddrT) (dg.DwordT, bool) {
+	if !as.isAddrMapped(addr) {
 		log.Printf("ERROR: Attempt to read unmapped doubleword at %#x\n", addr)
 		return 0, false
 	}
-	return ReadDWord(addr), true
+	return as.ReadDWord(addr), true
+}
+
+// The following operate on the default address space, user ring 7 where a ring is implied
+
+// MemInit must be called when the virtual machine is started
+func MemInit() {
+	defaultAS = NewAddrSpace()
+}
+
+func IsPageMapped(page int) bool {
+	return defaultAS.IsPageMapped(page)
+}
+
+// MapPage maps (allocates) a 1kW page of virtual memory for the process
+func MapPage(page int, shared bool) {
+	defaultAS.MapPage(page, shared)
+}
+
+// GetFirstSharedPage is a getter for the lowest shared page currently mapped
+func GetFirstSharedPage() dg.DwordT {
+	return defaultAS.GetFirstSharedPage(7)
+}
+
+// GetLastSharedPage calculates the last shared page mapped
+func GetLastSharedPage() dg.DwordT {
+	return defaultAS.GetLastSharedPage(7)
+}
+
+// AddUnsharedPage appends an unshared page to virtual memory
+func AddUnsharedPage() int {
+	return defaultAS.AddUnsharedPage(7)
+}
+
+// GetLastUnsharedPage is a getter for the highest unshared page currently mapped
+func GetLastUnsharedPage() dg.DwordT {
+	return defaultAS.GetLastUnsharedPage(7)
+}
+
+// GetNumSharedPages is a getter for the number of shared pages currently mapped
+func GetNumSharedPages() int {
+	return defaultAS.GetNumSharedPages(7)
+}
+
+// MapSlice maps (copies) the provided slice to virtual memory starting at the given address
+func MapSlice(addr dg.PhysAddrT, wds []dg.WordT, shared bool) {
+	defaultAS.MapSlice(addr, wds, shared)
+}
+
+// UnmapPage unmaps (deallocates) a 1kW page of virtual memory from the process
+func UnmapPage(page int, shared bool) {
+	defaultAS.UnmapPage(page, shared)
+}
+
+// ReadBytes - read specified # of bytes from 32-bit BA into slice
+func ReadBytes(ba32 dg.DwordT, pc dg.PhysAddrT, num int) []byte {
+	return defaultAS.ReadBytes(ba32, pc, num)
+}
+
+// WriteBytesBA copies a byte array to the specified address
+func WriteBytesBA(b []byte, byteAddr dg.DwordT) {
+	defaultAS.WriteBytesBA(b, byteAddr)
+}
+
+// WriteStringBA copies a string to the specified address
+func WriteStringBA(s string, byteAddr dg.DwordT) {
+	defaultAS.WriteStringBA(s, byteAddr)
+}
+
+// ReadWord reads a single 16-bit word from the specified address
+func ReadWord(addr dg.PhysAddrT) dg.WordT {
+	return defaultAS.ReadWord(addr)
+}
+
+func ReadWordTrap(addr dg.PhysAddrT) (dg.WordT, bool) {
+	return defaultAS.ReadWordTrap(addr)
+}
+
+func WriteWord(addr dg.PhysAddrT, datum dg.WordT) {
+	defaultAS.WriteWord(addr, datum)
+}
+
+func ReadDwordTrap(addr dg.PhysAddrT) (dg.DwordT, bool) {
+	return defaultAS.ReadDwordTrap(addr)
 }
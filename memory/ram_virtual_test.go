@@ -65,3 +65,113 @@ func TestReadWriteDWord(t *testing.T) {
 		t.Errorf("Expected %#x, got %#x", dwd, res)
 	}
 }
+
+func TestAddrSpaceIsolation(t *testing.T) {
+	as1 := NewAddrSpace()
+	as2 := NewAddrSpace()
+	as1.WriteWord(0x7000_0001, 5)
+	as2.WriteWord(0x7000_0001, 7)
+	if wd := as1.ReadWord(0x7000_0001); wd != 5 {
+		t.Errorf("Expected 5, got %#x", wd)
+	}
+	as1.MapPage(ring7page0+1, false)
+	if as2.IsPageMapped(ring7page0 + 1) {
+		t.Error("Expected page 1 to be unmapped in the other address space")
+	}
+	if lup := as2.GetLastUnsharedPage(7); lup != ring7page0 {
+		t.Errorf("Expected last unshared page %#x, got %#x", ring7page0, lup)
+	}
+	if pg := as1.AddUnsharedPage(0); pg != 0 {
+		t.Errorf("Expected first ring 0 page to be 0, got %#x", pg)
+	}
+}
+
+func TestMapSharedSlice(t *testing.T) {
+	wds := make([]dg.WordT, 1500)
+	wds[1025] = 0x1234
+	as1 := NewAddrSpace()
+	as2 := NewAddrSpace()
+	as1.MapSharedSlice("TEST.PR", 0x7000_4000, wds)
+	as2.MapSharedSlice("TEST.PR", 0x7000_4000, wds)
+	if n := as1.GetNumSharedPages(7); n != 2 {
+		t.Errorf("Expected 2 shared pages, got %d", n)
+	}
+	if wd := as2.ReadWord(0x7000_4401); wd != 0x1234 {
+		t.Errorf("Expected 0x1234, got %#x", wd)
+	}
+	as1.WriteWord(0x7000_4002, 9)
+	if wd := as2.ReadWord(0x7000_4002); wd != 9 {
+		t.Errorf("Expected shared write to be seen, got %#x", wd)
+	}
+	as1.Release()
+	as2.Release()
+	if _, found := sharedImages["TEST.PR"]; found {
+		t.Error("Expected shared image to be discarded")
+	}
+}
+
+func TestReleaseUnsharedPages(t *testing.T) {
+	as := NewAddrSpace()
+	as.FixUnsharedBase(7)
+	as.AddUnsharedPage(7)
+	as.AddUnsharedPage(7)
+	if as.ReleaseUnsharedPages(7, 3) {
+		t.Error("Expected release of the program's own page to be refused")
+	}
+	if !as.ReleaseUnsharedPages(7, 2) {
+		t.Error("Expected release to succeed")
+	}
+	if lup := as.GetLastUnsharedPage(7); lup != ring7page0 {
+		t.Errorf("Expected last unshared page %#x, got %#x", ring7page0, lup)
+	}
+	if as.IsPageMapped(ring7page0 + 1) {
+		t.Error("Expected released page to be unmapped")
+	}
+}
+
+func TestUnmapSharedPage(t *testing.T) {
+	as := NewAddrSpace()
+	as.MapPage(ring7page0+10, true)
+	as.MapPage(ring7page0+11, true)
+	as.MapPage(ring7page0+12, true)
+	if lsp := as.GetLastSharedPage(7); lsp != ring7page0+12 {
+		t.Errorf("Expected last shared page %#x, got %#x", ring7page0+12, lsp)
+	}
+	as.UnmapPage(ring7page0+10, true)
+	if fsp := as.GetFirstSharedPage(7); fsp != ring7page0+11 {
+		t.Errorf("Expected first shared page %#x, got %#x", ring7page0+11, fsp)
+	}
+	as.UnmapPage(ring7page0+11, true)
+	as.UnmapPage(ring7page0+12, true)
+	if n := as.GetNumSharedPages(7); n != 0 {
+		t.Errorf("Expected no shared pages, got %d", n)
+	}
+	if fsp := as.GetFirstSharedPage(7); fsp != noSharedPage {
+		t.Errorf("Expected no first shared page, got %#x", fsp)
+	}
+}
+
+func TestUnmappedAccessFaults(t *testing.T) {
+	as := NewAddrSpace()
+	unmapped := dg.PhysAddrT(ring7page0+20) << 10
+	for _, write := range []bool{false, true} {
+		func() {
+			defer func() {
+				pf, isFault := recover().(ProtectionFaultT)
+				if !isFault || pf.Addr != unmapped || pf.Write != write {
+					t.Errorf("Expected protection fault at %#o, write %v, got %v", unmapped, write, pf)
+				}
+			}()
+			if write {
+				as.WriteWord(unmapped, 1)
+			} else {
+				as.ReadWord(unmapped)
+			}
+		}()
+	}
+	// the address space must still be usable after a fault
+	as.WriteWord(ring7page0<<10, 3)
+	if wd := as.ReadWord(ring7page0 << 10); wd != 3 {
+		t.Errorf("Expected 3, got %d", wd)
+	}
+}
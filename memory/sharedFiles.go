@@ -0,0 +1,178 @@
+// +build virtual !physical
+
+// SHARED PAGES OF DISK FILES, MAPPED ON DEMAND INTO VIRTUAL MEMORY
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package memory
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+// A page of a file mapped into memory is held once in a cache, however many address spaces
+// map it.  Its contents are only read from the file when it is first touched, and are written
+// back on request or when the last address space releases it.
+
+// filePageKeyT identifies a 1kW page of a disk file
+type filePageKeyT struct {
+	file string
+	page int64
+}
+
+type filePageT struct {
+	pg    *pageT
+	users int
+	store func([]dg.WordT) // writes the page back to the file, nil if no mapper may write it
+}
+
+// filePageIOT is how a page's contents are fetched on demand
+type filePageIOT struct {
+	load     func() []dg.WordT
+	loadOnce sync.Once
+	loaded   int32 // atomic
+}
+
+var (
+	filePages   = map[filePageKeyT]*filePageT{}
+	filePagesMu sync.Mutex
+)
+
+// demand fills a file page from its file the first time it is used
+func (pg *pageT) demand() {
+	if pg.io != nil {
+		pg.io.loadOnce.Do(func() {
+			copy(pg.words[:], pg.io.load())
+			atomic.StoreInt32(&pg.io.loaded, 1)
+		})
+	}
+}
+
+// MapFilePage maps page filePage of the file into the address space as a shared page, its
+// contents are loaded on first use.  Store may be nil if this mapper is not allowed to write.
+// A page reserved by ReserveSharedPage is replaced, any other mapped page is not.
+func (as *AddrSpaceT) MapFilePage(page int, file string, filePage int64, load func() []dg.WordT, store func([]dg.WordT)) (ok bool) {
+	as.mu.RLock()
+	_, mapped := as.pages[page]
+	reserved := as.reserved[page]
+	as.mu.RUnlock()
+	if mapped {
+		if !reserved {
+			return false
+		}
+		as.UnmapPage(page, true)
+	}
+	key := filePageKeyT{file, filePage}
+	filePagesMu.Lock()
+	fp, found := filePages[key]
+	if !found {
+		fp = &filePageT{pg: &pageT{shared: true, io: &filePageIOT{load: load}}}
+		filePages[key] = fp
+	}
+	fp.users++
+	if store != nil {
+		fp.store = store
+	}
+	filePagesMu.Unlock()
+	as.mapPage(page, fp.pg)
+	as.mu.Lock()
+	as.filePages[page] = key
+	as.mu.Unlock()
+	return true
+}
+
+// storeFilePage writes back the page if it has ever been loaded, the caller must hold filePagesMu
+func storeFilePage(fp *filePageT) {
+	if fp.store == nil || atomic.LoadInt32(&fp.pg.io.loaded) == 0 {
+		return
+	}
+	var wds [memPageSizeWords]dg.WordT
+	sharedMu.RLock()
+	wds = fp.pg.words
+	sharedMu.RUnlock()
+	fp.store(wds[:])
+}
+
+// FlushFilePage writes the contents of a mapped file page back to its file
+func (as *AddrSpaceT) FlushFilePage(page int) (ok bool) {
+	as.mu.RLock()
+	key, found := as.filePages[page]
+	as.mu.RUnlock()
+	if !found {
+		return false
+	}
+	filePagesMu.Lock()
+	storeFilePage(filePages[key])
+	filePagesMu.Unlock()
+	return true
+}
+
+// UnmapFilePage removes a mapped file page from the address space, the last address
+// space to release a page writes it back
+func (as *AddrSpaceT) UnmapFilePage(page int) (ok bool) {
+	as.mu.Lock()
+	key, found := as.filePages[page]
+	delete(as.filePages, page)
+	as.mu.Unlock()
+	if !found {
+		return false
+	}
+	as.UnmapPage(page, true)
+	releaseFilePage(key)
+	return true
+}
+
+func releaseFilePage(key filePageKeyT) {
+	filePagesMu.Lock()
+	if fp := filePages[key]; fp != nil {
+		fp.users--
+		if fp.users == 0 {
+			storeFilePage(fp)
+			delete(filePages, key)
+		}
+	}
+	filePagesMu.Unlock()
+}
+
+// FilePages returns the pages of the address space which are mapped from the given file
+func (as *AddrSpaceT) FilePages(file string) (pages []int) {
+	as.mu.RLock()
+	for page, key := range as.filePages {
+		if key.file == file {
+			pages = append(pages, page)
+		}
+	}
+	as.mu.RUnlock()
+	return pages
+}
+
+// releaseFilePages detaches every file page of a discarded address space
+func (as *AddrSpaceT) releaseFilePages() {
+	as.mu.Lock()
+	keys := as.filePages
+	as.filePages = map[int]filePageKeyT{}
+	as.mu.Unlock()
+	for _, key := range keys {
+		releaseFilePage(key)
+	}
+}
@@ -0,0 +1,106 @@
+// +build virtual !physical
+
+// TESTS FOR SHARED PAGES OF DISK FILES
+
+// Copyright ©2020  Steve Merrony
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.package memory
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/SMerrony/dgemug/dg"
+)
+
+func TestFilePages(t *testing.T) {
+	loads := 0
+	var stored []dg.WordT
+	load := func() []dg.WordT {
+		loads++
+		return []dg.WordT{0x1234}
+	}
+	store := func(wds []dg.WordT) { stored = wds }
+	as1 := NewAddrSpace()
+	as2 := NewAddrSpace()
+	if !as1.MapFilePage(ring7page0+8, "TEST.DAT", 3, load, store) {
+		t.Fatal("Expected page to be mapped")
+	}
+	as2.MapFilePage(ring7page0+9, "TEST.DAT", 3, load, nil)
+	if loads != 0 {
+		t.Error("Expected page not to be loaded before use")
+	}
+	if wd := as2.ReadWord(0x7000_2400); wd != 0x1234 || loads != 1 {
+		t.Errorf("Expected 0x1234 loaded once, got %#x after %d. loads", wd, loads)
+	}
+	as1.WriteWord(0x7000_2001, 5)
+	if wd := as2.ReadWord(0x7000_2401); wd != 5 {
+		t.Errorf("Expected write to be shared, got %#x", wd)
+	}
+	if !as2.FlushFilePage(ring7page0+9) || stored[1] != 5 {
+		t.Error("Expected page to be written back")
+	}
+	if pages := as1.FilePages("TEST.DAT"); len(pages) != 1 || pages[0] != ring7page0+8 {
+		t.Errorf("Expected page %#x, got %v", ring7page0+8, pages)
+	}
+	as1.UnmapFilePage(ring7page0 + 8)
+	stored = nil
+	as2.Release()
+	if stored == nil {
+		t.Error("Expected last release to write back the page")
+	}
+	if len(filePages) != 0 {
+		t.Error("Expected file page cache to be empty")
+	}
+}
+
+func TestFilePageReplacesReservedPage(t *testing.T) {
+	as := NewAddrSpace()
+	as.ReserveSharedPage(ring7page0 + 4)
+	load := func() []dg.WordT { return []dg.WordT{7} }
+	if !as.MapFilePage(ring7page0+4, "TEST2.DAT", 0, load, nil) {
+		t.Fatal("Expected reserved shared page to be replaced")
+	}
+	if as.MapFilePage(ring7page0+4, "TEST2.DAT", 1, load, nil) || as.MapFilePage(ring7page0, "TEST2.DAT", 1, load, nil) {
+		t.Error("Expected mapped pages not to be replaced")
+	}
+	if n := as.GetNumSharedPages(7); n != 1 {
+		t.Errorf("Expected 1 shared page, got %d", n)
+	}
+	if wd := as.ReadWord(0x7000_1000); wd != 7 {
+		t.Errorf("Expected 7, got %#x", wd)
+	}
+	as.Release()
+}
+
+func TestFilePageKeepsSharedCode(t *testing.T) {
+	as := NewAddrSpace()
+	as.MapSharedSlice("TESTCODE.PR", 0x7000_1000, []dg.WordT{0123})
+	load := func() []dg.WordT { return []dg.WordT{7} }
+	if as.MapFilePage(ring7page0+4, "TEST3.DAT", 0, load, nil) {
+		t.Error("Expected a shared program page not to be replaced")
+	}
+	if wd := as.ReadWord(0x7000_1000); wd != 0123 {
+		t.Errorf("Expected the shared code to remain, got %#o", wd)
+	}
+	if len(filePages) != 0 {
+		t.Error("Expected no file page to be cached")
+	}
+	as.Release()
+}
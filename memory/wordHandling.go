@@ -96,3 +96,13 @@ func WordsFromBytes(ba []byte) (wa []dg.WordT) {
 	}
 	return wa
 }
+
+// BytesFromWords converts a slice of DG Words into a slice of (Go) bytes
+func BytesFromWords(wa []dg.WordT) (ba []byte) {
+	ba = make([]byte, len(wa)*2)
+	for w, wd := range wa {
+		ba[w*2] = byte(wd >> 8)
+		ba[w*2+1] = byte(wd)
+	}
+	return ba
+}
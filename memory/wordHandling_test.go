@@ -92,3 +92,13 @@ func TestSwapBytes(t *testing.T) {
 		t.Error("Expected 4660., got ", wd)
 	}
 }
+
+func TestBytesFromWords(t *testing.T) {
+	ba := BytesFromWords([]dg.WordT{0x1234, 0x5678})
+	if len(ba) != 4 || ba[0] != 0x12 || ba[3] != 0x78 {
+		t.Errorf("Expected 12 34 56 78, got % x", ba)
+	}
+	if wa := WordsFromBytes(ba); wa[1] != 0x5678 {
+		t.Errorf("Expected 0x5678, got %#x", wa[1])
+	}
+}
@@ -92,11 +92,13 @@ type CPUT struct {
 
 	devNum int
 	bus    *devices.BusT
+	mem    *memory.AddrSpaceT // the memory this CPU sees
 
 	// emulator internals
 	debugLogging bool
-	instrCount   uint64 // how many instructions executed during the current run, running at 2 MIPS this will loop round roughly every 100 million years!
-	scpIO        bool   // true if console I/O is directed to the SCP
+	instrCount   uint64                   // how many instructions executed during the current run, running at 2 MIPS this will loop round roughly every 100 million years!
+	scpIO        bool                     // true if console I/O is directed to the SCP
+	fault        *memory.ProtectionFaultT // set if the last Vrun stopped on a protection fault
 }
 
 // CPUStatT defines the data we will send to the statusCollector monitor
@@ -117,6 +119,9 @@ const cpuStatPeriodMs = 333 // 125 // i.e. we send stats every 1/8th of a second
 func (cpu *CPUT) CPUInit(devNum int, bus *devices.BusT, statsChan chan CPUStatT) {
 	cpu.devNum = devNum
 	cpu.bus = bus
+	if cpu.mem == nil {
+		cpu.mem = memory.DefaultAddrSpace()
+	}
 	cpu.Reset()
 	decoderGenAllPossOpcodes()
 	if statsChan != nil {
@@ -124,6 +129,16 @@ func (cpu *CPUT) CPUInit(devNum int, bus *devices.BusT, statsChan chan CPUStatT)
 	}
 }
 
+// SetAddrSpace gives the CPU its own view of memory, e.g. a process's virtual address space
+func (cpu *CPUT) SetAddrSpace(as *memory.AddrSpaceT) {
+	cpu.mem = as
+}
+
+// GetAddrSpace returns the memory this CPU sees
+func (cpu *CPUT) GetAddrSpace() *memory.AddrSpaceT {
+	return cpu.mem
+}
+
 // Reset sets sane initial values for a CPU
 func (cpu *CPUT) Reset() {
 	cpu.cpuMu.Lock()
@@ -182,7 +197,7 @@ func (cpu *CPUT) DisassembleRange(lowAddr, highAddr dg.PhysAddrT) (disassembly s
 	var skipDecode int
 
 	for addr := lowAddr; addr <= highAddr; addr++ {
-		word := memory.ReadWord(addr)
+		word := cpu.mem.ReadWord(addr)
 		byte1 := dg.ByteT(word >> 8)
 		byte2 := dg.ByteT(word & 0x00ff)
 		display := fmt.Sprintf("%c%#x: %02X %02X %06o %s \"", dg.ASCIINL, addr, byte1, byte2, word, memory.WordToBinStr(word))
@@ -198,7 +213,7 @@ func (cpu *CPUT) DisassembleRange(lowAddr, highAddr dg.PhysAddrT) (disassembly s
 		}
 		display += "\" "
 		if skipDecode == 0 {
-			instrTmp, ok := InstructionDecode(word, addr, true, false, true, true, nil)
+			instrTmp, ok := InstructionDecode(cpu.mem, word, addr, true, false, true, true, nil)
 			if ok {
 				display += instrTmp.GetDisassembly()
 				if instrTmp.GetLength() > 1 {
@@ -361,6 +376,16 @@ func (cpu *CPUT) GetSCPIO() (scp bool) {
 	return scp
 }
 
+// GetFault returns the protection fault which stopped the last Vrun, if any
+func (cpu *CPUT) GetFault() (fault memory.ProtectionFaultT, faulted bool) {
+	cpu.cpuMu.RLock()
+	if cpu.fault != nil {
+		fault, faulted = *cpu.fault, true
+	}
+	cpu.cpuMu.RUnlock()
+	return fault, faulted
+}
+
 // SetSCPIO is a setter for the SCP I/O flag
 func (cpu *CPUT) SetSCPIO(scp bool) {
 	cpu.cpuMu.Lock()
@@ -393,6 +418,42 @@ func (cpu *CPUT) SetZ(b bool) {
 	}
 }
 
+// FPUSaveWords is the size of a saved floating-point state, laid out as WFPSH pushes it:
+// the FPSR followed by FPAC0 to FPAC3, each a quadword
+const FPUSaveWords = 20
+
+// ResetFPU clears the floating-point accumulators and status register
+func (cpu *CPUT) ResetFPU() {
+	cpu.cpuMu.Lock()
+	cpu.fpac = [4]float64{}
+	cpu.fpsr = 0
+	cpu.cpuMu.Unlock()
+}
+
+// StoreFPUState saves the floating-point state in the FPUSaveWords words at addr
+func (cpu *CPUT) StoreFPUState(addr dg.PhysAddrT) {
+	cpu.cpuMu.RLock()
+	defer cpu.cpuMu.RUnlock() // the area may be unmapped
+	cpu.mem.WriteDWord(addr, dg.DwordT(cpu.fpsr>>32))
+	cpu.mem.WriteDWord(addr+2, dg.DwordT(cpu.fpsr))
+	for a := 0; a < 4; a++ {
+		qwd := memory.Float64toDGdouble(cpu.fpac[a])
+		cpu.mem.WriteDWord(addr+4+dg.PhysAddrT(a*4), dg.DwordT(qwd>>32))
+		cpu.mem.WriteDWord(addr+6+dg.PhysAddrT(a*4), dg.DwordT(qwd))
+	}
+}
+
+// LoadFPUState reloads the floating-point state saved at addr by StoreFPUState or WFPSH
+func (cpu *CPUT) LoadFPUState(addr dg.PhysAddrT) {
+	cpu.cpuMu.Lock()
+	defer cpu.cpuMu.Unlock()
+	cpu.fpsr = restoredFPSR(dg.QwordT(cpu.mem.ReadDWord(addr))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+2)))
+	for a := 0; a < 4; a++ {
+		qwd := dg.QwordT(cpu.mem.ReadDWord(addr+4+dg.PhysAddrT(a*4)))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+6+dg.PhysAddrT(a*4)))
+		cpu.fpac[a] = memory.DGdoubleToFloat64(qwd)
+	}
+}
+
 // SetupStack is a group-setter for the Wide Stack
 func (cpu *CPUT) SetupStack(wfp, wsp, wsb, wsl, wsfh dg.PhysAddrT) {
 	cpu.cpuMu.Lock()
@@ -400,7 +461,7 @@ func (cpu *CPUT) SetupStack(wfp, wsp, wsb, wsl, wsfh dg.PhysAddrT) {
 	cpu.wsp = wsp
 	cpu.wsb = wsb
 	cpu.wsl = wsl
-	memory.WriteWord((cpu.pc&0x7000_0000)|wsfhLoc, dg.WordT(wsfh))
+	cpu.mem.WriteWord((cpu.pc&0x7000_0000)|wsfhLoc, dg.WordT(wsfh))
 	cpu.cpuMu.Unlock()
 }
 
@@ -473,10 +534,10 @@ func (cpu *CPUT) Run(disassembly bool,
 RunLoop: // performance-critical section starts here
 	for {
 		// FETCH
-		thisOp = memory.ReadWord(cpu.pc)
+		thisOp = cpu.mem.ReadWord(cpu.pc)
 
 		// DECODE
-		iPtr, ok = InstructionDecode(thisOp, cpu.pc, cpu.sbr[cpu.pc>>29].lef, cpu.sbr[cpu.pc>>29].io, cpu.atu, disassembly, deviceMap)
+		iPtr, ok = InstructionDecode(cpu.mem, thisOp, cpu.pc, cpu.sbr[cpu.pc>>29].lef, cpu.sbr[cpu.pc>>29].io, cpu.atu, disassembly, deviceMap)
 		cpu.cpuMu.RUnlock()
 		if !ok || iPtr.ix == -1 {
 			errDetail = " *** Error: could not decode instruction ***"
@@ -507,14 +568,14 @@ RunLoop: // performance-critical section starts here
 			cpu.bus.SetIRQ(false)
 			// TODO - disable User MAP
 			// store PC in location zero
-			memory.WriteWord(0, dg.WordT(cpu.pc))
+			cpu.mem.WriteWord(0, dg.WordT(cpu.pc))
 			// fetch service routine address from location one
-			if memory.TestWbit(memory.ReadWord(1), 0) {
+			if memory.TestWbit(cpu.mem.ReadWord(1), 0) {
 				indIrq = '@'
 			} else {
 				indIrq = ' '
 			}
-			cpu.pc = resolve15bitDisplacement(cpu, indIrq, absoluteMode, memory.ReadWord(1), 0)
+			cpu.pc = resolve15bitDisplacement(cpu, indIrq, absoluteMode, cpu.mem.ReadWord(1), 0)
 			// next time round RunLoop the interrupt service routine will be started...
 		}
 		cpu.cpuMu.Unlock()
@@ -568,22 +629,51 @@ func (cpu *CPUT) Vrun(instrCounts *[maxInstrs]int) (syscallTrap bool, errDetail
 	var (
 		thisOp dg.WordT
 		// prevPC dg.PhysAddrT
-		iPtr *decodedInstrT
-		ok   bool
+		iPtr      *decodedInstrT
+		ok        bool
+		locked    bool // is the RLock held?
+		executing bool // Execute holds the write lock
 		// indIrq byte
 	)
 
+	// an access to unmapped memory abandons the current instruction and stops the run
+	defer func() {
+		if r := recover(); r != nil {
+			pf, isFault := r.(memory.ProtectionFaultT)
+			if !isFault {
+				panic(r)
+			}
+			switch {
+			case executing:
+			case locked:
+				cpu.cpuMu.RUnlock()
+				cpu.cpuMu.Lock()
+			default:
+				cpu.cpuMu.Lock()
+			}
+			cpu.fault = &pf
+			cpu.cpuMu.Unlock()
+			syscallTrap = SyscallNot
+			errDetail = fmt.Sprintf(" *** Protection fault: %s at PC %#o ***", pf.Error(), cpu.pc)
+		}
+	}()
+
 	// initial read lock taken before loop starts to eliminate one lock/unlock per cycle
+	cpu.cpuMu.Lock()
+	cpu.fault = nil
+	cpu.cpuMu.Unlock()
 	cpu.cpuMu.RLock()
+	locked = true
 
 	// RunLoop: // performance-critical section starts here
 	for {
 		// FETCH
-		thisOp = memory.ReadWord(cpu.pc)
+		thisOp = cpu.mem.ReadWord(cpu.pc)
 
 		// DECODE
-		iPtr, ok = InstructionDecode(thisOp, cpu.pc, true, false, true, cpu.debugLogging, nil)
+		iPtr, ok = InstructionDecode(cpu.mem, thisOp, cpu.pc, true, false, true, cpu.debugLogging, nil)
 		cpu.cpuMu.RUnlock()
+		locked = false
 		if !ok || iPtr.ix == -1 {
 			errDetail = " *** Error: could not decode instruction ***"
 			break
@@ -595,10 +685,12 @@ func (cpu *CPUT) Vrun(instrCounts *[maxInstrs]int) (syscallTrap bool, errDetail
 		}
 
 		// EXECUTE
+		executing = true
 		if !cpu.Execute(iPtr) {
 			errDetail = " *** Error: could not execute instruction (or CPU HALT encountered) ***"
 			break
 		}
+		executing = false
 
 		// System Call?
 		if cpu.pc == 0x3000_0000 {
@@ -619,14 +711,14 @@ func (cpu *CPUT) Vrun(instrCounts *[maxInstrs]int) (syscallTrap bool, errDetail
 		// 	cpu.bus.SetIRQ(false)
 		// 	// TODO - disable User MAP
 		// 	// store PC in location zero
-		// 	memory.WriteWord(0, dg.WordT(cpu.pc))
+		// 	cpu.mem.WriteWord(0, dg.WordT(cpu.pc))
 		// 	// fetch service routine address from location one
-		// 	if memory.TestWbit(memory.ReadWord(1), 0) {
+		// 	if memory.TestWbit(cpu.mem.ReadWord(1), 0) {
 		// 		indIrq = '@'
 		// 	} else {
 		// 		indIrq = ' '
 		// 	}
-		// 	cpu.pc = resolve15bitDisplacement(cpu, indIrq, absoluteMode, memory.ReadWord(1), 0)
+		// 	cpu.pc = resolve15bitDisplacement(cpu, indIrq, absoluteMode, cpu.mem.ReadWord(1), 0)
 		// 	// next time round RunLoop the interrupt service routine will be started...
 		// }
 		// cpu.cpuMu.Unlock()
@@ -654,16 +746,18 @@ func (cpu *CPUT) Vrun(instrCounts *[maxInstrs]int) (syscallTrap bool, errDetail
 
 		// Console interrupt?
 		cpu.cpuMu.RLock()
+		locked = true
 
 		if cpu.pc == 0x7000_0000 {
+			cpu.cpuMu.RUnlock()
 			log.Println("OOPS: At location 0 in ring 7")
 			break
 		}
-		// if cpu.scpIO {
-		// 	cpu.cpuMu.RUnlock()
-		// 	errDetail = " *** Console ESCape ***"
-		// 	break
-		// }
+		if cpu.scpIO {
+			cpu.cpuMu.RUnlock()
+			errDetail = " *** Console ESCape ***"
+			break
+		}
 
 		// instruction counting
 		instrCounts[iPtr.ix]++
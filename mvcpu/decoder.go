@@ -253,7 +253,7 @@ func instructionMatch(opcode dg.WordT, lefMode bool, ioOn bool, atuOn bool) (int
 }
 
 // InstructionDecode decodes an opcode
-func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool, atuOn bool, disassemble bool, devMap devices.DeviceMapT) (*decodedInstrT, bool) {
+func InstructionDecode(mem *memory.AddrSpaceT, opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool, atuOn bool, disassemble bool, devMap devices.DeviceMapT) (*decodedInstrT, bool) {
 	var decodedInstr decodedInstrT
 	var secondWord, thirdWord, fourthWord dg.WordT
 
@@ -285,7 +285,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var immMode2Word immMode2WordT
 		immMode2Word.immU16 = decode2bitImm(memory.GetWbits(opcode, 1, 2))
 		immMode2Word.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		immMode2Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		immMode2Word.disp15 = decode15bitDisp(secondWord, immMode2Word.mode)
 		decodedInstr.variant = immMode2Word
@@ -324,9 +324,9 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var lndo4Word lndo4WordT
 		lndo4Word.acd = int(int16(memory.GetWbits(opcode, 1, 2)))
 		lndo4Word.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
-		thirdWord = memory.ReadWord(pc + 2)
-		fourthWord = memory.ReadWord(pc + 3)
+		secondWord = mem.ReadWord(pc + 1)
+		thirdWord = mem.ReadWord(pc + 2)
+		fourthWord = mem.ReadWord(pc + 3)
 		lndo4Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		lndo4Word.disp31 = decode31bitDisp(secondWord, thirdWord, lndo4Word.mode)
 		lndo4Word.offsetU16 = uint16(fourthWord)
@@ -339,7 +339,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case NOACC_MODE_2_WORD_FMT: // eg. XPEFB
 		var noAccMode2Word noAccMode2WordT
 		noAccMode2Word.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		noAccMode2Word.disp16, noAccMode2Word.lowByte = decode16bitByteDisp(memory.ReadWord(pc + 1))
+		noAccMode2Word.disp16, noAccMode2Word.lowByte = decode16bitByteDisp(mem.ReadWord(pc + 1))
 		decodedInstr.variant = noAccMode2Word
 		if disassemble {
 			decodedInstr.disassembly += fmt.Sprintf(" %#o,%s %c[2-Word OpCode]",
@@ -348,7 +348,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case NOACC_MODE_3_WORD_FMT: // eg. LPEFB,
 		var noAccMode3Word noAccMode3WordT
 		noAccMode3Word.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		noAccMode3Word.immU32 = uint32(memory.ReadDWord(pc + 1))
+		noAccMode3Word.immU32 = uint32(mem.ReadDWord(pc + 1))
 		decodedInstr.variant = noAccMode3Word
 		if disassemble {
 			decodedInstr.disassembly += fmt.Sprintf(" %#o,%s [3-Word OpCode]",
@@ -356,7 +356,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		}
 	case NOACC_MODE_IND_2_WORD_E_FMT:
 		decodedInstr.mode = int(int16(memory.GetWbits(opcode, 6, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		decodedInstr.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		decodedInstr.disp15 = dg.WordT(decode15bitDisp(secondWord, decodedInstr.mode))
 		if disassemble {
@@ -365,7 +365,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		}
 	case NOACC_MODE_IND_2_WORD_X_FMT:
 		decodedInstr.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		decodedInstr.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		decodedInstr.disp15 = dg.WordT(decode15bitDisp(secondWord, decodedInstr.mode))
 		if disassemble {
@@ -375,8 +375,8 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case NOACC_MODE_IND_3_WORD_FMT: // eg. LJMP/LJSR, LNISZ, LNDSZ, LWDS
 		var noAccModeInd3Word noAccModeInd3WordT
 		noAccModeInd3Word.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
-		thirdWord = memory.ReadWord(pc + 2)
+		secondWord = mem.ReadWord(pc + 1)
+		thirdWord = mem.ReadWord(pc + 2)
 		noAccModeInd3Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		noAccModeInd3Word.disp31 = decode31bitDisp(secondWord, thirdWord, noAccModeInd3Word.mode)
 		decodedInstr.variant = noAccModeInd3Word
@@ -387,8 +387,8 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case NOACC_MODE_IND_3_WORD_XCALL_FMT: // XCALL
 		var noAccModeInd3WordXcall noAccModeInd3WordXcallT
 		noAccModeInd3WordXcall.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
-		thirdWord = memory.ReadWord(pc + 2)
+		secondWord = mem.ReadWord(pc + 1)
+		thirdWord = mem.ReadWord(pc + 2)
 		noAccModeInd3WordXcall.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		noAccModeInd3WordXcall.disp15 = decode15bitDisp(secondWord, noAccModeInd3WordXcall.mode)
 		noAccModeInd3WordXcall.argCount = int(thirdWord)
@@ -402,8 +402,8 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var noAccModeImmInd3Word noAccModeImmInd3WordT
 		noAccModeImmInd3Word.immU16 = decode2bitImm(memory.GetWbits(opcode, 1, 2))
 		noAccModeImmInd3Word.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
-		thirdWord = memory.ReadWord(pc + 2)
+		secondWord = mem.ReadWord(pc + 1)
+		thirdWord = mem.ReadWord(pc + 2)
 		noAccModeImmInd3Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		noAccModeImmInd3Word.disp31 = decode31bitDisp(secondWord, thirdWord, noAccModeImmInd3Word.mode)
 		decodedInstr.variant = noAccModeImmInd3Word
@@ -414,9 +414,9 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		}
 	case NOACC_MODE_IND_4_WORD_FMT: // eg. LCALL
 		decodedInstr.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
-		thirdWord = memory.ReadWord(pc + 2)
-		fourthWord = memory.ReadWord(pc + 3)
+		secondWord = mem.ReadWord(pc + 1)
+		thirdWord = mem.ReadWord(pc + 2)
+		fourthWord = mem.ReadWord(pc + 3)
 		decodedInstr.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		decodedInstr.disp31 = decode31bitDisp(secondWord, thirdWord, decodedInstr.mode)
 		decodedInstr.argCount = int(int16(fourthWord))
@@ -477,7 +477,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case ONEACC_IMM_2_WORD_FMT: // eg. ADDI, NADDI, NLDAI, WASHI, WSEQI, WLSHI, WNADI
 		var oneAccImm2Word oneAccImm2WordT
 		oneAccImm2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccImm2Word.immS16 = int16(secondWord)
 		decodedInstr.variant = oneAccImm2Word
 		if disassemble {
@@ -486,7 +486,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case ONEACC_IMMWD_2_WORD_FMT: // eg. ANDI, IORI
 		var oneAccImmWd2Word oneAccImmWd2WordT
 		oneAccImmWd2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccImmWd2Word.immWord = secondWord
 		decodedInstr.variant = oneAccImmWd2Word
 		if disassemble {
@@ -495,7 +495,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case ONEACC_IMM_3_WORD_FMT: // eg. WADDI, WUGTI, WXORI
 		var oneAccImm3Word oneAccImm3WordT
 		oneAccImm3Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		oneAccImm3Word.immU32 = uint32(memory.ReadDWord(pc + 1))
+		oneAccImm3Word.immU32 = uint32(mem.ReadDWord(pc + 1))
 		decodedInstr.variant = oneAccImm3Word
 		if disassemble {
 			decodedInstr.disassembly += fmt.Sprintf(" %#o,%d [3-Word OpCode]", oneAccImm3Word.immU32, oneAccImm3Word.acd)
@@ -503,7 +503,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 	case ONEACC_IMMDWD_3_WORD_FMT: // eg. WANDI, WIORI, WLDAI
 		var oneAccImmDwd3Word oneAccImmDwd3WordT
 		oneAccImmDwd3Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		oneAccImmDwd3Word.immDword = memory.ReadDWord(pc + 1)
+		oneAccImmDwd3Word.immDword = mem.ReadDWord(pc + 1)
 		decodedInstr.variant = oneAccImmDwd3Word
 		if disassemble {
 			decodedInstr.disassembly += fmt.Sprintf(" %#o,%d [3-Word OpCode]", oneAccImmDwd3Word.immDword, oneAccImmDwd3Word.acd)
@@ -512,7 +512,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var oneAccMode2Word oneAccMode2WordT
 		oneAccMode2Word.mode = int(int16(memory.GetWbits(opcode, 1, 2)))
 		oneAccMode2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccMode2Word.disp16, oneAccMode2Word.bitLow = decode16bitByteDisp(secondWord)
 		decodedInstr.variant = oneAccMode2Word
 		if disassemble {
@@ -523,7 +523,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var oneAccMode2Word oneAccMode2WordT
 		oneAccMode2Word.mode = int(int16(memory.GetWbits(opcode, 6, 2)))
 		oneAccMode2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccMode2Word.disp16, oneAccMode2Word.bitLow = decode16bitByteDisp(secondWord)
 		decodedInstr.variant = oneAccMode2Word
 		if disassemble {
@@ -534,8 +534,8 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var oneAccMode3Word oneAccMode3WordT
 		oneAccMode3Word.mode = int(int16(memory.GetWbits(opcode, 1, 2)))
 		oneAccMode3Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
-		thirdWord = memory.ReadWord(pc + 2)
+		secondWord = mem.ReadWord(pc + 1)
+		thirdWord = mem.ReadWord(pc + 2)
 		oneAccMode3Word.u32 = uint32(memory.DwordFromTwoWords(secondWord, thirdWord))
 		decodedInstr.variant = oneAccMode3Word
 		if disassemble {
@@ -546,7 +546,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var oneAccModeInd2Word oneAccModeInd2WordT
 		oneAccModeInd2Word.mode = int(int16(memory.GetWbits(opcode, 6, 2)))
 		oneAccModeInd2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccModeInd2Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		oneAccModeInd2Word.disp15 = decode15bitDisp(secondWord, oneAccModeInd2Word.mode)
 		decodedInstr.variant = oneAccModeInd2Word
@@ -559,7 +559,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var oneAccModeInd2Word oneAccModeInd2WordT
 		oneAccModeInd2Word.mode = int(int16(memory.GetWbits(opcode, 1, 2)))
 		oneAccModeInd2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccModeInd2Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		oneAccModeInd2Word.disp15 = decode15bitDisp(secondWord, oneAccModeInd2Word.mode)
 		decodedInstr.variant = oneAccModeInd2Word
@@ -571,9 +571,9 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var oneAccModeInd3Word oneAccModeInd3WordT
 		oneAccModeInd3Word.mode = int(int16(memory.GetWbits(opcode, 1, 2)))
 		oneAccModeInd3Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		oneAccModeInd3Word.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
-		thirdWord = memory.ReadWord(pc + 2)
+		thirdWord = mem.ReadWord(pc + 2)
 		oneAccModeInd3Word.disp31 = decode31bitDisp(secondWord, thirdWord, oneAccModeInd3Word.mode)
 		decodedInstr.variant = oneAccModeInd3Word
 		if disassemble {
@@ -602,10 +602,10 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var threeWordDo threeWordDoT
 		threeWordDo.acd = int(int16(memory.GetWbits(opcode, 1, 2)))
 		threeWordDo.mode = int(int16(memory.GetWbits(opcode, 3, 2)))
-		secondWord = memory.ReadWord(pc + 1)
+		secondWord = mem.ReadWord(pc + 1)
 		threeWordDo.ind = decodeIndirect(memory.TestWbit(secondWord, 0))
 		threeWordDo.disp15 = decode15bitDisp(secondWord, threeWordDo.mode)
-		thirdWord = memory.ReadWord(pc + 2)
+		thirdWord = mem.ReadWord(pc + 2)
 		threeWordDo.offsetU16 = uint16(thirdWord)
 		decodedInstr.variant = threeWordDo
 		if disassemble {
@@ -617,7 +617,7 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 		var twoAccImm2Word twoAccImm2WordT
 		twoAccImm2Word.acs = int(int16(memory.GetWbits(opcode, 1, 2)))
 		twoAccImm2Word.acd = int(int16(memory.GetWbits(opcode, 3, 2)))
-		twoAccImm2Word.immWord = memory.ReadWord(pc + 1)
+		twoAccImm2Word.immWord = mem.ReadWord(pc + 1)
 		decodedInstr.variant = twoAccImm2Word
 		if disassemble {
 			decodedInstr.disassembly += fmt.Sprintf(" %#o,%d,%d", twoAccImm2Word.immWord, twoAccImm2Word.acs,
@@ -628,13 +628,13 @@ func InstructionDecode(opcode dg.WordT, pc dg.PhysAddrT, lefMode bool, ioOn bool
 
 	case UNIQUE_2_WORD_FMT: // eg.SAVE, WSAVR, WSAVS
 		var unique2Word unique2WordT
-		unique2Word.immU16 = uint16(memory.ReadWord(pc + 1))
+		unique2Word.immU16 = uint16(mem.ReadWord(pc + 1))
 		decodedInstr.variant = unique2Word
 		if disassemble {
 			decodedInstr.disassembly += fmt.Sprintf(" %#o [2-Word OpCode]", unique2Word.immU16)
 		}
 	case WIDE_DEC_SPECIAL_FMT: // Funky - following word defines OpCode...
-		decodedInstr.word2 = memory.ReadWord(pc + 1)
+		decodedInstr.word2 = mem.ReadWord(pc + 1)
 		if disassemble {
 			switch decodedInstr.word2 {
 			case 0x0000:
@@ -48,9 +48,9 @@ func eagleDecimal(cpu *CPUT, iPtr *decodedInstrT) bool {
 				cpu.ac[1] = 0
 			} else {
 				sf1, dt1, sz1 := memory.DecodeDecDataType(arg1Type)
-				str1 := memory.ReadDec(dg.PhysAddrT(arg1BA), sz1)
+				str1 := cpu.mem.ReadDec(dg.PhysAddrT(arg1BA), sz1)
 				sf2, dt2, sz2 := memory.DecodeDecDataType(arg2Type)
-				str2 := memory.ReadDec(dg.PhysAddrT(arg2BA), sz2)
+				str2 := cpu.mem.ReadDec(dg.PhysAddrT(arg2BA), sz2)
 				logging.DebugPrint(logging.DebugLog, "Arg 1 - SF: %d., Type: %d., Size: %d., String: %s\n", sf1, dt1, sz1, str1)
 				logging.DebugPrint(logging.DebugLog, "Arg 2 - SF: %d., Type: %d., Size: %d., String: %s\n", sf2, dt2, sz2, str2)
 				i1 := memory.DecIntToInt(dt1, str1)
@@ -36,7 +36,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrLFAMD, instrLFDMD, instrLFMMD, instrLFSMD:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		qwd := dg.QwordT(memory.ReadDWord(addr))<<32 | dg.QwordT(memory.ReadDWord(addr+2))
+		qwd := dg.QwordT(cpu.mem.ReadDWord(addr))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+2))
 		switch iPtr.ix {
 		case instrLFAMD:
 			cpu.fpac[oneAccModeInd3Word.acd] += memory.DGdoubleToFloat64(qwd)
@@ -53,7 +53,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrLFLDD:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		qwd := dg.QwordT(memory.ReadDWord(addr))<<32 | dg.QwordT(memory.ReadDWord(addr+2))
+		qwd := dg.QwordT(cpu.mem.ReadDWord(addr))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+2))
 		cpu.fpac[oneAccModeInd3Word.acd] = memory.DGdoubleToFloat64(qwd)
 		cpu.SetZ(cpu.fpac[oneAccModeInd3Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd3Word.acd] < 0.0)
@@ -61,7 +61,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrLFLDS:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		dwd := memory.ReadDWord(addr)
+		dwd := cpu.mem.ReadDWord(addr)
 		cpu.fpac[oneAccModeInd3Word.acd] = memory.DGsingleToFloat64(dwd)
 		cpu.SetZ(cpu.fpac[oneAccModeInd3Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd3Word.acd] < 0.0)
@@ -69,7 +69,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrLFMMS:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		single := memory.DGsingleToFloat64(memory.ReadDWord(addr))
+		single := memory.DGsingleToFloat64(cpu.mem.ReadDWord(addr))
 		cpu.fpac[oneAccModeInd3Word.acd] *= single
 		cpu.SetZ(cpu.fpac[oneAccModeInd3Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd3Word.acd] < 0.0)
@@ -78,13 +78,13 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
 		qwd := memory.Float64toDGdouble(cpu.fpac[oneAccModeInd3Word.acd])
-		memory.WriteDWord(addr, dg.DwordT(qwd>>32))
-		memory.WriteDWord(addr+2, dg.DwordT(qwd))
+		cpu.mem.WriteDWord(addr, dg.DwordT(qwd>>32))
+		cpu.mem.WriteDWord(addr+2, dg.DwordT(qwd))
 
 	case instrLFSTS:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		memory.WriteDWord(addr, memory.Float64toDGsingle(cpu.fpac[oneAccModeInd3Word.acd]))
+		cpu.mem.WriteDWord(addr, memory.Float64toDGsingle(cpu.fpac[oneAccModeInd3Word.acd]))
 
 	case instrWFFAD:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
@@ -99,8 +99,8 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrWLDI:
 		_, dataType, size := memory.DecodeDecDataType(cpu.ac[1]) // "WLDI does not use the scale factor..."
 		cpu.ac[2] = cpu.ac[3]
-		//bs := memory.ReadNBytes(dg.PhysAddrT(cpu.ac[3]), size)
-		bs := memory.ReadDec(dg.PhysAddrT(cpu.ac[3]), size)
+		//bs := cpu.mem.ReadNBytes(dg.PhysAddrT(cpu.ac[3]), size)
+		bs := cpu.mem.ReadDec(dg.PhysAddrT(cpu.ac[3]), size)
 		switch dataType {
 		// case memory.UnpackedDecTSC:
 		// case memory.UnpackedDecLSC:
@@ -136,7 +136,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 			// }
 			converted := fmt.Sprintf("%+0*.f", size, unconverted)
 			for c := 0; c < size; c++ {
-				memory.WriteByteBA(cpu.ac[3], dg.ByteT(converted[c]))
+				cpu.mem.WriteByteBA(cpu.ac[3], dg.ByteT(converted[c]))
 				logging.DebugPrint(logging.DebugLog, "... %c -> %#x\n", converted[c], cpu.ac[3])
 				cpu.ac[3]++
 			}
@@ -146,7 +146,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 			// }
 			converted := fmt.Sprintf("%0*.f", size, unconverted)
 			for c := 0; c < size; c++ {
-				memory.WriteByteBA(cpu.ac[3], dg.ByteT(converted[c]))
+				cpu.mem.WriteByteBA(cpu.ac[3], dg.ByteT(converted[c]))
 				logging.DebugPrint(logging.DebugLog, "... %c -> %#x\n", converted[c], cpu.ac[3])
 				cpu.ac[3]++
 			}
@@ -157,7 +157,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFAMD:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpQuad := dg.QwordT(memory.ReadDWord(addr))<<32 | dg.QwordT(memory.ReadDWord(addr+2))
+		fpQuad := dg.QwordT(cpu.mem.ReadDWord(addr))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+2))
 		cpu.fpac[oneAccModeInd2Word.acd] += memory.DGdoubleToFloat64(fpQuad)
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
@@ -165,7 +165,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFAMS:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpDoub := memory.ReadDWord(addr)
+		fpDoub := cpu.mem.ReadDWord(addr)
 		cpu.fpac[oneAccModeInd2Word.acd] += memory.DGsingleToFloat64(fpDoub)
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
@@ -173,7 +173,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFDMS:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpSingle := memory.ReadDWord(addr)
+		fpSingle := cpu.mem.ReadDWord(addr)
 		if fpSingle == 0 {
 			log.Panicln("ERROR: Divide-by-Zero not yet handled in XFDMS")
 		} else {
@@ -185,7 +185,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFLDD:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpQuad := dg.QwordT(memory.ReadDWord(addr))<<32 | dg.QwordT(memory.ReadDWord(addr+2))
+		fpQuad := dg.QwordT(cpu.mem.ReadDWord(addr))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+2))
 		cpu.fpac[oneAccModeInd2Word.acd] = memory.DGdoubleToFloat64(fpQuad)
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
@@ -193,7 +193,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFLDS:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpSingle := memory.ReadDWord(addr)
+		fpSingle := cpu.mem.ReadDWord(addr)
 		cpu.fpac[oneAccModeInd2Word.acd] = memory.DGsingleToFloat64(fpSingle)
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
@@ -201,7 +201,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFMMD:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpQuad := dg.QwordT(memory.ReadDWord(addr))<<32 | dg.QwordT(memory.ReadDWord(addr+2))
+		fpQuad := dg.QwordT(cpu.mem.ReadDWord(addr))<<32 | dg.QwordT(cpu.mem.ReadDWord(addr+2))
 		cpu.fpac[oneAccModeInd2Word.acd] *= memory.DGdoubleToFloat64(fpQuad)
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
@@ -209,7 +209,7 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXFMMS:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		fpSingle := memory.ReadDWord(addr)
+		fpSingle := cpu.mem.ReadDWord(addr)
 		cpu.fpac[oneAccModeInd2Word.acd] *= memory.DGsingleToFloat64(fpSingle)
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
@@ -218,14 +218,14 @@ func eagleFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
 		fpQuad := memory.Float64toDGdouble(cpu.fpac[oneAccModeInd2Word.acd])
-		memory.WriteDWord(addr, dg.DwordT(fpQuad>>32))
-		memory.WriteDWord(addr+2, dg.DwordT(fpQuad))
+		cpu.mem.WriteDWord(addr, dg.DwordT(fpQuad>>32))
+		cpu.mem.WriteDWord(addr+2, dg.DwordT(fpQuad))
 
 	case instrXFSTS:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
 		fpSingle := memory.Float64toDGsingle(cpu.fpac[oneAccModeInd2Word.acd])
-		memory.WriteDWord(addr, fpSingle)
+		cpu.mem.WriteDWord(addr, fpSingle)
 
 	default:
 		log.Panicf("ERROR: EAGLE_FPU instruction <%s> not yet implemented\n", iPtr.mnemonic)
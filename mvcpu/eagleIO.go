@@ -122,16 +122,16 @@ func eagleIO(cpu *CPUT, iPtr *decodedInstrT) bool {
 			wAddr := dg.PhysAddrT(cpu.ac[2])
 			if cpu.debugLogging {
 				logging.DebugPrint(logging.DebugLog, "WLMP called with AC1 = 0 - MapRegAddr was %#o, 1st DWord was %#o\n",
-					mapRegAddr, memory.ReadDWord(wAddr))
+					mapRegAddr, cpu.mem.ReadDWord(wAddr))
 				logging.DebugPrint(logging.MapLog, "WLMP called with AC1 = 0 - MapRegAddr was %#o, 1st DWord was %#o\n",
-					mapRegAddr, memory.ReadDWord(wAddr))
+					mapRegAddr, cpu.mem.ReadDWord(wAddr))
 			}
-			// memory.BmcdchWriteSlot(mapRegAddr, memory.ReadDWord(wAddr))
+			// memory.BmcdchWriteSlot(mapRegAddr, cpu.mem.ReadDWord(wAddr))
 			// cpu.ac[0]++
 			// cpu.ac[2] += 2
 		} else {
 			for {
-				dwd, ok := memory.ReadDwordTrap(dg.PhysAddrT(cpu.ac[2]))
+				dwd, ok := cpu.mem.ReadDwordTrap(dg.PhysAddrT(cpu.ac[2]))
 				if !ok {
 					log.Fatalf("ERROR: Memory access failed at PC: %#o\n", cpu.pc)
 				}
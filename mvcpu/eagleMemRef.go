@@ -37,7 +37,7 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		oneAccMode3Word := iPtr.variant.(oneAccMode3WordT)
 		addr := resolve32bitEffAddr(cpu, ' ', oneAccMode3Word.mode, int32(oneAccMode3Word.u32>>1), iPtr.dispOffset)
 		lobyte := memory.TestDwbit(dg.DwordT(oneAccMode3Word.u32), 31)
-		cpu.ac[oneAccMode3Word.acd] = dg.DwordT(memory.ReadByte(addr, lobyte))
+		cpu.ac[oneAccMode3Word.acd] = dg.DwordT(cpu.mem.ReadByteWA(addr, lobyte))
 
 	case instrLLEF:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
@@ -59,37 +59,37 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		var s16 int16
 		switch iPtr.ix {
 		case instrLWADD:
-			s16 = int16(memory.ReadDWord(addr)) + int16(cpu.ac[oneAccModeInd3Word.acd])
+			s16 = int16(cpu.mem.ReadDWord(addr)) + int16(cpu.ac[oneAccModeInd3Word.acd])
 		case instrLWMUL:
-			s16 = int16(memory.ReadDWord(addr)) * int16(cpu.ac[oneAccModeInd3Word.acd])
+			s16 = int16(cpu.mem.ReadDWord(addr)) * int16(cpu.ac[oneAccModeInd3Word.acd])
 		case instrLWSUB:
-			s16 = int16(cpu.ac[oneAccModeInd3Word.acd]) - int16(memory.ReadDWord(addr))
+			s16 = int16(cpu.ac[oneAccModeInd3Word.acd]) - int16(cpu.mem.ReadDWord(addr))
 		}
 		cpu.ac[oneAccModeInd3Word.acd] = dg.DwordT(s16)
 
 	case instrLNADI:
 		noAccModeImmInd3Word := iPtr.variant.(noAccModeImmInd3WordT)
 		addr := resolve31bitDisplacement(cpu, noAccModeImmInd3Word.ind, noAccModeImmInd3Word.mode, noAccModeImmInd3Word.disp31, iPtr.dispOffset)
-		wd := int16(memory.ReadWord(addr))
+		wd := int16(cpu.mem.ReadWord(addr))
 		wd += int16(noAccModeImmInd3Word.immU16)
-		memory.WriteWord(addr, dg.WordT(wd))
+		cpu.mem.WriteWord(addr, dg.WordT(wd))
 
 	case instrLNLDA:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		cpu.ac[oneAccModeInd3Word.acd] = memory.SexWordToDword(memory.ReadWord(addr))
+		cpu.ac[oneAccModeInd3Word.acd] = memory.SexWordToDword(cpu.mem.ReadWord(addr))
 
 	case instrLNSTA:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
 		wd := memory.DwordGetLowerWord(cpu.ac[oneAccModeInd3Word.acd])
-		memory.WriteWord(addr, wd)
+		cpu.mem.WriteWord(addr, wd)
 
 	case instrLSTB:
 		oneAccMode3Word := iPtr.variant.(oneAccMode3WordT)
 		addr := resolve32bitEffAddr(cpu, ' ', oneAccMode3Word.mode, int32(oneAccMode3Word.u32>>1), iPtr.dispOffset)
 		lobyte := memory.TestDwbit(dg.DwordT(oneAccMode3Word.u32), 31)
-		memory.WriteByte(addr, lobyte, dg.ByteT(cpu.ac[oneAccMode3Word.acd]))
+		cpu.mem.WriteByteWA(addr, lobyte, dg.ByteT(cpu.ac[oneAccMode3Word.acd]))
 
 	case instrLWADD, instrLWMUL, instrLWSUB:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
@@ -97,30 +97,30 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		var s32 int32
 		switch iPtr.ix {
 		case instrLWADD:
-			s32 = int32(memory.ReadDWord(addr)) + int32(cpu.ac[oneAccModeInd3Word.acd])
+			s32 = int32(cpu.mem.ReadDWord(addr)) + int32(cpu.ac[oneAccModeInd3Word.acd])
 		case instrLWMUL:
-			s32 = int32(memory.ReadDWord(addr)) * int32(cpu.ac[oneAccModeInd3Word.acd])
+			s32 = int32(cpu.mem.ReadDWord(addr)) * int32(cpu.ac[oneAccModeInd3Word.acd])
 		case instrLWSUB:
-			s32 = int32(cpu.ac[oneAccModeInd3Word.acd]) - int32(memory.ReadDWord(addr))
+			s32 = int32(cpu.ac[oneAccModeInd3Word.acd]) - int32(cpu.mem.ReadDWord(addr))
 		}
 		cpu.ac[oneAccModeInd3Word.acd] = dg.DwordT(s32)
 
 	case instrLWADI:
 		noAccModeImmInd3Word := iPtr.variant.(noAccModeImmInd3WordT)
 		addr := resolve31bitDisplacement(cpu, noAccModeImmInd3Word.ind, noAccModeImmInd3Word.mode, noAccModeImmInd3Word.disp31, iPtr.dispOffset)
-		dwd := int32(memory.ReadDWord(addr))
+		dwd := int32(cpu.mem.ReadDWord(addr))
 		dwd += int32(noAccModeImmInd3Word.immU16)
-		memory.WriteDWord(addr, dg.DwordT(dwd))
+		cpu.mem.WriteDWord(addr, dg.DwordT(dwd))
 
 	case instrLWLDA:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		cpu.ac[oneAccModeInd3Word.acd] = memory.ReadDWord(addr)
+		cpu.ac[oneAccModeInd3Word.acd] = cpu.mem.ReadDWord(addr)
 
 	case instrLWSTA:
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		addr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		memory.WriteDWord(addr, cpu.ac[oneAccModeInd3Word.acd])
+		cpu.mem.WriteDWord(addr, cpu.ac[oneAccModeInd3Word.acd])
 
 	case instrWBLM:
 		wblm(cpu)
@@ -135,9 +135,9 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		}
 		offset := dg.PhysAddrT(cpu.ac[twoAcc1Word.acd]) >> 4
 		bitNum := uint(cpu.ac[twoAcc1Word.acd] & 0x0f)
-		wd := memory.ReadWord(addr + offset)
+		wd := cpu.mem.ReadWord(addr + offset)
 		memory.SetWbit(&wd, bitNum)
-		memory.WriteWord(addr+offset, wd)
+		cpu.mem.WriteWord(addr+offset, wd)
 
 	case instrWBTZ:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
@@ -149,9 +149,9 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		}
 		offset := dg.PhysAddrT(cpu.ac[twoAcc1Word.acd]) >> 4
 		bitNum := uint(cpu.ac[twoAcc1Word.acd] & 0x0f)
-		wd := memory.ReadWord(addr + offset)
+		wd := cpu.mem.ReadWord(addr + offset)
 		memory.ClearWbit(&wd, bitNum)
-		memory.WriteWord(addr+offset, wd)
+		cpu.mem.WriteWord(addr+offset, wd)
 
 	case instrWCMV:
 		wcmv(cpu)
@@ -169,17 +169,17 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		wordAddr := dg.PhysAddrT(cpu.ac[twoAcc1Word.acs]) >> 1
 		lowByte := memory.TestDwbit(cpu.ac[twoAcc1Word.acs], 31)
-		cpu.ac[twoAcc1Word.acd] = dg.DwordT(memory.ReadByte(wordAddr, lowByte))
+		cpu.ac[twoAcc1Word.acd] = dg.DwordT(cpu.mem.ReadByteWA(wordAddr, lowByte))
 
 	case instrWSTB:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
-		memWriteByteBA(dg.ByteT(cpu.ac[twoAcc1Word.acd]&0x0ff), cpu.ac[twoAcc1Word.acs])
+		memWriteByteBA(cpu, dg.ByteT(cpu.ac[twoAcc1Word.acd]&0x0ff), cpu.ac[twoAcc1Word.acs])
 
 	case instrXLDB:
 		oneAccMode2Word := iPtr.variant.(oneAccMode2WordT)
 		//eff := resolve16bitByteAddr(cpu, oneAccMode2Word.mode, oneAccMode2Word.disp16, oneAccMode2Word.bitLow)
 		eff := resolveLong15bitDisplacement(cpu, ' ', oneAccMode2Word.mode, dg.WordT(oneAccMode2Word.disp16), iPtr.dispOffset)
-		cpu.ac[oneAccMode2Word.acd] = dg.DwordT(memory.ReadByte(eff, oneAccMode2Word.bitLow)) & 0x00ff
+		cpu.ac[oneAccMode2Word.acd] = dg.DwordT(cpu.mem.ReadByteWA(eff, oneAccMode2Word.bitLow)) & 0x00ff
 
 	case instrXLEF:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
@@ -212,20 +212,20 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr := resolve15bitDisplacement(cpu, immMode2Word.ind, immMode2Word.mode, dg.WordT(immMode2Word.disp15), iPtr.dispOffset)
 		var s32 int32
 		if iPtr.ix == instrXNADI {
-			s32 = int32(int16(memory.ReadWord(addr))) + int32(immMode2Word.immU16)
+			s32 = int32(int16(cpu.mem.ReadWord(addr))) + int32(immMode2Word.immU16)
 		} else {
-			s32 = int32(int16(memory.ReadWord(addr))) - int32(immMode2Word.immU16)
+			s32 = int32(int16(cpu.mem.ReadWord(addr))) - int32(immMode2Word.immU16)
 		}
 		if (s32 > maxPosS16) || (s32 < minNegS16) {
 			cpu.carry = true
 			cpu.SetOVR(true)
 		}
-		memory.WriteWord(addr, dg.WordT(s32))
+		cpu.mem.WriteWord(addr, dg.WordT(s32))
 
 	case instrXNADD, instrXNMUL, instrXNSUB:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		i16mem := int16(memory.ReadWord(addr))
+		i16mem := int16(cpu.mem.ReadWord(addr))
 		i16ac := int16(memory.DwordGetLowerWord(cpu.ac[oneAccModeInd2Word.acd]))
 		var t32 int32
 		switch iPtr.ix {
@@ -248,28 +248,28 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXNLDA:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		wd, ok := memory.ReadWordTrap(addr)
+		wd, ok := cpu.mem.ReadWordTrap(addr)
 		if !ok {
-			return false
+			protectionFault(addr)
 		}
 		cpu.ac[oneAccModeInd2Word.acd] = dg.DwordT(int32(int16(wd)))
 
 	case instrXNSTA:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		memory.WriteWord(addr, memory.DwordGetLowerWord(cpu.ac[oneAccModeInd2Word.acd]))
+		cpu.mem.WriteWord(addr, memory.DwordGetLowerWord(cpu.ac[oneAccModeInd2Word.acd]))
 
 	case instrXSTB:
 		oneAccMode2Word := iPtr.variant.(oneAccMode2WordT)
 		//eff := resolve16bitByteAddr(cpu, oneAccMode2Word.mode, oneAccMode2Word.disp16, oneAccMode2Word.bitLow)
 		eff := resolve15bitDisplacement(cpu, ' ', oneAccMode2Word.mode, dg.WordT(oneAccMode2Word.disp16), iPtr.dispOffset)
 		byt := dg.ByteT(cpu.ac[oneAccMode2Word.acd])
-		memory.WriteByte(eff, oneAccMode2Word.bitLow, byt)
+		cpu.mem.WriteByteWA(eff, oneAccMode2Word.bitLow, byt)
 
 	case instrXWADD, instrXWDIV, instrXWSUB, instrXWMUL:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		s64mem := int64(int32(memory.ReadDWord(addr)))
+		s64mem := int64(int32(cpu.mem.ReadDWord(addr)))
 		s64ac := int64(int32(cpu.ac[oneAccModeInd2Word.acd]))
 		var t64 int64
 		switch iPtr.ix {
@@ -294,25 +294,25 @@ func eagleMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		var s64 int64
 		switch iPtr.ix {
 		case instrXWADI:
-			s64 = int64(int32(memory.ReadDWord(addr))) + int64(immMode2Word.immU16)
+			s64 = int64(int32(cpu.mem.ReadDWord(addr))) + int64(immMode2Word.immU16)
 		case instrXWSBI:
-			s64 = int64(int32(memory.ReadDWord(addr))) - int64(immMode2Word.immU16)
+			s64 = int64(int32(cpu.mem.ReadDWord(addr))) - int64(immMode2Word.immU16)
 		}
 		if (s64 > maxPosS32) || (s64 < minNegS32) {
 			cpu.carry = true
 			cpu.SetOVR(true)
 		}
-		memory.WriteDWord(addr, dg.DwordT(s64))
+		cpu.mem.WriteDWord(addr, dg.DwordT(s64))
 
 	case instrXWLDA:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		cpu.ac[oneAccModeInd2Word.acd] = memory.ReadDWord(addr)
+		cpu.ac[oneAccModeInd2Word.acd] = cpu.mem.ReadDWord(addr)
 
 	case instrXWSTA:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
-		memory.WriteDWord(addr, cpu.ac[oneAccModeInd2Word.acd])
+		cpu.mem.WriteDWord(addr, cpu.ac[oneAccModeInd2Word.acd])
 
 	default:
 		log.Fatalf("ERROR: EAGLE_MEMREF instruction <%s> not yet implemented\n", iPtr.mnemonic)
@@ -330,18 +330,18 @@ func split32bitByteAddr(byteAddr dg.DwordT) (wordAddr dg.PhysAddrT, loByte bool)
 	return wordAddr, loByte
 }
 
-func readByteBA(ba dg.DwordT) dg.ByteT {
-	return memory.ReadByte(split32bitByteAddr(ba))
+func readByteBA(cpu *CPUT, ba dg.DwordT) dg.ByteT {
+	return cpu.mem.ReadByteWA(split32bitByteAddr(ba))
 }
 
 // memWriteByte writes the supplied byte to the address derived from the given byte addr
-func memWriteByteBA(b dg.ByteT, ba dg.DwordT) {
+func memWriteByteBA(cpu *CPUT, b dg.ByteT, ba dg.DwordT) {
 	wordAddr, lowByte := split32bitByteAddr(ba)
-	memory.WriteByte(wordAddr, lowByte, b)
+	cpu.mem.WriteByteWA(wordAddr, lowByte, b)
 }
 
-func copyByte(srcBA, destBA dg.DwordT) {
-	memWriteByteBA(readByteBA(srcBA), destBA)
+func copyByte(cpu *CPUT, srcBA, destBA dg.DwordT) {
+	memWriteByteBA(cpu, readByteBA(cpu, srcBA), destBA)
 }
 
 func wblm(cpu *CPUT) {
@@ -355,7 +355,7 @@ func wblm(cpu *CPUT) {
 			int32(cpu.ac[1]), cpu.ac[2], cpu.ac[3])
 	}
 	for cpu.ac[1] != 0 {
-		memory.WriteWord(dg.PhysAddrT(cpu.ac[3]), memory.ReadWord(dg.PhysAddrT(cpu.ac[2])))
+		cpu.mem.WriteWord(dg.PhysAddrT(cpu.ac[3]), cpu.mem.ReadWord(dg.PhysAddrT(cpu.ac[2])))
 		if memory.TestDwbit(cpu.ac[1], 0) {
 			cpu.ac[1]++
 			cpu.ac[2]--
@@ -390,7 +390,7 @@ func wcmv(cpu *CPUT) {
 	}
 	// 1st move srcCount bytes
 	for srcCount != 0 && destCount != 0 {
-		copyByte(cpu.ac[3], cpu.ac[2])
+		copyByte(cpu, cpu.ac[3], cpu.ac[2])
 		if srcAscend {
 			cpu.ac[3]++
 			srcCount--
@@ -408,7 +408,7 @@ func wcmv(cpu *CPUT) {
 	}
 	// now fill any excess bytes with ASCII spaces
 	for destCount != 0 {
-		memWriteByteBA(dg.ASCIISPC, cpu.ac[2])
+		memWriteByteBA(cpu, dg.ASCIISPC, cpu.ac[2])
 		if destAscend {
 			cpu.ac[2]++
 			destCount--
@@ -448,12 +448,12 @@ func wcmp(cpu *CPUT) {
 	for cpu.ac[1] != 0 && cpu.ac[0] != 0 {
 		// read the two bytes to compare, substitute with a space if one string has run out
 		if cpu.ac[1] != 0 {
-			str1char = readByteBA(cpu.ac[3])
+			str1char = readByteBA(cpu, cpu.ac[3])
 		} else {
 			str1char = ' '
 		}
 		if cpu.ac[0] != 0 {
-			str2char = readByteBA(cpu.ac[2])
+			str2char = readByteBA(cpu, cpu.ac[2])
 		} else {
 			str2char = ' '
 		}
@@ -492,7 +492,7 @@ func wcst(cpu *CPUT) {
 	var table [256]bool
 	var tIx dg.PhysAddrT
 	for tIx = 0; tIx < 16; tIx++ {
-		wd := memory.ReadWord(delimTabAddr + tIx)
+		wd := cpu.mem.ReadWord(delimTabAddr + tIx)
 		for bit := 0; bit < 16; bit++ {
 			if memory.TestWbit(wd, bit) {
 				table[(int(tIx)*16)+bit] = true
@@ -506,7 +506,7 @@ func wcst(cpu *CPUT) {
 	}
 
 	for strLenDir != 0 {
-		thisChar := readByteBA(cpu.ac[3])
+		thisChar := readByteBA(cpu, cpu.ac[3])
 		if table[int(thisChar)] {
 			// match, so set AC1 and return
 			cpu.ac[1] = 0
@@ -534,21 +534,21 @@ func wctr(cpu *CPUT) {
 	var transTable [256]dg.ByteT
 	var c dg.DwordT
 	for c = 0; c < 256; c++ {
-		transTable[c] = readByteBA(transTablePtr<<1 + c)
+		transTable[c] = readByteBA(cpu, transTablePtr<<1+c)
 	}
 
 	for cpu.ac[1] != 0 {
-		srcByte := readByteBA(cpu.ac[3])
+		srcByte := readByteBA(cpu, cpu.ac[3])
 		cpu.ac[3]++
 		transByte := transTable[int(srcByte)]
 		if int32(cpu.ac[1]) < 0 {
 			// move mode
-			memWriteByteBA(transByte, cpu.ac[2])
+			memWriteByteBA(cpu, transByte, cpu.ac[2])
 			cpu.ac[2]++
 			cpu.ac[1]++
 		} else {
 			// compare mode
-			str2byte := readByteBA(cpu.ac[2])
+			str2byte := readByteBA(cpu, cpu.ac[2])
 			cpu.ac[2]++
 			trans2byte := transTable[int(str2byte)]
 			if srcByte < trans2byte {
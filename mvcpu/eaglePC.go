@@ -37,21 +37,21 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		derr := iPtr.variant.(derrT)
 		wsPush(cpu, dg.DwordT(cpu.pc))
 		wsPush(cpu, dg.DwordT(derr.errCode))
-		cpu.pc = cpu.pc&ringMask32 | dg.PhysAddrT(memory.ReadWord(cpu.pc&ringMask32|047))
+		cpu.pc = cpu.pc&ringMask32 | dg.PhysAddrT(cpu.mem.ReadWord(cpu.pc&ringMask32|047))
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "..... DERR handler at: %#x\n", cpu.pc)
 		}
 
 	case instrDSZTS, instrISZTS:
-		// tmpAddr := dg.PhysAddrT(memory.ReadDWord(cpu.wsp))
+		// tmpAddr := dg.PhysAddrT(cpu.mem.ReadDWord(cpu.wsp))
 		tmpAddr := dg.PhysAddrT(cpu.wsp)
 		var dwd dg.DwordT
 		if iPtr.ix == instrDSZTS {
-			dwd = memory.ReadDWord(tmpAddr) - 1
+			dwd = cpu.mem.ReadDWord(tmpAddr) - 1
 		} else {
-			dwd = memory.ReadDWord(tmpAddr) + 1
+			dwd = cpu.mem.ReadDWord(tmpAddr) + 1
 		}
-		memory.WriteDWord(tmpAddr, dwd)
+		cpu.mem.WriteDWord(tmpAddr, dwd)
 		cpu.SetOVR(false)
 		if dwd == 0 {
 			cpu.pc += 2
@@ -69,7 +69,7 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 			dwd = dg.DwordT(iPtr.argCount)
 		} else {
 			//dwd = dg.DwordT(iPtr.argCount) & 0x00007fff
-			dwd = memory.ReadDWord(cpu.wsp) & 0x0000_7fff
+			dwd = cpu.mem.ReadDWord(cpu.wsp) & 0x0000_7fff
 		}
 		dwd |= dg.DwordT(cpu.psr) << 16
 		ok, faultCode, secondaryFault := wspCheckBounds(cpu, 2, false)
@@ -86,13 +86,13 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		oneAccModeInd3Word := iPtr.variant.(oneAccModeInd3WordT)
 		value := int32(cpu.ac[oneAccModeInd3Word.acd])
 		tableAddr := resolve31bitDisplacement(cpu, oneAccModeInd3Word.ind, oneAccModeInd3Word.mode, oneAccModeInd3Word.disp31, iPtr.dispOffset)
-		h := int32(memory.ReadDWord(tableAddr - 2))
-		l := int32(memory.ReadDWord(tableAddr - 4))
+		h := int32(cpu.mem.ReadDWord(tableAddr - 2))
+		l := int32(cpu.mem.ReadDWord(tableAddr - 4))
 		if value < l || value > h {
 			cpu.pc += 3
 		} else {
 			tableIndex := tableAddr + (2 * dg.PhysAddrT(value)) - (2 * dg.PhysAddrT(l))
-			tableVal := memory.ReadDWord(tableIndex)
+			tableVal := cpu.mem.ReadDWord(tableIndex)
 			if memory.TestDwbit(tableVal, 4) { // sign-extend from 28-bits
 				tableVal |= 0xF000_0000
 			}
@@ -116,8 +116,8 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		lndo4Word := iPtr.variant.(lndo4WordT)
 		count := int32(cpu.ac[lndo4Word.acd])
 		memVarAddr := resolve31bitDisplacement(cpu, lndo4Word.ind, lndo4Word.mode, lndo4Word.disp31, iPtr.dispOffset)
-		memVar := int32(int16(memory.ReadWord(memVarAddr))) + 1
-		memory.WriteWord(memVarAddr, dg.WordT(memVar))
+		memVar := int32(int16(cpu.mem.ReadWord(memVarAddr))) + 1
+		cpu.mem.WriteWord(memVarAddr, dg.WordT(memVar))
 		cpu.ac[lndo4Word.acd] = dg.DwordT(memVar)
 		if memVar > count {
 			// loop ends
@@ -130,13 +130,13 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrLNDSZ, instrLNISZ:
 		noAccModeInd3Word := iPtr.variant.(noAccModeInd3WordT)
 		tmpAddr := resolve31bitDisplacement(cpu, noAccModeInd3Word.ind, noAccModeInd3Word.mode, noAccModeInd3Word.disp31, iPtr.dispOffset)
-		wd := memory.ReadWord(tmpAddr)
+		wd := cpu.mem.ReadWord(tmpAddr)
 		if iPtr.ix == instrLNDSZ {
 			wd--
 		} else {
 			wd++
 		}
-		memory.WriteWord(tmpAddr, wd)
+		cpu.mem.WriteWord(tmpAddr, wd)
 		if wd == 0 {
 			cpu.pc += 4
 		} else {
@@ -152,8 +152,8 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		lndo4Word := iPtr.variant.(lndo4WordT)
 		count := int32(cpu.ac[lndo4Word.acd])
 		memVarAddr := resolve31bitDisplacement(cpu, lndo4Word.ind, lndo4Word.mode, lndo4Word.disp31, iPtr.dispOffset)
-		memVar := int32(memory.ReadDWord(memVarAddr)) + 1
-		memory.WriteDWord(memVarAddr, dg.DwordT(memVar))
+		memVar := int32(cpu.mem.ReadDWord(memVarAddr)) + 1
+		cpu.mem.WriteDWord(memVarAddr, dg.DwordT(memVar))
 		cpu.ac[lndo4Word.acd] = dg.DwordT(memVar)
 		if memVar > count {
 			// loop ends
@@ -166,13 +166,13 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrLWDSZ, instrLWISZ:
 		noAccModeInd3Word := iPtr.variant.(noAccModeInd3WordT)
 		tmpAddr := resolve31bitDisplacement(cpu, noAccModeInd3Word.ind, noAccModeInd3Word.mode, noAccModeInd3Word.disp31, iPtr.dispOffset)
-		tmp32b := memory.ReadDWord(tmpAddr)
+		tmp32b := cpu.mem.ReadDWord(tmpAddr)
 		if iPtr.ix == instrLWDSZ {
 			tmp32b--
 		} else {
 			tmp32b++
 		}
-		memory.WriteDWord(tmpAddr, tmp32b)
+		cpu.mem.WriteDWord(tmpAddr, tmp32b)
 		if tmp32b == 0 {
 			cpu.pc += 4
 		} else {
@@ -213,16 +213,16 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		var h, l int32
 		v := int32(cpu.ac[twoAcc1Word.acs])
 		if twoAcc1Word.acs != twoAcc1Word.acd {
-			l = int32(memory.ReadDWord(dg.PhysAddrT(cpu.ac[twoAcc1Word.acd])))
-			h = int32(memory.ReadDWord(dg.PhysAddrT(cpu.ac[twoAcc1Word.acd] + 2)))
+			l = int32(cpu.mem.ReadDWord(dg.PhysAddrT(cpu.ac[twoAcc1Word.acd])))
+			h = int32(cpu.mem.ReadDWord(dg.PhysAddrT(cpu.ac[twoAcc1Word.acd] + 2)))
 			if v >= l && v <= h {
 				cpu.pc += 2
 			} else {
 				cpu.pc++
 			}
 		} else {
-			l = int32(memory.ReadDWord(cpu.pc + 1))
-			h = int32(memory.ReadDWord(cpu.pc + 3))
+			l = int32(cpu.mem.ReadDWord(cpu.pc + 1))
+			h = int32(cpu.mem.ReadDWord(cpu.pc + 3))
 			if v >= l && v <= h {
 				cpu.pc += 6
 			} else {
@@ -231,10 +231,10 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		}
 
 	case instrWMESS:
-		dwd := memory.ReadDWord(dg.PhysAddrT(cpu.ac[2]))
+		dwd := cpu.mem.ReadDWord(dg.PhysAddrT(cpu.ac[2]))
 		ord := dwd ^ cpu.ac[0]
 		if ord&cpu.ac[3] == 0 {
-			memory.WriteDWord(dg.PhysAddrT(cpu.ac[2]), cpu.ac[1])
+			cpu.mem.WriteDWord(dg.PhysAddrT(cpu.ac[2]), cpu.ac[1])
 			cpu.ac[1] = dwd
 			cpu.pc += 2
 		} else {
@@ -335,7 +335,7 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrWSNB:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		tmpAddr, bit := resolveEagleBitAddr(cpu, &twoAcc1Word)
-		wd := memory.ReadWord(tmpAddr)
+		wd := cpu.mem.ReadWord(tmpAddr)
 		if memory.TestWbit(wd, int(bit)) {
 			cpu.pc += 2
 		} else {
@@ -348,7 +348,7 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrWSZB:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		tmpAddr, bit := resolveEagleBitAddr(cpu, &twoAcc1Word)
-		wd := memory.ReadWord(tmpAddr)
+		wd := cpu.mem.ReadWord(tmpAddr)
 		if !memory.TestWbit(wd, int(bit)) {
 			cpu.pc += 2
 		} else {
@@ -361,10 +361,10 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrWSZBO:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		tmpAddr, bit := resolveEagleBitAddr(cpu, &twoAcc1Word)
-		wd := memory.ReadWord(tmpAddr)
+		wd := cpu.mem.ReadWord(tmpAddr)
 		if !memory.TestWbit(wd, int(bit)) {
 			memory.SetWbit(&wd, uint(bit))
-			memory.WriteWord(tmpAddr, wd)
+			cpu.mem.WriteWord(tmpAddr, wd)
 			cpu.pc += 2
 		} else {
 			cpu.pc++
@@ -430,11 +430,11 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXNDO: // Narrow Do Until Greater Than
 		threeWordDo := iPtr.variant.(threeWordDoT)
 		loopVarAddr := resolve15bitDisplacement(cpu, threeWordDo.ind, threeWordDo.mode, dg.WordT(threeWordDo.disp15), iPtr.dispOffset)
-		//loopVar := int32(int16(memory.ReadWord(loopVarAddr + 1)))
-		loopVar := int32(int16(memory.ReadWord(loopVarAddr)))
+		//loopVar := int32(int16(cpu.mem.ReadWord(loopVarAddr + 1)))
+		loopVar := int32(int16(cpu.mem.ReadWord(loopVarAddr)))
 		loopVar++
-		//memory.WriteDWord(loopVarAddr, dg.DwordT(loopVar))
-		memory.WriteWord(loopVarAddr, dg.WordT(loopVar))
+		//cpu.mem.WriteDWord(loopVarAddr, dg.DwordT(loopVar))
+		cpu.mem.WriteWord(loopVarAddr, dg.WordT(loopVar))
 		acVar := int32(cpu.ac[threeWordDo.acd])
 		//log.Printf("\t loopVar: %#x, acVar: %#x\n", loopVar, acVar)
 		cpu.ac[threeWordDo.acd] = dg.DwordT(loopVar)
@@ -449,13 +449,13 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 
 	case instrXNDSZ, instrXNISZ: // unsigned narrow inc/decrement and skip if zero
 		tmpAddr := resolve15bitDisplacement(cpu, iPtr.ind, iPtr.mode, dg.WordT(iPtr.disp15), iPtr.dispOffset)
-		wd := memory.ReadWord(tmpAddr)
+		wd := cpu.mem.ReadWord(tmpAddr)
 		if iPtr.ix == instrXNDSZ {
 			wd-- // N.B. have checked that 0xffff + 1 == 0 in Go
 		} else {
 			wd++
 		}
-		memory.WriteWord(tmpAddr, wd)
+		cpu.mem.WriteWord(tmpAddr, wd)
 		if wd == 0 {
 			cpu.pc += 3
 		} else {
@@ -465,9 +465,9 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrXWDO:
 		threeWordDo := iPtr.variant.(threeWordDoT)
 		loopVarAddr := resolve15bitDisplacement(cpu, threeWordDo.ind, threeWordDo.mode, dg.WordT(threeWordDo.disp15), iPtr.dispOffset)
-		loopVar := int32(memory.ReadDWord(loopVarAddr))
+		loopVar := int32(cpu.mem.ReadDWord(loopVarAddr))
 		loopVar++
-		memory.WriteDWord(loopVarAddr, dg.DwordT(loopVar))
+		cpu.mem.WriteDWord(loopVarAddr, dg.DwordT(loopVar))
 		acVar := int32(cpu.ac[threeWordDo.acd])
 		cpu.ac[threeWordDo.acd] = dg.DwordT(loopVar)
 		if loopVar > acVar {
@@ -479,9 +479,9 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 
 	case instrXWDSZ:
 		tmpAddr := resolve15bitDisplacement(cpu, iPtr.ind, iPtr.mode, dg.WordT(iPtr.disp15), iPtr.dispOffset)
-		dwd := memory.ReadDWord(tmpAddr)
+		dwd := cpu.mem.ReadDWord(tmpAddr)
 		dwd--
-		memory.WriteDWord(tmpAddr, dwd)
+		cpu.mem.WriteDWord(tmpAddr, dwd)
 		if dwd == 0 {
 			cpu.pc += 3
 		} else {
@@ -490,9 +490,9 @@ func eaglePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 
 	case instrXWISZ:
 		tmpAddr := resolve15bitDisplacement(cpu, iPtr.ind, iPtr.mode, dg.WordT(iPtr.disp15), iPtr.dispOffset)
-		dwd := memory.ReadDWord(tmpAddr)
+		dwd := cpu.mem.ReadDWord(tmpAddr)
 		dwd++
-		memory.WriteDWord(tmpAddr, dwd)
+		cpu.mem.WriteDWord(tmpAddr, dwd)
 		if dwd == 0 {
 			cpu.pc += 3
 		} else {
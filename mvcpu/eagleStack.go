@@ -72,7 +72,7 @@ func eagleStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 		cpu.SetOVR(false)
 
 	case instrLDATS:
-		cpu.ac[iPtr.ac] = memory.ReadDWord(cpu.wsp)
+		cpu.ac[iPtr.ac] = cpu.mem.ReadDWord(cpu.wsp)
 		cpu.SetOVR(false)
 
 	case instrLPEF:
@@ -100,31 +100,31 @@ func eagleStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 		// FIXME handle segments
 		cpu.wfp = dg.PhysAddrT(cpu.ac[iPtr.ac])
 		// according the PoP does not write through to page zero...
-		//memory.WriteDWord(memory.wfpLoc, cpu.ac[iPtr.ac])
+		//cpu.mem.WriteDWord(memory.wfpLoc, cpu.ac[iPtr.ac])
 		cpu.SetOVR(false)
 
 	case instrSTASB:
 		cpu.wsb = dg.PhysAddrT(cpu.ac[iPtr.ac])
-		memory.WriteDWord((cpu.pc&0x7000_0000)|wsbLoc, cpu.ac[iPtr.ac]) // write-through to p.0
+		cpu.mem.WriteDWord((cpu.pc&0x7000_0000)|wsbLoc, cpu.ac[iPtr.ac]) // write-through to p.0
 		cpu.SetOVR(false)
 
 	case instrSTASL:
 		cpu.wsl = dg.PhysAddrT(cpu.ac[iPtr.ac])
-		memory.WriteDWord((cpu.pc&0x7000_0000)|wslLoc, cpu.ac[iPtr.ac]) // write-through to p.0
+		cpu.mem.WriteDWord((cpu.pc&0x7000_0000)|wslLoc, cpu.ac[iPtr.ac]) // write-through to p.0
 		cpu.SetOVR(false)
 
 	case instrSTASP:
 		// FIXME handle segments
 		cpu.wsp = dg.PhysAddrT(cpu.ac[iPtr.ac])
 		// according the PoP does not write through to page zero...
-		// memory.WriteDWord(memory.wspLoc, cpu.ac[iPtr.ac])
+		// cpu.mem.WriteDWord(memory.wspLoc, cpu.ac[iPtr.ac])
 		cpu.SetOVR(false)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... STASP set WSP to %#o\n", cpu.ac[iPtr.ac])
 		}
 
 	case instrSTATS:
-		memory.WriteDWord(cpu.wsp, cpu.ac[iPtr.ac])
+		cpu.mem.WriteDWord(cpu.wsp, cpu.ac[iPtr.ac])
 		cpu.SetOVR(false)
 
 	case instrWFPOP:
@@ -132,33 +132,7 @@ func eagleStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 		cpu.fpac[2] = memory.DGdoubleToFloat64(wsPopQWord(cpu))
 		cpu.fpac[1] = memory.DGdoubleToFloat64(wsPopQWord(cpu))
 		cpu.fpac[0] = memory.DGdoubleToFloat64(wsPopQWord(cpu))
-		tmpQwd := wsPopQWord(cpu)
-		cpu.fpsr = 0
-		any := false
-		// set the ANY bit?
-		if memory.GetQwbits(tmpQwd, 1, 4) != 0 {
-			memory.SetQwbit(&cpu.fpsr, 0)
-			any = true
-		}
-		// copy bits 1-11
-		for b := 1; b <= 11; b++ {
-			if memory.TestQwbit(tmpQwd, b) {
-				memory.SetQwbit(&cpu.fpsr, uint(b))
-			}
-		}
-		// bits 28-31
-		if any {
-			for b := 28; b <= 31; b++ {
-				if memory.TestQwbit(tmpQwd, b) {
-					memory.SetQwbit(&cpu.fpsr, uint(b))
-				}
-			}
-			for b := 33; b <= 63; b++ {
-				if memory.TestQwbit(tmpQwd, b) {
-					memory.SetQwbit(&cpu.fpsr, uint(b))
-				}
-			}
-		}
+		cpu.fpsr = restoredFPSR(wsPopQWord(cpu))
 
 	case instrWFPSH:
 		wsPushQWord(cpu, cpu.fpsr) // TODO Is this right?
@@ -393,7 +367,7 @@ func wssav(cpu *CPUT, u2wd *unique2WordT) {
 func wsPush(cpu *CPUT, data dg.DwordT) {
 	// TODO overflow/underflow handling - either here or in instruction?
 	cpu.wsp += 2
-	memory.WriteDWord(cpu.wsp, data)
+	cpu.mem.WriteDWord(cpu.wsp, data)
 	if cpu.debugLogging {
 		logging.DebugPrint(logging.DebugLog, "... wsPush pushed %#o onto the Wide Stack at location: %#o\n", data, cpu.wsp)
 	}
@@ -401,14 +375,44 @@ func wsPush(cpu *CPUT, data dg.DwordT) {
 
 func wsPushQWord(cpu *CPUT, qw dg.QwordT) {
 	cpu.wsp += 2
-	memory.WriteDWord(cpu.wsp, dg.DwordT(qw>>32))
+	cpu.mem.WriteDWord(cpu.wsp, dg.DwordT(qw>>32))
 	cpu.wsp += 2
-	memory.WriteDWord(cpu.wsp, dg.DwordT(qw))
+	cpu.mem.WriteDWord(cpu.wsp, dg.DwordT(qw))
+}
+
+// restoredFPSR returns the FPSR as reloaded from a saved floating-point state
+func restoredFPSR(saved dg.QwordT) (fpsr dg.QwordT) {
+	any := false
+	// set the ANY bit?
+	if memory.GetQwbits(saved, 1, 4) != 0 {
+		memory.SetQwbit(&fpsr, 0)
+		any = true
+	}
+	// copy bits 1-11
+	for b := 1; b <= 11; b++ {
+		if memory.TestQwbit(saved, b) {
+			memory.SetQwbit(&fpsr, uint(b))
+		}
+	}
+	// bits 28-31
+	if any {
+		for b := 28; b <= 31; b++ {
+			if memory.TestQwbit(saved, b) {
+				memory.SetQwbit(&fpsr, uint(b))
+			}
+		}
+		for b := 33; b <= 63; b++ {
+			if memory.TestQwbit(saved, b) {
+				memory.SetQwbit(&fpsr, uint(b))
+			}
+		}
+	}
+	return fpsr
 }
 
 // WsPop - POP a doubleword off the Wide Stack
 func WsPop(cpu *CPUT) (dword dg.DwordT) {
-	dword = memory.ReadDWord(cpu.wsp)
+	dword = cpu.mem.ReadDWord(cpu.wsp)
 	cpu.wsp -= 2
 	if cpu.debugLogging {
 		logging.DebugPrint(logging.DebugLog, "... WsPop  popped %#o off  the Wide Stack at location: %#o\n", dword, cpu.wsp+2)
@@ -490,7 +494,7 @@ func wspHandleFault(cpu *CPUT, instrLen int, primaryFault, secondaryFault int) {
 	// Step 8
 	cpu.ac[1] = dg.DwordT(primaryFault)
 	// Step 9
-	wsfhAddr := dg.PhysAddrT(memory.ReadWord((cpu.pc & 0x7000_0000) | wsfhLoc))
+	wsfhAddr := dg.PhysAddrT(cpu.mem.ReadWord((cpu.pc & 0x7000_0000) | wsfhLoc))
 	wsfhAddr |= (cpu.pc & 0x7000_0000)
 	log.Printf("DEBUG: Calling Wide Stack Fault Handler at %#x (#%o)", wsfhAddr, wsfhAddr)
 	cpu.pc = wsfhAddr
@@ -498,8 +502,8 @@ func wspHandleFault(cpu *CPUT, instrLen int, primaryFault, secondaryFault int) {
 
 func wsSaveToMemory(cpu *CPUT) {
 	seg := (cpu.pc & 0x7000_0000)
-	memory.WriteDWord(seg+wfpLoc, dg.DwordT(cpu.wfp))
-	memory.WriteDWord(seg+wspLoc, dg.DwordT(cpu.wsp))
-	memory.WriteDWord(seg+wslLoc, dg.DwordT(cpu.wsl))
-	memory.WriteDWord(seg+wsbLoc, dg.DwordT(cpu.wsb))
+	cpu.mem.WriteDWord(seg+wfpLoc, dg.DwordT(cpu.wfp))
+	cpu.mem.WriteDWord(seg+wspLoc, dg.DwordT(cpu.wsp))
+	cpu.mem.WriteDWord(seg+wslLoc, dg.DwordT(cpu.wsl))
+	cpu.mem.WriteDWord(seg+wsbLoc, dg.DwordT(cpu.wsb))
 }
@@ -65,3 +65,22 @@ func TestPopQWord(t *testing.T) {
 		t.Errorf("Expected 0x1111222233334444, got %x", r)
 	}
 }
+
+func TestFPUStateSaveAndLoad(t *testing.T) {
+	cpu := new(CPUT)
+	memory.MemInit(1000, false)
+	cpu.fpac = [4]float64{1.5, -2.25, 0, 1000}
+	memory.SetQwbit(&cpu.fpsr, fpsrN)
+	cpu.StoreFPUState(100)
+	cpu.ResetFPU()
+	if cpu.fpac[0] != 0 || cpu.fpsr != 0 {
+		t.Error("Expected ResetFPU to clear the FPU")
+	}
+	cpu.LoadFPUState(100)
+	if cpu.fpac != [4]float64{1.5, -2.25, 0, 1000} {
+		t.Errorf("Expected FPACs to be restored, got %v", cpu.fpac)
+	}
+	if !memory.TestQwbit(cpu.fpsr, fpsrN) {
+		t.Errorf("Expected FPSR N bit to be restored, got %x", cpu.fpsr)
+	}
+}
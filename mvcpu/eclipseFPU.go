@@ -107,7 +107,7 @@ func eclipseFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= (cpu.pc & ringMask32)
-		cpu.fpac[oneAccModeInd2Word.acd] = memory.DGsingleToFloat64(memory.ReadDWord(addr))
+		cpu.fpac[oneAccModeInd2Word.acd] = memory.DGsingleToFloat64(cpu.mem.ReadDWord(addr))
 		cpu.SetZ(cpu.fpac[oneAccModeInd2Word.acd] == 0.0)
 		cpu.SetN(cpu.fpac[oneAccModeInd2Word.acd] < 0.0)
 
@@ -193,15 +193,15 @@ func eclipseFPU(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr := resolve15bitDisplacement(cpu, iPtr.ind, iPtr.mode, dg.WordT(iPtr.disp15), iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= (cpu.pc & ringMask32)
-		memory.WriteWord(addr, dg.WordT(cpu.fpsr>>48))
-		memory.WriteWord(addr+1, dg.WordT(cpu.fpsr)) // last word of FPSR
+		cpu.mem.WriteWord(addr, dg.WordT(cpu.fpsr>>48))
+		cpu.mem.WriteWord(addr+1, dg.WordT(cpu.fpsr)) // last word of FPSR
 
 	case instrFSTS:
 		oneAccModeInd2Word := iPtr.variant.(oneAccModeInd2WordT)
 		addr := resolve15bitDisplacement(cpu, oneAccModeInd2Word.ind, oneAccModeInd2Word.mode, dg.WordT(oneAccModeInd2Word.disp15), iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= (cpu.pc & ringMask32)
-		memory.WriteDWord(addr, memory.Float64toDGsingle(cpu.fpac[oneAccModeInd2Word.acd]))
+		cpu.mem.WriteDWord(addr, memory.Float64toDGsingle(cpu.fpac[oneAccModeInd2Word.acd]))
 
 	case instrFTD:
 		memory.ClearQwbit(&cpu.fpsr, fpsrTe)
@@ -51,7 +51,7 @@ func eclipseMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 			logging.DebugPrint(logging.DebugLog, fmt.Sprintf("BLM moving %d words from %d to %d\n", numWds, src, dest))
 		}
 		for numWds != 0 {
-			memory.WriteWord(dest, memory.ReadWord(src))
+			cpu.mem.WriteWord(dest, cpu.mem.ReadWord(src))
 			numWds--
 			src++
 			dest++
@@ -65,13 +65,13 @@ func eclipseMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		addr, bitNum := resolveEclipseBitAddr(cpu, &twoAcc1Word)
 		addr |= ring
-		wd := memory.ReadWord(addr)
+		wd := cpu.mem.ReadWord(addr)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... BTO Addr: %d, Bit: %d, Before: %s\n",
 				addr, bitNum, memory.WordToBinStr(wd))
 		}
 		memory.SetWbit(&wd, bitNum)
-		memory.WriteWord(addr, wd)
+		cpu.mem.WriteWord(addr, wd)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... BTO                     Result: %s\n", memory.WordToBinStr(wd))
 		}
@@ -81,12 +81,12 @@ func eclipseMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		addr, bitNum := resolveEclipseBitAddr(cpu, &twoAcc1Word)
 		addr |= ring
-		wd := memory.ReadWord(addr)
+		wd := cpu.mem.ReadWord(addr)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... BTZ Addr: %d, Bit: %d, Before: %s\n", addr, bitNum, memory.WordToBinStr(wd))
 		}
 		memory.ClearWbit(&wd, bitNum)
-		memory.WriteWord(addr, wd)
+		cpu.mem.WriteWord(addr, wd)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... BTZ                     Result: %s\n",
 				memory.WordToBinStr(wd))
@@ -102,7 +102,7 @@ func eclipseMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr := resolve15bitDisplacement(cpu, oneAccModeInt2Word.ind, oneAccModeInt2Word.mode, dg.WordT(oneAccModeInt2Word.disp15), iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= ring
-		cpu.ac[oneAccModeInt2Word.acd] = dg.DwordT(memory.ReadWord(addr))
+		cpu.ac[oneAccModeInt2Word.acd] = dg.DwordT(cpu.mem.ReadWord(addr))
 
 	case instrELEF:
 		oneAccModeInt2Word := iPtr.variant.(oneAccModeInd2WordT)
@@ -117,11 +117,11 @@ func eclipseMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr := resolve15bitDisplacement(cpu, oneAccModeInt2Word.ind, oneAccModeInt2Word.mode, dg.WordT(oneAccModeInt2Word.disp15), iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= ring
-		memory.WriteWord(addr, memory.DwordGetLowerWord(cpu.ac[oneAccModeInt2Word.acd]))
+		cpu.mem.WriteWord(addr, memory.DwordGetLowerWord(cpu.ac[oneAccModeInt2Word.acd]))
 
 	case instrLDB:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
-		cpu.ac[twoAcc1Word.acd] = dg.DwordT(memory.ReadByteEclipseBA(cpu.pc, memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acs])))
+		cpu.ac[twoAcc1Word.acd] = dg.DwordT(cpu.mem.ReadByteEclipseBA(cpu.pc, memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acs])))
 
 	case instrLEF:
 		novaOneAccEffAddr := iPtr.variant.(novaOneAccEffAddrT)
@@ -137,7 +137,7 @@ func eclipseMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr &= 0x7fff
 		addr |= ring
 		byt := dg.ByteT(cpu.ac[twoAcc1Word.acd])
-		memory.WriteByte(addr, hiLo, byt)
+		cpu.mem.WriteByteWA(addr, hiLo, byt)
 
 	default:
 		log.Panicf("ERROR: ECLIPSE_MEMREF instruction <%s> not yet implemented\n", iPtr.mnemonic)
@@ -162,12 +162,12 @@ func cmp(cpu *CPUT) {
 	res := 0
 	for {
 		if str1len != 0 {
-			byte1 = memory.ReadByteEclipseBA(cpu.pc, str1bp)
+			byte1 = cpu.mem.ReadByteEclipseBA(cpu.pc, str1bp)
 		} else {
 			byte1 = ' '
 		}
 		if str2len != 0 {
-			byte2 = memory.ReadByteEclipseBA(cpu.pc, str2bp)
+			byte2 = cpu.mem.ReadByteEclipseBA(cpu.pc, str2bp)
 		} else {
 			byte2 = ' '
 		}
@@ -228,7 +228,7 @@ func cmv(cpu *CPUT) {
 	// 1st move srcCount bytes
 	ring := dg.DwordT(cpu.pc & 0x7000_0000)
 	for {
-		copyByte(cpu.ac[3]|ring, cpu.ac[2]|ring)
+		copyByte(cpu, cpu.ac[3]|ring, cpu.ac[2]|ring)
 		if srcAscend {
 			cpu.ac[3]++
 			srcCount--
@@ -250,7 +250,7 @@ func cmv(cpu *CPUT) {
 	// now fill any excess bytes with ASCII spaces
 	if destCount != 0 {
 		for {
-			memWriteByteBA(dg.ASCIISPC, cpu.ac[2]|ring)
+			memWriteByteBA(cpu, dg.ASCIISPC, cpu.ac[2]|ring)
 			if destAscend {
 				cpu.ac[2]++
 				destCount--
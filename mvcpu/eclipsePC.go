@@ -43,16 +43,16 @@ func eclipsePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		acs := int16(memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acs]))
 		if twoAcc1Word.acs == twoAcc1Word.acd {
-			l = int16(memory.ReadWord(cpu.pc + 1))
-			h = int16(memory.ReadWord(cpu.pc + 2))
+			l = int16(cpu.mem.ReadWord(cpu.pc + 1))
+			h = int16(cpu.mem.ReadWord(cpu.pc + 2))
 			if acs < l || acs > h {
 				inc = 3
 			} else {
 				inc = 4
 			}
 		} else {
-			l = int16(memory.ReadWord(dg.PhysAddrT(memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acd])) | ring))
-			h = int16(memory.ReadWord(dg.PhysAddrT(memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acd])+1) | ring))
+			l = int16(cpu.mem.ReadWord(dg.PhysAddrT(memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acd])) | ring))
+			h = int16(cpu.mem.ReadWord(dg.PhysAddrT(memory.DwordGetLowerWord(cpu.ac[twoAcc1Word.acd])+1) | ring))
 			if acs < l || acs > h {
 				inc = 1
 			} else {
@@ -71,8 +71,8 @@ func eclipsePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		tableStart &= 0x7fff
 		tableStart |= ring
 		offset := memory.DwordGetLowerWord(cpu.ac[oneAccModeInt2Word.acd])
-		lowLimit := memory.ReadWord(tableStart - 2)
-		hiLimit := memory.ReadWord(tableStart - 1)
+		lowLimit := cpu.mem.ReadWord(tableStart - 2)
+		hiLimit := cpu.mem.ReadWord(tableStart - 1)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "DSPA called with table at %d, offset %d, lo %d hi %d\n",
 				tableStart, offset, lowLimit, hiLimit)
@@ -81,7 +81,7 @@ func eclipsePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 			log.Fatalf("ERROR: DPSA called with out of bounds offset %d", offset)
 		}
 		entry := tableStart - dg.PhysAddrT(lowLimit) + dg.PhysAddrT(offset)
-		addr := dg.PhysAddrT(memory.ReadWord(entry))
+		addr := dg.PhysAddrT(cpu.mem.ReadWord(entry))
 		if addr == 0xffffffff {
 			cpu.pc += 2
 		} else {
@@ -92,9 +92,9 @@ func eclipsePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		addr := resolve15bitDisplacement(cpu, iPtr.ind, iPtr.mode, iPtr.disp15, iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= ring
-		wd := memory.ReadWord(addr)
+		wd := cpu.mem.ReadWord(addr)
 		wd++
-		memory.WriteWord(addr, wd)
+		cpu.mem.WriteWord(addr, wd)
 		if wd == 0 {
 			cpu.pc += 3
 		} else {
@@ -144,7 +144,7 @@ func eclipsePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		addr, bit := resolveEclipseBitAddr(cpu, &twoAcc1Word)
 		addr |= ring
-		wd := memory.ReadWord(addr)
+		wd := cpu.mem.ReadWord(addr)
 		if memory.TestWbit(wd, int(bit)) {
 			cpu.pc += 2
 		} else {
@@ -159,11 +159,11 @@ func eclipsePC(cpu *CPUT, iPtr *decodedInstrT) bool {
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
 		addr, bit := resolveEclipseBitAddr(cpu, &twoAcc1Word)
 		addr |= (cpu.pc & ringMask32)
-		wd := memory.ReadWord(addr)
+		wd := cpu.mem.ReadWord(addr)
 		if !memory.TestWbit(wd, int(bit)) {
 			if iPtr.ix == instrSZBO {
 				memory.SetWbit(&wd, bit)
-				memory.WriteWord(addr, wd)
+				cpu.mem.WriteWord(addr, wd)
 			}
 			cpu.pc += 2
 		} else {
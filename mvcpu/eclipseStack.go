@@ -38,8 +38,8 @@ func eclipseStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 	case instrMSP:
 		// TODO handle overflow
 		s16 := int16(cpu.ac[iPtr.ac])
-		nsp := int16(memory.ReadWord(memory.NspLoc|ring)) + s16
-		memory.WriteWord(memory.NspLoc|ring, dg.WordT(nsp))
+		nsp := int16(cpu.mem.ReadWord(memory.NspLoc|ring)) + s16
+		cpu.mem.WriteWord(memory.NspLoc|ring, dg.WordT(nsp))
 
 	case instrPOP:
 		twoAcc1Word := iPtr.variant.(twoAcc1WordT)
@@ -54,11 +54,11 @@ func eclipseStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 				logging.DebugPrint(logging.DebugLog, "... narrow popping AC%d\n", acsUp[thisAc])
 			}
 
-			cpu.ac[acsUp[thisAc]] = dg.DwordT(memory.NsPop(ring, cpu.debugLogging))
+			cpu.ac[acsUp[thisAc]] = dg.DwordT(cpu.mem.NsPop(ring, cpu.debugLogging))
 		}
 
 	case instrPOPJ:
-		addr := dg.PhysAddrT(memory.NsPop(ring, cpu.debugLogging))
+		addr := dg.PhysAddrT(cpu.mem.NsPop(ring, cpu.debugLogging))
 		cpu.pc = (addr & 0x7fff) | ring
 		return true // because PC set
 
@@ -74,11 +74,11 @@ func eclipseStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 			if cpu.debugLogging {
 				logging.DebugPrint(logging.DebugLog, "... narrow pushing AC%d\n", acsUp[thisAc])
 			}
-			memory.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[acsUp[thisAc]]), cpu.debugLogging)
+			cpu.mem.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[acsUp[thisAc]]), cpu.debugLogging)
 		}
 
 	case instrPSHJ:
-		memory.NsPush(ring, dg.WordT(cpu.pc)+2, cpu.debugLogging)
+		cpu.mem.NsPush(ring, dg.WordT(cpu.pc)+2, cpu.debugLogging)
 		addr := resolve15bitDisplacement(cpu, iPtr.ind, iPtr.mode, iPtr.disp15, iPtr.dispOffset)
 		addr &= 0x7fff
 		addr |= ring
@@ -87,69 +87,69 @@ func eclipseStack(cpu *CPUT, iPtr *decodedInstrT) bool {
 
 	case instrRTN:
 		// // complement of SAVE
-		// memory.WriteWord(memory.NspLoc, memory.ReadWord(memory.NfpLoc)) // ???
-		// //memory.WriteWord(memory.NfpLoc, memory.ReadWord(memory.NspLoc)) // ???
-		// word := memory.NsPop(0, cpu.debugLogging)
+		// cpu.mem.WriteWord(memory.NspLoc, cpu.mem.ReadWord(memory.NfpLoc)) // ???
+		// //cpu.mem.WriteWord(memory.NfpLoc, cpu.mem.ReadWord(memory.NspLoc)) // ???
+		// word := cpu.mem.NsPop(0, cpu.debugLogging)
 		// cpu.carry = memory.TestWbit(word, 0)
 		// cpu.pc = dg.PhysAddrT(word) & 0x7fff
-		// //nfpSave := memory.NsPop(0)               // 1
-		// cpu.ac[3] = dg.DwordT(memory.NsPop(0, cpu.debugLogging)) // 2
-		// cpu.ac[2] = dg.DwordT(memory.NsPop(0, cpu.debugLogging)) // 3
-		// cpu.ac[1] = dg.DwordT(memory.NsPop(0, cpu.debugLogging)) // 4
-		// cpu.ac[0] = dg.DwordT(memory.NsPop(0, cpu.debugLogging)) // 5
-		// memory.WriteWord(memory.NfpLoc, memory.DWordGetLowerWord(cpu.ac[3]))
+		// //nfpSave := cpu.mem.NsPop(0)               // 1
+		// cpu.ac[3] = dg.DwordT(cpu.mem.NsPop(0, cpu.debugLogging)) // 2
+		// cpu.ac[2] = dg.DwordT(cpu.mem.NsPop(0, cpu.debugLogging)) // 3
+		// cpu.ac[1] = dg.DwordT(cpu.mem.NsPop(0, cpu.debugLogging)) // 4
+		// cpu.ac[0] = dg.DwordT(cpu.mem.NsPop(0, cpu.debugLogging)) // 5
+		// cpu.mem.WriteWord(memory.NfpLoc, memory.DWordGetLowerWord(cpu.ac[3]))
 		// return true // because PC set
 
-		nfpSav := memory.ReadWord(memory.NfpLoc | ring)
-		memory.WriteWord(memory.NspLoc|(ring), nfpSav)
-		pwd1 := memory.NsPop(ring, cpu.debugLogging) // 1
+		nfpSav := cpu.mem.ReadWord(memory.NfpLoc | ring)
+		cpu.mem.WriteWord(memory.NspLoc|(ring), nfpSav)
+		pwd1 := cpu.mem.NsPop(ring, cpu.debugLogging) // 1
 		cpu.carry = memory.TestWbit(pwd1, 0)
 		cpu.pc = dg.PhysAddrT((pwd1 & 0x07fff)) | ring
-		cpu.ac[3] = dg.DwordT(memory.NsPop(ring, cpu.debugLogging)) // 2
-		cpu.ac[2] = dg.DwordT(memory.NsPop(ring, cpu.debugLogging)) // 3
-		cpu.ac[1] = dg.DwordT(memory.NsPop(ring, cpu.debugLogging)) // 4
-		cpu.ac[0] = dg.DwordT(memory.NsPop(ring, cpu.debugLogging)) // 5
-		//memory.WriteWord(memory.NspLoc, nfpSav-5)
-		memory.WriteWord(memory.NfpLoc|ring, memory.DwordGetLowerWord(cpu.ac[3]))
+		cpu.ac[3] = dg.DwordT(cpu.mem.NsPop(ring, cpu.debugLogging)) // 2
+		cpu.ac[2] = dg.DwordT(cpu.mem.NsPop(ring, cpu.debugLogging)) // 3
+		cpu.ac[1] = dg.DwordT(cpu.mem.NsPop(ring, cpu.debugLogging)) // 4
+		cpu.ac[0] = dg.DwordT(cpu.mem.NsPop(ring, cpu.debugLogging)) // 5
+		//cpu.mem.WriteWord(memory.NspLoc, nfpSav-5)
+		cpu.mem.WriteWord(memory.NfpLoc|ring, memory.DwordGetLowerWord(cpu.ac[3]))
 
 		return true // because PC set
 
 	case instrSAVE:
 		unique2Word := iPtr.variant.(unique2WordT)
 		i := dg.WordT(unique2Word.immU16)
-		nfpSav := memory.ReadWord(memory.NfpLoc | ring)
-		nspSav := memory.ReadWord(memory.NspLoc | ring)
+		nfpSav := cpu.mem.ReadWord(memory.NfpLoc | ring)
+		nspSav := cpu.mem.ReadWord(memory.NspLoc | ring)
 
 		// // version based in simH Nova SAVn
-		// memory.WriteWord(memory.NspLoc, nspSav+i)
-		// memory.NsPush(ring, memory.DWordGetLowerWord(cpu.ac[0]), cpu.debugLogging) // 1
-		// memory.NsPush(ring, memory.DWordGetLowerWord(cpu.ac[1]), cpu.debugLogging) // 2
-		// memory.NsPush(ring, memory.DWordGetLowerWord(cpu.ac[2]), cpu.debugLogging) // 3
-		// memory.NsPush(ring, nfpSav, cpu.debugLogging)                               // 4
+		// cpu.mem.WriteWord(memory.NspLoc, nspSav+i)
+		// cpu.mem.NsPush(ring, memory.DWordGetLowerWord(cpu.ac[0]), cpu.debugLogging) // 1
+		// cpu.mem.NsPush(ring, memory.DWordGetLowerWord(cpu.ac[1]), cpu.debugLogging) // 2
+		// cpu.mem.NsPush(ring, memory.DWordGetLowerWord(cpu.ac[2]), cpu.debugLogging) // 3
+		// cpu.mem.NsPush(ring, nfpSav, cpu.debugLogging)                               // 4
 		// word := memory.DWordGetLowerWord(cpu.ac[3])
 		// if cpu.carry {
 		// 	word |= 0x8000
 		// } else {
 		// 	word &= 0x7fff
 		// }
-		// memory.NsPush(ring, word, cpu.debugLogging) // 5
-		// cpu.ac[3] = dg.DwordT(memory.ReadWord(memory.NspLoc))
-		// memory.WriteWord(memory.NfpLoc, memory.DWordGetLowerWord(cpu.ac[3]))
+		// cpu.mem.NsPush(ring, word, cpu.debugLogging) // 5
+		// cpu.ac[3] = dg.DwordT(cpu.mem.ReadWord(memory.NspLoc))
+		// cpu.mem.WriteWord(memory.NfpLoc, memory.DWordGetLowerWord(cpu.ac[3]))
 
 		// version based on 32-bit PoP
-		memory.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[0]), cpu.debugLogging) // 1
-		memory.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[1]), cpu.debugLogging) // 2
-		memory.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[2]), cpu.debugLogging) // 3
-		memory.NsPush(ring, nfpSav, cpu.debugLogging)                              // 4
+		cpu.mem.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[0]), cpu.debugLogging) // 1
+		cpu.mem.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[1]), cpu.debugLogging) // 2
+		cpu.mem.NsPush(ring, memory.DwordGetLowerWord(cpu.ac[2]), cpu.debugLogging) // 3
+		cpu.mem.NsPush(ring, nfpSav, cpu.debugLogging)                              // 4
 		word := memory.DwordGetLowerWord(cpu.ac[3])
 		if cpu.carry {
 			word |= 0x8000
 		} else {
 			word &= 0x7fff
 		}
-		memory.NsPush(ring, word, cpu.debugLogging) // 5
-		memory.WriteWord(memory.NspLoc|ring, nspSav+5+i)
-		memory.WriteWord(memory.NfpLoc|ring, nspSav+5)
+		cpu.mem.NsPush(ring, word, cpu.debugLogging) // 5
+		cpu.mem.WriteWord(memory.NspLoc|ring, nspSav+5+i)
+		cpu.mem.WriteWord(memory.NfpLoc|ring, nspSav+5)
 		cpu.ac[3] = dg.DwordT(nspSav + 5)
 
 	default:
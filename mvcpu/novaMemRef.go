@@ -46,9 +46,9 @@ func novaMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		// if effAddr != effAddrNew {
 		// 	runtime.Breakpoint()
 		// }
-		shifter = memory.ReadWord(effAddr)
+		shifter = cpu.mem.ReadWord(effAddr)
 		shifter--
-		memory.WriteWord(effAddr, shifter)
+		cpu.mem.WriteWord(effAddr, shifter)
 		if shifter == 0 {
 			cpu.pc++
 		}
@@ -57,9 +57,9 @@ func novaMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		// effAddr = resolve16bitEffAddr(cpu, iPtr.ind, iPtr.mode, iPtr.disp15, iPtr.dispOffset)
 		effAddr = resolve8bitDisplacement(cpu, iPtr.ind, iPtr.mode, int16(iPtr.disp15)) & 0x7fff
 		effAddr |= ring // constrain to current segment
-		shifter = memory.ReadWord(effAddr)
+		shifter = cpu.mem.ReadWord(effAddr)
 		shifter++
-		memory.WriteWord(effAddr, shifter)
+		cpu.mem.WriteWord(effAddr, shifter)
 		if shifter == 0 {
 			cpu.pc++
 		}
@@ -69,7 +69,7 @@ func novaMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		// effAddr = resolve16bitEffAddr(cpu, novaOneAccEffAddr.ind, novaOneAccEffAddr.mode, novaOneAccEffAddr.disp15, iPtr.dispOffset)
 		effAddr = resolve8bitDisplacement(cpu, novaOneAccEffAddr.ind, novaOneAccEffAddr.mode, novaOneAccEffAddr.disp15) & 0x7fff
 		effAddr |= ring // constrain to current segment
-		shifter = memory.ReadWord(effAddr)
+		shifter = cpu.mem.ReadWord(effAddr)
 		//log.Printf("DEBUG: LDA loading AC from resolved address %#o\n", effAddr)
 		cpu.ac[novaOneAccEffAddr.acd] = 0x0000ffff & dg.DwordT(shifter)
 
@@ -79,11 +79,11 @@ func novaMemRef(cpu *CPUT, iPtr *decodedInstrT) bool {
 		// effAddr = resolve16bitEffAddr(cpu, novaOneAccEffAddr.ind, novaOneAccEffAddr.mode, novaOneAccEffAddr.disp15, iPtr.dispOffset)
 		effAddr = resolve8bitDisplacement(cpu, novaOneAccEffAddr.ind, novaOneAccEffAddr.mode, novaOneAccEffAddr.disp15) & 0x7fff
 		effAddr |= ring // constrain to current segment
-		memory.WriteWord(effAddr, shifter)
+		cpu.mem.WriteWord(effAddr, shifter)
 		logging.DebugPrint(logging.DebugLog, "STA storing AC %d to resolved address %#o\n", novaOneAccEffAddr.acd, effAddr)
 
 	default:
-		log.Printf("ERROR: NOVA_MEMREF instruction <%s> (%#x)not yet implemented at PC=%#o\n", iPtr.mnemonic, memory.ReadWord(cpu.pc), cpu.pc)
+		log.Printf("ERROR: NOVA_MEMREF instruction <%s> (%#x)not yet implemented at PC=%#o\n", iPtr.mnemonic, cpu.mem.ReadWord(cpu.pc), cpu.pc)
 		return false
 	}
 	cpu.pc++
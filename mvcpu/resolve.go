@@ -51,14 +51,15 @@ func resolve31bitDisplacement(cpu *CPUT, ind byte, mode int, disp int32, dispOff
 	// handle indirection
 	if ind == '@' { // down the rabbit hole...
 		eff |= ring
-		indAddr, ok := memory.ReadDwordTrap(eff)
+		indAddr, ok := cpu.mem.ReadDwordTrap(eff)
 		if !ok {
-			log.Panicln("Terminating")
+			protectionFault(eff)
 		}
 		for memory.TestDwbit(indAddr, 0) {
-			indAddr, ok = memory.ReadDwordTrap(dg.PhysAddrT(indAddr & physMask32))
+			nextAddr := dg.PhysAddrT(indAddr & physMask32)
+			indAddr, ok = cpu.mem.ReadDwordTrap(nextAddr)
 			if !ok {
-				log.Panicln("Terminating")
+				protectionFault(nextAddr)
 			}
 		}
 		eff = dg.PhysAddrT(indAddr) | ring
@@ -75,6 +76,11 @@ func resolve31bitDisplacement(cpu *CPUT, ind byte, mode int, disp int32, dispOff
 	return eff & physMask32
 }
 
+// protectionFault abandons the current instruction, Vrun recovers it and stops with the fault recorded
+func protectionFault(addr dg.PhysAddrT) {
+	panic(memory.ProtectionFaultT{Addr: addr})
+}
+
 func resolve15bitDisplacement(cpu *CPUT, ind byte, mode int, disp dg.WordT, dispOffset int) (eff dg.PhysAddrT) {
 	var dispS32 int32
 	ring := cpu.pc & 0x7000_0000
@@ -97,20 +103,21 @@ func resolve15bitDisplacement(cpu *CPUT, ind byte, mode int, disp dg.WordT, disp
 	// handle indirection
 	if ind == '@' { // down the rabbit hole...
 		eff |= ring
-		indAddr, ok := memory.ReadDwordTrap(eff)
+		indAddr, ok := cpu.mem.ReadDwordTrap(eff)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... resolve15bitDisplacement got: @%#o %s, reading %#o, got %#o\n", disp, modeToString(mode), eff, indAddr)
 		}
 		if !ok {
-			log.Panicln("Terminating")
+			protectionFault(eff)
 		}
 		for memory.TestDwbit(indAddr, 0) {
-			indAddr, ok = memory.ReadDwordTrap(dg.PhysAddrT(indAddr & physMask32))
+			nextAddr := dg.PhysAddrT(indAddr & physMask32)
+			indAddr, ok = cpu.mem.ReadDwordTrap(nextAddr)
 			if cpu.debugLogging {
 				logging.DebugPrint(logging.DebugLog, "... resolve15bitDisplacement ... reading %#o\n", indAddr)
 			}
 			if !ok {
-				log.Panicln("Terminating")
+				protectionFault(nextAddr)
 			}
 		}
 		eff = dg.PhysAddrT(indAddr) | ring
@@ -148,20 +155,21 @@ func resolveLong15bitDisplacement(cpu *CPUT, ind byte, mode int, disp dg.WordT,
 	// handle indirection
 	if ind == '@' { // down the rabbit hole...
 		eff |= ring
-		indAddr, ok := memory.ReadDwordTrap(eff)
+		indAddr, ok := cpu.mem.ReadDwordTrap(eff)
 		if cpu.debugLogging {
 			logging.DebugPrint(logging.DebugLog, "... resolve15bitDisplacement got: @%#o %s, reading %#o, got %#o\n", disp, modeToString(mode), eff, indAddr)
 		}
 		if !ok {
-			log.Panicln("Terminating")
+			protectionFault(eff)
 		}
 		for memory.TestDwbit(indAddr, 0) {
-			indAddr, ok = memory.ReadDwordTrap(dg.PhysAddrT(indAddr & physMask32))
+			nextAddr := dg.PhysAddrT(indAddr & physMask32)
+			indAddr, ok = cpu.mem.ReadDwordTrap(nextAddr)
 			if cpu.debugLogging {
 				logging.DebugPrint(logging.DebugLog, "... resolve15bitDisplacement ... reading %#o\n", indAddr)
 			}
 			if !ok {
-				log.Panicln("Terminating")
+				protectionFault(nextAddr)
 			}
 		}
 		eff = dg.PhysAddrT(indAddr) | ring
@@ -201,17 +209,18 @@ func resolve8bitDisplacement(cpu *CPUT, ind byte, mode int, disp int16) (eff dg.
 	if ind == '@' { // down the rabbit hole...
 		eff |= ring
 
-		indAddr, ok := memory.ReadWordTrap(eff)
+		indAddr, ok := cpu.mem.ReadWordTrap(eff)
 		logging.DebugPrint(logging.DebugLog, "... examining location %#o (%#x) - contains: %#o (%#x)\n", eff, eff, indAddr, indAddr)
 		if !ok {
-			log.Panicln("Terminating")
+			protectionFault(eff)
 		}
 		for memory.TestWbit(indAddr, 0) {
 			logging.DebugPrint(logging.DebugLog, "... examining location %#o (%#x) ", indAddr, indAddr)
-			indAddr, ok = memory.ReadWordTrap(dg.PhysAddrT(indAddr&physMask16) | ring)
+			nextAddr := dg.PhysAddrT(indAddr&physMask16) | ring
+			indAddr, ok = cpu.mem.ReadWordTrap(nextAddr)
 			logging.DebugPrint(logging.DebugLog, "- contains: %#o (%#x)\n", indAddr, indAddr)
 			if !ok {
-				log.Panicln("Terminating")
+				protectionFault(nextAddr)
 			}
 		}
 		eff = dg.PhysAddrT(indAddr) | ring
@@ -261,14 +270,15 @@ func resolve32bitEffAddr(cpu *CPUT, ind byte, mode int, disp int32, dispOffset i
 	}
 	// handle indirection
 	if ind == '@' { //|| memory.TestDwbit(dg.DwordT(eff), 0) { // down the rabbit hole...
-		indAddr, ok := memory.ReadDwordTrap(eff)
+		indAddr, ok := cpu.mem.ReadDwordTrap(eff)
 		if !ok {
-			log.Panicln("Terminating")
+			protectionFault(eff)
 		}
 		for memory.TestDwbit(indAddr, 0) {
-			indAddr, ok = memory.ReadDwordTrap(dg.PhysAddrT(indAddr & physMask32))
+			nextAddr := dg.PhysAddrT(indAddr & physMask32)
+			indAddr, ok = cpu.mem.ReadDwordTrap(nextAddr)
 			if !ok {
-				log.Panicln("Terminating")
+				protectionFault(nextAddr)
 			}
 		}
 		eff = dg.PhysAddrT(indAddr)
@@ -289,7 +299,7 @@ func resolve32bitIndirectableAddr(cpu *CPUT, iAddr dg.DwordT) dg.PhysAddrT {
 	eff := iAddr
 	// handle indirection
 	for memory.TestDwbit(eff, 0) {
-		eff = memory.ReadDWord(dg.PhysAddrT(eff & physMask32))
+		eff = cpu.mem.ReadDWord(dg.PhysAddrT(eff & physMask32))
 	}
 	// check ATU
 	if cpu.atu == false {